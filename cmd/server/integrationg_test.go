@@ -48,6 +48,7 @@ func TestFullIntegration(t *testing.T) {
         ChainID:           31337, // Hardhat chain ID
         RateLimit:         10000, // High rate limit for testing
         SignatureNonce:    "test-nonce",
+        AdminToken:        "test-admin-token", // Operator credentials for record-minted
     }
     
     // Create real instances
@@ -63,8 +64,12 @@ func TestFullIntegration(t *testing.T) {
     // Create vote service for server
     voteService := core.NewVoteService(cfg, kvStore, signer)
     
+    // Create license service with the same signer
+    bc := &mockBlockchainClient{}
+    licenseService := blockchain.NewLicenseService(cfg, kvStore, signer, bc)
+    
     // Create server with all components
-    server := api.NewServer(cfg, kvStore, &mockBlockchainClient{}, voteService)
+    server := api.NewServer(cfg, kvStore, bc, voteService, licenseService)
     
     // Start test server
     testServer := httptest.NewServer(server.Handler())
@@ -121,7 +126,11 @@ func TestFullIntegration(t *testing.T) {
         }
         
         body, _ = json.Marshal(recordReq)
-        resp, err = client.Post(testServer.URL+"/api/v1/license/record-minted", "application/json", bytes.NewReader(body))
+        recordHTTPReq, err := http.NewRequest(http.MethodPost, testServer.URL+"/api/v1/license/record-minted", bytes.NewReader(body))
+        require.NoError(t, err)
+        recordHTTPReq.Header.Set("Content-Type", "application/json")
+        recordHTTPReq.Header.Set("X-Admin-Token", cfg.AdminToken)
+        resp, err = client.Do(recordHTTPReq)
         require.NoError(t, err)
         assert.Equal(t, http.StatusOK, resp.StatusCode)
         
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"math/big"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"moltket/internal/blockchain"
 	"moltket/internal/cache"
 	"moltket/internal/core"
+	"moltket/internal/kvstore"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -30,37 +33,158 @@ func main() {
 	kvStore := cache.NewKVStore()
 	defer kvStore.Close()
 
-	// Initialize blockchain client if enabled
-	var bcClient *blockchain.Client
+	ctx := context.Background()
+
+	// Initialize blockchain connection. If the node is unreachable the service
+	// starts in degraded mode and keeps re-dialing in the background; without
+	// ENABLE_BLOCKCHAIN the connection stays disabled.
+	chain := blockchain.NewConnection(nil, 0)
 	if cfg.EnableBlockchain {
-		var err error
-		bcClient, err = blockchain.NewClient(cfg.EthNodeURL)
+		// An incomplete configuration is an operator error: refuse to start
+		bcConfig := config.LoadBlockchainConfig()
+		if err := bcConfig.Validate(); err != nil {
+			log.Fatalf("Blockchain enabled but configuration is incomplete: %v", err)
+		}
+
+		chain = blockchain.NewConnection(func() (*blockchain.Client, error) {
+			return blockchain.NewClientFromConfig(bcConfig)
+		}, cfg.ReconnectInterval)
+
+		// Index LicenseMinted once the node is first reachable. The buyer's
+		// cached license is left alone: it was written by record-minted with
+		// the limits of its payment, and if it is missing the next check
+		// rebuilds it from the chain and the payment ledger
+		var indexOnce sync.Once
+		chain.OnOnline(func() {
+			indexOnce.Do(func() {
+				client, err := chain.Client()
+				if err != nil {
+					return
+				}
+				_, err = client.ListenForLicenseMinted(ctx, func(toolID *big.Int, owner common.Address) {
+					log.Printf("License minted for tool %s by %s", toolID, owner.Hex())
+				})
+				if err != nil {
+					log.Printf("Warning: LicenseMinted indexing disabled: %v", err)
+				}
+			})
+		})
+
+		err := chain.Connect(ctx)
+		if errors.Is(err, blockchain.ErrConfigMismatch) {
+			log.Fatalf("Blockchain configuration rejected by node: %v", err)
+		}
 		if err != nil {
 			log.Printf("Warning: Failed to connect to blockchain: %v", err)
-			log.Println("Continuing in offline mode...")
-		} else {
-			defer bcClient.Close()
+			log.Printf("Continuing in degraded mode, retrying every %s...", cfg.ReconnectInterval)
 		}
+		chain.Start(ctx)
+		defer chain.Close()
 	}
 
 	// Initialize EIP-712 signer for licenses
 	chainID := big.NewInt(cfg.ChainID)
 	verifyingContract := common.HexToAddress(cfg.LicenseNFTAddress)
 
-	signer, err := auth.NewSigner(cfg.SignerPrivateKey, chainID, verifyingContract)
+	// The key comes from a remote signer, an encrypted keystore or, for
+	// development, SIGNER_PRIVATE_KEY; license signatures go through it.
+	// Nothing is signed for ReputationOracle yet, since vote batches are not
+	// submitted on-chain, so the signer has no oracle domain.
+	signerBackend, err := auth.NewSignerBackend(auth.SignerConfig{
+		PrivateKey:     cfg.SignerPrivateKey,
+		KeystoreFile:   cfg.SignerKeystore,
+		PassphraseFile: cfg.SignerPassphraseFile,
+		RemoteURL:      cfg.RemoteSignerURL,
+		RemoteAddress:  cfg.RemoteSignerAddress,
+		RemoteMethod:   cfg.RemoteSignerMethod,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create signer: %v", err)
 	}
+	signer := auth.NewEIP712Signer(signerBackend, chainID, verifyingContract)
+	log.Printf("Signing as %s", signer.Address().Hex())
+
+	// Initialize license service; with a blockchain, only issue signatures the
+	// deployed contracts will accept
+	licenseService := blockchain.NewLicenseService(cfg, kvStore, signer, chain)
+	var signerMonitor *blockchain.SignerMonitor
+	if cfg.EnableBlockchain {
+		signerMonitor = blockchain.NewSignerMonitor(chain, signer, cfg.SignerCheckInterval)
+		signerMonitor.Keys().SetOverlap(cfg.SignerKeyOverlap)
+		// Standby keys come from the same kinds of backends as the active one
+		for _, spec := range cfg.SignerStandbyKeys {
+			backend, err := auth.NewSignerBackend(auth.ParseSignerSpec(spec, cfg.RemoteSignerMethod))
+			if err != nil {
+				log.Fatalf("Failed to load standby signer: %v", err)
+			}
+			if _, err := signerMonitor.AddSigner(signer.WithBackend(backend)); err != nil {
+				log.Fatalf("Failed to load standby signer: %v", err)
+			}
+		}
+		if err := signerMonitor.Check(ctx); err != nil {
+			log.Printf("Warning: license issuance disabled until signer check passes: %v", err)
+		}
+		signerMonitor.Start(ctx)
+		defer signerMonitor.Stop()
+		licenseService.UseSignerMonitor(signerMonitor)
+
+		// Re-verify the signer as soon as the node is back
+		chain.OnOnline(func() { signerMonitor.Check(ctx) })
+	}
+
+	// Signatures from contract wallets (Safe and the like) are checked with
+	// EIP-1271 against the node; without a blockchain only EOAs can sign
+	signatures := auth.NewSignatureVerifier(nil, kvStore)
+	if cfg.EnableBlockchain {
+		signatures = auth.NewSignatureVerifier(chain, kvStore)
+	}
 
 	// Initialize vote service for batch processor
 	voteService := core.NewVoteService(cfg, kvStore, signer)
+	voteService.UseSignatureVerifier(signatures)
+	scorer, err := core.NewScorer(cfg)
+	if err != nil {
+		log.Fatalf("Invalid reputation scorer configuration: %v", err)
+	}
+	voteService.UseScorer(scorer)
+	voteService.UseLicensePayments(licenseService)
+	if cfg.EnableBlockchain {
+		voteService.UseSkillBalances(chain)
+	}
+	if cfg.ReputationLedgerFile != "" {
+		journal, err := kvstore.OpenJournal(cfg.ReputationLedgerFile)
+		if err != nil {
+			log.Fatalf("Failed to open reputation ledger: %v", err)
+		}
+		defer journal.Close()
+		if err := voteService.UseLedgerJournal(ctx, journal); err != nil {
+			log.Fatalf("Failed to restore reputation ledger: %v", err)
+		}
+	} else {
+		log.Printf("Warning: REPUTATION_LEDGER_FILE is not set, reputation is lost on restart")
+	}
 	batchProcessor := core.NewBatchProcessor(voteService, kvStore, 5*time.Minute)
 
 	// Create and start server with all components
-	server := api.NewServer(cfg, kvStore, bcClient, voteService)
+	server := api.NewServer(cfg, kvStore, chain, voteService, licenseService)
+	server.UseSignatureVerifier(signatures)
+	if cfg.RoleBindingsFile != "" {
+		journal, err := kvstore.OpenJournal(cfg.RoleBindingsFile)
+		if err != nil {
+			log.Fatalf("Failed to open role bindings: %v", err)
+		}
+		defer journal.Close()
+		if err := server.UseRoleJournal(ctx, journal); err != nil {
+			log.Fatalf("Failed to restore role bindings: %v", err)
+		}
+	} else {
+		log.Printf("Warning: ROLE_BINDINGS_FILE is not set, role bindings are lost on restart")
+	}
+	if signerMonitor != nil {
+		server.UseSignerMonitor(signerMonitor)
+	}
 
 	// Start batch processor (runs automatically every 5 minutes)
-	ctx := context.Background()
 	batchProcessor.Start(ctx)
 	defer batchProcessor.Stop()
 	// Start server in a goroutine
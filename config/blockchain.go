@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -11,8 +12,10 @@ type BlockchainConfig struct {
 	WSEndpoint  string `mapstructure:"WS_ENDPOINT"`
 
 	// Contract addresses (set after deployment)
-	LicenseNFTAddress common.Address `mapstructure:"LICENSE_NFT_ADDRESS"`
-	StakingNFTAddress common.Address `mapstructure:"STAKING_NFT_ADDRESS"`
+	LicenseNFTAddress       common.Address `mapstructure:"LICENSE_NFT_ADDRESS"`
+	StakingNFTAddress       common.Address `mapstructure:"STAKING_NFT_ADDRESS"`
+	ReputationOracleAddress common.Address `mapstructure:"REPUTATION_ORACLE_ADDRESS"`
+	SkillTokenAddress       common.Address `mapstructure:"SKILL_TOKEN_ADDRESS"`
 
 	// Wallet for transactions (optional for hackathon)
 	AdminPrivateKey string         `mapstructure:"ADMIN_PRIVATE_KEY"`
@@ -25,6 +28,10 @@ type BlockchainConfig struct {
 	// Network
 	ChainID int64  `mapstructure:"CHAIN_ID"`
 	Network string `mapstructure:"NETWORK"` // sepolia, localhost, etc.
+
+	// Blocks behind the head before an event is considered final. Event
+	// checkpoints trail the head by this much, so a reconnect rescans them
+	ConfirmationDepth uint64 `mapstructure:"CONFIRMATION_DEPTH"`
 }
 
 func DefaultBlockchainConfig() *BlockchainConfig {
@@ -35,21 +42,85 @@ func DefaultBlockchainConfig() *BlockchainConfig {
 		GasPrice:    20,       // gwei
 		ChainID:     11155111, // Sepolia
 		Network:     "sepolia",
+
+		ConfirmationDepth: 12,
 	}
 }
 
+// LoadBlockchainConfig reads the blockchain settings from the environment,
+// starting from DefaultBlockchainConfig. RPC_ENDPOINT falls back to
+// ETH_NODE_URL so existing deployments keep working.
+func LoadBlockchainConfig() *BlockchainConfig {
+	cfg := DefaultBlockchainConfig()
+
+	cfg.RPCEndpoint = getEnv("RPC_ENDPOINT", getEnv("ETH_NODE_URL", cfg.RPCEndpoint))
+	cfg.WSEndpoint = getEnv("WS_ENDPOINT", cfg.WSEndpoint)
+
+	cfg.LicenseNFTAddress = getEnvAsAddress("LICENSE_NFT_ADDRESS")
+	cfg.StakingNFTAddress = getEnvAsAddress("STAKING_NFT_ADDRESS")
+	cfg.ReputationOracleAddress = getEnvAsAddress("REPUTATION_ORACLE_ADDRESS")
+	cfg.SkillTokenAddress = getEnvAsAddress("SKILL_TOKEN_ADDRESS")
+
+	cfg.AdminPrivateKey = getEnv("ADMIN_PRIVATE_KEY", "")
+	cfg.AdminAddress = getEnvAsAddress("ADMIN_ADDRESS")
+
+	cfg.GasLimit = uint64(getEnvAsInt("GAS_LIMIT", int(cfg.GasLimit)))
+	cfg.GasPrice = int64(getEnvAsInt("GAS_PRICE", int(cfg.GasPrice)))
+
+	cfg.ChainID = int64(getEnvAsInt("CHAIN_ID", int(cfg.ChainID)))
+	cfg.Network = getEnv("NETWORK", cfg.Network)
+
+	cfg.ConfirmationDepth = uint64(getEnvAsInt("CONFIRMATION_DEPTH", int(cfg.ConfirmationDepth)))
+
+	return cfg
+}
+
+// Validate reports every missing or invalid setting at once so a
+// misconfigured deployment can be fixed in a single pass.
 func (c *BlockchainConfig) Validate() error {
+	var problems []string
+
 	if c.RPCEndpoint == "" {
-		return fmt.Errorf("RPC endpoint is required")
+		problems = append(problems, "RPC endpoint is required")
+	}
+
+	if c.ChainID <= 0 {
+		problems = append(problems, "chain ID must be positive")
 	}
 
 	if c.LicenseNFTAddress == (common.Address{}) {
-		return fmt.Errorf("license NFT address is required")
+		problems = append(problems, "license NFT address is required")
 	}
 
 	if c.StakingNFTAddress == (common.Address{}) {
-		return fmt.Errorf("staking NFT address is required")
+		problems = append(problems, "staking NFT address is required")
+	}
+
+	if c.ReputationOracleAddress == (common.Address{}) {
+		problems = append(problems, "reputation oracle address is required")
+	}
+
+	if c.SkillTokenAddress == (common.Address{}) {
+		problems = append(problems, "skill token address is required")
+	}
+
+	if c.GasLimit == 0 {
+		problems = append(problems, "gas limit must be positive")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid blockchain config: %s", strings.Join(problems, "; "))
 	}
 
 	return nil
 }
+
+// getEnvAsAddress returns the zero address when the variable is unset or not
+// a valid hex address, which Validate then reports as missing.
+func getEnvAsAddress(key string) common.Address {
+	value := getEnv(key, "")
+	if !common.IsHexAddress(value) {
+		return common.Address{}
+	}
+	return common.HexToAddress(value)
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBlockchainConfig(t *testing.T) {
+	t.Setenv("RPC_ENDPOINT", "")
+	t.Setenv("ETH_NODE_URL", "http://node:8545")
+	t.Setenv("LICENSE_NFT_ADDRESS", "0x1111111111111111111111111111111111111111")
+	t.Setenv("STAKING_NFT_ADDRESS", "0x2222222222222222222222222222222222222222")
+	t.Setenv("REPUTATION_ORACLE_ADDRESS", "0x3333333333333333333333333333333333333333")
+	t.Setenv("SKILL_TOKEN_ADDRESS", "not-an-address")
+	t.Setenv("CHAIN_ID", "31337")
+
+	cfg := LoadBlockchainConfig()
+
+	assert.Equal(t, "http://node:8545", cfg.RPCEndpoint, "should fall back to ETH_NODE_URL")
+	assert.Equal(t, int64(31337), cfg.ChainID)
+	assert.Equal(t, common.HexToAddress("0x3333333333333333333333333333333333333333"), cfg.ReputationOracleAddress)
+	assert.Equal(t, common.Address{}, cfg.SkillTokenAddress, "invalid address should be treated as unset")
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "skill token address is required")
+	assert.NotContains(t, err.Error(), "license NFT address")
+}
+
+func TestBlockchainConfig_Validate(t *testing.T) {
+	t.Run("ReportsAllProblems", func(t *testing.T) {
+		cfg := &BlockchainConfig{}
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		for _, msg := range []string{
+			"RPC endpoint is required",
+			"chain ID must be positive",
+			"license NFT address is required",
+			"staking NFT address is required",
+			"reputation oracle address is required",
+			"skill token address is required",
+			"gas limit must be positive",
+		} {
+			assert.Contains(t, err.Error(), msg)
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		cfg := DefaultBlockchainConfig()
+		cfg.LicenseNFTAddress = common.HexToAddress("0x1")
+		cfg.StakingNFTAddress = common.HexToAddress("0x2")
+		cfg.ReputationOracleAddress = common.HexToAddress("0x3")
+		cfg.SkillTokenAddress = common.HexToAddress("0x4")
+
+		assert.NoError(t, cfg.Validate())
+	})
+}
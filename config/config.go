@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,10 +18,92 @@ type Config struct {
 	DemoMode        bool          // Use demo mode for testing
 	// Blockchain / signing config
 	LicenseNFTAddress string
-	SignatureNonce    string
-	ChainID           int64
-	SignerPrivateKey  string
-	EnableBlockchain  bool
+	// Verifying contract of the EIP-712 domain votes are signed under
+	ReputationOracleAddress string
+	SignatureNonce          string
+	ChainID                 int64
+	SignerPrivateKey        string
+	// Alternatives to SignerPrivateKey that keep the key out of the
+	// environment: an encrypted keystore file unlocked with the first line
+	// of a passphrase file, or a remote JSON-RPC signer holding the key of
+	// RemoteSignerAddress
+	SignerKeystore       string
+	SignerPassphraseFile string
+	RemoteSignerURL      string
+	RemoteSignerAddress  string
+	RemoteSignerMethod   string
+	EnableBlockchain     bool
+	// How often the signer is re-checked against the deployed contracts
+	SignerCheckInterval time.Duration
+	// Additional signer keys to switch to after an on-chain updateSigner,
+	// each "keystore:<keystore file>:<passphrase file>",
+	// "remote:<address>@<url>" (using RemoteSignerMethod) or, for
+	// development, a hex private key
+	SignerStandbyKeys []string
+	// How long a rotated-out signer key still verifies before it retires
+	SignerKeyOverlap time.Duration
+	// Shared secret for /api/v1/admin; admin endpoints are off when empty
+	AdminToken string
+	// Addresses granted the admin role at startup, on top of the bindings
+	// kept in RoleBindingsFile
+	AdminAddresses []string
+	// Journal files that keep role bindings and the reputation ledger across
+	// restarts. Without them both live only in memory and are lost on
+	// restart.
+	RoleBindingsFile     string
+	ReputationLedgerFile string
+	// Degraded mode: how often to re-dial an unreachable node, how long past
+	// its refresh time a cached license may still be served, and whether new
+	// licenses are issued meanwhile
+	ReconnectInterval        time.Duration
+	MaxLicenseStaleness      time.Duration
+	DegradedAllowNewLicenses bool
+	// Readiness fails when the event pipeline is more blocks behind the head
+	MaxChainLag uint64
+	// Sign-In With Ethereum: the domain and URI login messages must carry,
+	// and the lifetime of the JWT access and refresh tokens (signed with
+	// JWTSecret) issued on login. Sign-in is off unless JWT_SECRET is set
+	// to at least 32 bytes.
+	SIWEDomain      string
+	SIWEURI         string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// Tool host API keys: whether /verify, /access/verify and /vote require
+	// an X-API-Key, and the default per-key limit in requests per minute.
+	// Off by default so existing hosts keep working; to enable it, set
+	// ADMIN_TOKEN or JWT_SECRET, issue keys to every host through
+	// /api/v1/admin/keys or /api/v1/keys, then set REQUIRE_API_KEYS=true.
+	RequireAPIKeys  bool
+	APIKeyRateLimit int
+	// How far a tool host's HMAC-signed request timestamp may be from the
+	// server clock
+	HostSignatureSkew time.Duration
+	// Reputation scoring: the algorithm ("mean", "bayesian" or "wilson"),
+	// the Bayesian prior as a mean score and a weight in votes, the Wilson
+	// confidence z-value, and the half-life of votes in RecentScore
+	ReputationScorer      string
+	ReputationPriorMean   float64
+	ReputationPriorWeight float64
+	ReputationWilsonZ     float64
+	ReputationHalfLife    time.Duration
+	// Vote weights: whether votes are weighted by the voter's standing, the
+	// weight a valid license adds, how long a license must be held for each
+	// extra point, whether SKILL holdings count, and the highest weight
+	VoteWeighting          bool
+	VoteWeightLicense      int
+	VoteWeightTenurePeriod time.Duration
+	VoteWeightSkill        bool
+	VoteWeightMax          int
+	// Vote eligibility: verified calls to a tool required to vote on it,
+	// how long ago the first must be, how often a voter may vote on a tool,
+	// and whether votes must carry a provenance receipt. Zero disables a rule,
+	// and all are off by default: the usage ledger is in memory, so after a
+	// restart nobody meets the call or usage age rules until they build up
+	// usage again
+	VoteMinCalls       int
+	VoteMinUsageAge    time.Duration
+	VotePeriod         time.Duration
+	VoteRequireReceipt bool
 	//WSEndpoint        string
 	Env string
 }
@@ -30,18 +113,56 @@ func Load() *Config {
 	demoMode := getEnvAsBool("DEMO_MODE", false)
 
 	cfg := &Config{
-		ServerPort:        getEnv("PORT", "8080"),
-		EthNodeURL:        getEnv("ETH_NODE_URL", "wss://sepolia.infura.io/ws/v3/YOUR_KEY"),
-		JWTSecret:         getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		CacheTTL:          getEnvAsInt("CACHE_TTL", 300),
-		RateLimit:         getEnvAsInt("RATE_LIMIT", 100),
-		CleanupInterval:   cleanupInterval,
-		DemoMode:          demoMode,
-		LicenseNFTAddress: getEnv("LICENSE_NFT_ADDRESS", "0x..."),
-		SignatureNonce:    getEnv("SIGNATURE_NONCE", "default-nonce"),
-		ChainID:           int64(getEnvAsInt("CHAIN_ID", 11155111)),
-		SignerPrivateKey:  getEnv("SIGNER_PRIVATE_KEY", ""),
-		EnableBlockchain:  getEnvAsBool("ENABLE_BLOCKCHAIN", false),
+		ServerPort:               getEnv("PORT", "8080"),
+		EthNodeURL:               getEnv("ETH_NODE_URL", "wss://sepolia.infura.io/ws/v3/YOUR_KEY"),
+		JWTSecret:                getEnv("JWT_SECRET", ""),
+		CacheTTL:                 getEnvAsInt("CACHE_TTL", 300),
+		RateLimit:                getEnvAsInt("RATE_LIMIT", 100),
+		CleanupInterval:          cleanupInterval,
+		DemoMode:                 demoMode,
+		LicenseNFTAddress:        getEnv("LICENSE_NFT_ADDRESS", "0x..."),
+		ReputationOracleAddress:  getEnv("REPUTATION_ORACLE_ADDRESS", ""),
+		SignatureNonce:           getEnv("SIGNATURE_NONCE", "default-nonce"),
+		ChainID:                  int64(getEnvAsInt("CHAIN_ID", 11155111)),
+		SignerPrivateKey:         getEnv("SIGNER_PRIVATE_KEY", ""),
+		SignerKeystore:           getEnv("SIGNER_KEYSTORE", ""),
+		SignerPassphraseFile:     getEnv("SIGNER_PASSPHRASE_FILE", ""),
+		RemoteSignerURL:          getEnv("REMOTE_SIGNER_URL", ""),
+		RemoteSignerAddress:      getEnv("REMOTE_SIGNER_ADDRESS", ""),
+		RemoteSignerMethod:       getEnv("REMOTE_SIGNER_METHOD", ""),
+		EnableBlockchain:         getEnvAsBool("ENABLE_BLOCKCHAIN", false),
+		SignerCheckInterval:      getEnvAsDuration("SIGNER_CHECK_INTERVAL", 5*time.Minute),
+		SignerStandbyKeys:        getEnvAsSlice("SIGNER_STANDBY_KEYS"),
+		SignerKeyOverlap:         getEnvAsDuration("SIGNER_KEY_OVERLAP", 24*time.Hour),
+		AdminToken:               getEnv("ADMIN_TOKEN", ""),
+		AdminAddresses:           getEnvAsSlice("ADMIN_ADDRESSES"),
+		RoleBindingsFile:         getEnv("ROLE_BINDINGS_FILE", ""),
+		ReputationLedgerFile:     getEnv("REPUTATION_LEDGER_FILE", ""),
+		ReconnectInterval:        getEnvAsDuration("RECONNECT_INTERVAL", 30*time.Second),
+		MaxLicenseStaleness:      getEnvAsDuration("MAX_LICENSE_STALENESS", 6*time.Hour),
+		DegradedAllowNewLicenses: getEnvAsBool("DEGRADED_ALLOW_NEW_LICENSES", false),
+		MaxChainLag:              uint64(getEnvAsInt("MAX_CHAIN_LAG", 50)),
+		SIWEDomain:               getEnv("SIWE_DOMAIN", "localhost:8080"),
+		SIWEURI:                  getEnv("SIWE_URI", "http://localhost:8080"),
+		AccessTokenTTL:           getEnvAsDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:          getEnvAsDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		RequireAPIKeys:           getEnvAsBool("REQUIRE_API_KEYS", false),
+		APIKeyRateLimit:          getEnvAsInt("API_KEY_RATE_LIMIT", 600),
+		HostSignatureSkew:        getEnvAsDuration("HOST_SIGNATURE_SKEW", 5*time.Minute),
+		ReputationScorer:         getEnv("REPUTATION_SCORER", "bayesian"),
+		ReputationPriorMean:      getEnvAsFloat("REPUTATION_PRIOR_MEAN", 0),
+		ReputationPriorWeight:    getEnvAsFloat("REPUTATION_PRIOR_WEIGHT", 5),
+		ReputationWilsonZ:        getEnvAsFloat("REPUTATION_WILSON_Z", 1.96),
+		ReputationHalfLife:       getEnvAsDuration("REPUTATION_HALF_LIFE", 7*24*time.Hour),
+		VoteWeighting:            getEnvAsBool("VOTE_WEIGHTING", true),
+		VoteWeightLicense:        getEnvAsInt("VOTE_WEIGHT_LICENSE", 2),
+		VoteWeightTenurePeriod:   getEnvAsDuration("VOTE_WEIGHT_TENURE_PERIOD", 90*24*time.Hour),
+		VoteWeightSkill:          getEnvAsBool("VOTE_WEIGHT_SKILL", false),
+		VoteWeightMax:            getEnvAsInt("VOTE_WEIGHT_MAX", 10),
+		VoteMinCalls:             getEnvAsInt("VOTE_MIN_CALLS", 0),
+		VoteMinUsageAge:          getEnvAsDuration("VOTE_MIN_USAGE_AGE", 0),
+		VotePeriod:               getEnvAsDuration("VOTE_PERIOD", 0),
+		VoteRequireReceipt:       getEnvAsBool("VOTE_REQUIRE_RECEIPT", false),
 		//WSEndpoint:        getEnv("WS_ENDPOINT", ""),
 		Env: getEnv("ENV", "development"),
 	}
@@ -65,6 +186,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -82,3 +212,14 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvAsSlice splits a comma-separated variable, dropping empty items.
+func getEnvAsSlice(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -22,6 +22,10 @@ contract ReputationOracle is Ownable, EIP712{
     bytes32 private constant _UPDATE_ROOT_TYPEHASH = 
         keccak256("UpdateReputationRoot(uint256 toolId,uint256 timestamp,bytes32 rootHash,uint256 nonce)");
     
+    // EIP-712 typehash for agent votes, signed by the voter's wallet
+    bytes32 private constant _VOTE_TYPEHASH =
+        keccak256("Vote(address voter,uint256 toolId,int8 score,uint64 nonce)");
+    
     event ReputationUpdated(uint256 toolId, uint256 timestamp, bytes32 rootHash);
 
     constructor(address _trustedSigner) 
@@ -71,4 +75,22 @@ contract ReputationOracle is Ownable, EIP712{
     function getReputationRoot(uint256 _toolId, uint256 _timestamp) external view returns (bytes32) {
         return reputationCheckpoints[_toolId][_timestamp];
     }
+
+    // Digest a voter signs for a vote; the backend computes the same (auth.HashVote)
+    function hashVote(address _voter, uint256 _toolId, int8 _score, uint64 _nonce) public view returns (bytes32) {
+        return _hashTypedDataV4(
+            keccak256(abi.encode(_VOTE_TYPEHASH, _voter, _toolId, _score, _nonce))
+        );
+    }
+
+    // Address that signed a vote, so batches can be re-verified on-chain
+    function recoverVoteSigner(
+        address _voter,
+        uint256 _toolId,
+        int8 _score,
+        uint64 _nonce,
+        bytes memory _signature
+    ) external view returns (address) {
+        return hashVote(_voter, _toolId, _score, _nonce).recover(_signature);
+    }
 }
\ No newline at end of file
@@ -309,6 +309,59 @@ const { chainId } = await ethers.provider.getNetwork();
         .withArgs(1, slashAmount);
     })
   });
+
+  /******************************************************************
+   * REPUTATIONORACLE - VOTE TYPED DATA (must match auth.HashVote)
+   ******************************************************************/
+  describe("ReputationOracle - Vote Typed Data", function () {
+    const voteTypes = {
+      Vote: [
+        { name: "voter", type: "address" },
+        { name: "toolId", type: "uint256" },
+        { name: "score", type: "int8" },
+        { name: "nonce", type: "uint64" },
+      ],
+    };
+    let oracle;
+
+    beforeEach(async function () {
+      const ReputationOracle = await ethers.getContractFactory("ReputationOracle");
+      oracle = await ReputationOracle.deploy(backendSigner.address);
+      await oracle.waitForDeployment();
+    });
+
+    async function voteDomain(verifyingContract: string) {
+      const { chainId } = await ethers.provider.getNetwork();
+      return { name: "SkillChainLicense", version: "1", chainId, verifyingContract };
+    }
+
+    it("Should match the backend's pinned vote digest", async function () {
+      // Same vector as TestHashVote_MatchesSolidity in internal/auth
+      const domain = await voteDomain("0x5FbDB2315678afecb367f032d93F642f64180aa3");
+      const vote = { voter: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", toolId: 42, score: -1, nonce: 7 };
+      expect(ethers.TypedDataEncoder.hash(domain, voteTypes, vote))
+        .to.equal("0x86e82d49cb5c94f1a560251dbe36dc1bbfcf5b67730b951edd2b27e718979981");
+    });
+
+    it("Should hash votes like eth_signTypedData_v4", async function () {
+      const domain = await voteDomain(await oracle.getAddress());
+      for (const score of [-1, 0, 1]) {
+        const vote = { voter: user.address, toolId: 42, score, nonce: 7 };
+        expect(await oracle.hashVote(vote.voter, vote.toolId, vote.score, vote.nonce))
+          .to.equal(ethers.TypedDataEncoder.hash(domain, voteTypes, vote));
+      }
+    });
+
+    it("Should recover the wallet that signed a vote", async function () {
+      const domain = await voteDomain(await oracle.getAddress());
+      const vote = { voter: user.address, toolId: 42, score: -1, nonce: 7 };
+      const signature = await user.signTypedData(domain, voteTypes, vote);
+      expect(await oracle.recoverVoteSigner(vote.voter, vote.toolId, vote.score, vote.nonce, signature))
+        .to.equal(user.address);
+      expect(await oracle.recoverVoteSigner(vote.voter, vote.toolId, 1, vote.nonce, signature))
+        .to.not.equal(user.address);
+    });
+  });
 });
 
 async function increase(time: Number){
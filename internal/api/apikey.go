@@ -0,0 +1,309 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"moltket/internal/auth"
+	"moltket/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// Header tool hosts send their API key in
+	apiKeyHeader = "X-API-Key"
+
+	// Context key of the *models.APIKey set by requireAPIKey
+	apiKeyContextKey = "api_key"
+
+	// Set by apiKeyAllowsTool when a request is refused for its tool
+	apiKeyRejectedContextKey = "api_key_rejected"
+
+	// Longest usage history returned by GET /keys/:id/usage
+	maxAPIKeyUsageDays = 90
+)
+
+type apiKeyHandler struct {
+	apiKeys *auth.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeys *auth.APIKeyService) *apiKeyHandler {
+	return &apiKeyHandler{
+		apiKeys: apiKeys,
+	}
+}
+
+type createAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Owner     string   `json:"owner"` // Admin only
+	Scopes    []string `json:"scopes" validate:"required"`
+	ToolIDs   []string `json:"tool_ids"`
+	RateLimit int      `json:"rate_limit"`
+}
+
+// Create handles POST /api/v1/keys. Keys are owned by the signed-in
+// address, cannot carry the admin scope, and cannot exceed the default
+// rate limit.
+func (h *apiKeyHandler) Create(c echo.Context) error {
+	var req createAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	for _, scope := range req.Scopes {
+		if scope == models.ScopeAdmin {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Admin keys can only be created through the admin API",
+			})
+		}
+	}
+	if req.RateLimit > h.apiKeys.DefaultRateLimit() {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Rate limit exceeds the maximum of " + strconv.Itoa(h.apiKeys.DefaultRateLimit()) + " requests per minute",
+		})
+	}
+
+	return h.create(c, sessionClaims(c).Address().Hex(), req)
+}
+
+// AdminCreate handles POST /api/v1/admin/keys, which may grant any scope
+// and rate limit to any owner.
+func (h *apiKeyHandler) AdminCreate(c echo.Context) error {
+	var req createAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	return h.create(c, req.Owner, req)
+}
+
+func (h *apiKeyHandler) create(c echo.Context, owner string, req createAPIKeyRequest) error {
+	key, apiKey, err := h.apiKeys.Create(c.Request().Context(), auth.APIKeyRequest{
+		Name:      req.Name,
+		Owner:     owner,
+		Scopes:    req.Scopes,
+		ToolIDs:   req.ToolIDs,
+		RateLimit: req.RateLimit,
+	})
+	if errors.Is(err, auth.ErrInvalidAPIKeyRequest) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create API key",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"key":     key,
+		"api_key": apiKey, // Only ever shown here and on rotate
+	})
+}
+
+// List handles GET /api/v1/keys
+func (h *apiKeyHandler) List(c echo.Context) error {
+	keys, err := h.apiKeys.List(c.Request().Context(), sessionClaims(c).Address().Hex())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to list API keys",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+// Rotate handles POST /api/v1/keys/:id/rotate
+func (h *apiKeyHandler) Rotate(c echo.Context) error {
+	if _, ok := h.ownedKey(c); !ok {
+		return apiKeyNotFound(c)
+	}
+
+	key, apiKey, err := h.apiKeys.Rotate(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		return apiKeyNotFound(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to rotate API key",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// Revoke handles DELETE /api/v1/keys/:id
+func (h *apiKeyHandler) Revoke(c echo.Context) error {
+	if _, ok := h.ownedKey(c); !ok {
+		return apiKeyNotFound(c)
+	}
+	return h.revoke(c)
+}
+
+// AdminRevoke handles DELETE /api/v1/admin/keys/:id
+func (h *apiKeyHandler) AdminRevoke(c echo.Context) error {
+	return h.revoke(c)
+}
+
+func (h *apiKeyHandler) revoke(c echo.Context) error {
+	err := h.apiKeys.Revoke(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		return apiKeyNotFound(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to revoke API key",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// Usage handles GET /api/v1/keys/:id/usage?days=N (default 30)
+func (h *apiKeyHandler) Usage(c echo.Context) error {
+	key, ok := h.ownedKey(c)
+	if !ok {
+		return apiKeyNotFound(c)
+	}
+
+	days := 30
+	if value := c.QueryParam("days"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > maxAPIKeyUsageDays {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "days must be between 1 and " + strconv.Itoa(maxAPIKeyUsageDays),
+			})
+		}
+	}
+
+	usage, err := h.apiKeys.Usage(c.Request().Context(), key.ID, days)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get API key usage",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"key":   key,
+		"usage": usage,
+	})
+}
+
+// ownedKey returns the key named by :id if the signed-in address owns it.
+// Other owners' keys are reported as missing.
+func (h *apiKeyHandler) ownedKey(c echo.Context) (*models.APIKey, bool) {
+	key, err := h.apiKeys.Get(c.Request().Context(), c.Param("id"))
+	if err != nil || !strings.EqualFold(key.Owner, sessionClaims(c).Address().Hex()) {
+		return nil, false
+	}
+	return key, true
+}
+
+func apiKeyNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]string{
+		"error": "API key not found",
+	})
+}
+
+// requireAPIKey authenticates the X-API-Key header, checks it grants scope
+// and is within its rate limit, and records the request against the key.
+// Requests without a key are let through unless RequireAPIKeys is set, or
+// if a registered host signed them.
+func (s *Server) requireAPIKey(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(apiKeyHeader)
+			if header == "" {
+				if !s.config.RequireAPIKeys || requestHost(c) != nil {
+					return next(c)
+				}
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Missing API key",
+				})
+			}
+
+			ctx := c.Request().Context()
+			key, err := s.apiKeys.Authenticate(ctx, header)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Invalid API key",
+				})
+			}
+
+			if !key.HasScope(scope) {
+				s.apiKeys.RecordUsage(ctx, key, true)
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "API key lacks the " + scope + " scope",
+				})
+			}
+
+			allowed, err := s.apiKeys.Allow(ctx, key)
+			if err != nil || !allowed {
+				s.apiKeys.RecordUsage(ctx, key, true)
+				c.Response().Header().Set("Retry-After", "60")
+				return c.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "API key rate limit exceeded",
+				})
+			}
+
+			c.Set(apiKeyContextKey, key)
+			err = next(c)
+			_, rejected := c.Get(apiKeyRejectedContextKey).(bool)
+			s.apiKeys.RecordUsage(ctx, key, rejected)
+			return err
+		}
+	}
+}
+
+// requestAPIKey returns the key stored by requireAPIKey, or nil.
+func requestAPIKey(c echo.Context) *models.APIKey {
+	key, _ := c.Get(apiKeyContextKey).(*models.APIKey)
+	return key
+}
+
+// apiKeyAllowsTool reports whether the request's API key, if any, may be
+// used for toolID. If not, the 403 response has been written and the
+// request is counted as rejected.
+func apiKeyAllowsTool(c echo.Context, toolID string) bool {
+	key := requestAPIKey(c)
+	if key == nil || key.AllowsTool(toolID) {
+		return true
+	}
+
+	c.Set(apiKeyRejectedContextKey, true)
+	c.JSON(http.StatusForbidden, map[string]string{
+		"error": "API key is not valid for this tool",
+	})
+	return false
+}
+
+func (s *Server) setupAPIKeyRoutes() {
+	apiKeyHandler := NewAPIKeyHandler(s.apiKeys)
+
+	keys := s.echo.Group("/api/v1/keys", s.signInEnabled, s.authenticateSession)
+	keys.POST("", apiKeyHandler.Create)
+	keys.GET("", apiKeyHandler.List)
+	keys.POST("/:id/rotate", apiKeyHandler.Rotate)
+	keys.DELETE("/:id", apiKeyHandler.Revoke)
+	keys.GET("/:id/usage", apiKeyHandler.Usage)
+
+	admin := s.echo.Group("/api/v1/admin/keys", s.authenticateAdmin)
+	admin.POST("", apiKeyHandler.AdminCreate)
+	admin.DELETE("/:id", apiKeyHandler.AdminRevoke)
+}
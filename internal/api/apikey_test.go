@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/blockchain"
+	"moltket/internal/cache"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:       "test-secret-0123456789abcdef0123456789",
+		SIWEDomain:      "app.skillchain.xyz",
+		SIWEURI:         "https://app.skillchain.xyz",
+		ChainID:         31337,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+		RateLimit:       1000,
+		AdminToken:      "admin-secret",
+		RequireAPIKeys:  true,
+		APIKeyRateLimit: 100,
+	}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	licenseService := &mockLicenseService{
+		verifyResp: &blockchain.AccessResult{Valid: true, Tier: "paid"},
+	}
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, nil, licenseService)
+
+	do := func(method, path string, body interface{}, headers map[string]string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+	verifyAccess := func(apiKey, toolID string) int {
+		rec, _ := do(http.MethodPost, "/api/v1/access/verify", map[string]string{
+			"user_address": "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+			"tool_id":      toolID,
+		}, map[string]string{"X-API-Key": apiKey})
+		return rec.Code
+	}
+	admin := map[string]string{"X-Admin-Token": "admin-secret"}
+
+	t.Run("VerifyRequiresKey", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, verifyAccess("", "1"))
+		assert.Equal(t, http.StatusUnauthorized, verifyAccess("mk_unknown_secret", "1"))
+	})
+
+	t.Run("ScopesToolsAndRateLimit", func(t *testing.T) {
+		rec, resp := do(http.MethodPost, "/api/v1/admin/keys", map[string]interface{}{
+			"name":       "host",
+			"owner":      "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+			"scopes":     []string{"verify"},
+			"tool_ids":   []string{"1"},
+			"rate_limit": 2,
+		}, admin)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		apiKey := resp["api_key"].(string)
+		keyID := resp["key"].(map[string]interface{})["id"].(string)
+		assert.NotContains(t, rec.Body.String(), "secret_hash")
+
+		assert.Equal(t, http.StatusOK, verifyAccess(apiKey, "1"))
+		assert.Equal(t, http.StatusForbidden, verifyAccess(apiKey, "2"))
+		assert.Equal(t, http.StatusTooManyRequests, verifyAccess(apiKey, "1"))
+
+		// A verify key cannot vote
+		rec, _ = do(http.MethodPost, "/api/v1/vote/submit", map[string]interface{}{
+			"tool_id": "1",
+		}, map[string]string{"X-API-Key": apiKey})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		// Nor reach the admin API
+		rec, _ = do(http.MethodGet, "/api/v1/admin/payments/underpaid", nil, map[string]string{"X-API-Key": apiKey})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		usage, err := s.apiKeys.Usage(t.Context(), keyID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), usage[0].Requests)
+		assert.Equal(t, int64(4), usage[0].Rejected)
+	})
+
+	t.Run("AdminKeyReachesAdminAPI", func(t *testing.T) {
+		rec, resp := do(http.MethodPost, "/api/v1/admin/keys", map[string]interface{}{
+			"scopes": []string{"admin"},
+		}, admin)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		rec, _ = do(http.MethodGet, "/api/v1/admin/payments/underpaid", nil, map[string]string{"X-API-Key": resp["api_key"].(string)})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("SelfServiceKeys", func(t *testing.T) {
+		session := map[string]string{"Authorization": "Bearer " + signIn(t, s, cfg)}
+
+		// Without a session the key API is closed
+		rec, _ := do(http.MethodGet, "/api/v1/keys", nil, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec, _ = do(http.MethodPost, "/api/v1/keys", map[string]interface{}{"scopes": []string{"admin"}}, session)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec, _ = do(http.MethodPost, "/api/v1/keys", map[string]interface{}{"scopes": []string{"verify"}, "rate_limit": 1000}, session)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, resp := do(http.MethodPost, "/api/v1/keys", map[string]interface{}{"name": "mine", "scopes": []string{"verify"}}, session)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		oldKey := resp["api_key"].(string)
+		keyID := resp["key"].(map[string]interface{})["id"].(string)
+		assert.Equal(t, http.StatusOK, verifyAccess(oldKey, "7"))
+
+		rec, resp = do(http.MethodGet, "/api/v1/keys", nil, session)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, resp["keys"], 1)
+
+		// Keys created by the admin for someone else are invisible
+		rec, other := do(http.MethodPost, "/api/v1/admin/keys", map[string]interface{}{"scopes": []string{"verify"}}, admin)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		otherID := other["key"].(map[string]interface{})["id"].(string)
+		rec, _ = do(http.MethodDelete, "/api/v1/keys/"+otherID, nil, session)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec, resp = do(http.MethodPost, "/api/v1/keys/"+keyID+"/rotate", nil, session)
+		require.Equal(t, http.StatusOK, rec.Code)
+		newKey := resp["api_key"].(string)
+		assert.Equal(t, http.StatusUnauthorized, verifyAccess(oldKey, "7"))
+		assert.Equal(t, http.StatusOK, verifyAccess(newKey, "7"))
+
+		rec, resp = do(http.MethodGet, "/api/v1/keys/"+keyID+"/usage?days=7", nil, session)
+		require.Equal(t, http.StatusOK, rec.Code)
+		usage := resp["usage"].([]interface{})
+		require.Len(t, usage, 7)
+		assert.Equal(t, float64(2), usage[6].(map[string]interface{})["requests"])
+
+		rec, _ = do(http.MethodDelete, "/api/v1/keys/"+keyID, nil, session)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, http.StatusUnauthorized, verifyAccess(newKey, "7"))
+	})
+}
+
+// signIn logs a fresh wallet in through SIWE and returns its access token.
+func signIn(t *testing.T, s *Server, cfg *config.Config) string {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	return signInWith(t, s, cfg, key)
+}
+
+// signInWith logs the wallet of key in through SIWE and returns its access
+// token.
+func signInWith(t *testing.T, s *Server, cfg *config.Config, key *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	nonce, err := s.sessions.IssueNonce(t.Context())
+	require.NoError(t, err)
+
+	message := (&auth.SIWEMessage{
+		Domain:   cfg.SIWEDomain,
+		Address:  crypto.PubkeyToAddress(key.PublicKey),
+		URI:      cfg.SIWEURI,
+		Version:  "1",
+		ChainID:  cfg.ChainID,
+		Nonce:    nonce,
+		IssuedAt: time.Now(),
+	}).String()
+	signature, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	require.NoError(t, err)
+	signature[64] += 27
+
+	tokens, err := s.sessions.Login(t.Context(), message, signature)
+	require.NoError(t, err)
+	return tokens.AccessToken
+}
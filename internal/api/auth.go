@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"moltket/internal/auth"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/labstack/echo/v4"
+)
+
+// Context key of the *auth.SessionClaims set by authenticateSession
+const sessionContextKey = "session"
+
+type authHandler struct {
+	sessions *auth.SessionManager
+}
+
+func NewAuthHandler(sessions *auth.SessionManager) *authHandler {
+	return &authHandler{
+		sessions: sessions,
+	}
+}
+
+// Nonce handles GET /api/v1/auth/nonce
+func (h *authHandler) Nonce(c echo.Context) error {
+	nonce, err := h.sessions.IssueNonce(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to issue nonce",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"nonce": nonce,
+	})
+}
+
+// Login handles POST /api/v1/auth/login with an EIP-4361 message and its
+// personal_sign signature
+func (h *authHandler) Login(c echo.Context) error {
+	var req struct {
+		Message   string `json:"message" validate:"required"`
+		Signature string `json:"signature" validate:"required"`
+	}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	signature, err := hexutil.Decode(req.Signature)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid signature format",
+		})
+	}
+
+	tokens, err := h.sessions.Login(c.Request().Context(), req.Message, signature)
+	if errors.Is(err, auth.ErrInvalidSIWEMessage) || errors.Is(err, auth.ErrInvalidNonce) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to sign in",
+		})
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh handles POST /api/v1/auth/refresh
+func (h *authHandler) Refresh(c echo.Context) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	tokens, err := h.sessions.Refresh(c.Request().Context(), req.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to refresh session",
+		})
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// Logout handles POST /api/v1/auth/logout
+func (h *authHandler) Logout(c echo.Context) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	h.sessions.Logout(c.Request().Context(), req.RefreshToken)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// Me handles GET /api/v1/auth/me
+func (h *authHandler) Me(c echo.Context) error {
+	claims := sessionClaims(c)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"address":    claims.Address().Hex(),
+		"chain_id":   claims.ChainID,
+		"expires_at": claims.ExpiresAt.Time,
+	})
+}
+
+// authenticateSession only lets through requests with a valid access token
+// in the Authorization header, and stores its claims in the context.
+func (s *Server) authenticateSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.sessions == nil {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Sign-in disabled",
+			})
+		}
+
+		header := c.Request().Header.Get(echo.HeaderAuthorization)
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Missing bearer token",
+			})
+		}
+
+		claims, err := s.sessions.ParseAccessToken(token)
+		if err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Invalid or expired access token",
+			})
+		}
+
+		c.Set(sessionContextKey, claims)
+		return next(c)
+	}
+}
+
+// sessionClaims returns the claims stored by authenticateSession, or nil.
+func sessionClaims(c echo.Context) *auth.SessionClaims {
+	claims, _ := c.Get(sessionContextKey).(*auth.SessionClaims)
+	return claims
+}
+
+// signInEnabled answers 403 on every auth route when no session manager
+// could be configured.
+func (s *Server) signInEnabled(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.sessions == nil {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Sign-in disabled",
+			})
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) setupAuthRoutes() {
+	authHandler := NewAuthHandler(s.sessions)
+
+	api := s.echo.Group("/api/v1/auth", s.signInEnabled)
+	api.GET("/nonce", authHandler.Nonce)
+	api.POST("/login", authHandler.Login)
+	api.POST("/refresh", authHandler.Refresh)
+	api.POST("/logout", authHandler.Logout)
+	api.GET("/me", authHandler.Me, s.authenticateSession)
+}
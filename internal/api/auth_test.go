@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHandler_SIWELogin(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:       "test-secret-0123456789abcdef0123456789",
+		SIWEDomain:      "app.skillchain.xyz",
+		SIWEURI:         "https://app.skillchain.xyz",
+		ChainID:         31337,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+		RateLimit:       1000,
+	}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, nil, nil)
+
+	do := func(method, path string, body interface{}, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var reader *bytes.Reader
+		if body != nil {
+			payload, err := json.Marshal(body)
+			require.NoError(t, err)
+			reader = bytes.NewReader(payload)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	// Unauthenticated calls are refused
+	rec, _ := do(http.MethodGet, "/api/v1/auth/me", nil, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec, _ = do(http.MethodGet, "/api/v1/auth/me", nil, "not-a-token")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Nonce, sign, login
+	rec, resp := do(http.MethodGet, "/api/v1/auth/nonce", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	nonce := resp["nonce"].(string)
+
+	message := (&auth.SIWEMessage{
+		Domain:    cfg.SIWEDomain,
+		Address:   address,
+		Statement: "Sign in to SkillChain.",
+		URI:       cfg.SIWEURI,
+		Version:   "1",
+		ChainID:   cfg.ChainID,
+		Nonce:     nonce,
+		IssuedAt:  time.Now(),
+	}).String()
+	signature, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	require.NoError(t, err)
+	signature[64] += 27
+
+	rec, resp = do(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"message":   message,
+		"signature": hexutil.Encode(signature),
+	}, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	accessToken := resp["access_token"].(string)
+	refreshToken := resp["refresh_token"].(string)
+
+	// Replaying the login fails: the nonce was consumed
+	rec, _ = do(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"message":   message,
+		"signature": hexutil.Encode(signature),
+	}, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Later calls authenticate with the access token
+	rec, resp = do(http.MethodGet, "/api/v1/auth/me", nil, accessToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, address.Hex(), resp["address"])
+
+	// Refresh rotates the refresh token
+	rec, resp = do(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": refreshToken}, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEqual(t, refreshToken, resp["refresh_token"])
+	rec, _ = do(http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": refreshToken}, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec, _ = do(http.MethodPost, "/api/v1/auth/logout", map[string]string{"refresh_token": resp["refresh_token"].(string)}, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuthHandler_SignInDisabled(t *testing.T) {
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	// Without a secret, or with the sample config's placeholder
+	for _, secret := range []string{"", "your-secret-key-change-in-production"} {
+		cfg := &config.Config{
+			RateLimit:       1000,
+			JWTSecret:       secret,
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 24 * time.Hour,
+		}
+		s := NewServer(cfg, kvStore, &mockBlockchainClient{}, nil, nil)
+
+		for _, path := range []string{"/api/v1/auth/nonce", "/api/v1/auth/me"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+			s.echo.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusForbidden, rec.Code, path)
+		}
+	}
+}
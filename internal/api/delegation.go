@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"moltket/internal/auth"
+	"moltket/internal/core"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/labstack/echo/v4"
+)
+
+// Header naming the delegation whose session key signed a /verify or
+// /access/verify request, instead of the user
+const (
+	delegationHeader = "X-Verify-Delegation"
+
+	// Context key of the user address whose signature requestSignedByUser
+	// checked
+	signedUserContextKey = "signed_user"
+)
+
+type registerDelegationRequest struct {
+	User       string   `json:"user"`
+	SessionKey string   `json:"session_key"`
+	ToolIDs    []string `json:"tool_ids"`
+	MaxCalls   uint64   `json:"max_calls"`
+	ExpiresAt  int64    `json:"expires_at"` // Unix seconds
+	Nonce      string   `json:"nonce"`
+	Signature  string   `json:"signature"` // User's EIP-712 Delegation signature
+}
+
+// registerDelegation handles POST /api/v1/delegations. The user's signature
+// is the authentication, so agents can register delegations they were
+// handed.
+func (s *Server) registerDelegation(c echo.Context) error {
+	var req registerDelegationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	signature, err := hexutil.Decode(req.Signature)
+	if err != nil || !common.IsHexAddress(req.User) || !common.IsHexAddress(req.SessionKey) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid user, session key or signature",
+		})
+	}
+
+	delegation, err := s.service.RegisterDelegation(c.Request().Context(), &auth.Delegation{
+		User:       common.HexToAddress(req.User),
+		SessionKey: common.HexToAddress(req.SessionKey),
+		ToolIDs:    req.ToolIDs,
+		MaxCalls:   req.MaxCalls,
+		ExpiresAt:  req.ExpiresAt,
+		Nonce:      req.Nonce,
+	}, signature)
+	if errors.Is(err, core.ErrInvalidDelegation) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to register delegation",
+		})
+	}
+	return c.JSON(http.StatusCreated, delegation)
+}
+
+// listDelegations handles GET /api/v1/delegations
+func (s *Server) listDelegations(c echo.Context) error {
+	delegations := s.service.Delegations(c.Request().Context(), sessionClaims(c).Address())
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"delegations": delegations,
+		"count":       len(delegations),
+	})
+}
+
+// revokeDelegation handles DELETE /api/v1/delegations/:id
+func (s *Server) revokeDelegation(c echo.Context) error {
+	err := s.service.RevokeDelegation(c.Request().Context(), sessionClaims(c).Address(), c.Param("id"))
+	if errors.Is(err, core.ErrDelegationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Delegation not found",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to revoke delegation",
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+type revokeDelegationRequest struct {
+	Nonce     string `json:"nonce"`     // From /verify/nonce
+	Signature string `json:"signature"` // User's EIP-712 RevokeDelegation signature
+}
+
+// revokeSignedDelegation handles POST /api/v1/delegations/:id/revoke. Like
+// registration, the user's signature is the authentication, so a leaked
+// session key can be revoked without signing in.
+func (s *Server) revokeSignedDelegation(c echo.Context) error {
+	var req revokeDelegationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	signature, err := hexutil.Decode(req.Signature)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid signature",
+		})
+	}
+
+	err = s.service.RevokeDelegationSigned(c.Request().Context(), &auth.RevokeDelegation{
+		ID:    c.Param("id"),
+		Nonce: req.Nonce,
+	}, signature)
+	if errors.Is(err, core.ErrDelegationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Delegation not found",
+		})
+	}
+	if errors.Is(err, core.ErrInvalidVerifyNonce) || errors.Is(err, core.ErrInvalidRequestSignature) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to revoke delegation",
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// requestSignedByUser reports whether a verification request for user and
+// toolID carries a VerifyRequest signature over body, by the user or by the
+// session key of the delegation named in X-Verify-Delegation, and stores
+// the user for recordCall. If not, the 401 response has been written.
+func (s *Server) requestSignedByUser(c echo.Context, userAddress, toolID string, body []byte) bool {
+	header := c.Request().Header
+	signature, err := hexutil.Decode(header.Get(verifySignatureHeader))
+	if err != nil || !common.IsHexAddress(userAddress) {
+		c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Missing or malformed request signature",
+		})
+		return false
+	}
+
+	ctx := c.Request().Context()
+	signed := auth.NewVerifyRequest(common.HexToAddress(userAddress), toolID, body, header.Get(verifyNonceHeader))
+	if id := header.Get(delegationHeader); id != "" {
+		_, err = s.service.AuthenticateDelegatedRequest(ctx, id, signed, signature)
+	} else {
+		err = s.service.AuthenticateRequest(ctx, signed, signature)
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+		return false
+	}
+	c.Set(signedUserContextKey, common.HexToAddress(userAddress))
+	return true
+}
+
+// authenticateDelegation checks the session key signature of requests
+// carrying X-Verify-Delegation, for endpoints that do not otherwise require
+// a user signature. Other requests are let through unchanged.
+func (s *Server) authenticateDelegation(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Header.Get(delegationHeader) == "" {
+			return next(c)
+		}
+
+		// The signature covers the exact body, so keep it for the handler
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid request format",
+			})
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		var req struct {
+			UserAddress string `json:"user_address"`
+			ToolID      string `json:"tool_id"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid request format",
+			})
+		}
+
+		if !s.requestSignedByUser(c, req.UserAddress, req.ToolID, body) {
+			return nil
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) setupDelegationRoutes() {
+	api := s.echo.Group("/api/v1/delegations")
+	api.POST("", s.registerDelegation)
+	api.GET("", s.listDelegations, s.signInEnabled, s.authenticateSession)
+	api.DELETE("/:id", s.revokeDelegation, s.signInEnabled, s.authenticateSession)
+	api.POST("/:id/revoke", s.revokeSignedDelegation)
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/blockchain"
+	"moltket/internal/cache"
+	"moltket/internal/testutils"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelegatedSessionKeys(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:         "test-secret-0123456789abcdef0123456789",
+		SIWEDomain:        "app.skillchain.xyz",
+		SIWEURI:           "https://app.skillchain.xyz",
+		ChainID:           31337,
+		LicenseNFTAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		AccessTokenTTL:    15 * time.Minute,
+		RefreshTokenTTL:   24 * time.Hour,
+		RateLimit:         1000,
+	}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	licenseService := &mockLicenseService{
+		verifyResp: &blockchain.AccessResult{Valid: true, Tier: "paid"},
+	}
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, nil, licenseService)
+
+	userKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	user := crypto.PubkeyToAddress(userKey.PublicKey)
+	agentKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	agent := crypto.PubkeyToAddress(agentKey.PublicKey)
+
+	do := func(method, path string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The user signs a delegation for the agent's session key, tool 42 only
+	delegation := &auth.Delegation{
+		User:       user,
+		SessionKey: agent,
+		ToolIDs:    []string{"42"},
+		MaxCalls:   10,
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+		Nonce:      "agent-1",
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"user":        user.Hex(),
+		"session_key": agent.Hex(),
+		"tool_ids":    delegation.ToolIDs,
+		"max_calls":   delegation.MaxCalls,
+		"expires_at":  delegation.ExpiresAt,
+		"nonce":       delegation.Nonce,
+		"signature":   testutils.SignDelegation(t, userKey, s.service.RequestDomain(), delegation),
+	})
+	require.NoError(t, err)
+	rec := do(http.MethodPost, "/api/v1/delegations", payload, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var registered struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &registered))
+
+	// signed returns the headers of a request signed by the agent
+	signed := func(toolID string, body []byte) map[string]string {
+		challenge, err := s.service.IssueVerifyNonce(t.Context())
+		require.NoError(t, err)
+		req := auth.NewVerifyRequest(user, toolID, body, challenge.Nonce)
+		return map[string]string{
+			delegationHeader:      registered.ID,
+			verifyNonceHeader:     challenge.Nonce,
+			verifySignatureHeader: testutils.SignVerifyRequest(t, agentKey, s.service.RequestDomain(), req),
+		}
+	}
+	verifyBody := []byte(`{"license_nft_id":"1","tool_id":"42","user_address":"` + user.Hex() + `"}`)
+	accessBody := []byte(`{"tool_id":"42","user_address":"` + user.Hex() + `"}`)
+
+	t.Run("Verify", func(t *testing.T) {
+		// Authenticated as the user: the mock chain says they hold no license
+		rec := do(http.MethodPost, "/api/v1/verify", verifyBody, signed("42", verifyBody))
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), `"valid":false`)
+
+		other := bytes.Replace(verifyBody, []byte(`"42"`), []byte(`"7"`), 1)
+		rec = do(http.MethodPost, "/api/v1/verify", other, signed("7", other))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "tool 7 is not delegated")
+	})
+
+	t.Run("Access", func(t *testing.T) {
+		rec := do(http.MethodPost, "/api/v1/access/verify", accessBody, signed("42", accessBody))
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		headers := signed("42", accessBody)
+		headers[verifySignatureHeader] = testutils.SignVerifyRequest(t, userKey, s.service.RequestDomain(),
+			auth.NewVerifyRequest(user, "42", accessBody, headers[verifyNonceHeader]))
+		rec = do(http.MethodPost, "/api/v1/access/verify", accessBody, headers)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "only the session key signs delegated requests")
+	})
+
+	t.Run("ListAndRevoke", func(t *testing.T) {
+		session := map[string]string{"Authorization": "Bearer " + signInWith(t, s, cfg, userKey)}
+		agentSession := map[string]string{"Authorization": "Bearer " + signInWith(t, s, cfg, agentKey)}
+
+		rec := do(http.MethodGet, "/api/v1/delegations", nil, session)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var list struct {
+			Delegations []struct {
+				ID        string `json:"id"`
+				CallsUsed int64  `json:"calls_used"`
+			} `json:"delegations"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		require.Len(t, list.Delegations, 1)
+		assert.Equal(t, int64(2), list.Delegations[0].CallsUsed)
+
+		rec = do(http.MethodDelete, "/api/v1/delegations/"+registered.ID, nil, agentSession)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		rec = do(http.MethodDelete, "/api/v1/delegations/"+registered.ID, nil, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = do(http.MethodDelete, "/api/v1/delegations/"+registered.ID, nil, session)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		rec = do(http.MethodPost, "/api/v1/access/verify", accessBody, signed("42", accessBody))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "revoked")
+	})
+
+	t.Run("RevokeSignedWithoutSession", func(t *testing.T) {
+		second := *delegation
+		second.Nonce = "agent-2"
+		payload, err := json.Marshal(map[string]interface{}{
+			"user":        user.Hex(),
+			"session_key": agent.Hex(),
+			"tool_ids":    second.ToolIDs,
+			"max_calls":   second.MaxCalls,
+			"expires_at":  second.ExpiresAt,
+			"nonce":       second.Nonce,
+			"signature":   testutils.SignDelegation(t, userKey, s.service.RequestDomain(), &second),
+		})
+		require.NoError(t, err)
+		rec := do(http.MethodPost, "/api/v1/delegations", payload, nil)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created struct {
+			ID string `json:"id"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+		revoke := func(signer *ecdsa.PrivateKey) *httptest.ResponseRecorder {
+			challenge, err := s.service.IssueVerifyNonce(t.Context())
+			require.NoError(t, err)
+			r := &auth.RevokeDelegation{ID: created.ID, Nonce: challenge.Nonce}
+			body, err := json.Marshal(map[string]string{
+				"nonce":     r.Nonce,
+				"signature": testutils.SignRevokeDelegation(t, signer, s.service.RequestDomain(), r),
+			})
+			require.NoError(t, err)
+			return do(http.MethodPost, "/api/v1/delegations/"+created.ID+"/revoke", body, nil)
+		}
+
+		rec = revoke(agentKey)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "the session key cannot revoke its own delegation")
+		rec = revoke(userKey)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		headers := signed("42", accessBody)
+		headers[delegationHeader] = created.ID
+		rec = do(http.MethodPost, "/api/v1/access/verify", accessBody, headers)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "revoked")
+	})
+}
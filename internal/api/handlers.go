@@ -1,29 +1,50 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"log"
 	"moltket/config"
+	"moltket/internal/auth"
 	"moltket/internal/blockchain"
 	"moltket/internal/cache"
 	"moltket/internal/core"
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
 	"net/http"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"golang.org/x/time/rate"
 )
 
+// Headers carrying the user's authentication of a /verify request: a nonce
+// from /verify/nonce and the EIP-712 VerifyRequest signature over it
+const (
+	verifyNonceHeader     = "X-Verify-Nonce"
+	verifySignatureHeader = "X-Verify-Signature"
+)
+
 type Server struct {
-	echo        *echo.Echo
-	config      *config.Config
-	service     *core.VerificationService
-	cache       *cache.Client
-	blockchain  blockchain.BlockchainInterface
-	voteService *core.VoteService
+	echo           *echo.Echo
+	config         *config.Config
+	service        *core.VerificationService
+	cache          *cache.Client
+	blockchain     blockchain.BlockchainInterface
+	voteService    *core.VoteService
+	licenseService blockchain.LicenseServiceInterface
+	signerMonitor  *blockchain.SignerMonitor
+	sessions       *auth.SessionManager
+	apiKeys        *auth.APIKeyService
+	roles          *auth.RoleService
+	hosts          *auth.HostService
+	usage          *core.UsageLedger
 }
 
-func NewServer(cfg *config.Config, cacheClient *cache.Client, ethClient blockchain.BlockchainInterface, voteService *core.VoteService) *Server {
+func NewServer(cfg *config.Config, cacheClient *cache.Client, ethClient blockchain.BlockchainInterface, voteService *core.VoteService, licenseService blockchain.LicenseServiceInterface) *Server {
 	e := echo.New()
 
 	// Middleware for security and observability
@@ -37,17 +58,47 @@ func NewServer(cfg *config.Config, cacheClient *cache.Client, ethClient blockcha
 	// We need to pass the store directly, so we'll need to update the approach
 	service := core.NewVerificationService(cfg, cacheClient.GetStore(), ethClient)
 
+	// Sign-In With Ethereum sessions; without a JWT secret sign-in is off
+	sessions, err := auth.NewSessionManager(auth.SessionConfig{
+		Secret:          []byte(cfg.JWTSecret),
+		Domain:          cfg.SIWEDomain,
+		URI:             cfg.SIWEURI,
+		ChainID:         cfg.ChainID,
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+	}, cacheClient.GetStore())
+	if err != nil {
+		log.Printf("Warning: sign-in disabled: %v", err)
+	}
+	if cfg.RequireAPIKeys && cfg.AdminToken == "" && sessions == nil {
+		log.Printf("Warning: REQUIRE_API_KEYS is set, but without ADMIN_TOKEN or sign-in no API key can be issued")
+	}
+
 	server := &Server{
-		echo:        e,
-		config:      cfg,
-		service:     service,
-		cache:       cacheClient,
-		blockchain:  ethClient,
-		voteService: voteService,
+		echo:           e,
+		config:         cfg,
+		service:        service,
+		cache:          cacheClient,
+		blockchain:     ethClient,
+		voteService:    voteService,
+		licenseService: licenseService,
+		sessions:       sessions,
+		apiKeys:        auth.NewAPIKeyService(cacheClient.GetStore(), cfg.APIKeyRateLimit),
+		roles:          auth.NewRoleService(cacheClient.GetStore()),
+		hosts:          auth.NewHostService(cacheClient.GetStore(), cfg.HostSignatureSkew),
+		usage:          core.NewUsageLedger(cacheClient.GetStore()),
 	}
 
+	server.grantConfiguredAdmins(context.Background())
+
 	server.setupRoutes()
+	server.setupAuthRoutes()
+	server.setupAPIKeyRoutes()
+	server.setupRoleRoutes()
+	server.setupHostRoutes()
+	server.setupDelegationRoutes()
 	server.setupLicenseRoutes()
+	server.setupSignerRoutes()
 	server.setupVoteRoutes(voteService)
 	return server
 }
@@ -58,19 +109,127 @@ func (s *Server) setupRoutes() {
 	// Public health check
 	api.GET("/health", s.healthCheck)
 
-	// License verification endpoint (used by tool hosts)
-	api.POST("/verify", s.verifyLicense)
+	// Chain sync status and readiness probe
+	api.GET("/chain/status", s.chainStatus)
+	api.GET("/ready", s.readiness)
+
+	// License verification endpoint (used by tool hosts). Each request is
+	// signed either by the user, or a session key they delegated to, over a
+	// nonce from /verify/nonce, or by a registered host with its HMAC secret
+	api.GET("/verify/nonce", s.verifyNonce)
+	api.POST("/verify", s.verifyLicense, s.authenticateHost, s.requireAPIKey(models.ScopeVerify))
+}
+
+// UseSignerMonitor lets the chain status compare the contracts' EIP-712
+// domains with the active signer.
+func (s *Server) UseSignerMonitor(monitor *blockchain.SignerMonitor) {
+	s.signerMonitor = monitor
+}
+
+// UseRoleJournal keeps role bindings in journal, so they survive restarts;
+// see auth.RoleService.UseJournal. Configured admins are granted again on
+// top of the restored bindings.
+func (s *Server) UseRoleJournal(ctx context.Context, journal *kvstore.Journal) error {
+	if err := s.roles.UseJournal(ctx, journal); err != nil {
+		return err
+	}
+	s.grantConfiguredAdmins(ctx)
+	return nil
+}
 
-	// Admin endpoints (optional for demo)
-	//admin := api.Group("/admin", s.authenticateAdmin)
-	//admin.POST("/cache/clear", s.clearCache)
+// grantConfiguredAdmins binds the admin role to each of ADMIN_ADDRESSES.
+func (s *Server) grantConfiguredAdmins(ctx context.Context) {
+	for _, address := range s.config.AdminAddresses {
+		if _, err := s.roles.Grant(ctx, address, models.RoleAdmin, configGrantor); err != nil {
+			log.Printf("Warning: ignoring admin address %q: %v", address, err)
+		}
+	}
 }
 
+// UseSignatureVerifier lets contract wallets sign /verify requests; see
+// core.VerificationService.UseSignatureVerifier.
+func (s *Server) UseSignatureVerifier(verifier *auth.SignatureVerifier) {
+	s.service.UseSignatureVerifier(verifier)
+}
+
+// authenticateAdmin only lets through admins: X-Admin-Token, an API key
+// with the admin scope, or a key or signed-in address bound to the admin
+// role. See requireRole.
+func (s *Server) authenticateAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return s.requireRole(models.RoleAdmin)(next)
+}
+
+// healthCheck stays 200 in degraded mode, since cached licenses are still
+// served; the blockchain field tells monitoring what is going on.
 func (s *Server) healthCheck(c echo.Context) error {
+	response := map[string]interface{}{
+		"status":   200,
+		"service":  "skillchain-verification",
+		"time":     time.Now().UTC(),
+		"degraded": false,
+	}
+
+	if reporter, ok := s.blockchain.(blockchain.ChainStatusReporter); ok {
+		status := reporter.ChainStatus()
+		response["blockchain"] = status
+		response["degraded"] = status.Mode == blockchain.ChainDegraded
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// syncStatus returns the chain sync state, or nil if the backend cannot
+// report it.
+func (s *Server) syncStatus(c echo.Context) *blockchain.SyncStatus {
+	reporter, ok := s.blockchain.(blockchain.SyncStatusReporter)
+	if !ok {
+		return nil
+	}
+
+	var signer *auth.EIP712Signer
+	if s.signerMonitor != nil {
+		signer = s.signerMonitor.Signer()
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+	return reporter.SyncStatus(ctx, signer)
+}
+
+// chainStatus reports chain ID, head, indexing lag, subscriptions, RPC
+// latency, the configured contracts and the last signer check, including
+// a ReputationOracle domain or backendSigner mismatch.
+func (s *Server) chainStatus(c echo.Context) error {
+	status := s.syncStatus(c)
+	if status == nil {
+		status = &blockchain.SyncStatus{
+			Connection:    blockchain.ChainStatus{Mode: blockchain.ChainDisabled},
+			Subscriptions: []blockchain.SubscriptionState{},
+			Contracts:     []blockchain.ContractStatus{},
+		}
+	}
+	if s.signerMonitor != nil {
+		status.Signer = s.signerMonitor.Status()
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// readiness fails once the event pipeline falls more than MaxChainLag blocks
+// behind the confirmed head. A degraded chain does not fail it, since cached licenses
+// are still served.
+func (s *Server) readiness(c echo.Context) error {
+	status := s.syncStatus(c)
+	if status != nil && status.Lag != nil && *status.Lag > s.config.MaxChainLag {
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"ready":   false,
+			"reason":  "event indexing is behind the chain head",
+			"lag":     *status.Lag,
+			"max_lag": s.config.MaxChainLag,
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"status":  200,
-		"service": "skillchain-verification",
-		"time":    time.Now().UTC(),
+		"ready": true,
 	})
 }
 
@@ -80,10 +239,17 @@ func (s *Server) verifyLicense(c echo.Context) error {
 		LicenseNFTID string `json:"license_nft_id" validate:"required,alphanum"`
 		ToolID       string `json:"tool_id" validate:"required,alphanum"`
 		UserAddress  string `json:"user_address" validate:"required,eth_addr"`
-		Timestamp    int64  `json:"timestamp" validate:"required"`
-		Signature    string `json:"signature" validate:"required"` // EIP-712 signature
 	}
 
+	// The signature covers the exact body, so keep it for hashing
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
 	// Bind and validate input
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
@@ -91,11 +257,22 @@ func (s *Server) verifyLicense(c echo.Context) error {
 		})
 	}
 
-	// Validate signature (prevent replay attacks)
-	if !s.service.ValidateSignature(req.UserAddress, req.Timestamp, req.Signature) {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid or expired signature",
-		})
+	if !apiKeyAllowsTool(c, req.ToolID) {
+		return nil
+	}
+
+	// Requests are signed by a registered host (authenticateHost checked
+	// the signature, timestamp and replay), or with an EIP-712 signature of
+	// the user, or a session key they delegated to, over a single-use nonce
+	// (prevents replay attacks)
+	if host := requestHost(c); host != nil {
+		if !host.AllowsTool(req.ToolID) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Host is not registered for this tool",
+			})
+		}
+	} else if !s.requestSignedByUser(c, req.UserAddress, req.ToolID, body) {
+		return nil
 	}
 
 	// Check rate limit using Redis
@@ -127,6 +304,8 @@ func (s *Server) verifyLicense(c echo.Context) error {
 		})
 	}
 
+	recordCall(c, s.usage, common.HexToAddress(req.UserAddress), req.ToolID, result.ProvenanceHash)
+
 	// Success response
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"valid":           true,
@@ -138,9 +317,43 @@ func (s *Server) verifyLicense(c echo.Context) error {
 		"tier":            result.Tier,
 		"verified_at":     time.Now().UTC(),
 		"provenance_hash": result.ProvenanceHash, // Your tamper-proof hash
+		"stale":           result.Stale,
 	})
 }
 
+// recordCall adds a granted call to the usage ledger votes are judged on.
+// Only calls authenticated as made for user count: signed by the user or a
+// session key they delegated to, or by a registered host for toolID.
+// Anyone can ask whether an address has access, so unsigned calls would let
+// them make it eligible to vote. A failure to record does not fail the call.
+func recordCall(c echo.Context, usage *core.UsageLedger, user common.Address, toolID, receipt string) {
+	if usage == nil || !callAuthenticated(c, user, toolID) {
+		return
+	}
+	if err := usage.RecordCall(c.Request().Context(), user, toolID, receipt); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func callAuthenticated(c echo.Context, user common.Address, toolID string) bool {
+	if host := requestHost(c); host != nil {
+		return host.AllowsTool(toolID)
+	}
+	signed, ok := c.Get(signedUserContextKey).(common.Address)
+	return ok && signed == user
+}
+
+// verifyNonce handles GET /api/v1/verify/nonce
+func (s *Server) verifyNonce(c echo.Context) error {
+	challenge, err := s.service.IssueVerifyNonce(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to issue nonce",
+		})
+	}
+	return c.JSON(http.StatusOK, challenge)
+}
+
 func (s *Server) Start(addr string) error {
 	return s.echo.Start(addr)
 }
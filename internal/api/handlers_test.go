@@ -1,14 +1,21 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHealthCheckHandler(t *testing.T) {
@@ -56,3 +63,50 @@ func TestVerifyLicenseHandler(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 	})
 }
+
+func TestVerifyRequestAuthentication(t *testing.T) {
+	cfg := &config.Config{RateLimit: 1000, ChainID: 31337, LicenseNFTAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3"}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, nil, &mockLicenseService{})
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	body := []byte(`{"license_nft_id":"1","tool_id":"42","user_address":"` + user.Hex() + `"}`)
+
+	verify := func(body []byte, nonce, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/verify", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(verifyNonceHeader, nonce)
+		req.Header.Set(verifySignatureHeader, signature)
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/verify/nonce", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var challenge struct {
+		Nonce  string                   `json:"nonce"`
+		Domain apitypes.TypedDataDomain `json:"domain"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &challenge))
+
+	// Sign the typed data exactly as served to the client
+	signature, err := auth.Types.Sign(key, challenge.Domain, "VerifyRequest", auth.NewVerifyRequest(user, "42", body, challenge.Nonce).Message())
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, verify(body, challenge.Nonce, "").Code)
+	tampered := bytes.Replace(body, []byte(`"1"`), []byte(`"2"`), 1)
+	assert.Equal(t, http.StatusUnauthorized, verify(tampered, challenge.Nonce, hexutil.Encode(signature)).Code)
+
+	// Authenticated: the mock chain says the user holds no license
+	rec = verify(body, challenge.Nonce, hexutil.Encode(signature))
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+	rec = verify(body, challenge.Nonce, hexutil.Encode(signature))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "nonce")
+}
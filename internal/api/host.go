@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"moltket/internal/auth"
+	"moltket/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers of a request signed by a registered tool host: the host ID, the
+// Unix time of the request, and the hex HMAC-SHA256 of
+// auth.HostSigningString under the host's secret
+const (
+	hostIDHeader        = "X-Host-ID"
+	hostTimestampHeader = "X-Host-Timestamp"
+	hostSignatureHeader = "X-Host-Signature"
+
+	// Context key of the *models.Host set by authenticateHost
+	hostContextKey = "host"
+)
+
+type hostHandler struct {
+	hosts *auth.HostService
+}
+
+func NewHostHandler(hosts *auth.HostService) *hostHandler {
+	return &hostHandler{
+		hosts: hosts,
+	}
+}
+
+type registerHostRequest struct {
+	Name    string   `json:"name"`
+	Owner   string   `json:"owner"`
+	ToolIDs []string `json:"tool_ids"`
+}
+
+// Register handles POST /api/v1/admin/hosts
+func (h *hostHandler) Register(c echo.Context) error {
+	var req registerHostRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	host, secret, err := h.hosts.Register(c.Request().Context(), req.Name, req.Owner, req.ToolIDs)
+	if errors.Is(err, auth.ErrInvalidHost) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to register host",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"host":   host,
+		"secret": secret, // Only ever shown here and on rotate
+	})
+}
+
+// List handles GET /api/v1/admin/hosts
+func (h *hostHandler) List(c echo.Context) error {
+	hosts := h.hosts.List(c.Request().Context())
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"hosts": hosts,
+		"count": len(hosts),
+	})
+}
+
+// Rotate handles POST /api/v1/admin/hosts/:id/rotate
+func (h *hostHandler) Rotate(c echo.Context) error {
+	host, secret, err := h.hosts.Rotate(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, auth.ErrHostNotFound) {
+		return hostNotFound(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to rotate host secret",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"host":   host,
+		"secret": secret,
+	})
+}
+
+// Revoke handles DELETE /api/v1/admin/hosts/:id
+func (h *hostHandler) Revoke(c echo.Context) error {
+	err := h.hosts.Revoke(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, auth.ErrHostNotFound) {
+		return hostNotFound(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to revoke host",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+func hostNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]string{
+		"error": "Host not found",
+	})
+}
+
+// authenticateHost checks the HMAC signature of requests carrying
+// X-Host-ID, rejecting stale and replayed ones. Requests without it are let
+// through to be authenticated otherwise.
+func (s *Server) authenticateHost(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header
+		hostID := header.Get(hostIDHeader)
+		if hostID == "" {
+			return next(c)
+		}
+
+		timestamp, err := strconv.ParseInt(header.Get(hostTimestampHeader), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Missing or malformed " + hostTimestampHeader,
+			})
+		}
+
+		// The signature covers the exact body, so keep it for the handler
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid request format",
+			})
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		host, err := s.hosts.Authenticate(c.Request().Context(), auth.HostRequest{
+			HostID:    hostID,
+			Method:    c.Request().Method,
+			Path:      c.Request().URL.RequestURI(),
+			Timestamp: timestamp,
+			Body:      body,
+			Signature: header.Get(hostSignatureHeader),
+		})
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		c.Set(hostContextKey, host)
+		return next(c)
+	}
+}
+
+// requestHost returns the host stored by authenticateHost, or nil.
+func requestHost(c echo.Context) *models.Host {
+	host, _ := c.Get(hostContextKey).(*models.Host)
+	return host
+}
+
+func (s *Server) setupHostRoutes() {
+	hostHandler := NewHostHandler(s.hosts)
+
+	admin := s.echo.Group("/api/v1/admin/hosts", s.authenticateAdmin)
+	admin.POST("", hostHandler.Register)
+	admin.GET("", hostHandler.List)
+	admin.POST("/:id/rotate", hostHandler.Rotate)
+	admin.DELETE("/:id", hostHandler.Revoke)
+}
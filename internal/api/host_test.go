@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostSignedVerify(t *testing.T) {
+	cfg := &config.Config{
+		RateLimit:         1000,
+		ChainID:           31337,
+		LicenseNFTAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		AdminToken:        "admin-secret",
+		RequireAPIKeys:    true,
+		HostSignatureSkew: time.Minute,
+	}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, nil, &mockLicenseService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/hosts", bytes.NewReader([]byte(`{"name":"host","tool_ids":["42"]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Token", "admin-secret")
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var registered struct {
+		Host struct {
+			ID string `json:"id"`
+		} `json:"host"`
+		Secret string `json:"secret"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &registered))
+	assert.NotContains(t, rec.Body.String(), `"Secret"`)
+
+	verify := func(body []byte, timestamp int64, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/verify", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(hostIDHeader, registered.Host.ID)
+		req.Header.Set(hostTimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(hostSignatureHeader, signature)
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	body := []byte(`{"license_nft_id":"1","tool_id":"42","user_address":"0x70997970C51812dc3A010C7d01b50e0d17dc79C8"}`)
+	now := time.Now().Unix()
+	signature := auth.SignHostRequest(registered.Secret, http.MethodPost, "/api/v1/verify", now, body)
+
+	// Host-signed requests need neither a user signature nor an API key.
+	// The mock chain says the user holds no license.
+	rec = verify(body, now, signature)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"valid":false`)
+
+	rec = verify(body, now, signature)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "already used")
+
+	tampered := bytes.Replace(body, []byte(`"1"`), []byte(`"2"`), 1)
+	assert.Equal(t, http.StatusUnauthorized, verify(tampered, now+1, auth.SignHostRequest(registered.Secret, http.MethodPost, "/api/v1/verify", now+1, body)).Code)
+
+	stale := now - 120
+	assert.Equal(t, http.StatusUnauthorized, verify(body, stale, auth.SignHostRequest(registered.Secret, http.MethodPost, "/api/v1/verify", stale, body)).Code)
+
+	// The host is registered for tool 42 only
+	other := bytes.Replace(body, []byte(`"42"`), []byte(`"43"`), 1)
+	rec = verify(other, now+2, auth.SignHostRequest(registered.Secret, http.MethodPost, "/api/v1/verify", now+2, other))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "not registered for this tool")
+
+	// Without host headers the API key requirement still applies
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Missing API key")
+}
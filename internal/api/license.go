@@ -1,20 +1,24 @@
 package api
 
 import (
-	"log"
+	"errors"
 	"math/big"
 	"net/http"
+	"strings"
 	"time"
 
-	"moltket/internal/auth"
 	"moltket/internal/blockchain"
+	"moltket/internal/core"
+	"moltket/internal/models"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/labstack/echo/v4"
 )
 
 type licenseHandler struct {
 	licenseService blockchain.LicenseServiceInterface
+	usage          *core.UsageLedger // Records granted calls, if set
 }
 
 func NewLicenseHandler(licenseService blockchain.LicenseServiceInterface) *licenseHandler {
@@ -61,6 +65,11 @@ func (h *licenseHandler) RequestLicense(c echo.Context) error {
 
 	// Request license from service
 	resp, err := h.licenseService.RequestLicense(c.Request().Context(), licenseReq)
+	if errors.Is(err, blockchain.ErrSigningDisabled) || errors.Is(err, blockchain.ErrChainUnavailable) {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
@@ -81,6 +90,7 @@ func (h *licenseHandler) RequestLicense(c echo.Context) error {
 			},
 			"price":            resp.Price,
 			"contract_address": resp.Contract,
+			"key_id":           resp.KeyID,
 			"instructions":     "Call mintLicense() on the contract with these parameters",
 		},
 	})
@@ -106,6 +116,15 @@ func (h *licenseHandler) VerifyAccess(c echo.Context) error {
 		})
 	}
 
+	if !apiKeyAllowsTool(c, req.ToolID) {
+		return nil
+	}
+	if host := requestHost(c); host != nil && !host.AllowsTool(req.ToolID) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "Host is not registered for this tool",
+		})
+	}
+
 	userAddress := common.HexToAddress(req.UserAddress)
 
 	// Verify access
@@ -123,6 +142,7 @@ func (h *licenseHandler) VerifyAccess(c echo.Context) error {
 			"reason": result.Reason,
 		})
 	}
+	recordCall(c, h.usage, userAddress, req.ToolID, result.ProvenanceHash)
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"valid":           result.Valid,
@@ -130,17 +150,20 @@ func (h *licenseHandler) VerifyAccess(c echo.Context) error {
 		"calls_remaining": result.CallsRemaining,
 		"expires_at":      result.ExpiresAt,
 		"provenance_hash": result.ProvenanceHash,
+		"stale":           result.Stale,
 	})
 }
 
 // RecordLicenseMinted handles POST /api/v1/license/record-minted
-// This is called by a blockchain event listener when a license is minted
+// This is called by a blockchain event listener when a license is minted,
+// authenticated as an operator
 func (h *licenseHandler) RecordLicenseMinted(c echo.Context) error {
 	var req struct {
 		UserAddress string `json:"user_address" validate:"required,eth_addr"`
 		ToolID      string `json:"tool_id" validate:"required"`
 		ExpiresAt   string `json:"expires_at" validate:"required"`
 		Nonce       string `json:"nonce" validate:"required"`
+		TxHash      string `json:"tx_hash"`
 	}
 
 	if err := c.Bind(&req); err != nil {
@@ -173,6 +196,19 @@ func (h *licenseHandler) RecordLicenseMinted(c echo.Context) error {
 		})
 	}
 
+	// The transaction hash is required where the backend can verify the
+	// payment; elsewhere the payment is recorded as unverified without it
+	var txHash common.Hash
+	if req.TxHash != "" {
+		decoded, err := hexutil.Decode(req.TxHash)
+		if err != nil || len(decoded) != common.HashLength {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid tx_hash",
+			})
+		}
+		txHash = common.BytesToHash(decoded)
+	}
+
 	// Record the minted license
 	err := h.licenseService.RecordLicenseMinted(
 		c.Request().Context(),
@@ -180,6 +216,7 @@ func (h *licenseHandler) RecordLicenseMinted(c echo.Context) error {
 		toolID,
 		expiresAt,
 		nonce,
+		txHash,
 	)
 
 	if err != nil {
@@ -194,35 +231,117 @@ func (h *licenseHandler) RecordLicenseMinted(c echo.Context) error {
 	})
 }
 
-// Update server setup to include license routes
-func (s *Server) setupLicenseRoutes() {
-	// Initialize license service dependencies
-	chainID, _ := new(big.Int).SetString("11155111", 10) // Sepolia
-	verifyingContract := common.HexToAddress(s.config.LicenseNFTAddress)
-
-	signer, err := auth.NewSigner(
-		s.config.JWTSecret, // Using JWT secret as private key for demo
-		chainID,
-		verifyingContract,
-	)
+// VerifySignature handles POST /api/v1/license/verify-signature. It checks a
+// mint authorization issued by RequestLicense against every signing key that
+// is not retired and reports which key made it.
+func (h *licenseHandler) VerifySignature(c echo.Context) error {
+	var req struct {
+		UserAddress string `json:"user_address" validate:"required,eth_addr"`
+		ToolID      string `json:"tool_id" validate:"required"`
+		ExpiresAt   string `json:"expires_at" validate:"required"`
+		Nonce       string `json:"nonce" validate:"required"`
+		Signature   struct {
+			R string `json:"r"`
+			S string `json:"s"`
+			V string `json:"v"`
+		} `json:"signature"`
+	}
+
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	if !common.IsHexAddress(req.UserAddress) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid user address",
+		})
+	}
+
+	toolID, ok1 := new(big.Int).SetString(req.ToolID, 10)
+	expiresAt, ok2 := new(big.Int).SetString(req.ExpiresAt, 10)
+	nonce, ok3 := new(big.Int).SetString(req.Nonce, 10)
+	if !ok1 || !ok2 || !ok3 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "tool_id, expires_at and nonce must be decimal integers",
+		})
+	}
+
+	signature := common.FromHex(req.Signature.R + strings.TrimPrefix(req.Signature.S, "0x") + strings.TrimPrefix(req.Signature.V, "0x"))
+	if len(signature) != 65 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid signature",
+		})
+	}
+
+	key, err := h.licenseService.VerifyLicenseSignature(common.HexToAddress(req.UserAddress), toolID, expiresAt, nonce, signature)
 	if err != nil {
-		log.Fatalf("Failed to create signer: %v", err)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"valid":  false,
+			"reason": err.Error(),
+		})
 	}
 
-	// Create license service
-	licenseService := blockchain.NewLicenseService(
-		s.config,
-		s.cache,
-		signer,
-		s.blockchain,
-	)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"valid":     true,
+		"key_id":    key.ID,
+		"key_state": key.State,
+		"signer":    key.Address.Hex(),
+	})
+}
+
+// ListUnderpayments handles GET /api/v1/admin/payments/underpaid
+func (h *licenseHandler) ListUnderpayments(c echo.Context) error {
+	payments := h.licenseService.ListUnderpayments(c.Request().Context())
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"payments": payments,
+		"count":    len(payments),
+	})
+}
+
+// GetPayments handles GET /api/v1/admin/payments/:user/:toolId
+func (h *licenseHandler) GetPayments(c echo.Context) error {
+	user := c.Param("user")
+	if !common.IsHexAddress(user) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid user address",
+		})
+	}
+
+	toolID, ok := new(big.Int).SetString(c.Param("toolId"), 10)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid tool ID",
+		})
+	}
+
+	payments := h.licenseService.GetPayments(c.Request().Context(), common.HexToAddress(user), toolID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"user_address": common.HexToAddress(user).Hex(),
+		"tool_id":      toolID.String(),
+		"payments":     payments,
+	})
+}
 
-	// Create handler
-	licenseHandler := NewLicenseHandler(licenseService)
+// Update server setup to include license routes
+func (s *Server) setupLicenseRoutes() {
+	// Create handler around the license service built by the caller, whose
+	// signer uses the configured chain ID and signing key
+	licenseHandler := NewLicenseHandler(s.licenseService)
+	licenseHandler.usage = s.usage
 
 	// Register routes
 	api := s.echo.Group("/api/v1")
 	api.POST("/license/request", licenseHandler.RequestLicense)
-	api.POST("/access/verify", licenseHandler.VerifyAccess)
-	api.POST("/license/record-minted", licenseHandler.RecordLicenseMinted)
+	api.POST("/access/verify", licenseHandler.VerifyAccess, s.authenticateHost, s.requireAPIKey(models.ScopeVerify), s.authenticateDelegation)
+	api.POST("/license/record-minted", licenseHandler.RecordLicenseMinted, s.requireRole(models.RoleOperator))
+	api.POST("/license/verify-signature", licenseHandler.VerifySignature)
+
+	// Auditors can read the payment ledger
+	payments := api.Group("/admin/payments", s.requireRole(models.RoleAuditor))
+	payments.GET("/underpaid", licenseHandler.ListUnderpayments)
+	payments.GET("/:user/:toolId", licenseHandler.GetPayments)
 }
@@ -8,10 +8,16 @@ import (
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"moltket/config"
+	"moltket/internal/auth"
 	"moltket/internal/blockchain"
+	"moltket/internal/cache"
+	"moltket/internal/core"
+	"moltket/internal/models"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/labstack/echo/v4"
@@ -25,6 +31,7 @@ type mockLicenseService struct {
     verifyResp  *blockchain.AccessResult
     verifyErr   error
     recordErr   error
+    payments    []*models.LicensePayment
 }
 
 func (m *mockLicenseService) RequestLicense(ctx context.Context, req *blockchain.LicenseRequest) (*blockchain.LicenseResponse, error) {
@@ -35,10 +42,22 @@ func (m *mockLicenseService) VerifyAccess(ctx context.Context, user common.Addre
     return m.verifyResp, m.verifyErr
 }
 
-func (m *mockLicenseService) RecordLicenseMinted(ctx context.Context, user common.Address, toolID, expiresAt, nonce *big.Int) error {
+func (m *mockLicenseService) RecordLicenseMinted(ctx context.Context, user common.Address, toolID, expiresAt, nonce *big.Int, txHash common.Hash) error {
     return m.recordErr
 }
 
+func (m *mockLicenseService) GetPayments(ctx context.Context, user common.Address, toolID *big.Int) []*models.LicensePayment {
+    return m.payments
+}
+
+func (m *mockLicenseService) ListUnderpayments(ctx context.Context) []*models.LicensePayment {
+    return m.payments
+}
+
+func (m *mockLicenseService) VerifyLicenseSignature(user common.Address, toolID, expiresAt, nonce *big.Int, signature []byte) (auth.KeyInfo, error) {
+    return auth.KeyInfo{}, auth.ErrUnknownSignature
+}
+
 func TestLicenseHandler_Integration(t *testing.T) {
     e := echo.New()
     
@@ -202,4 +221,171 @@ func TestLicenseHandler_Integration(t *testing.T) {
         assert.False(t, response["valid"].(bool))
         assert.Equal(t, "No valid license", response["reason"])
     })
-}
\ No newline at end of file
+}
+
+func TestLicenseHandler_SigningDisabled(t *testing.T) {
+    e := echo.New()
+    mockService := &mockLicenseService{
+        requestErr: fmt.Errorf("%w: LicenseNFT trustedSigner mismatch", blockchain.ErrSigningDisabled),
+    }
+    handler := NewLicenseHandler(mockService)
+    
+    body, _ := json.Marshal(map[string]string{
+        "user_address": "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+        "tool_id":      "42",
+    })
+    req := httptest.NewRequest(http.MethodPost, "/api/v1/license/request", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    rec := httptest.NewRecorder()
+    c := e.NewContext(req, rec)
+    
+    err := handler.RequestLicense(c)
+    require.NoError(t, err)
+    assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+    
+    var response map[string]interface{}
+    json.Unmarshal(rec.Body.Bytes(), &response)
+    assert.Contains(t, response["error"], "license issuance disabled")
+}
+
+func TestLicenseHandler_AdminPayments(t *testing.T) {
+    underpaid := []*models.LicensePayment{{
+        UserAddress:  "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+        ToolID:       "42",
+        QuotedWei:    "10000000000000000",
+        PaidWei:      "1",
+        ShortfallWei: "9999999999999999",
+        Status:       models.PaymentUnderpaid,
+    }}
+    
+    newAdminServer := func(token string) *Server {
+        s := &Server{
+            echo:           echo.New(),
+            config:         &config.Config{AdminToken: token},
+            licenseService: &mockLicenseService{payments: underpaid},
+        }
+        s.setupLicenseRoutes()
+        return s
+    }
+    
+    get := func(s *Server, path, token string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        if token != "" {
+            req.Header.Set("X-Admin-Token", token)
+        }
+        rec := httptest.NewRecorder()
+        s.echo.ServeHTTP(rec, req)
+        return rec
+    }
+    
+    t.Run("ListsUnderpayments", func(t *testing.T) {
+        rec := get(newAdminServer("admin-secret"), "/api/v1/admin/payments/underpaid", "admin-secret")
+        require.Equal(t, http.StatusOK, rec.Code)
+        
+        var response map[string]interface{}
+        require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+        assert.Equal(t, float64(1), response["count"])
+        payment := response["payments"].([]interface{})[0].(map[string]interface{})
+        assert.Equal(t, "9999999999999999", payment["shortfall_wei"])
+    })
+    
+    t.Run("PaymentLedger", func(t *testing.T) {
+        rec := get(newAdminServer("admin-secret"), "/api/v1/admin/payments/0x70997970C51812dc3A010C7d01b50e0d17dc79C8/42", "admin-secret")
+        require.Equal(t, http.StatusOK, rec.Code)
+        
+        rec = get(newAdminServer("admin-secret"), "/api/v1/admin/payments/0xUser/42", "admin-secret")
+        assert.Equal(t, http.StatusBadRequest, rec.Code)
+    })
+    
+    t.Run("RejectsWrongToken", func(t *testing.T) {
+        rec := get(newAdminServer("admin-secret"), "/api/v1/admin/payments/underpaid", "guess")
+        assert.Equal(t, http.StatusUnauthorized, rec.Code)
+    })
+    
+    t.Run("DisabledWithoutToken", func(t *testing.T) {
+        rec := get(newAdminServer(""), "/api/v1/admin/payments/underpaid", "")
+        assert.Equal(t, http.StatusForbidden, rec.Code)
+    })
+}
+
+func TestLicenseHandler_VerifyAccessRecordsUsage(t *testing.T) {
+    e := echo.New()
+    store := cache.NewKVStore()
+    defer store.Close()
+    
+    user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+    mockService := &mockLicenseService{}
+    handler := NewLicenseHandler(mockService)
+    handler.usage = core.NewUsageLedger(store)
+    
+    // signed stands in for authenticateDelegation having checked the
+    // user's delegated signature
+    verify := func(signed bool) int {
+        body, _ := json.Marshal(map[string]string{
+            "user_address": user.Hex(),
+            "tool_id":      "42",
+        })
+        req := httptest.NewRequest(http.MethodPost, "/api/v1/access/verify", bytes.NewReader(body))
+        req.Header.Set("Content-Type", "application/json")
+        rec := httptest.NewRecorder()
+        c := e.NewContext(req, rec)
+        if signed {
+            c.Set(signedUserContextKey, user)
+        }
+        require.NoError(t, handler.VerifyAccess(c))
+        return rec.Code
+    }
+    
+    t.Run("Unauthenticated", func(t *testing.T) {
+        // Anyone can check an address, but that does not make it eligible
+        mockService.verifyResp = &blockchain.AccessResult{Valid: true, Tier: "free", ProvenanceHash: "ABCD"}
+        for i := 0; i < 3; i++ {
+            assert.Equal(t, http.StatusOK, verify(false))
+        }
+        
+        usage := handler.usage.Usage(context.Background(), user, "42")
+        assert.Zero(t, usage.Calls)
+        _, ok := handler.usage.Receipt(context.Background(), "abcd")
+        assert.False(t, ok)
+        
+        policy := core.NewEligibilityPolicy(core.MinCallsRule{Min: 1})
+        err := policy.Check(&core.VoterHistory{Voter: strings.ToLower(user.Hex()), ToolID: "42", Usage: usage}, time.Now())
+        assert.ErrorIs(t, err, core.ErrVoterNotEligible)
+    })
+    
+    t.Run("Signed", func(t *testing.T) {
+        mockService.verifyResp = &blockchain.AccessResult{Valid: true, Tier: "free", ProvenanceHash: "ABCD"}
+        assert.Equal(t, http.StatusOK, verify(true))
+        mockService.verifyResp = &blockchain.AccessResult{Valid: false, Tier: "none"}
+        assert.Equal(t, http.StatusForbidden, verify(true))
+        
+        // Only the granted call counts, and its provenance hash is a receipt
+        usage := handler.usage.Usage(context.Background(), user, "42")
+        assert.Equal(t, int64(1), usage.Calls)
+        assert.False(t, usage.FirstCallAt.IsZero())
+        
+        receipt, ok := handler.usage.Receipt(context.Background(), "abcd")
+        require.True(t, ok)
+        assert.Equal(t, strings.ToLower(user.Hex()), receipt.UserAddress)
+        assert.Equal(t, "42", receipt.ToolID)
+    })
+    
+    t.Run("HostNotRegisteredForTool", func(t *testing.T) {
+        mockService.verifyResp = &blockchain.AccessResult{Valid: true, Tier: "free", ProvenanceHash: "EF01"}
+        body, _ := json.Marshal(map[string]string{
+            "user_address": user.Hex(),
+            "tool_id":      "42",
+        })
+        req := httptest.NewRequest(http.MethodPost, "/api/v1/access/verify", bytes.NewReader(body))
+        req.Header.Set("Content-Type", "application/json")
+        rec := httptest.NewRecorder()
+        c := e.NewContext(req, rec)
+        c.Set(hostContextKey, &models.Host{ID: "host-1", ToolIDs: []string{"7"}})
+        
+        require.NoError(t, handler.VerifyAccess(c))
+        assert.Equal(t, http.StatusForbidden, rec.Code)
+        assert.Contains(t, rec.Body.String(), "Host is not registered for this tool")
+        _, ok := handler.usage.Receipt(context.Background(), "ef01")
+        assert.False(t, ok)
+    })
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"moltket/internal/auth"
+	"moltket/internal/blockchain"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// Context key of the *principal set by requireRole
+	principalContextKey = "principal"
+
+	// Subject of callers authenticated with X-Admin-Token
+	adminTokenSubject = "admin-token"
+
+	// Grantor recorded on admin bindings from ADMIN_ADDRESSES
+	configGrantor = "config"
+
+	// Tool creators rarely change; a StakingNFT transfer is picked up
+	// within this window
+	toolCreatorCacheTTL = 5 * time.Minute
+)
+
+// principal is the authenticated caller of a privileged endpoint.
+type principal struct {
+	Subject string
+	Address *common.Address // Signed-in address, or the owner of an API key
+	Roles   []string
+	apiKey  *models.APIKey
+}
+
+// hasRole reports whether the caller holds one of roles. Admins hold every
+// role.
+func (p *principal) hasRole(roles ...string) bool {
+	for _, held := range p.Roles {
+		if held == models.RoleAdmin {
+			return true
+		}
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestPrincipal returns the caller stored by requireRole, or nil.
+func requestPrincipal(c echo.Context) *principal {
+	p, _ := c.Get(principalContextKey).(*principal)
+	return p
+}
+
+// requireRole lets through callers holding one of roles, or admin. Callers
+// authenticate with X-Admin-Token, an API key, or a sign-in session; roles
+// come from the role bindings of the key or address.
+func (s *Server) requireRole(roles ...string) echo.MiddlewareFunc {
+	return s.authorize(false, roles...)
+}
+
+// requireToolRole is like requireRole, but also lets through the creator of
+// the tool named by the :toolId path parameter, as recorded on StakingNFT.
+func (s *Server) requireToolRole(roles ...string) echo.MiddlewareFunc {
+	return s.authorize(true, roles...)
+}
+
+func (s *Server) authorize(toolOwner bool, roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			p, status, message := s.authenticatePrincipal(c)
+			if p == nil {
+				return c.JSON(status, map[string]string{
+					"error": message,
+				})
+			}
+
+			allowed := p.hasRole(roles...)
+			if !allowed && toolOwner {
+				owns, err := s.ownsTool(ctx, p, c.Param("toolId"))
+				if err != nil {
+					return c.JSON(http.StatusServiceUnavailable, map[string]string{
+						"error": "Failed to look up tool owner",
+					})
+				}
+				allowed = owns
+			}
+
+			if !allowed {
+				if p.apiKey != nil {
+					s.apiKeys.RecordUsage(ctx, p.apiKey, true)
+				}
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "Requires role: " + strings.Join(requiredRoles(toolOwner, roles), ", "),
+				})
+			}
+
+			c.Set(principalContextKey, p)
+			if p.apiKey == nil {
+				return next(c)
+			}
+
+			// API keys keep their rate limit and usage accounting
+			if allowed, err := s.apiKeys.Allow(ctx, p.apiKey); err != nil || !allowed {
+				s.apiKeys.RecordUsage(ctx, p.apiKey, true)
+				c.Response().Header().Set("Retry-After", "60")
+				return c.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "API key rate limit exceeded",
+				})
+			}
+			c.Set(apiKeyContextKey, p.apiKey)
+			err := next(c)
+			_, rejected := c.Get(apiKeyRejectedContextKey).(bool)
+			s.apiKeys.RecordUsage(ctx, p.apiKey, rejected)
+			return err
+		}
+	}
+}
+
+// requiredRoles lists the roles that pass a check, for error messages.
+func requiredRoles(toolOwner bool, roles []string) []string {
+	required := append([]string(nil), roles...)
+	if toolOwner {
+		required = append(required, models.RoleToolOwner)
+	}
+	for _, role := range required {
+		if role == models.RoleAdmin {
+			return required
+		}
+	}
+	return append(required, models.RoleAdmin)
+}
+
+// authenticatePrincipal identifies the caller from X-Admin-Token, X-API-Key
+// or a bearer session, in that order. On failure it returns the status and
+// message to answer with.
+func (s *Server) authenticatePrincipal(c echo.Context) (*principal, int, string) {
+	ctx := c.Request().Context()
+	header := c.Request().Header
+
+	if token := header.Get("X-Admin-Token"); token != "" {
+		if s.config.AdminToken == "" {
+			return nil, http.StatusForbidden, "Admin token disabled"
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			return nil, http.StatusUnauthorized, "Invalid admin token"
+		}
+		return &principal{Subject: adminTokenSubject, Roles: []string{models.RoleAdmin}}, 0, ""
+	}
+
+	if apiKey := header.Get(apiKeyHeader); apiKey != "" {
+		if s.apiKeys == nil {
+			return nil, http.StatusUnauthorized, "Invalid API key"
+		}
+		key, err := s.apiKeys.Authenticate(ctx, apiKey)
+		if err != nil {
+			return nil, http.StatusUnauthorized, "Invalid API key"
+		}
+
+		p := &principal{
+			Subject: auth.APIKeySubject(key.ID),
+			Roles:   s.roles.Roles(ctx, auth.APIKeySubject(key.ID)),
+			apiKey:  key,
+		}
+		if key.HasScope(models.ScopeAdmin) {
+			p.Roles = append(p.Roles, models.RoleAdmin)
+		}
+		if common.IsHexAddress(key.Owner) {
+			owner := common.HexToAddress(key.Owner)
+			p.Address = &owner
+		}
+		return p, 0, ""
+	}
+
+	if bearer, ok := strings.CutPrefix(header.Get(echo.HeaderAuthorization), "Bearer "); ok && s.sessions != nil {
+		claims, err := s.sessions.ParseAccessToken(bearer)
+		if err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return nil, http.StatusUnauthorized, "Invalid or expired access token"
+		}
+		address := claims.Address()
+		subject := auth.AddressSubject(address)
+		return &principal{
+			Subject: subject,
+			Address: &address,
+			Roles:   s.roles.Roles(ctx, subject),
+		}, 0, ""
+	}
+
+	// Nobody could ever authenticate
+	if s.config.AdminToken == "" && s.apiKeys == nil && s.sessions == nil {
+		return nil, http.StatusForbidden, "Admin API disabled"
+	}
+	return nil, http.StatusUnauthorized, "Missing credentials"
+}
+
+// ownsTool reports whether the caller's address created toolID on
+// StakingNFT.
+func (s *Server) ownsTool(ctx context.Context, p *principal, toolID string) (bool, error) {
+	id, ok := new(big.Int).SetString(toolID, 10)
+	if p.Address == nil || !ok {
+		return false, nil
+	}
+	creator, err := s.toolCreator(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return creator != (common.Address{}) && creator == *p.Address, nil
+}
+
+func (s *Server) toolCreator(ctx context.Context, toolID *big.Int) (common.Address, error) {
+	reader, ok := s.blockchain.(blockchain.ToolCreatorReader)
+	if !ok {
+		return common.Address{}, nil
+	}
+
+	cacheKey := "toolcreator:" + toolID.String()
+	if s.cache != nil {
+		if cached, found := s.cache.Get(ctx, cacheKey); found {
+			if creator, ok := cached.(common.Address); ok {
+				return creator, nil
+			}
+		}
+	}
+
+	creator, err := reader.ToolCreator(ctx, toolID)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if s.cache != nil {
+		s.cache.Set(ctx, cacheKey, creator, toolCreatorCacheTTL)
+	}
+	return creator, nil
+}
+
+type roleBindingRequest struct {
+	Subject string `json:"subject"` // "address:0x…", "apikey:<id>" or a bare address
+	Role    string `json:"role"`
+}
+
+// listRoles handles GET /api/v1/admin/roles
+func (s *Server) listRoles(c echo.Context) error {
+	bindings := s.roles.List(c.Request().Context())
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"roles": bindings,
+		"count": len(bindings),
+	})
+}
+
+// grantRole handles POST /api/v1/admin/roles
+func (s *Server) grantRole(c echo.Context) error {
+	var req roleBindingRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	binding, err := s.roles.Grant(c.Request().Context(), req.Subject, req.Role, requestPrincipal(c).Subject)
+	if errors.Is(err, auth.ErrInvalidRole) || errors.Is(err, auth.ErrInvalidSubject) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to grant role",
+		})
+	}
+	return c.JSON(http.StatusCreated, binding)
+}
+
+// revokeRole handles DELETE /api/v1/admin/roles/:subject/:role
+func (s *Server) revokeRole(c echo.Context) error {
+	err := s.roles.Revoke(c.Request().Context(), c.Param("subject"), c.Param("role"))
+	if errors.Is(err, auth.ErrInvalidSubject) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, auth.ErrRoleNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Role binding not found",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to revoke role",
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+func (s *Server) setupRoleRoutes() {
+	admin := s.echo.Group("/api/v1/admin/roles", s.authenticateAdmin)
+	admin.GET("", s.listRoles)
+	admin.POST("", s.grantRole)
+	admin.DELETE("/:subject/:role", s.revokeRole)
+}
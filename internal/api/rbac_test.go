@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+	"moltket/internal/core"
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolCreatorBlockchainClient reports fixed StakingNFT tool creators.
+type toolCreatorBlockchainClient struct {
+	mockBlockchainClient
+	creators map[string]common.Address
+}
+
+func (m *toolCreatorBlockchainClient) ToolCreator(ctx context.Context, toolID *big.Int) (common.Address, error) {
+	return m.creators[toolID.String()], nil
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:       "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+		SIWEDomain:      "app.skillchain.xyz",
+		SIWEURI:         "https://app.skillchain.xyz",
+		ChainID:         31337,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+		RateLimit:       1000,
+		AdminToken:      "admin-secret",
+		APIKeyRateLimit: 100,
+	}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	signer, err := auth.NewSigner(cfg.JWTSecret, big.NewInt(cfg.ChainID), common.Address{})
+	require.NoError(t, err)
+	bc := &toolCreatorBlockchainClient{creators: map[string]common.Address{}}
+	s := NewServer(cfg, kvStore, bc, core.NewVoteService(cfg, kvStore, signer), &mockLicenseService{})
+
+	do := func(method, path string, body interface{}, headers map[string]string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+	admin := map[string]string{"X-Admin-Token": "admin-secret"}
+	grant := func(subject, role string) {
+		rec, _ := do(http.MethodPost, "/api/v1/admin/roles", map[string]string{
+			"subject": subject,
+			"role":    role,
+		}, admin)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	signInAs := func() (map[string]string, common.Address) {
+		token := signIn(t, s, cfg)
+		claims, err := s.sessions.ParseAccessToken(token)
+		require.NoError(t, err)
+		return map[string]string{"Authorization": "Bearer " + token}, claims.Address()
+	}
+	recordMinted := map[string]string{
+		"user_address": "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		"tool_id":      "1",
+		"expires_at":   strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+		"nonce":        "1",
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		rec, _ := do(http.MethodPost, "/api/v1/license/record-minted", recordMinted, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec, _ = do(http.MethodPost, "/api/v1/vote/process-batch/1", nil, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec, _ = do(http.MethodGet, "/api/v1/admin/roles", nil, map[string]string{"X-Admin-Token": "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("OperatorSession", func(t *testing.T) {
+		session, address := signInAs()
+
+		rec, resp := do(http.MethodPost, "/api/v1/license/record-minted", recordMinted, session)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "Requires role: operator, admin", resp["error"])
+
+		grant(address.Hex(), models.RoleOperator)
+		rec, _ = do(http.MethodPost, "/api/v1/license/record-minted", recordMinted, session)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		// Operators run batches for any tool, but cannot manage roles
+		rec, _ = do(http.MethodPost, "/api/v1/vote/process-batch/9", nil, session)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "passes authorization, no pending votes")
+		rec, _ = do(http.MethodGet, "/api/v1/admin/roles", nil, session)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("OperatorAPIKey", func(t *testing.T) {
+		rec, resp := do(http.MethodPost, "/api/v1/admin/keys", map[string]interface{}{
+			"name":   "indexer",
+			"scopes": []string{models.ScopeVerify},
+		}, admin)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		apiKey := map[string]string{"X-API-Key": resp["api_key"].(string)}
+		keyID := resp["key"].(map[string]interface{})["id"].(string)
+
+		rec, _ = do(http.MethodPost, "/api/v1/license/record-minted", recordMinted, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		grant("apikey:"+keyID, models.RoleOperator)
+		rec, _ = do(http.MethodPost, "/api/v1/license/record-minted", recordMinted, apiKey)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec, _ = do(http.MethodDelete, "/api/v1/admin/roles/apikey:"+keyID+"/"+models.RoleOperator, nil, admin)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		rec, _ = do(http.MethodPost, "/api/v1/license/record-minted", recordMinted, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Auditor", func(t *testing.T) {
+		session, address := signInAs()
+		grant(address.Hex(), models.RoleAuditor)
+
+		rec, _ := do(http.MethodGet, "/api/v1/admin/payments/underpaid", nil, session)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		rec, _ = do(http.MethodGet, "/api/v1/admin/roles", nil, session)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec, _ = do(http.MethodPost, "/api/v1/license/record-minted", recordMinted, session)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("ToolOwner", func(t *testing.T) {
+		session, address := signInAs()
+		bc.creators["5"] = address
+
+		rec, _ := do(http.MethodPost, "/api/v1/vote/process-batch/5", nil, session)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "passes authorization, no pending votes")
+
+		rec, resp := do(http.MethodPost, "/api/v1/vote/process-batch/6", nil, session)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "Requires role: operator, tool-owner, admin", resp["error"])
+	})
+
+	t.Run("ManageRoles", func(t *testing.T) {
+		rec, _ := do(http.MethodPost, "/api/v1/admin/roles", map[string]string{
+			"subject": "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+			"role":    models.RoleToolOwner,
+		}, admin)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = do(http.MethodPost, "/api/v1/admin/roles", map[string]string{
+			"subject": "nobody",
+			"role":    models.RoleOperator,
+		}, admin)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = do(http.MethodDelete, "/api/v1/admin/roles/apikey:missing/operator", nil, admin)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		// A bound admin can manage roles, and grants are attributed to them
+		session, address := signInAs()
+		grant(address.Hex(), models.RoleAdmin)
+		rec, resp := do(http.MethodPost, "/api/v1/admin/roles", map[string]string{
+			"subject": "apikey:ops",
+			"role":    models.RoleAuditor,
+		}, session)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, auth.AddressSubject(address), resp["granted_by"])
+
+		rec, resp = do(http.MethodGet, "/api/v1/admin/roles", nil, session)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, float64(4), resp["count"])
+	})
+}
+
+func TestRoleBasedAccess_ConfiguredAdmins(t *testing.T) {
+	cfg := &config.Config{
+		AdminAddresses: []string{"0x70997970C51812dc3A010C7d01b50e0d17dc79C8", "not-an-address"},
+	}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, core.NewVoteService(cfg, kvStore, nil), &mockLicenseService{})
+
+	subject := auth.AddressSubject(common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"))
+	assert.Equal(t, []string{models.RoleAdmin}, s.roles.Roles(context.Background(), subject))
+	bindings := s.roles.List(context.Background())
+	require.Len(t, bindings, 1)
+	assert.Equal(t, configGrantor, bindings[0].GrantedBy)
+
+	// Bindings restored from the journal are kept, and configured admins
+	// are granted on top
+	path := filepath.Join(t.TempDir(), "roles.jsonl")
+	journal, err := kvstore.OpenJournal(path)
+	require.NoError(t, err)
+	require.NoError(t, s.UseRoleJournal(context.Background(), journal))
+	_, err = s.roles.Grant(context.Background(), "apikey:ops", models.RoleOperator, subject)
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	restartedStore := cache.NewKVStore()
+	defer restartedStore.Close()
+	restarted := NewServer(&config.Config{AdminAddresses: cfg.AdminAddresses}, restartedStore, &mockBlockchainClient{}, nil, &mockLicenseService{})
+	journal, err = kvstore.OpenJournal(path)
+	require.NoError(t, err)
+	defer journal.Close()
+	require.NoError(t, restarted.UseRoleJournal(context.Background(), journal))
+	assert.Equal(t, []string{models.RoleOperator}, restarted.roles.Roles(context.Background(), "apikey:ops"))
+	assert.Equal(t, []string{models.RoleAdmin}, restarted.roles.Roles(context.Background(), subject))
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/require"
 
@@ -350,3 +352,116 @@ func TestHealthCheck(t *testing.T) {
 	require.Equal(t, "OK", resp["status"])
 	require.Equal(t, "skillchain-verification", resp["service"])
 }
+
+func TestHealthCheck_DegradedMode(t *testing.T) {
+	conn := blockchain.NewConnection(func() (*blockchain.Client, error) {
+		return nil, fmt.Errorf("dial tcp: connection refused")
+	}, time.Hour)
+	require.Error(t, conn.Connect(context.Background()))
+
+	s := &Server{echo: echo.New(), config: &config.Config{}, blockchain: conn}
+	s.setupRoutes()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, true, resp["degraded"])
+	status := resp["blockchain"].(map[string]interface{})
+	require.Equal(t, blockchain.ChainDegraded, status["mode"])
+	require.Contains(t, status["last_error"], "connection refused")
+	require.NotEmpty(t, status["degraded_since"])
+}
+
+// syncingBlockchainClient reports a fixed indexing lag.
+type syncingBlockchainClient struct {
+	mockBlockchainClient
+	lag uint64
+}
+
+func (m *syncingBlockchainClient) SyncStatus(ctx context.Context, signer *auth.EIP712Signer) *blockchain.SyncStatus {
+	lastIndexed := 1000 - m.lag
+	return &blockchain.SyncStatus{
+		Connection:       blockchain.ChainStatus{Mode: blockchain.ChainOnline},
+		ChainID:          "31337",
+		Head:             1000,
+		LastIndexedBlock: &lastIndexed,
+		Lag:              &m.lag,
+		Subscriptions:    []blockchain.SubscriptionState{},
+		Contracts:        []blockchain.ContractStatus{},
+	}
+}
+
+func TestChainStatusAndReadiness(t *testing.T) {
+	get := func(s *Server, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	newServer := func(bc blockchain.BlockchainInterface) *Server {
+		s := &Server{echo: echo.New(), config: &config.Config{MaxChainLag: 50}, blockchain: bc}
+		s.setupRoutes()
+		return s
+	}
+
+	t.Run("InSync", func(t *testing.T) {
+		s := newServer(&syncingBlockchainClient{lag: 3})
+
+		rec := get(s, "/api/v1/chain/status")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var status blockchain.SyncStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		require.Equal(t, "31337", status.ChainID)
+		require.Equal(t, uint64(1000), status.Head)
+		require.Equal(t, uint64(997), *status.LastIndexedBlock)
+
+		require.Equal(t, http.StatusOK, get(s, "/api/v1/ready").Code)
+	})
+
+	t.Run("LaggingBehind", func(t *testing.T) {
+		s := newServer(&syncingBlockchainClient{lag: 51})
+
+		rec := get(s, "/api/v1/ready")
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Equal(t, false, resp["ready"])
+		require.Equal(t, float64(51), resp["lag"])
+	})
+
+	t.Run("NoChain", func(t *testing.T) {
+		s := newServer(&mockBlockchainClient{})
+
+		rec := get(s, "/api/v1/chain/status")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `"mode":"disabled"`)
+		require.Equal(t, http.StatusOK, get(s, "/api/v1/ready").Code)
+	})
+
+	t.Run("OracleSignerMismatch", func(t *testing.T) {
+		s := newServer(&syncingBlockchainClient{lag: 3})
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		signer := auth.NewEIP712Signer(auth.NewKeySignerFromECDSA(key), big.NewInt(31337), common.Address{})
+		monitor := blockchain.NewSignerMonitor(&staticSignerChecker{
+			trusted:   signer.Address(),
+			oracleErr: fmt.Errorf("%w: ReputationOracle backendSigner differs", blockchain.ErrSignerMismatch),
+		}, signer, time.Hour)
+		require.ErrorIs(t, monitor.Check(context.Background()), blockchain.ErrSignerMismatch)
+		s.UseSignerMonitor(monitor)
+
+		rec := get(s, "/api/v1/chain/status")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var status blockchain.SyncStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		require.NotNil(t, status.Signer)
+		require.Equal(t, signer.Address(), status.Signer.Active)
+		require.Empty(t, status.Signer.Error)
+		require.Contains(t, status.Signer.OracleError, "backendSigner differs")
+	})
+}
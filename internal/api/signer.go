@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"moltket/internal/auth"
+
+	"github.com/labstack/echo/v4"
+)
+
+type stageSignerKeyRequest struct {
+	KeystoreFile   string `json:"keystore_file"`
+	PassphraseFile string `json:"passphrase_file"`
+	RemoteURL      string `json:"remote_url"`
+	RemoteAddress  string `json:"remote_address"`
+	RemoteMethod   string `json:"remote_method"`
+}
+
+// listSignerKeys handles GET /api/v1/admin/signer/keys
+func (s *Server) listSignerKeys(c echo.Context) error {
+	if s.signerMonitor == nil {
+		return signerRotationDisabled(c)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"keys":           s.signerMonitor.Keys().Keys(),
+		"active":         s.signerMonitor.Signer().KeyID(),
+		"trusted_signer": s.signerMonitor.TrustedSigner().Hex(),
+	})
+}
+
+// stageSignerKey handles POST /api/v1/admin/signer/keys. The key is loaded
+// from a keystore or a remote signer, never sent in the request, and stays
+// verify-only until the contract owner calls updateSigner with its address.
+func (s *Server) stageSignerKey(c echo.Context) error {
+	if s.signerMonitor == nil {
+		return signerRotationDisabled(c)
+	}
+
+	var req stageSignerKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request format",
+		})
+	}
+
+	backend, err := auth.NewSignerBackend(auth.SignerConfig{
+		KeystoreFile:   req.KeystoreFile,
+		PassphraseFile: req.PassphraseFile,
+		RemoteURL:      req.RemoteURL,
+		RemoteAddress:  req.RemoteAddress,
+		RemoteMethod:   req.RemoteMethod,
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	info, err := s.signerMonitor.AddSigner(s.signerMonitor.Signer().WithBackend(backend))
+	if errors.Is(err, auth.ErrKeyExists) || errors.Is(err, auth.ErrKeyRetired) {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// Staged after updateSigner: the key took over, so re-check it now
+	if info.Address == s.signerMonitor.TrustedSigner() {
+		s.signerMonitor.Check(c.Request().Context())
+		info, _ = s.signerMonitor.Keys().Get(info.ID)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"key":          info,
+		"instructions": "Call updateSigner(" + info.Address.Hex() + ") on the license contract to activate this key",
+	})
+}
+
+// retireSignerKey handles DELETE /api/v1/admin/signer/keys/:id
+func (s *Server) retireSignerKey(c echo.Context) error {
+	if s.signerMonitor == nil {
+		return signerRotationDisabled(c)
+	}
+
+	err := s.signerMonitor.Keys().Retire(c.Param("id"))
+	if errors.Is(err, auth.ErrUnknownKey) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Signing key not found",
+		})
+	}
+	if errors.Is(err, auth.ErrActiveKey) {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	info, _ := s.signerMonitor.Keys().Get(c.Param("id"))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"key": info,
+	})
+}
+
+func signerRotationDisabled(c echo.Context) error {
+	return c.JSON(http.StatusServiceUnavailable, map[string]string{
+		"error": "Signer keys are managed by the signer monitor, which needs the blockchain enabled",
+	})
+}
+
+func (s *Server) setupSignerRoutes() {
+	admin := s.echo.Group("/api/v1/admin/signer", s.authenticateAdmin)
+	admin.GET("/keys", s.listSignerKeys)
+	admin.POST("/keys", s.stageSignerKey)
+	admin.DELETE("/keys/:id", s.retireSignerKey)
+}
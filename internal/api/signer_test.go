@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/blockchain"
+	"moltket/internal/cache"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticSignerChecker reports a fixed trusted signer and accepts it, failing
+// only the ReputationOracle check with oracleErr.
+type staticSignerChecker struct {
+	trusted   common.Address
+	oracleErr error
+}
+
+func (c *staticSignerChecker) TrustedSigner(ctx context.Context) (common.Address, error) {
+	return c.trusted, nil
+}
+
+func (c *staticSignerChecker) CheckSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	return nil
+}
+
+func (c *staticSignerChecker) CheckOracleSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	return c.oracleErr
+}
+
+func TestSignerKeys(t *testing.T) {
+	cfg := &config.Config{RateLimit: 1000, AdminToken: "admin-secret"}
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, nil, &mockLicenseService{})
+
+	do := func(method, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", "admin-secret")
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	rec, _ := do(http.MethodGet, "/api/v1/admin/signer/keys", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	active, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := auth.NewEIP712Signer(auth.NewKeySignerFromECDSA(active), big.NewInt(31337), common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	checker := &staticSignerChecker{trusted: signer.Address()}
+	monitor := blockchain.NewSignerMonitor(checker, signer, 0)
+	require.NoError(t, monitor.Check(context.Background()))
+	s.UseSignerMonitor(monitor)
+
+	// Stage a keystore key
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	next := crypto.PubkeyToAddress(key.PublicKey)
+	keyJSON, err := keystore.EncryptKey(&keystore.Key{Id: uuid.New(), Address: next, PrivateKey: key}, "pw", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	keyFile, passphraseFile := filepath.Join(dir, "key.json"), filepath.Join(dir, "passphrase")
+	require.NoError(t, os.WriteFile(keyFile, keyJSON, 0o600))
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("pw"), 0o600))
+
+	stage := map[string]string{"keystore_file": keyFile, "passphrase_file": passphraseFile}
+	rec, resp := do(http.MethodPost, "/api/v1/admin/signer/keys", stage)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	staged := resp["key"].(map[string]interface{})
+	assert.Equal(t, auth.KeyID(next), staged["id"])
+	assert.Equal(t, auth.KeyVerifyOnly, staged["state"])
+
+	rec, _ = do(http.MethodPost, "/api/v1/admin/signer/keys", stage)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec, _ = do(http.MethodPost, "/api/v1/admin/signer/keys", map[string]string{"keystore_file": keyFile})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// updateSigner(next) makes the staged key active
+	checker.trusted = next
+	require.NoError(t, monitor.Check(context.Background()))
+	rec, resp = do(http.MethodGet, "/api/v1/admin/signer/keys", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, auth.KeyID(next), resp["active"])
+	assert.Len(t, resp["keys"], 2)
+
+	rec, _ = do(http.MethodDelete, "/api/v1/admin/signer/keys/"+auth.KeyID(next), nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec, resp = do(http.MethodDelete, "/api/v1/admin/signer/keys/"+signer.KeyID(), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, auth.KeyRetired, resp["key"].(map[string]interface{})["state"])
+	rec, _ = do(http.MethodDelete, "/api/v1/admin/signer/keys/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
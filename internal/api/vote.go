@@ -2,7 +2,11 @@
 package api
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"moltket/internal/core"
@@ -11,6 +15,13 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// History returned when no range is given, by bucket size
+var defaultHistorySpans = map[string]time.Duration{
+    core.BucketHour: 48 * time.Hour,
+    core.BucketDay:  30 * 24 * time.Hour,
+    core.BucketWeek: 26 * 7 * 24 * time.Hour,
+}
+
 type voteHandler struct {
     voteService *core.VoteService
 }
@@ -44,6 +55,10 @@ func (h *voteHandler) SubmitVote(c echo.Context) error {
         })
     }
     
+    if !apiKeyAllowsTool(c, submission.ToolID) {
+        return nil
+    }
+    
     // Submit vote
     result, err := h.voteService.SubmitVote(c.Request().Context(), &submission)
     if err != nil {
@@ -56,6 +71,7 @@ func (h *voteHandler) SubmitVote(c echo.Context) error {
         return c.JSON(http.StatusBadRequest, map[string]interface{}{
             "valid":  false,
             "reason": result.Reason,
+            "rule":   result.Rule,
         })
     }
     
@@ -66,6 +82,47 @@ func (h *voteHandler) SubmitVote(c echo.Context) error {
     })
 }
 
+// RetractVote handles POST /api/v1/vote/retract
+func (h *voteHandler) RetractVote(c echo.Context) error {
+    var retraction models.VoteRetraction
+    
+    if err := c.Bind(&retraction); err != nil {
+        return c.JSON(http.StatusBadRequest, map[string]string{
+            "error": "Invalid request format",
+        })
+    }
+    
+    if retraction.Nonce == 0 {
+        return c.JSON(http.StatusBadRequest, map[string]string{
+            "error": "Nonce is required",
+        })
+    }
+    
+    if !apiKeyAllowsTool(c, retraction.ToolID) {
+        return nil
+    }
+    
+    result, err := h.voteService.RetractVote(c.Request().Context(), &retraction)
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{
+            "error": "Failed to process retraction",
+        })
+    }
+    
+    if !result.Valid {
+        return c.JSON(http.StatusBadRequest, map[string]interface{}{
+            "valid":  false,
+            "reason": result.Reason,
+        })
+    }
+    
+    return c.JSON(http.StatusOK, map[string]interface{}{
+        "valid":    true,
+        "vote_id":  result.VoteID,
+        "message":  "Vote retracted successfully",
+    })
+}
+
 // GetReputation handles GET /api/v1/vote/reputation/:toolId
 func (h *voteHandler) GetReputation(c echo.Context) error {
     toolID := c.Param("toolId")
@@ -85,15 +142,111 @@ func (h *voteHandler) GetReputation(c echo.Context) error {
     return c.JSON(http.StatusOK, map[string]interface{}{
         "tool_id":    reputation.ToolID,
         "score":      reputation.TotalScore,
+        "raw_score":  reputation.RawScore,
         "votes":      reputation.TotalVotes,
+        "weight":     reputation.TotalWeight,
         "average":    reputation.AverageScore,
         "recent":     reputation.RecentScore,
+        "algorithm":  reputation.Algorithm,
+        "parameters": reputation.Parameters,
         "updated_at": reputation.LastCalculatedAt.Format(time.RFC3339),
     })
 }
 
+// GetReputationHistory handles GET /api/v1/vote/reputation/:toolId/history
+// Optional query parameters: bucket (hour, day or week; default day), from
+// and to (RFC 3339; default the last 30 days, 48 hours or 26 weeks) and
+// format=csv to download the series as CSV
+func (h *voteHandler) GetReputationHistory(c echo.Context) error {
+    toolID := c.Param("toolId")
+    
+    bucket := c.QueryParam("bucket")
+    if bucket == "" {
+        bucket = core.BucketDay
+    }
+    span, ok := defaultHistorySpans[bucket]
+    if !ok {
+        return c.JSON(http.StatusBadRequest, map[string]string{
+            "error": core.ErrInvalidBucket.Error(),
+        })
+    }
+    
+    to := time.Now()
+    if value := c.QueryParam("to"); value != "" {
+        var err error
+        if to, err = time.Parse(time.RFC3339, value); err != nil {
+            return c.JSON(http.StatusBadRequest, map[string]string{
+                "error": "to must be an RFC 3339 time",
+            })
+        }
+    }
+    from := to.Add(-span)
+    if value := c.QueryParam("from"); value != "" {
+        var err error
+        if from, err = time.Parse(time.RFC3339, value); err != nil {
+            return c.JSON(http.StatusBadRequest, map[string]string{
+                "error": "from must be an RFC 3339 time",
+            })
+        }
+    }
+    
+    series, err := h.voteService.ReputationHistory(c.Request().Context(), toolID, bucket, from, to)
+    if errors.Is(err, core.ErrInvalidHistoryRange) {
+        return c.JSON(http.StatusBadRequest, map[string]string{
+            "error": err.Error(),
+        })
+    }
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{
+            "error": "Failed to get reputation history",
+        })
+    }
+    
+    if c.QueryParam("format") == "csv" {
+        return writeHistoryCSV(c, toolID, bucket, series)
+    }
+    
+    return c.JSON(http.StatusOK, map[string]interface{}{
+        "tool_id": toolID,
+        "bucket":  bucket,
+        "from":    from.UTC().Format(time.RFC3339),
+        "to":      to.UTC().Format(time.RFC3339),
+        "series":  series,
+    })
+}
+
+// writeHistoryCSV sends a reputation history as a CSV download, one row per
+// bucket
+func writeHistoryCSV(c echo.Context, toolID, bucket string, series []models.ReputationBucket) error {
+    c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+    c.Response().Header().Set(echo.HeaderContentDisposition,
+        fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("reputation-%s-%s.csv", toolID, bucket)))
+    c.Response().WriteHeader(http.StatusOK)
+    
+    w := csv.NewWriter(c.Response())
+    w.Write([]string{"start", "votes", "withdrawn", "score_sum", "raw_score_sum", "weight_sum",
+        "average_score", "net_score", "batches", "committed_votes"})
+    for _, point := range series {
+        w.Write([]string{
+            point.Start.Format(time.RFC3339),
+            strconv.FormatInt(point.Votes, 10),
+            strconv.FormatInt(point.Withdrawn, 10),
+            strconv.FormatInt(point.ScoreSum, 10),
+            strconv.FormatInt(point.RawScoreSum, 10),
+            strconv.FormatInt(point.WeightSum, 10),
+            strconv.FormatFloat(point.AverageScore, 'f', -1, 64),
+            strconv.FormatInt(point.NetScore, 10),
+            strconv.FormatInt(point.Batches, 10),
+            strconv.FormatInt(point.CommittedVotes, 10),
+        })
+    }
+    w.Flush()
+    return w.Error()
+}
+
 // ProcessBatch handles POST /api/v1/vote/process-batch/:toolId
-// This is an admin endpoint to manually trigger batch processing
+// This lets operators, and the creator of the tool, manually trigger batch
+// processing
 func (h *voteHandler) ProcessBatch(c echo.Context) error {
     toolID := c.Param("toolId")
     if toolID == "" {
@@ -102,9 +255,6 @@ func (h *voteHandler) ProcessBatch(c echo.Context) error {
         })
     }
     
-    // In production, add authentication/authorization here
-    // For demo, we'll allow anyone to trigger batch processing
-    
     batch, err := h.voteService.ProcessBatch(c.Request().Context(), toolID)
     if err != nil {
         return c.JSON(http.StatusBadRequest, map[string]string{
@@ -117,18 +267,41 @@ func (h *voteHandler) ProcessBatch(c echo.Context) error {
         "tool_id":       batch.ToolID,
         "votes_count":   batch.VotesCount,
         "total_score":   batch.TotalScore,
+        "raw_score":     batch.RawScore,
         "merkle_root":   batch.MerkleRoot,
+        "supersessions": batch.Supersessions,
         "created_at":    batch.CreatedAt.Format(time.RFC3339),
         "message":       "Batch processed successfully. Ready for blockchain commitment.",
     })
 }
 
+// GetProof handles GET /api/v1/vote/proof/:voteId
+// Returns the Merkle proof of a vote against its batch's root
+func (h *voteHandler) GetProof(c echo.Context) error {
+    proof, err := h.voteService.VoteProof(c.Request().Context(), c.Param("voteId"))
+    if errors.Is(err, core.ErrVoteNotFound) || errors.Is(err, core.ErrVoteNotBatched) {
+        return c.JSON(http.StatusNotFound, map[string]string{
+            "error": err.Error(),
+        })
+    }
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{
+            "error": "Failed to build vote proof",
+        })
+    }
+    
+    return c.JSON(http.StatusOK, proof)
+}
+
 // Setup function to be called from main server
 func (s *Server) setupVoteRoutes(voteService *core.VoteService) {
     voteHandler := NewVoteHandler(voteService)
     
     api := s.echo.Group("/api/v1/vote")
-    api.POST("/submit", voteHandler.SubmitVote)
+    api.POST("/submit", voteHandler.SubmitVote, s.requireAPIKey(models.ScopeVote))
+    api.POST("/retract", voteHandler.RetractVote, s.requireAPIKey(models.ScopeVote))
     api.GET("/reputation/:toolId", voteHandler.GetReputation)
-    api.POST("/process-batch/:toolId", voteHandler.ProcessBatch)
+    api.GET("/reputation/:toolId/history", voteHandler.GetReputationHistory)
+    api.GET("/proof/:voteId", voteHandler.GetProof)
+    api.POST("/process-batch/:toolId", voteHandler.ProcessBatch, s.requireToolRole(models.RoleOperator))
 }
\ No newline at end of file
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/cache"
+	"moltket/internal/core"
+	"moltket/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputationHistoryEndpoint(t *testing.T) {
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	cfg := &config.Config{RateLimit: 1000, ChainID: 31337}
+	s := NewServer(cfg, kvStore, &mockBlockchainClient{}, core.NewVoteService(cfg, kvStore, nil), &mockLicenseService{})
+
+	at := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	history := core.NewReputationHistory(kvStore)
+	history.RecordVote(context.Background(), &models.Vote{ToolID: "3", Score: 1, Weight: 2, CreatedAt: at})
+	history.RecordVote(context.Background(), &models.Vote{ToolID: "3", Score: -1, CreatedAt: at.Add(25 * time.Hour)})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/vote/reputation/3/history?"+query, nil)
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		rec := get("bucket=day&from=2026-03-04T00:00:00Z&to=2026-03-06T00:00:00Z")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp struct {
+			Bucket string                    `json:"bucket"`
+			Series []models.ReputationBucket `json:"series"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, core.BucketDay, resp.Bucket)
+		require.Len(t, resp.Series, 2)
+		assert.Equal(t, int64(2), resp.Series[0].ScoreSum)
+		assert.Equal(t, 1.0, resp.Series[0].AverageScore)
+		assert.Equal(t, int64(-1), resp.Series[1].ScoreSum)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		rec := get("bucket=week&from=2026-03-02T00:00:00Z&to=2026-03-09T00:00:00Z&format=csv")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), `filename="reputation-3-week.csv"`)
+
+		rows, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "start", rows[0][0])
+		assert.Equal(t, []string{"2026-03-02T00:00:00Z", "2", "0", "1", "0", "3"}, rows[1][:6])
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		for _, query := range []string{
+			"bucket=month",
+			"from=yesterday",
+			"from=2026-03-05T00:00:00Z&to=2026-03-04T00:00:00Z",
+			"bucket=hour&from=2025-01-01T00:00:00Z&to=2026-01-01T00:00:00Z",
+		} {
+			assert.Equal(t, http.StatusBadRequest, get(query).Code, query)
+		}
+	})
+}
@@ -0,0 +1,361 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+)
+
+var (
+	// ErrInvalidAPIKey is returned for keys that are malformed, unknown,
+	// revoked or carry the wrong secret.
+	ErrInvalidAPIKey = errors.New("invalid API key")
+
+	// ErrAPIKeyNotFound is returned when managing a key that does not exist.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	// ErrInvalidAPIKeyRequest is returned when creating a key with an unknown
+	// scope, no scopes, or a negative rate limit.
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+)
+
+const (
+	apiKeyPrefix = "mk_"
+
+	// Key records live as long as a payment ledger; every use refreshes them
+	apiKeyTTL = 365 * 24 * time.Hour
+
+	// Daily usage counters are kept for this long
+	apiKeyUsageTTL = 90 * 24 * time.Hour
+)
+
+// APIKeyRequest describes a key to create.
+type APIKeyRequest struct {
+	Name      string
+	Owner     string
+	Scopes    []string
+	ToolIDs   []string
+	RateLimit int // Requests per minute; 0 uses the service default
+}
+
+// APIKeyService manages tool host API keys. A key reads
+// "mk_<id>_<secret>"; the id is public and names the record, the secret is
+// only stored as a SHA-256 hash and is shown once, on create and rotate.
+type APIKeyService struct {
+	store            kvstore.Store
+	defaultRateLimit int
+	now              func() time.Time
+
+	// Serializes read-modify-write of key records and owner indexes
+	mu sync.Mutex
+}
+
+// NewAPIKeyService creates a key service. defaultRateLimit applies to keys
+// created without a limit of their own.
+func NewAPIKeyService(store kvstore.Store, defaultRateLimit int) *APIKeyService {
+	return &APIKeyService{
+		store:            store,
+		defaultRateLimit: defaultRateLimit,
+		now:              time.Now,
+	}
+}
+
+// DefaultRateLimit returns the per-minute limit of keys created without one.
+func (s *APIKeyService) DefaultRateLimit() int {
+	return s.defaultRateLimit
+}
+
+// Create stores a new key and returns it with its secret key string.
+func (s *APIKeyService) Create(ctx context.Context, req APIKeyRequest) (*models.APIKey, string, error) {
+	if err := validateAPIKeyRequest(req); err != nil {
+		return nil, "", err
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = s.defaultRateLimit
+	}
+
+	key := &models.APIKey{
+		ID:         id,
+		Name:       req.Name,
+		Owner:      strings.ToLower(req.Owner),
+		SecretHash: hashAPIKeySecret(secret),
+		Scopes:     append([]string(nil), req.Scopes...),
+		ToolIDs:    append([]string(nil), req.ToolIDs...),
+		RateLimit:  rateLimit,
+		CreatedAt:  s.now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.save(ctx, key); err != nil {
+		return nil, "", err
+	}
+	ids := append(s.ownerKeyIDs(ctx, key.Owner), key.ID)
+	if err := s.store.Set(ctx, ownerKeysKey(key.Owner), ids, apiKeyTTL); err != nil {
+		return nil, "", fmt.Errorf("failed to index API key: %w", err)
+	}
+
+	return copyAPIKey(key), formatAPIKey(id, secret), nil
+}
+
+// Get returns a key by id, revoked or not.
+func (s *APIKeyService) Get(ctx context.Context, id string) (*models.APIKey, error) {
+	key, ok := s.load(ctx, id)
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return copyAPIKey(key), nil
+}
+
+// List returns the keys created by owner, oldest first.
+func (s *APIKeyService) List(ctx context.Context, owner string) ([]*models.APIKey, error) {
+	keys := []*models.APIKey{}
+	for _, id := range s.ownerKeyIDs(ctx, strings.ToLower(owner)) {
+		if key, ok := s.load(ctx, id); ok {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+	return keys, nil
+}
+
+// Rotate replaces the secret of a key, keeping its id, scopes and usage.
+// The old key string stops working immediately.
+func (s *APIKeyService) Rotate(ctx context.Context, id string) (*models.APIKey, string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.load(ctx, id)
+	if !ok || key.RevokedAt != nil {
+		return nil, "", ErrAPIKeyNotFound
+	}
+
+	rotated := copyAPIKey(key)
+	now := s.now().UTC()
+	rotated.SecretHash = hashAPIKeySecret(secret)
+	rotated.RotatedAt = &now
+	if err := s.save(ctx, rotated); err != nil {
+		return nil, "", err
+	}
+
+	return copyAPIKey(rotated), formatAPIKey(id, secret), nil
+}
+
+// Revoke disables a key for good. The record is kept so that its usage
+// stays visible.
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.load(ctx, id)
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	revoked := copyAPIKey(key)
+	now := s.now().UTC()
+	revoked.RevokedAt = &now
+	return s.save(ctx, revoked)
+}
+
+// Authenticate returns the active key a key string belongs to. The secret
+// is compared in constant time.
+func (s *APIKeyService) Authenticate(ctx context.Context, apiKey string) (*models.APIKey, error) {
+	id, secret, ok := parseAPIKey(apiKey)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, found := s.load(ctx, id)
+	if !found {
+		return nil, ErrInvalidAPIKey
+	}
+	hash := hashAPIKeySecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.SecretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: key was revoked", ErrInvalidAPIKey)
+	}
+
+	return copyAPIKey(key), nil
+}
+
+// Allow counts a request against the key's per-minute limit and reports
+// whether it is within it.
+func (s *APIKeyService) Allow(ctx context.Context, key *models.APIKey) (bool, error) {
+	limit := key.RateLimit
+	if limit <= 0 {
+		limit = s.defaultRateLimit
+	}
+
+	minute := s.now().UTC().Truncate(time.Minute).Unix()
+	count, err := s.store.Increment(ctx, fmt.Sprintf("apikey:rate:%s:%d", key.ID, minute), 1, time.Minute)
+	if err != nil {
+		return false, fmt.Errorf("failed to count API key request: %w", err)
+	}
+	return count <= int64(limit), nil
+}
+
+// RecordUsage counts a request made with the key on today's usage, and
+// marks the key as used. Rejected requests are counted separately too.
+func (s *APIKeyService) RecordUsage(ctx context.Context, key *models.APIKey, rejected bool) {
+	now := s.now().UTC()
+	date := now.Format(time.DateOnly)
+
+	s.store.Increment(ctx, usageKey("requests", key.ID, date), 1, apiKeyUsageTTL)
+	if rejected {
+		s.store.Increment(ctx, usageKey("rejected", key.ID, date), 1, apiKeyUsageTTL)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.load(ctx, key.ID); ok {
+		used := copyAPIKey(current)
+		used.LastUsedAt = &now
+		s.save(ctx, used)
+	}
+}
+
+// Usage returns the daily usage of a key over the last days days, oldest
+// first. Days without requests are included with zero counts.
+func (s *APIKeyService) Usage(ctx context.Context, id string, days int) ([]models.APIKeyUsage, error) {
+	if _, ok := s.load(ctx, id); !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	today := s.now().UTC()
+	usage := make([]models.APIKeyUsage, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		date := today.AddDate(0, 0, -i).Format(time.DateOnly)
+		usage = append(usage, models.APIKeyUsage{
+			Date:     date,
+			Requests: s.counter(ctx, usageKey("requests", id, date)),
+			Rejected: s.counter(ctx, usageKey("rejected", id, date)),
+		})
+	}
+	return usage, nil
+}
+
+func (s *APIKeyService) load(ctx context.Context, id string) (*models.APIKey, bool) {
+	cached, found := s.store.Get(ctx, "apikey:"+id)
+	if !found {
+		return nil, false
+	}
+	key, ok := cached.(*models.APIKey)
+	return key, ok
+}
+
+// save stores a key record. Stored records are never mutated, so readers
+// can hold on to what load returned.
+func (s *APIKeyService) save(ctx context.Context, key *models.APIKey) error {
+	if err := s.store.Set(ctx, "apikey:"+key.ID, key, apiKeyTTL); err != nil {
+		return fmt.Errorf("failed to store API key: %w", err)
+	}
+	return nil
+}
+
+func (s *APIKeyService) ownerKeyIDs(ctx context.Context, owner string) []string {
+	cached, found := s.store.Get(ctx, ownerKeysKey(owner))
+	if !found {
+		return nil
+	}
+	ids, _ := cached.([]string)
+	return append([]string(nil), ids...)
+}
+
+func (s *APIKeyService) counter(ctx context.Context, key string) int64 {
+	cached, found := s.store.Get(ctx, key)
+	if !found {
+		return 0
+	}
+	count, _ := cached.(int64)
+	return count
+}
+
+func validateAPIKeyRequest(req APIKeyRequest) error {
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	for _, scope := range req.Scopes {
+		switch scope {
+		case models.ScopeVerify, models.ScopeVote, models.ScopeAdmin:
+		default:
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+	}
+	for _, toolID := range req.ToolIDs {
+		if toolID == "" {
+			return fmt.Errorf("%w: empty tool ID", ErrInvalidAPIKeyRequest)
+		}
+	}
+	if req.RateLimit < 0 {
+		return fmt.Errorf("%w: rate limit must not be negative", ErrInvalidAPIKeyRequest)
+	}
+	return nil
+}
+
+func formatAPIKey(id, secret string) string {
+	return apiKeyPrefix + id + "_" + secret
+}
+
+func parseAPIKey(apiKey string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(apiKey, apiKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+func hashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func ownerKeysKey(owner string) string {
+	return "apikeys:owner:" + owner
+}
+
+func usageKey(kind, id, date string) string {
+	return fmt.Sprintf("apikey:%s:%s:%s", kind, id, date)
+}
+
+func copyAPIKey(key *models.APIKey) *models.APIKey {
+	c := *key
+	c.Scopes = append([]string(nil), key.Scopes...)
+	c.ToolIDs = append([]string(nil), key.ToolIDs...)
+	return &c
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyService(t *testing.T) {
+	ctx := context.Background()
+	owner := "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+
+	newService := func(t *testing.T) *APIKeyService {
+		store := kvstore.NewMemoryStore(time.Minute)
+		t.Cleanup(func() { store.Close() })
+		return NewAPIKeyService(store, 600)
+	}
+
+	t.Run("CreateAndAuthenticate", func(t *testing.T) {
+		service := newService(t)
+
+		key, apiKey, err := service.Create(ctx, APIKeyRequest{
+			Name:    "host",
+			Owner:   owner,
+			Scopes:  []string{models.ScopeVerify},
+			ToolIDs: []string{"1"},
+		})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(apiKey, "mk_"+key.ID+"_"))
+		assert.Equal(t, 600, key.RateLimit)
+		assert.NotContains(t, key.SecretHash, strings.TrimPrefix(apiKey, "mk_"+key.ID+"_"))
+
+		authenticated, err := service.Authenticate(ctx, apiKey)
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, authenticated.ID)
+		assert.True(t, authenticated.HasScope(models.ScopeVerify))
+		assert.False(t, authenticated.HasScope(models.ScopeAdmin))
+		assert.True(t, authenticated.AllowsTool("1"))
+		assert.False(t, authenticated.AllowsTool("2"))
+
+		for _, wrong := range []string{"", "mk_", "mk_" + key.ID, apiKey + "0", "xx" + apiKey[2:]} {
+			_, err := service.Authenticate(ctx, wrong)
+			assert.ErrorIs(t, err, ErrInvalidAPIKey, wrong)
+		}
+
+		keys, err := service.List(ctx, strings.ToLower(owner))
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, key.ID, keys[0].ID)
+	})
+
+	t.Run("RejectsInvalidRequests", func(t *testing.T) {
+		service := newService(t)
+
+		for name, req := range map[string]APIKeyRequest{
+			"NoScopes":      {Owner: owner},
+			"UnknownScope":  {Owner: owner, Scopes: []string{"root"}},
+			"EmptyToolID":   {Owner: owner, Scopes: []string{models.ScopeVote}, ToolIDs: []string{""}},
+			"NegativeLimit": {Owner: owner, Scopes: []string{models.ScopeVote}, RateLimit: -1},
+		} {
+			_, _, err := service.Create(ctx, req)
+			assert.ErrorIs(t, err, ErrInvalidAPIKeyRequest, name)
+		}
+	})
+
+	t.Run("RotateAndRevoke", func(t *testing.T) {
+		service := newService(t)
+
+		key, oldKey, err := service.Create(ctx, APIKeyRequest{Owner: owner, Scopes: []string{models.ScopeVote}})
+		require.NoError(t, err)
+
+		rotated, newKey, err := service.Rotate(ctx, key.ID)
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, rotated.ID)
+		assert.NotNil(t, rotated.RotatedAt)
+		assert.NotEqual(t, oldKey, newKey)
+
+		_, err = service.Authenticate(ctx, oldKey)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		_, err = service.Authenticate(ctx, newKey)
+		require.NoError(t, err)
+
+		require.NoError(t, service.Revoke(ctx, key.ID))
+		_, err = service.Authenticate(ctx, newKey)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+		_, _, err = service.Rotate(ctx, key.ID)
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+		assert.ErrorIs(t, service.Revoke(ctx, "missing"), ErrAPIKeyNotFound)
+
+		// Revoked keys stay listed with their revocation time
+		keys, err := service.List(ctx, owner)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.NotNil(t, keys[0].RevokedAt)
+	})
+
+	t.Run("RateLimitAndUsage", func(t *testing.T) {
+		service := newService(t)
+		now := time.Date(2025, 3, 10, 12, 0, 30, 0, time.UTC)
+		service.now = func() time.Time { return now }
+
+		key, _, err := service.Create(ctx, APIKeyRequest{Owner: owner, Scopes: []string{models.ScopeVerify}, RateLimit: 2})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			allowed, err := service.Allow(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+			service.RecordUsage(ctx, key, false)
+		}
+		allowed, err := service.Allow(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		service.RecordUsage(ctx, key, true)
+
+		// The next minute starts a new window
+		now = now.Add(time.Minute)
+		allowed, err = service.Allow(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+
+		usage, err := service.Usage(ctx, key.ID, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []models.APIKeyUsage{
+			{Date: "2025-03-09"},
+			{Date: "2025-03-10", Requests: 3, Rejected: 1},
+		}, usage)
+
+		used, err := service.Get(ctx, key.ID)
+		require.NoError(t, err)
+		require.NotNil(t, used.LastUsedAt)
+	})
+}
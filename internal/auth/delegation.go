@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// DelegationType is the EIP-712 type a user signs to let a session key,
+// typically held by an agent, sign /verify and /access/verify requests on
+// their behalf. It is signed under RequestDomain.
+const DelegationType = "Delegation(address user,address sessionKey,string[] toolIds,uint256 maxCalls,uint256 expiresAt,string nonce)"
+
+// Delegation authorizes SessionKey to sign requests for User, for the given
+// tools (all tools if empty), up to MaxCalls requests (unlimited if 0),
+// until ExpiresAt (Unix seconds). The nonce is chosen by the user and makes
+// otherwise identical delegations distinct.
+type Delegation struct {
+	User       common.Address
+	SessionKey common.Address
+	ToolIDs    []string
+	MaxCalls   uint64
+	ExpiresAt  int64
+	Nonce      string
+}
+
+// Message returns the delegation as an EIP-712 message of type Delegation.
+func (d *Delegation) Message() apitypes.TypedDataMessage {
+	toolIDs := make([]interface{}, len(d.ToolIDs))
+	for i, id := range d.ToolIDs {
+		toolIDs[i] = id
+	}
+	return apitypes.TypedDataMessage{
+		"user":       d.User.Hex(),
+		"sessionKey": d.SessionKey.Hex(),
+		"toolIds":    toolIDs,
+		"maxCalls":   new(big.Int).SetUint64(d.MaxCalls),
+		"expiresAt":  big.NewInt(d.ExpiresAt),
+		"nonce":      d.Nonce,
+	}
+}
+
+// HashDelegation returns the EIP-712 digest of d under domain. It also
+// serves as the delegation's ID.
+func HashDelegation(domain apitypes.TypedDataDomain, d *Delegation) (common.Hash, error) {
+	return Types.Hash(domain, "Delegation", d.Message())
+}
+
+// RevokeDelegationType is the EIP-712 type a user signs to revoke one of
+// their delegations without signing in. It is signed under RequestDomain,
+// over a nonce from /verify/nonce.
+const RevokeDelegationType = "RevokeDelegation(string id,string nonce)"
+
+// RevokeDelegation revokes the delegation with the given ID.
+type RevokeDelegation struct {
+	ID    string
+	Nonce string
+}
+
+// Message returns the revocation as an EIP-712 message of type
+// RevokeDelegation.
+func (r *RevokeDelegation) Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"id":    r.ID,
+		"nonce": r.Nonce,
+	}
+}
+
+// HashRevokeDelegation returns the EIP-712 digest of r under domain.
+func HashRevokeDelegation(domain apitypes.TypedDataDomain, r *RevokeDelegation) (common.Hash, error) {
+	return Types.Hash(domain, "RevokeDelegation", r.Message())
+}
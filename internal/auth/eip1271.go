@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"moltket/internal/kvstore"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// EIP-1271: isValidSignature(bytes32,bytes) returns this selector when the
+// contract accepts the signature
+var eip1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+
+var isValidSignatureSelector = crypto.Keccak256([]byte("isValidSignature(bytes32,bytes)"))[:4]
+
+const (
+	// Whether an address has code rarely changes; re-checked hourly so a
+	// counterfactual wallet is picked up once deployed
+	contractCodeCacheTTL = time.Hour
+
+	// A wallet may change owners, so its answers are only cached briefly
+	eip1271ResultCacheTTL = 5 * time.Minute
+)
+
+// ContractCaller is the part of an Ethereum client EIP-1271 checks need.
+// *ethclient.Client and the simulated backend implement it.
+type ContractCaller interface {
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// SignatureVerifier checks that an address signed a hash. EOA signatures are
+// recovered with ECDSA; for addresses with code it falls back to asking the
+// contract wallet through EIP-1271 isValidSignature. Wallet answers and
+// code lookups are cached in the store.
+type SignatureVerifier struct {
+	caller ContractCaller
+	store  kvstore.Store
+}
+
+// NewSignatureVerifier creates a verifier. Without a caller only EOA
+// signatures are accepted; without a store nothing is cached.
+func NewSignatureVerifier(caller ContractCaller, store kvstore.Store) *SignatureVerifier {
+	return &SignatureVerifier{
+		caller: caller,
+		store:  store,
+	}
+}
+
+// Verify reports whether signer signed hash. An error means the wallet
+// could not be asked; a wallet that reverts or answers anything but the
+// magic value rejects the signature. A nil verifier only accepts EOA
+// signatures.
+func (v *SignatureVerifier) Verify(ctx context.Context, signer common.Address, hash common.Hash, signature []byte) (bool, error) {
+	if len(signature) == 65 {
+		if recovered, err := recoverAddress(hash, signature); err == nil && recovered == signer {
+			return true, nil
+		}
+	}
+
+	if v == nil || v.caller == nil {
+		return false, nil
+	}
+
+	isContract, err := v.hasCode(ctx, signer)
+	if err != nil || !isContract {
+		return false, err
+	}
+
+	resultKey := fmt.Sprintf("eip1271:%s:%s:%s", signer.Hex(), hash.Hex(), signatureDigest(signature))
+	if cached, found := v.get(ctx, resultKey); found {
+		if valid, ok := cached.(bool); ok {
+			return valid, nil
+		}
+	}
+
+	valid, err := v.isValidSignature(ctx, signer, hash, signature)
+	if err != nil {
+		return false, err
+	}
+	v.set(ctx, resultKey, valid, eip1271ResultCacheTTL)
+	return valid, nil
+}
+
+func (v *SignatureVerifier) hasCode(ctx context.Context, account common.Address) (bool, error) {
+	codeKey := "eip1271:code:" + account.Hex()
+	if cached, found := v.get(ctx, codeKey); found {
+		if hasCode, ok := cached.(bool); ok {
+			return hasCode, nil
+		}
+	}
+
+	code, err := v.caller.CodeAt(ctx, account, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get code of %s: %w", account.Hex(), err)
+	}
+	hasCode := len(code) > 0
+	v.set(ctx, codeKey, hasCode, contractCodeCacheTTL)
+	return hasCode, nil
+}
+
+func (v *SignatureVerifier) isValidSignature(ctx context.Context, wallet common.Address, hash common.Hash, signature []byte) (bool, error) {
+	result, err := v.caller.CallContract(ctx, ethereum.CallMsg{
+		To:   &wallet,
+		Data: packIsValidSignature(hash, signature),
+	}, nil)
+	if isRevert(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("isValidSignature call to %s failed: %w", wallet.Hex(), err)
+	}
+
+	// bytes4 is returned left-aligned in a 32-byte word
+	return len(result) >= 32 && bytes.Equal(result[:4], eip1271MagicValue), nil
+}
+
+func (v *SignatureVerifier) get(ctx context.Context, key string) (interface{}, bool) {
+	if v.store == nil {
+		return nil, false
+	}
+	return v.store.Get(ctx, key)
+}
+
+func (v *SignatureVerifier) set(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if v.store != nil {
+		v.store.Set(ctx, key, value, ttl)
+	}
+}
+
+// packIsValidSignature ABI-encodes isValidSignature(hash, signature).
+func packIsValidSignature(hash common.Hash, signature []byte) []byte {
+	padded := (len(signature) + 31) / 32 * 32
+
+	data := make([]byte, 0, 4+32*3+padded)
+	data = append(data, isValidSignatureSelector...)
+	data = append(data, hash.Bytes()...)
+	data = append(data, common.LeftPadBytes(big.NewInt(64).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(signature))).Bytes(), 32)...)
+	data = append(data, common.RightPadBytes(signature, padded)...)
+	return data
+}
+
+func recoverAddress(hash common.Hash, signature []byte) (common.Address, error) {
+	sig, err := normalizeSignature(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	pubkey, err := crypto.SigToPub(hash.Bytes(), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover public key: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// isRevert tells a reverted call, which means the wallet rejects the
+// signature, from a failure to reach the node.
+func isRevert(err error) bool {
+	if err == nil {
+		return false
+	}
+	var dataErr rpc.DataError
+	return errors.As(err, &dataErr) || strings.Contains(err.Error(), "execution reverted")
+}
+
+func signatureDigest(signature []byte) string {
+	digest := sha256.Sum256(signature)
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"moltket/internal/kvstore"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assembler builds EVM bytecode for the mock wallets below, since there is
+// no Solidity compiler in the Go test environment.
+type assembler []byte
+
+func (a *assembler) op(ops ...vm.OpCode) {
+	for _, op := range ops {
+		*a = append(*a, byte(op))
+	}
+}
+
+func (a *assembler) push(op vm.OpCode, data []byte) {
+	*a = append(append(*a, byte(op)), data...)
+}
+
+func (a *assembler) push1(b byte) {
+	a.push(vm.PUSH1, []byte{b})
+}
+
+// mockWalletCode is the runtime code of a minimal EIP-1271 wallet that
+// accepts a 65-byte r||s||v signature of the hash by owner:
+//
+//	function isValidSignature(bytes32 hash, bytes sig) returns (bytes4) {
+//	    return ecrecover(hash, v, r, s) == owner ? 0x1626ba7e : 0x00000000;
+//	}
+func mockWalletCode(owner common.Address) []byte {
+	var a assembler
+	// ecrecover input at memory 0x00: hash, v, r, s
+	a.push1(0x04)
+	a.op(vm.CALLDATALOAD)
+	a.push1(0x00)
+	a.op(vm.MSTORE)
+	a.push1(0xa4) // v is the first byte after r and s
+	a.op(vm.CALLDATALOAD)
+	a.push1(0x00)
+	a.op(vm.BYTE)
+	a.push1(0x20)
+	a.op(vm.MSTORE)
+	a.push1(0x64)
+	a.op(vm.CALLDATALOAD)
+	a.push1(0x40)
+	a.op(vm.MSTORE)
+	a.push1(0x84)
+	a.op(vm.CALLDATALOAD)
+	a.push1(0x60)
+	a.op(vm.MSTORE)
+
+	// staticcall(gas, 0x01, 0x00, 0x80, 0x80, 0x20)
+	a.push1(0x20)
+	a.push1(0x80)
+	a.push1(0x80)
+	a.push1(0x00)
+	a.push1(0x01)
+	a.op(vm.GAS, vm.STATICCALL, vm.POP)
+
+	// Jump to the magic value if the recovered address is the owner
+	a.push1(0x80)
+	a.op(vm.MLOAD)
+	a.push(vm.PUSH20, owner.Bytes())
+	a.op(vm.EQ)
+	a.push1(0x00) // patched below
+	jumpTarget := len(a) - 1
+	a.op(vm.JUMPI)
+
+	// Not the owner: return a zero word
+	a.push1(0x20)
+	a.push1(0xa0)
+	a.op(vm.RETURN)
+
+	a[jumpTarget] = byte(len(a))
+	a.op(vm.JUMPDEST)
+	a.push(vm.PUSH4, eip1271MagicValue)
+	a.push1(0xe0)
+	a.op(vm.SHL)
+	a.push1(0x00)
+	a.op(vm.MSTORE)
+	a.push1(0x20)
+	a.push1(0x00)
+	a.op(vm.RETURN)
+	return a
+}
+
+// revertingWalletCode rejects every call.
+func revertingWalletCode() []byte {
+	var a assembler
+	a.push1(0x00)
+	a.op(vm.DUP1, vm.REVERT)
+	return a
+}
+
+// deployCode wraps runtime in init code, deploys it and returns its address.
+func deployCode(t *testing.T, backend *simulated.Backend, deployer *ecdsa.PrivateKey, runtime []byte) common.Address {
+	t.Helper()
+	ctx := context.Background()
+	client := backend.Client()
+
+	var init assembler
+	init.push1(byte(len(runtime)))
+	init.push1(12) // length of this init code
+	init.push1(0x00)
+	init.op(vm.CODECOPY)
+	init.push1(byte(len(runtime)))
+	init.push1(0x00)
+	init.op(vm.RETURN)
+	require.Len(t, init, 12)
+
+	from := crypto.PubkeyToAddress(deployer.PublicKey)
+	nonce, err := client.PendingNonceAt(ctx, from)
+	require.NoError(t, err)
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	require.NoError(t, err)
+	chainID, err := client.ChainID(ctx)
+	require.NoError(t, err)
+
+	tx, err := types.SignTx(
+		types.NewContractCreation(nonce, big.NewInt(0), 200_000, gasPrice, append(init, runtime...)),
+		types.LatestSignerForChainID(chainID),
+		deployer,
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.SendTransaction(ctx, tx))
+	backend.Commit()
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	return receipt.ContractAddress
+}
+
+// signHash signs hash with v as 27/28, as wallets and contracts expect.
+func signHash(t *testing.T, key *ecdsa.PrivateKey, hash common.Hash) []byte {
+	t.Helper()
+	signature, err := crypto.Sign(hash.Bytes(), key)
+	require.NoError(t, err)
+	signature[64] += 27
+	return signature
+}
+
+// countingCaller counts calls and can be made to fail like an unreachable node.
+type countingCaller struct {
+	ContractCaller
+	codeCalls, contractCalls int
+	err                      error
+}
+
+func (c *countingCaller) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	c.codeCalls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.ContractCaller.CodeAt(ctx, account, blockNumber)
+}
+
+func (c *countingCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	c.contractCalls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.ContractCaller.CallContract(ctx, call, blockNumber)
+}
+
+func TestSignatureVerifier_EIP1271(t *testing.T) {
+	ctx := context.Background()
+
+	deployer, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	owner, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	stranger, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(deployer.PublicKey): {Balance: big.NewInt(params.Ether)},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	wallet := deployCode(t, backend, deployer, mockWalletCode(crypto.PubkeyToAddress(owner.PublicKey)))
+	reverting := deployCode(t, backend, deployer, revertingWalletCode())
+
+	hash := crypto.Keccak256Hash([]byte("vote"))
+
+	newVerifier := func(t *testing.T) (*SignatureVerifier, *countingCaller) {
+		store := kvstore.NewMemoryStore(time.Minute)
+		t.Cleanup(func() { store.Close() })
+		caller := &countingCaller{ContractCaller: backend.Client()}
+		return NewSignatureVerifier(caller, store), caller
+	}
+
+	t.Run("EOA", func(t *testing.T) {
+		verifier, caller := newVerifier(t)
+		signer := crypto.PubkeyToAddress(owner.PublicKey)
+
+		valid, err := verifier.Verify(ctx, signer, hash, signHash(t, owner, hash))
+		require.NoError(t, err)
+		assert.True(t, valid)
+		assert.Zero(t, caller.codeCalls, "EOA signatures need no RPC")
+
+		// A wrong signature for an address without code is simply invalid
+		valid, err = verifier.Verify(ctx, signer, hash, signHash(t, stranger, hash))
+		require.NoError(t, err)
+		assert.False(t, valid)
+		assert.Zero(t, caller.contractCalls)
+	})
+
+	t.Run("ContractWallet", func(t *testing.T) {
+		verifier, caller := newVerifier(t)
+
+		valid, err := verifier.Verify(ctx, wallet, hash, signHash(t, owner, hash))
+		require.NoError(t, err)
+		assert.True(t, valid)
+
+		valid, err = verifier.Verify(ctx, wallet, hash, signHash(t, stranger, hash))
+		require.NoError(t, err)
+		assert.False(t, valid)
+
+		// The owner's signature of another hash is not accepted either
+		other := crypto.Keccak256Hash([]byte("other"))
+		valid, err = verifier.Verify(ctx, wallet, hash, signHash(t, owner, other))
+		require.NoError(t, err)
+		assert.False(t, valid)
+
+		valid, err = verifier.Verify(ctx, reverting, hash, signHash(t, owner, hash))
+		require.NoError(t, err)
+		assert.False(t, valid)
+		assert.Equal(t, 4, caller.contractCalls)
+	})
+
+	t.Run("CachesResults", func(t *testing.T) {
+		verifier, caller := newVerifier(t)
+		signature := signHash(t, owner, hash)
+
+		for i := 0; i < 3; i++ {
+			valid, err := verifier.Verify(ctx, wallet, hash, signature)
+			require.NoError(t, err)
+			assert.True(t, valid)
+		}
+		assert.Equal(t, 1, caller.codeCalls)
+		assert.Equal(t, 1, caller.contractCalls)
+
+		// A different signature is a new question for the wallet, but the
+		// code lookup is reused
+		_, err := verifier.Verify(ctx, wallet, hash, signHash(t, stranger, hash))
+		require.NoError(t, err)
+		assert.Equal(t, 1, caller.codeCalls)
+		assert.Equal(t, 2, caller.contractCalls)
+	})
+
+	t.Run("NodeErrorsAreNotCached", func(t *testing.T) {
+		verifier, caller := newVerifier(t)
+		signature := signHash(t, owner, hash)
+
+		caller.err = errors.New("connection refused")
+		_, err := verifier.Verify(ctx, wallet, hash, signature)
+		assert.Error(t, err)
+
+		caller.err = nil
+		valid, err := verifier.Verify(ctx, wallet, hash, signature)
+		require.NoError(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("WithoutCallerOnlyEOAs", func(t *testing.T) {
+		for _, verifier := range []*SignatureVerifier{nil, NewSignatureVerifier(nil, nil)} {
+			valid, err := verifier.Verify(ctx, wallet, hash, signHash(t, owner, hash))
+			require.NoError(t, err)
+			assert.False(t, valid)
+
+			valid, err = verifier.Verify(ctx, crypto.PubkeyToAddress(owner.PublicKey), hash, signHash(t, owner, hash))
+			require.NoError(t, err)
+			assert.True(t, valid)
+		}
+	})
+}
+
+func TestPackIsValidSignature(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	data := packIsValidSignature(hash, []byte{0xaa, 0xbb})
+
+	assert.Equal(t, common.FromHex("0x1626ba7e"), data[:4])
+	assert.Equal(t, hash.Bytes(), data[4:36])
+	assert.Equal(t, common.LeftPadBytes([]byte{0x40}, 32), data[36:68])
+	assert.Equal(t, common.LeftPadBytes([]byte{0x02}, 32), data[68:100])
+	assert.Equal(t, common.RightPadBytes([]byte{0xaa, 0xbb}, 32), data[100:])
+}
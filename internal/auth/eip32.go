@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
@@ -12,174 +13,153 @@ import (
 )
 
 type EIP712Signer struct {
-    privateKey    *ecdsa.PrivateKey
+    backend       Signer
     publicAddress common.Address
     domain        apitypes.TypedDataDomain
+    domains       map[string]apitypes.TypedDataDomain
     chainID       *big.Int
 }
 
+// NewSigner creates a signer around a raw hex private key. See
+// NewEIP712Signer for keystore and remote keys.
 func NewSigner(privateKeyHex string, chainID *big.Int, verifyingContract common.Address) (*EIP712Signer, error) {
-    privateKey, err := crypto.HexToECDSA(privateKeyHex)
+    backend, err := NewKeySigner(privateKeyHex)
     if err != nil {
-        return nil, fmt.Errorf("invalid private key: %w", err)
+        return nil, err
     }
-    
-    publicKey := privateKey.Public()
-    publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-    if !ok {
-        return nil, fmt.Errorf("error casting public key to ECDSA")
-    }
-    
-    publicAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-    
-    return &EIP712Signer{
-        privateKey:    privateKey,
-        publicAddress: publicAddress,
-        chainID:       chainID,
-        domain: apitypes.TypedDataDomain{
-            Name:              "SkillChainLicense",
-            Version:           "1",
-            ChainId:           math.NewHexOrDecimal256(chainID.Int64()),
-            VerifyingContract: verifyingContract.Hex(),
-        },
-    }, nil
+    return NewEIP712Signer(backend, chainID, verifyingContract), nil
 }
 
-func (s *EIP712Signer) CreateLicenseSignature(
-    user common.Address,
-    toolId *big.Int,
-    expiresAt *big.Int,
-    nonce *big.Int,
-) ([]byte, []byte, []byte, error) {
-    
-    types := apitypes.Types{
-        "EIP712Domain": []apitypes.Type{
-            {Name: "name", Type: "string"},
-            {Name: "version", Type: "string"},
-            {Name: "chainId", Type: "uint256"},
-            {Name: "verifyingContract", Type: "address"},
-        },
-        "MintLicense": []apitypes.Type{
-            {Name: "user", Type: "address"},
-            {Name: "toolId", Type: "uint256"},
-            {Name: "expiresAt", Type: "uint256"},
-            {Name: "nonce", Type: "uint256"},
-        },
+// NewEIP712Signer creates a signer whose signatures are made by backend,
+// bound to the license domain of verifyingContract.
+func NewEIP712Signer(backend Signer, chainID *big.Int, verifyingContract common.Address) *EIP712Signer {
+    signer := &EIP712Signer{
+        backend:       backend,
+        publicAddress: backend.Address(),
+        chainID:       chainID,
+        domains:       make(map[string]apitypes.TypedDataDomain),
     }
-    
-    message := apitypes.TypedDataMessage{
-        "user":      user.Hex(),
-        "toolId":    toolId.String(),
-        "expiresAt": expiresAt.String(),
-        "nonce":     nonce.String(),
+    signer.SetDomain(LicenseDomain, verifyingContract)
+    signer.domain = signer.domains[LicenseDomain]
+    return signer
+}
+
+// SetDomain binds the named domain (LicenseDomain, OracleDomain) to a
+// contract. All domains share the signer's name, version and chain ID.
+func (s *EIP712Signer) SetDomain(name string, verifyingContract common.Address) {
+    s.domains[name] = apitypes.TypedDataDomain{
+        Name:              "SkillChainLicense",
+        Version:           "1",
+        ChainId:           math.NewHexOrDecimal256(s.chainID.Int64()),
+        VerifyingContract: verifyingContract.Hex(),
     }
-    
-    typedData := apitypes.TypedData{
-        Types:       types,
-        PrimaryType: "MintLicense",
-        Domain:      s.domain,
-        Message:     message,
+}
+
+// DomainFor returns the named domain.
+func (s *EIP712Signer) DomainFor(name string) (apitypes.TypedDataDomain, error) {
+    domain, ok := s.domains[name]
+    if !ok {
+        return apitypes.TypedDataDomain{}, fmt.Errorf("signer has no %s domain", name)
     }
-    
-    // Hash and sign the typed data
-    domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+    return domain, nil
+}
+
+// Sign signs a message of a type registered in Types, under the domain the
+// type belongs to. Signatures from the backend are checked to recover to the
+// signer's address, so a misconfigured remote signer is caught here rather
+// than by a reverting contract.
+func (s *EIP712Signer) Sign(primaryType string, message apitypes.TypedDataMessage) ([]byte, error) {
+    domain, err := s.domainForType(primaryType)
     if err != nil {
-        return nil, nil, nil, fmt.Errorf("failed to hash domain: %w", err)
+        return nil, err
     }
-    
-    hash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+    typedData, err := Types.TypedData(domain, primaryType, message)
     if err != nil {
-        return nil, nil, nil, fmt.Errorf("failed to hash message: %w", err)
+        return nil, err
     }
-    
-    rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(hash)))
-    sighash := crypto.Keccak256(rawData)
-    
-    signature, err := crypto.Sign(sighash, s.privateKey)
+
+    signature, err := s.backend.SignTypedData(context.Background(), typedData)
     if err != nil {
-        return nil, nil, nil, fmt.Errorf("failed to sign: %w", err)
+        return nil, err
+    }
+    if len(signature) != 65 {
+        return nil, fmt.Errorf("signer returned a %d-byte signature", len(signature))
+    }
+    signature = append([]byte(nil), signature...)
+    if signature[64] < 27 {
+        signature[64] += 27
     }
-    
-    // Split signature into r, s, v
-    r := signature[:32]
-    sigS := signature[32:64]
-    sigV := signature[64:]
 
-    // Ensure v is 27 or 28
-    if sigV[0] < 27 {
-        sigV[0] += 27
+    recovered, err := Types.Recover(domain, primaryType, message, signature)
+    if err != nil {
+        return nil, fmt.Errorf("signer returned an invalid signature: %w", err)
     }
+    if recovered != s.publicAddress {
+        return nil, fmt.Errorf("signer returned a signature by %s, expected %s", recovered.Hex(), s.publicAddress.Hex())
+    }
+    return signature, nil
+}
 
-    return r, sigS, sigV, nil
+// Recover returns the address that signed a message of a type registered in
+// Types, under the signer's domain for that type.
+func (s *EIP712Signer) Recover(primaryType string, message apitypes.TypedDataMessage, signature []byte) (common.Address, error) {
+    domain, err := s.domainForType(primaryType)
+    if err != nil {
+        return common.Address{}, err
+    }
+    return Types.Recover(domain, primaryType, message, signature)
 }
 
-func (s *EIP712Signer) VerifySignature(
-    user common.Address,
-    toolId *big.Int,
-    expiresAt *big.Int,
-    nonce *big.Int,
-    sigR, sigS, sigV []byte,
-) (bool, error) {
-    
-    // Recreate the typed data
-    types := apitypes.Types{
-        "EIP712Domain": []apitypes.Type{
-            {Name: "name", Type: "string"},
-            {Name: "version", Type: "string"},
-            {Name: "chainId", Type: "uint256"},
-            {Name: "verifyingContract", Type: "address"},
-        },
-        "MintLicense": []apitypes.Type{
-            {Name: "user", Type: "address"},
-            {Name: "toolId", Type: "uint256"},
-            {Name: "expiresAt", Type: "uint256"},
-            {Name: "nonce", Type: "uint256"},
-        },
+func (s *EIP712Signer) domainForType(primaryType string) (apitypes.TypedDataDomain, error) {
+    name, err := Types.DomainOf(primaryType)
+    if err != nil {
+        return apitypes.TypedDataDomain{}, err
     }
-    
-    message := apitypes.TypedDataMessage{
+    return s.DomainFor(name)
+}
+
+func licenseMessage(user common.Address, toolId, expiresAt, nonce *big.Int) apitypes.TypedDataMessage {
+    return apitypes.TypedDataMessage{
         "user":      user.Hex(),
         "toolId":    toolId.String(),
         "expiresAt": expiresAt.String(),
         "nonce":     nonce.String(),
     }
+}
+
+func (s *EIP712Signer) CreateLicenseSignature(
+    user common.Address,
+    toolId *big.Int,
+    expiresAt *big.Int,
+    nonce *big.Int,
+) ([]byte, []byte, []byte, error) {
     
-    typedData := apitypes.TypedData{
-        Types:       types,
-        PrimaryType: "MintLicense",
-        Domain:      s.domain,
-        Message:     message,
-    }
-    
-    // Hash the typed data
-    domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
-    if err != nil {
-        return false, fmt.Errorf("failed to hash domain: %w", err)
-    }
-    
-    hash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+    signature, err := s.Sign("MintLicense", licenseMessage(user, toolId, expiresAt, nonce))
     if err != nil {
-        return false, fmt.Errorf("failed to hash message: %w", err)
+        return nil, nil, nil, err
     }
     
-    rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(hash)))
-    sighash := crypto.Keccak256(rawData)
+    // Split signature into r, s, v (v is 27 or 28)
+    return signature[:32], signature[32:64], signature[64:], nil
+}
+
+func (s *EIP712Signer) VerifySignature(
+    user common.Address,
+    toolId *big.Int,
+    expiresAt *big.Int,
+    nonce *big.Int,
+    sigR, sigS, sigV []byte,
+) (bool, error) {
     
-    // Recover the public key
-    signature := append(sigR, sigS...)
+    signature := make([]byte, 0, 65)
+    signature = append(signature, sigR...)
+    signature = append(signature, sigS...)
     signature = append(signature, sigV...)
     
-    // Adjust v if necessary
-    if signature[64] >= 27 {
-        signature[64] -= 27
-    }
-    
-    pubkey, err := crypto.SigToPub(sighash, signature)
+    recoveredAddr, err := s.Recover("MintLicense", licenseMessage(user, toolId, expiresAt, nonce), signature)
     if err != nil {
-        return false, fmt.Errorf("failed to recover public key: %w", err)
+        return false, err
     }
-    
-    recoveredAddr := crypto.PubkeyToAddress(*pubkey)
     return recoveredAddr == s.publicAddress, nil
 }
 
@@ -187,6 +167,42 @@ func (s *EIP712Signer) Address() common.Address {
     return s.publicAddress
 }
 
+// KeyID returns the ID of the signer's key, see KeyID.
+func (s *EIP712Signer) KeyID() string {
+    return KeyID(s.publicAddress)
+}
+
+// WithBackend returns a signer for another key bound to the same domains,
+// e.g. a key staged to replace this one.
+func (s *EIP712Signer) WithBackend(backend Signer) *EIP712Signer {
+    signer := &EIP712Signer{
+        backend:       backend,
+        publicAddress: backend.Address(),
+        domain:        s.domain,
+        chainID:       s.chainID,
+        domains:       make(map[string]apitypes.TypedDataDomain, len(s.domains)),
+    }
+    for name, domain := range s.domains {
+        signer.domains[name] = domain
+    }
+    return signer
+}
+
+// Domain returns the EIP-712 domain every signature is bound to.
+func (s *EIP712Signer) Domain() apitypes.TypedDataDomain {
+    return s.domain
+}
+
+// ChainID returns the chain ID of the signing domain.
+func (s *EIP712Signer) ChainID() *big.Int {
+    return new(big.Int).Set(s.chainID)
+}
+
+// VerifyingContract returns the contract address of the signing domain.
+func (s *EIP712Signer) VerifyingContract() common.Address {
+    return common.HexToAddress(s.domain.VerifyingContract)
+}
+
 // GenerateTestPrivateKey is a test helper that returns a freshly generated private key.
 // Provided for tests that expect a test key generation helper.
 func GenerateTestPrivateKey(t interface{}, seed string) (*ecdsa.PrivateKey, error) {
@@ -68,7 +68,8 @@ func TestEIP712Signer_Integration(t *testing.T) {
     // Signatures should be different
     assert.NotEqual(t, r, r2, "Different signers should produce different r values")
     assert.NotEqual(t, s, s2, "Different signers should produce different s values")
-    assert.NotEqual(t, v, v2, "Different signers should produce different v values")
+    // v is 27 or 28 for either key, so only the signatures as a whole must differ
+    assert.NotEqual(t, append(append(r, s...), v...), append(append(r2, s2...), v2...), "Different signers should produce different signatures")
     
     // Test 5: Address should match
     expectedAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
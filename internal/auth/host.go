@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+)
+
+var (
+	// ErrHostNotFound is returned when managing a host that does not exist.
+	ErrHostNotFound = errors.New("host not found")
+
+	// ErrInvalidHost is returned when registering a host with an empty tool
+	// ID.
+	ErrInvalidHost = errors.New("invalid host registration")
+
+	// ErrInvalidHostSignature is returned for requests from unknown or
+	// revoked hosts, and for signatures that do not match the request.
+	ErrInvalidHostSignature = errors.New("invalid host signature")
+
+	// ErrStaleHostRequest is returned for requests whose timestamp is
+	// further from the server clock than the allowed skew.
+	ErrStaleHostRequest = errors.New("host request timestamp outside allowed clock skew")
+
+	// ErrReplayedHostRequest is returned when a signed request is sent again.
+	ErrReplayedHostRequest = errors.New("host request already used")
+)
+
+const (
+	// DefaultHostSignatureSkew is how far a request timestamp may be from the
+	// server clock, either way, when no skew is configured.
+	DefaultHostSignatureSkew = 5 * time.Minute
+
+	// Host records live as long as API key records
+	hostTTL = apiKeyTTL
+)
+
+// HostRequest is a request as signed by a tool host.
+type HostRequest struct {
+	HostID    string
+	Method    string
+	Path      string // Request URI, including the query string
+	Timestamp int64  // Unix seconds
+	Body      []byte
+	Signature string // Hex HMAC-SHA256 of HostSigningString
+}
+
+// HostSigningString returns what a host signs for a request: the method,
+// path, timestamp and hex SHA-256 of the body, one per line.
+func HostSigningString(method, path string, timestamp int64, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		strconv.FormatInt(timestamp, 10),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignHostRequest returns the hex HMAC-SHA256 signature of a request under
+// a host's secret.
+func SignHostRequest(secret, method, path string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(HostSigningString(method, path, timestamp, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HostService manages tool hosts and authenticates their signed requests.
+// Each signature is accepted once; it is remembered for twice the clock
+// skew, after which its timestamp is rejected anyway.
+type HostService struct {
+	store kvstore.Store
+	skew  time.Duration
+	now   func() time.Time
+
+	// Serializes read-modify-write of host records and the index
+	mu sync.Mutex
+}
+
+// NewHostService creates a host service accepting timestamps within skew of
+// the server clock. A zero skew uses DefaultHostSignatureSkew.
+func NewHostService(store kvstore.Store, skew time.Duration) *HostService {
+	if skew <= 0 {
+		skew = DefaultHostSignatureSkew
+	}
+	return &HostService{
+		store: store,
+		skew:  skew,
+		now:   time.Now,
+	}
+}
+
+// Register stores a new host and returns it with its secret, which is only
+// ever returned here and on rotate.
+func (s *HostService) Register(ctx context.Context, name, owner string, toolIDs []string) (*models.Host, string, error) {
+	for _, toolID := range toolIDs {
+		if toolID == "" {
+			return nil, "", fmt.Errorf("%w: empty tool ID", ErrInvalidHost)
+		}
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	host := &models.Host{
+		ID:        id,
+		Name:      name,
+		Owner:     strings.ToLower(owner),
+		Secret:    secret,
+		ToolIDs:   append([]string(nil), toolIDs...),
+		CreatedAt: s.now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.save(ctx, host); err != nil {
+		return nil, "", err
+	}
+	if err := s.store.Set(ctx, "hosts", append(s.hostIDs(ctx), id), hostTTL); err != nil {
+		return nil, "", fmt.Errorf("failed to index host: %w", err)
+	}
+	return copyHost(host), secret, nil
+}
+
+// Get returns a host by id, revoked or not.
+func (s *HostService) Get(ctx context.Context, id string) (*models.Host, error) {
+	host, ok := s.load(ctx, id)
+	if !ok {
+		return nil, ErrHostNotFound
+	}
+	return copyHost(host), nil
+}
+
+// List returns every registered host, oldest first.
+func (s *HostService) List(ctx context.Context) []*models.Host {
+	hosts := []*models.Host{}
+	for _, id := range s.hostIDs(ctx) {
+		if host, ok := s.load(ctx, id); ok {
+			hosts = append(hosts, copyHost(host))
+		}
+	}
+	return hosts
+}
+
+// Rotate replaces the secret of a host. Requests signed with the old
+// secret stop working immediately.
+func (s *HostService) Rotate(ctx context.Context, id string) (*models.Host, string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host, ok := s.load(ctx, id)
+	if !ok || host.RevokedAt != nil {
+		return nil, "", ErrHostNotFound
+	}
+
+	rotated := copyHost(host)
+	now := s.now().UTC()
+	rotated.Secret = secret
+	rotated.RotatedAt = &now
+	if err := s.save(ctx, rotated); err != nil {
+		return nil, "", err
+	}
+	return copyHost(rotated), secret, nil
+}
+
+// Revoke disables a host for good.
+func (s *HostService) Revoke(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host, ok := s.load(ctx, id)
+	if !ok {
+		return ErrHostNotFound
+	}
+	if host.RevokedAt != nil {
+		return nil
+	}
+
+	revoked := copyHost(host)
+	now := s.now().UTC()
+	revoked.RevokedAt = &now
+	return s.save(ctx, revoked)
+}
+
+// Authenticate checks a request's signature and timestamp, and accepts
+// each signature only once. The signature is checked first, so a forged
+// request cannot use up someone else's.
+func (s *HostService) Authenticate(ctx context.Context, req HostRequest) (*models.Host, error) {
+	host, ok := s.load(ctx, req.HostID)
+	if !ok || host.RevokedAt != nil {
+		return nil, ErrInvalidHostSignature
+	}
+
+	expected := SignHostRequest(host.Secret, req.Method, req.Path, req.Timestamp, req.Body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(req.Signature))) {
+		return nil, ErrInvalidHostSignature
+	}
+
+	skew := s.now().Sub(time.Unix(req.Timestamp, 0))
+	if skew > s.skew || skew < -s.skew {
+		return nil, ErrStaleHostRequest
+	}
+
+	uses, err := s.store.Increment(ctx, "host:seen:"+host.ID+":"+expected, 1, 2*s.skew)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record host request: %w", err)
+	}
+	if uses != 1 {
+		return nil, ErrReplayedHostRequest
+	}
+	return copyHost(host), nil
+}
+
+func (s *HostService) load(ctx context.Context, id string) (*models.Host, bool) {
+	cached, found := s.store.Get(ctx, "host:"+id)
+	if !found {
+		return nil, false
+	}
+	host, ok := cached.(*models.Host)
+	return host, ok
+}
+
+// save stores a host record. Stored records are never mutated, so readers
+// can hold on to what load returned.
+func (s *HostService) save(ctx context.Context, host *models.Host) error {
+	if err := s.store.Set(ctx, "host:"+host.ID, host, hostTTL); err != nil {
+		return fmt.Errorf("failed to store host: %w", err)
+	}
+	return nil
+}
+
+func (s *HostService) hostIDs(ctx context.Context) []string {
+	cached, found := s.store.Get(ctx, "hosts")
+	if !found {
+		return nil
+	}
+	ids, _ := cached.([]string)
+	return append([]string(nil), ids...)
+}
+
+func copyHost(host *models.Host) *models.Host {
+	c := *host
+	c.ToolIDs = append([]string(nil), host.ToolIDs...)
+	return &c
+}
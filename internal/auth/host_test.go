@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"moltket/internal/kvstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostService(t *testing.T) {
+	ctx := context.Background()
+	body := []byte(`{"tool_id":"1"}`)
+
+	newService := func(t *testing.T) *HostService {
+		store := kvstore.NewMemoryStore(time.Minute)
+		t.Cleanup(func() { store.Close() })
+		return NewHostService(store, time.Minute)
+	}
+	signed := func(hostID, secret string, timestamp int64, body []byte) HostRequest {
+		return HostRequest{
+			HostID:    hostID,
+			Method:    "POST",
+			Path:      "/api/v1/verify",
+			Timestamp: timestamp,
+			Body:      body,
+			Signature: SignHostRequest(secret, "POST", "/api/v1/verify", timestamp, body),
+		}
+	}
+
+	t.Run("SigningString", func(t *testing.T) {
+		assert.Equal(t,
+			"POST\n/api/v1/verify?x=1\n1700000000\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			HostSigningString("post", "/api/v1/verify?x=1", 1700000000, nil))
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		service := newService(t)
+		host, secret, err := service.Register(ctx, "host", "0xF39Fd6e51aad88F6F4ce6aB8827279cffFb92266", []string{"1"})
+		require.NoError(t, err)
+		assert.Equal(t, "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266", host.Owner)
+		assert.Len(t, service.List(ctx), 1)
+
+		now := time.Now().Unix()
+		authenticated, err := service.Authenticate(ctx, signed(host.ID, secret, now, body))
+		require.NoError(t, err)
+		assert.Equal(t, host.ID, authenticated.ID)
+
+		// Each signature is accepted once
+		_, err = service.Authenticate(ctx, signed(host.ID, secret, now, body))
+		assert.ErrorIs(t, err, ErrReplayedHostRequest)
+
+		tampered := signed(host.ID, secret, now+1, body)
+		tampered.Body = []byte(`{"tool_id":"2"}`)
+		_, err = service.Authenticate(ctx, tampered)
+		assert.ErrorIs(t, err, ErrInvalidHostSignature)
+
+		_, err = service.Authenticate(ctx, signed(host.ID, "wrong-secret", now+2, body))
+		assert.ErrorIs(t, err, ErrInvalidHostSignature)
+		_, err = service.Authenticate(ctx, signed("unknown", secret, now+3, body))
+		assert.ErrorIs(t, err, ErrInvalidHostSignature)
+	})
+
+	t.Run("ClockSkew", func(t *testing.T) {
+		service := newService(t)
+		host, secret, err := service.Register(ctx, "host", "", nil)
+		require.NoError(t, err)
+
+		now := time.Now()
+		_, err = service.Authenticate(ctx, signed(host.ID, secret, now.Add(-50*time.Second).Unix(), body))
+		assert.NoError(t, err)
+		_, err = service.Authenticate(ctx, signed(host.ID, secret, now.Add(50*time.Second).Unix(), body))
+		assert.NoError(t, err)
+		_, err = service.Authenticate(ctx, signed(host.ID, secret, now.Add(-2*time.Minute).Unix(), body))
+		assert.ErrorIs(t, err, ErrStaleHostRequest)
+		_, err = service.Authenticate(ctx, signed(host.ID, secret, now.Add(2*time.Minute).Unix(), body))
+		assert.ErrorIs(t, err, ErrStaleHostRequest)
+	})
+
+	t.Run("RotateAndRevoke", func(t *testing.T) {
+		service := newService(t)
+		host, oldSecret, err := service.Register(ctx, "host", "", nil)
+		require.NoError(t, err)
+
+		rotated, newSecret, err := service.Rotate(ctx, host.ID)
+		require.NoError(t, err)
+		assert.NotEqual(t, oldSecret, newSecret)
+		assert.NotNil(t, rotated.RotatedAt)
+
+		now := time.Now().Unix()
+		_, err = service.Authenticate(ctx, signed(host.ID, oldSecret, now, body))
+		assert.ErrorIs(t, err, ErrInvalidHostSignature)
+		_, err = service.Authenticate(ctx, signed(host.ID, newSecret, now, body))
+		assert.NoError(t, err)
+
+		require.NoError(t, service.Revoke(ctx, host.ID))
+		_, err = service.Authenticate(ctx, signed(host.ID, newSecret, now+1, body))
+		assert.ErrorIs(t, err, ErrInvalidHostSignature)
+		_, _, err = service.Rotate(ctx, host.ID)
+		assert.ErrorIs(t, err, ErrHostNotFound)
+		assert.ErrorIs(t, service.Revoke(ctx, "unknown"), ErrHostNotFound)
+
+		_, _, err = service.Register(ctx, "host", "", []string{""})
+		assert.ErrorIs(t, err, ErrInvalidHost)
+	})
+}
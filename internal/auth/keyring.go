@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Signing key states. Exactly one key in a KeyRing is active.
+const (
+	// KeyActive signs new licenses and oracle updates.
+	KeyActive = "active"
+	// KeyVerifyOnly keys are accepted when verifying but never sign: keys
+	// staged ahead of an on-chain updateSigner, and keys rotated out whose
+	// signatures are still within their overlap window.
+	KeyVerifyOnly = "verify-only"
+	// KeyRetired keys are neither used nor accepted.
+	KeyRetired = "retired"
+)
+
+// DefaultKeyOverlap is how long a rotated-out key keeps verifying by default.
+const DefaultKeyOverlap = 24 * time.Hour
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrKeyExists        = errors.New("signing key already loaded")
+	ErrKeyRetired       = errors.New("signing key is retired")
+	ErrActiveKey        = errors.New("the active signing key cannot be retired")
+	ErrUnknownSignature = errors.New("signature is not from a known signing key")
+)
+
+// KeyID names a signing key. It is derived from the key's address, so the
+// same key has the same ID across restarts and replicas.
+func KeyID(address common.Address) string {
+	return hex.EncodeToString(crypto.Keccak256(address.Bytes())[:4])
+}
+
+// KeyInfo describes a key held by a KeyRing.
+type KeyInfo struct {
+	ID      string         `json:"id"`
+	Address common.Address `json:"address"`
+	State   string         `json:"state"`
+	AddedAt time.Time      `json:"added_at"`
+	// RetiresAt is when a rotated-out key stops verifying
+	RetiresAt *time.Time `json:"retires_at,omitempty"`
+}
+
+type ringKey struct {
+	signer *EIP712Signer
+	info   KeyInfo
+}
+
+// KeyRing holds the backend's signing keys. One key is active and signs;
+// the others verify until they are retired, so signatures handed out before
+// a rotation stay checkable while the new key takes over.
+type KeyRing struct {
+	mu      sync.Mutex
+	keys    []*ringKey
+	active  *ringKey
+	overlap time.Duration
+	now     func() time.Time
+}
+
+// NewKeyRing creates a key ring with active as the signing key. Keys rotated
+// out stay verify-only for overlap before they retire.
+func NewKeyRing(active *EIP712Signer, overlap time.Duration) *KeyRing {
+	r := &KeyRing{
+		overlap: overlap,
+		now:     time.Now,
+	}
+	r.active = &ringKey{
+		signer: active,
+		info: KeyInfo{
+			ID:      active.KeyID(),
+			Address: active.Address(),
+			State:   KeyActive,
+			AddedAt: r.now(),
+		},
+	}
+	r.keys = []*ringKey{r.active}
+	return r
+}
+
+// SetOverlap changes the overlap window of keys rotated out from now on.
+func (r *KeyRing) SetOverlap(overlap time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overlap = overlap
+}
+
+// Add stages signer as a verify-only key, to be activated once it is the
+// trusted signer on-chain.
+func (r *KeyRing) Add(signer *EIP712Signer) (KeyInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+
+	if existing := r.find(signer.KeyID()); existing != nil {
+		if existing.info.State == KeyRetired {
+			return existing.info, fmt.Errorf("%w: %s", ErrKeyRetired, existing.info.ID)
+		}
+		return existing.info, fmt.Errorf("%w: %s", ErrKeyExists, existing.info.ID)
+	}
+
+	key := &ringKey{
+		signer: signer,
+		info: KeyInfo{
+			ID:      signer.KeyID(),
+			Address: signer.Address(),
+			State:   KeyVerifyOnly,
+			AddedAt: r.now(),
+		},
+	}
+	r.keys = append(r.keys, key)
+	return key.info, nil
+}
+
+// Active returns the signing key.
+func (r *KeyRing) Active() *EIP712Signer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active.signer
+}
+
+// Get returns the key with the given ID.
+func (r *KeyRing) Get(id string) (KeyInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+
+	key := r.find(id)
+	if key == nil {
+		return KeyInfo{}, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return key.info, nil
+}
+
+// Lookup returns the non-retired key of address, if the ring holds one.
+func (r *KeyRing) Lookup(address common.Address) (KeyInfo, bool) {
+	info, err := r.Get(KeyID(address))
+	if err != nil || info.State == KeyRetired {
+		return KeyInfo{}, false
+	}
+	return info, true
+}
+
+// Activate makes the key with the given ID the signing key. The previously
+// active key becomes verify-only for the overlap window.
+func (r *KeyRing) Activate(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+
+	key := r.find(id)
+	switch {
+	case key == nil:
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	case key.info.State == KeyRetired:
+		return fmt.Errorf("%w: %s", ErrKeyRetired, id)
+	case key == r.active:
+		return nil
+	}
+
+	retiresAt := r.now().Add(r.overlap)
+	r.active.info.State = KeyVerifyOnly
+	r.active.info.RetiresAt = &retiresAt
+
+	key.info.State = KeyActive
+	key.info.RetiresAt = nil
+	r.active = key
+	return nil
+}
+
+// Retire stops accepting the key with the given ID ahead of its overlap
+// window, e.g. when it is compromised. Retired keys cannot come back.
+func (r *KeyRing) Retire(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+
+	key := r.find(id)
+	switch {
+	case key == nil:
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	case key == r.active:
+		return ErrActiveKey
+	}
+	r.retire(key)
+	return nil
+}
+
+// Keys lists the keys in the order they were loaded.
+func (r *KeyRing) Keys() []KeyInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+
+	keys := make([]KeyInfo, len(r.keys))
+	for i, key := range r.keys {
+		keys[i] = key.info
+	}
+	return keys
+}
+
+// Verify returns the key that signed a message of a type registered in
+// Types. Any non-retired key is accepted.
+func (r *KeyRing) Verify(primaryType string, message apitypes.TypedDataMessage, signature []byte) (KeyInfo, error) {
+	r.mu.Lock()
+	r.expire()
+	keys := make([]ringKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.info.State != KeyRetired {
+			keys = append(keys, *key)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, key := range keys {
+		recovered, err := key.signer.Recover(primaryType, message, signature)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		if recovered == key.info.Address {
+			return key.info, nil
+		}
+	}
+	return KeyInfo{}, ErrUnknownSignature
+}
+
+// VerifyLicense returns the key that signed a MintLicense signature.
+func (r *KeyRing) VerifyLicense(user common.Address, toolID, expiresAt, nonce *big.Int, signature []byte) (KeyInfo, error) {
+	return r.Verify("MintLicense", licenseMessage(user, toolID, expiresAt, nonce), signature)
+}
+
+func (r *KeyRing) find(id string) *ringKey {
+	for _, key := range r.keys {
+		if key.info.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// expire retires verify-only keys whose overlap window has passed.
+func (r *KeyRing) expire() {
+	now := r.now()
+	for _, key := range r.keys {
+		if key.info.State == KeyVerifyOnly && key.info.RetiresAt != nil && !now.Before(*key.info.RetiresAt) {
+			r.retire(key)
+		}
+	}
+}
+
+func (r *KeyRing) retire(key *ringKey) {
+	retiredAt := r.now()
+	key.info.State = KeyRetired
+	key.info.RetiresAt = &retiredAt
+}
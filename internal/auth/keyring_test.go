@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRing(t *testing.T) {
+	chainID := big.NewInt(31337)
+	newSigner := func(t *testing.T) *EIP712Signer {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		return NewEIP712Signer(NewKeySignerFromECDSA(key), chainID, testLicenseContract)
+	}
+
+	toolID, expiresAt, nonce := big.NewInt(42), big.NewInt(1735689600), big.NewInt(7)
+	sign := func(t *testing.T, signer *EIP712Signer) []byte {
+		t.Helper()
+		r, s, v, err := signer.CreateLicenseSignature(testLicenseUser, toolID, expiresAt, nonce)
+		require.NoError(t, err)
+		return append(append(append([]byte(nil), r...), s...), v...)
+	}
+
+	t.Run("Rotation", func(t *testing.T) {
+		old, next := newSigner(t), newSigner(t)
+		now := time.Now()
+		ring := NewKeyRing(old, time.Hour)
+		ring.now = func() time.Time { return now }
+
+		oldSignature := sign(t, old)
+		nextSignature := sign(t, next)
+
+		// Staged keys verify but do not sign
+		staged, err := ring.Add(next)
+		require.NoError(t, err)
+		assert.Equal(t, KeyVerifyOnly, staged.State)
+		assert.Equal(t, next.KeyID(), staged.ID)
+		assert.Equal(t, old, ring.Active())
+
+		key, err := ring.VerifyLicense(testLicenseUser, toolID, expiresAt, nonce, nextSignature)
+		require.NoError(t, err)
+		assert.Equal(t, staged.ID, key.ID)
+
+		_, err = ring.Add(next)
+		assert.ErrorIs(t, err, ErrKeyExists)
+
+		// After activation the old key verifies through the overlap window
+		require.NoError(t, ring.Activate(staged.ID))
+		assert.Equal(t, next, ring.Active())
+
+		key, err = ring.VerifyLicense(testLicenseUser, toolID, expiresAt, nonce, oldSignature)
+		require.NoError(t, err)
+		assert.Equal(t, old.KeyID(), key.ID)
+		assert.Equal(t, KeyVerifyOnly, key.State)
+		require.NotNil(t, key.RetiresAt)
+		assert.Equal(t, now.Add(time.Hour), *key.RetiresAt)
+
+		// and is retired once it has passed
+		now = now.Add(time.Hour)
+		_, err = ring.VerifyLicense(testLicenseUser, toolID, expiresAt, nonce, oldSignature)
+		assert.ErrorIs(t, err, ErrUnknownSignature)
+
+		info, err := ring.Get(old.KeyID())
+		require.NoError(t, err)
+		assert.Equal(t, KeyRetired, info.State)
+
+		// Retired keys never come back
+		assert.ErrorIs(t, ring.Activate(old.KeyID()), ErrKeyRetired)
+		_, err = ring.Add(old)
+		assert.ErrorIs(t, err, ErrKeyRetired)
+		_, ok := ring.Lookup(old.Address())
+		assert.False(t, ok)
+	})
+
+	t.Run("Retire", func(t *testing.T) {
+		active, staged := newSigner(t), newSigner(t)
+		ring := NewKeyRing(active, DefaultKeyOverlap)
+		_, err := ring.Add(staged)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, ring.Retire(active.KeyID()), ErrActiveKey)
+		assert.ErrorIs(t, ring.Retire("unknown"), ErrUnknownKey)
+
+		require.NoError(t, ring.Retire(staged.KeyID()))
+		_, err = ring.VerifyLicense(testLicenseUser, toolID, expiresAt, nonce, sign(t, staged))
+		assert.ErrorIs(t, err, ErrUnknownSignature)
+
+		keys := ring.Keys()
+		require.Len(t, keys, 2)
+		assert.Equal(t, KeyActive, keys[0].State)
+		assert.Equal(t, KeyRetired, keys[1].State)
+	})
+
+	t.Run("KeyIDIsStable", func(t *testing.T) {
+		signer := newSigner(t)
+		assert.Len(t, signer.KeyID(), 8)
+		assert.Equal(t, signer.KeyID(), KeyID(signer.Address()))
+		assert.Equal(t, signer.KeyID(), signer.WithBackend(signer.backend).KeyID())
+	})
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ErrInvalidRole is returned when granting a role that does not exist or
+	// cannot be granted.
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrInvalidSubject is returned for subjects that are neither an address
+	// nor an API key.
+	ErrInvalidSubject = errors.New("invalid role subject")
+
+	// ErrRoleNotFound is returned when revoking a role the subject lacks.
+	ErrRoleNotFound = errors.New("role binding not found")
+)
+
+// Role bindings do not expire; they are revoked explicitly
+const roleBindingTTL = 100 * 365 * 24 * time.Hour
+
+// Roles that can be bound. Tool ownership is not granted here: it is read
+// from StakingNFT, so it follows the NFT.
+var grantableRoles = map[string]bool{
+	models.RoleAdmin:    true,
+	models.RoleOperator: true,
+	models.RoleAuditor:  true,
+}
+
+// AddressSubject returns the role binding subject of an address.
+func AddressSubject(address common.Address) string {
+	return models.SubjectAddress + ":" + strings.ToLower(address.Hex())
+}
+
+// APIKeySubject returns the role binding subject of an API key.
+func APIKeySubject(id string) string {
+	return models.SubjectAPIKey + ":" + id
+}
+
+// ParseSubject normalizes a subject given as "address:0x…", "apikey:<id>"
+// or a bare address.
+func ParseSubject(subject string) (string, error) {
+	kind, id, found := strings.Cut(subject, ":")
+	if !found {
+		kind, id = models.SubjectAddress, subject
+	}
+	switch {
+	case kind == models.SubjectAddress && common.IsHexAddress(id):
+		return AddressSubject(common.HexToAddress(id)), nil
+	case kind == models.SubjectAPIKey && id != "":
+		return APIKeySubject(id), nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidSubject, subject)
+}
+
+// RoleService stores role bindings in the kvstore, one record per subject
+// at "roles:<subject>" plus an index of subjects at "roles:subjects".
+// The in-memory store loses them on restart; with a journal (see
+// UseJournal) every change is also written to disk and replayed at startup.
+type RoleService struct {
+	store   kvstore.Store
+	journal *kvstore.Journal
+	now     func() time.Time
+
+	// Serializes read-modify-write of binding records and the index
+	mu sync.Mutex
+}
+
+// NewRoleService creates a role service.
+func NewRoleService(store kvstore.Store) *RoleService {
+	return &RoleService{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// roleJournalRecord is a subject's bindings after a change.
+type roleJournalRecord struct {
+	Subject  string                `json:"subject"`
+	Bindings []*models.RoleBinding `json:"bindings"`
+}
+
+// UseJournal restores the role bindings recorded in journal and records
+// every later change there.
+func (s *RoleService) UseJournal(ctx context.Context, journal *kvstore.Journal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := journal.Replay(func(data json.RawMessage) error {
+		var record roleJournalRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("undecodable role journal record: %w", err)
+		}
+		if err := s.save(ctx, record.Subject, record.Bindings); err != nil {
+			return err
+		}
+		return s.index(ctx, record.Subject)
+	})
+	if err != nil {
+		return err
+	}
+	s.journal = journal
+	return nil
+}
+
+// Grant binds role to subject. Granting a role the subject already has
+// returns the existing binding.
+func (s *RoleService) Grant(ctx context.Context, subject, role, grantedBy string) (*models.RoleBinding, error) {
+	subject, err := ParseSubject(subject)
+	if err != nil {
+		return nil, err
+	}
+	if !grantableRoles[role] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings := s.load(ctx, subject)
+	for _, binding := range bindings {
+		if binding.Role == role {
+			return copyRoleBinding(binding), nil
+		}
+	}
+
+	binding := &models.RoleBinding{
+		Subject:   subject,
+		Role:      role,
+		GrantedBy: grantedBy,
+		GrantedAt: s.now().UTC(),
+	}
+	if err := s.record(ctx, subject, append(bindings, binding)); err != nil {
+		return nil, err
+	}
+	if err := s.index(ctx, subject); err != nil {
+		return nil, err
+	}
+	return copyRoleBinding(binding), nil
+}
+
+// Revoke removes role from subject.
+func (s *RoleService) Revoke(ctx context.Context, subject, role string) error {
+	subject, err := ParseSubject(subject)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings := s.load(ctx, subject)
+	kept := make([]*models.RoleBinding, 0, len(bindings))
+	for _, binding := range bindings {
+		if binding.Role != role {
+			kept = append(kept, binding)
+		}
+	}
+	if len(kept) == len(bindings) {
+		return ErrRoleNotFound
+	}
+	return s.record(ctx, subject, kept)
+}
+
+// Roles returns the roles bound to any of subjects. A nil service has no
+// bindings.
+func (s *RoleService) Roles(ctx context.Context, subjects ...string) []string {
+	if s == nil {
+		return nil
+	}
+
+	var roles []string
+	seen := make(map[string]bool)
+	for _, subject := range subjects {
+		for _, binding := range s.load(ctx, subject) {
+			if !seen[binding.Role] {
+				seen[binding.Role] = true
+				roles = append(roles, binding.Role)
+			}
+		}
+	}
+	return roles
+}
+
+// List returns every role binding, grouped by subject in the order subjects
+// were first granted a role.
+func (s *RoleService) List(ctx context.Context) []*models.RoleBinding {
+	bindings := []*models.RoleBinding{}
+	for _, subject := range s.subjects(ctx) {
+		for _, binding := range s.load(ctx, subject) {
+			bindings = append(bindings, copyRoleBinding(binding))
+		}
+	}
+	return bindings
+}
+
+func (s *RoleService) load(ctx context.Context, subject string) []*models.RoleBinding {
+	cached, found := s.store.Get(ctx, "roles:"+subject)
+	if !found {
+		return nil
+	}
+	bindings, _ := cached.([]*models.RoleBinding)
+	return bindings
+}
+
+// record journals and stores the bindings of subject.
+func (s *RoleService) record(ctx context.Context, subject string, bindings []*models.RoleBinding) error {
+	if s.journal != nil {
+		if err := s.journal.Append(&roleJournalRecord{Subject: subject, Bindings: bindings}); err != nil {
+			return fmt.Errorf("failed to record role binding: %w", err)
+		}
+	}
+	return s.save(ctx, subject, bindings)
+}
+
+// index adds subject to the subject index. Revoking a subject's last role
+// leaves it indexed.
+func (s *RoleService) index(ctx context.Context, subject string) error {
+	subjects := s.subjects(ctx)
+	for _, indexed := range subjects {
+		if indexed == subject {
+			return nil
+		}
+	}
+	if err := s.store.Set(ctx, "roles:subjects", append(subjects, subject), roleBindingTTL); err != nil {
+		return fmt.Errorf("failed to index role binding: %w", err)
+	}
+	return nil
+}
+
+func (s *RoleService) save(ctx context.Context, subject string, bindings []*models.RoleBinding) error {
+	if len(bindings) == 0 {
+		s.store.Delete(ctx, "roles:"+subject)
+		return nil
+	}
+	if err := s.store.Set(ctx, "roles:"+subject, bindings, roleBindingTTL); err != nil {
+		return fmt.Errorf("failed to store role binding: %w", err)
+	}
+	return nil
+}
+
+func (s *RoleService) subjects(ctx context.Context) []string {
+	cached, found := s.store.Get(ctx, "roles:subjects")
+	if !found {
+		return nil
+	}
+	subjects, _ := cached.([]string)
+	return subjects
+}
+
+func copyRoleBinding(binding *models.RoleBinding) *models.RoleBinding {
+	c := *binding
+	return &c
+}
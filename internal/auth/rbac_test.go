@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleService(t *testing.T) {
+	ctx := context.Background()
+	address := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	subject := AddressSubject(address)
+
+	newService := func(t *testing.T) *RoleService {
+		store := kvstore.NewMemoryStore(time.Minute)
+		t.Cleanup(func() { store.Close() })
+		return NewRoleService(store)
+	}
+
+	t.Run("ParseSubject", func(t *testing.T) {
+		for _, given := range []string{address.Hex(), "address:" + address.Hex(), subject} {
+			parsed, err := ParseSubject(given)
+			require.NoError(t, err, given)
+			assert.Equal(t, "address:0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266", parsed)
+		}
+
+		parsed, err := ParseSubject("apikey:1f2e3d4c")
+		require.NoError(t, err)
+		assert.Equal(t, APIKeySubject("1f2e3d4c"), parsed)
+
+		for _, given := range []string{"", "apikey:", "address:0x123", "user:0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"} {
+			_, err := ParseSubject(given)
+			assert.ErrorIs(t, err, ErrInvalidSubject, given)
+		}
+	})
+
+	t.Run("GrantAndRevoke", func(t *testing.T) {
+		service := newService(t)
+
+		binding, err := service.Grant(ctx, address.Hex(), models.RoleOperator, "admin-token")
+		require.NoError(t, err)
+		assert.Equal(t, subject, binding.Subject)
+		assert.Equal(t, "admin-token", binding.GrantedBy)
+		assert.False(t, binding.GrantedAt.IsZero())
+
+		// Granting twice keeps the original binding
+		again, err := service.Grant(ctx, subject, models.RoleOperator, "someone-else")
+		require.NoError(t, err)
+		assert.Equal(t, "admin-token", again.GrantedBy)
+
+		_, err = service.Grant(ctx, subject, models.RoleAuditor, "admin-token")
+		require.NoError(t, err)
+		assert.Equal(t, []string{models.RoleOperator, models.RoleAuditor}, service.Roles(ctx, subject))
+		assert.Len(t, service.List(ctx), 2)
+
+		require.NoError(t, service.Revoke(ctx, subject, models.RoleOperator))
+		assert.Equal(t, []string{models.RoleAuditor}, service.Roles(ctx, subject))
+		assert.ErrorIs(t, service.Revoke(ctx, subject, models.RoleOperator), ErrRoleNotFound)
+
+		require.NoError(t, service.Revoke(ctx, subject, models.RoleAuditor))
+		assert.Empty(t, service.Roles(ctx, subject))
+		assert.Empty(t, service.List(ctx))
+
+		// The subject is indexed once, even after being emptied and re-granted
+		_, err = service.Grant(ctx, subject, models.RoleAdmin, "admin-token")
+		require.NoError(t, err)
+		assert.Len(t, service.List(ctx), 1)
+	})
+
+	t.Run("RolesAcrossSubjects", func(t *testing.T) {
+		service := newService(t)
+
+		_, err := service.Grant(ctx, subject, models.RoleOperator, "")
+		require.NoError(t, err)
+		_, err = service.Grant(ctx, "apikey:abcd", models.RoleOperator, "")
+		require.NoError(t, err)
+		_, err = service.Grant(ctx, "apikey:abcd", models.RoleAuditor, "")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{models.RoleOperator, models.RoleAuditor}, service.Roles(ctx, subject, APIKeySubject("abcd")))
+		assert.Empty(t, service.Roles(ctx, APIKeySubject("other")))
+
+		var nilService *RoleService
+		assert.Empty(t, nilService.Roles(ctx, subject))
+	})
+
+	t.Run("InvalidRole", func(t *testing.T) {
+		service := newService(t)
+
+		for _, role := range []string{"", "root", models.RoleToolOwner} {
+			_, err := service.Grant(ctx, subject, role, "")
+			assert.ErrorIs(t, err, ErrInvalidRole, role)
+		}
+		_, err := service.Grant(ctx, "nobody", models.RoleAdmin, "")
+		assert.ErrorIs(t, err, ErrInvalidSubject)
+	})
+	t.Run("Journal", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "roles.jsonl")
+		restart := func(t *testing.T) (*RoleService, *kvstore.Journal) {
+			service := newService(t)
+			journal, err := kvstore.OpenJournal(path)
+			require.NoError(t, err)
+			t.Cleanup(func() { journal.Close() })
+			require.NoError(t, service.UseJournal(ctx, journal))
+			return service, journal
+		}
+
+		service, journal := restart(t)
+		_, err := service.Grant(ctx, subject, models.RoleAdmin, "admin-token")
+		require.NoError(t, err)
+		_, err = service.Grant(ctx, "apikey:abcd", models.RoleOperator, subject)
+		require.NoError(t, err)
+		_, err = service.Grant(ctx, "apikey:abcd", models.RoleAuditor, subject)
+		require.NoError(t, err)
+		require.NoError(t, service.Revoke(ctx, "apikey:abcd", models.RoleOperator))
+		require.NoError(t, journal.Close())
+
+		restored, _ := restart(t)
+		assert.Equal(t, []string{models.RoleAdmin}, restored.Roles(ctx, subject))
+		assert.Equal(t, []string{models.RoleAuditor}, restored.Roles(ctx, APIKeySubject("abcd")))
+		bindings := restored.List(ctx)
+		require.Len(t, bindings, 2)
+		assert.Equal(t, subject, bindings[0].Subject)
+		assert.Equal(t, "admin-token", bindings[0].GrantedBy)
+	})
+}
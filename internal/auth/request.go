@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// VerifyRequestType is the EIP-712 type users sign to authenticate a
+// /verify call. The nonce comes from the backend and can be used once.
+const VerifyRequestType = "VerifyRequest(address user,string toolId,bytes32 bodyHash,string nonce)"
+
+// VerifyRequest is the message signed for one /verify call. BodyHash is the
+// keccak256 of the exact request body, so the signature covers the license
+// being checked and cannot be moved to another request.
+type VerifyRequest struct {
+	User     common.Address
+	ToolID   string
+	BodyHash common.Hash
+	Nonce    string
+}
+
+// NewVerifyRequest builds the message for a request with the given body.
+func NewVerifyRequest(user common.Address, toolID string, body []byte, nonce string) *VerifyRequest {
+	return &VerifyRequest{
+		User:     user,
+		ToolID:   toolID,
+		BodyHash: crypto.Keccak256Hash(body),
+		Nonce:    nonce,
+	}
+}
+
+// RequestDomainName names the backend API in the domain requests are signed
+// under, so request signatures can never be valid license or vote signatures.
+const RequestDomainName = "SkillChainVerification"
+
+// RequestDomain returns the domain /verify requests are signed under. It is
+// bound to the deployment's LicenseNFT, so a signature for one deployment is
+// useless against another.
+func RequestDomain(chainID *big.Int, licenseContract common.Address) apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name:              RequestDomainName,
+		Version:           "1",
+		ChainId:           (*math.HexOrDecimal256)(new(big.Int).Set(chainID)),
+		VerifyingContract: licenseContract.Hex(),
+	}
+}
+
+// Message returns the request as an EIP-712 message of type VerifyRequest.
+func (r *VerifyRequest) Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"user":     r.User.Hex(),
+		"toolId":   r.ToolID,
+		"bodyHash": r.BodyHash.Hex(),
+		"nonce":    r.Nonce,
+	}
+}
+
+// HashVerifyRequest returns the EIP-712 digest of req under domain.
+func HashVerifyRequest(domain apitypes.TypedDataDomain, req *VerifyRequest) (common.Hash, error) {
+	return Types.Hash(domain, "VerifyRequest", req.Message())
+}
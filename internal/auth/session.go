@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"moltket/internal/kvstore"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrInvalidNonce is returned when a sign-in message uses a nonce this
+	// server did not issue, or one that was already used or has expired.
+	ErrInvalidNonce = errors.New("invalid or expired sign-in nonce")
+
+	// ErrInvalidToken is returned for access or refresh tokens that are
+	// malformed, expired, revoked or signed with another secret.
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// How long an issued sign-in nonce may be used
+const siweNonceTTL = 10 * time.Minute
+
+// Shortest JWT secret accepted: 256 bits, the size of the HS256 key
+const minSecretLength = 32
+
+// Placeholder JWT secrets from sample configs; anyone can forge tokens
+// signed with them
+var placeholderSecrets = map[string]bool{
+	"your-secret-key-change-in-production": true,
+}
+
+// SessionConfig configures SIWE login and the tokens it issues.
+type SessionConfig struct {
+	Secret          []byte // HMAC key for access tokens
+	Domain          string // Expected SIWE domain, also the token issuer
+	URI             string // Expected SIWE URI
+	ChainID         int64
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// SessionClaims are the claims of an access token. The subject is the
+// checksummed address that signed in.
+type SessionClaims struct {
+	ChainID int64 `json:"chain_id"`
+	jwt.RegisteredClaims
+}
+
+// Address returns the address the token was issued to.
+func (c *SessionClaims) Address() common.Address {
+	return common.HexToAddress(c.Subject)
+}
+
+// TokenPair is returned on login and refresh.
+type TokenPair struct {
+	AccessToken      string         `json:"access_token"`
+	TokenType        string         `json:"token_type"` // Always "Bearer"
+	ExpiresAt        time.Time      `json:"expires_at"`
+	RefreshToken     string         `json:"refresh_token"`
+	RefreshExpiresAt time.Time      `json:"refresh_expires_at"`
+	Address          common.Address `json:"address"`
+}
+
+// refreshSession is what a refresh token is bound to.
+type refreshSession struct {
+	Address   common.Address
+	ChainID   int64
+	ExpiresAt time.Time
+}
+
+// SessionManager implements Sign-In With Ethereum: it issues single-use
+// nonces, verifies signed EIP-4361 messages and issues short-lived JWT access
+// tokens plus refresh tokens. Refresh tokens are opaque, stored hashed, and
+// rotated on every use.
+type SessionManager struct {
+	config SessionConfig
+	store  kvstore.Store
+	now    func() time.Time
+}
+
+// NewSessionManager creates a session manager. Empty, short and
+// placeholder secrets are rejected, since they would let anyone mint access
+// tokens.
+func NewSessionManager(cfg SessionConfig, store kvstore.Store) (*SessionManager, error) {
+	if len(cfg.Secret) == 0 {
+		return nil, fmt.Errorf("JWT secret is required")
+	}
+	if placeholderSecrets[string(cfg.Secret)] {
+		return nil, fmt.Errorf("JWT secret is a published placeholder")
+	}
+	if len(cfg.Secret) < minSecretLength {
+		return nil, fmt.Errorf("JWT secret must be at least %d bytes", minSecretLength)
+	}
+	if cfg.AccessTokenTTL <= 0 || cfg.RefreshTokenTTL <= 0 {
+		return nil, fmt.Errorf("token lifetimes must be positive")
+	}
+	return &SessionManager{config: cfg, store: store, now: time.Now}, nil
+}
+
+// IssueNonce returns a new single-use nonce for a sign-in message.
+func (m *SessionManager) IssueNonce(ctx context.Context) (string, error) {
+	nonce, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	if err := m.store.Set(ctx, "siwe:nonce:"+nonce, true, siweNonceTTL); err != nil {
+		return "", fmt.Errorf("failed to store nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// Login verifies a signed sign-in message and starts a session for the
+// address that signed it. The nonce is consumed even if verification fails
+// afterwards, so a message can only be tried once.
+func (m *SessionManager) Login(ctx context.Context, message string, signature []byte) (*TokenPair, error) {
+	msg, err := ParseSIWEMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := msg.Validate(SIWEExpectations{
+		Domain:  m.config.Domain,
+		URI:     m.config.URI,
+		ChainID: m.config.ChainID,
+	}, m.now()); err != nil {
+		return nil, err
+	}
+
+	nonceKey := "siwe:nonce:" + msg.Nonce
+	if _, found := m.store.Get(ctx, nonceKey); !found {
+		return nil, ErrInvalidNonce
+	}
+
+	// Only the first login to claim the nonce gets through
+	uses, err := m.store.Increment(ctx, nonceKey+":uses", 1, siweNonceTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume nonce: %w", err)
+	}
+	if uses != 1 {
+		return nil, ErrInvalidNonce
+	}
+	m.store.Delete(ctx, nonceKey)
+
+	signer, err := RecoverSIWESigner(message, signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSIWEMessage, err)
+	}
+	if signer != msg.Address {
+		return nil, fmt.Errorf("%w: signed by %s, not %s", ErrInvalidSIWEMessage, signer.Hex(), msg.Address.Hex())
+	}
+
+	return m.issue(ctx, msg.Address, msg.ChainID)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The old refresh
+// token stops working.
+func (m *SessionManager) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	key := refreshKey(refreshToken)
+	cached, found := m.store.Get(ctx, key)
+	if !found {
+		return nil, ErrInvalidToken
+	}
+	session, ok := cached.(*refreshSession)
+	if !ok || !m.now().Before(session.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	m.store.Delete(ctx, key)
+
+	return m.issue(ctx, session.Address, session.ChainID)
+}
+
+// Logout revokes a refresh token. Access tokens stay valid until they expire.
+func (m *SessionManager) Logout(ctx context.Context, refreshToken string) error {
+	return m.store.Delete(ctx, refreshKey(refreshToken))
+}
+
+// ParseAccessToken verifies an access token and returns its claims.
+func (m *SessionManager) ParseAccessToken(token string) (*SessionClaims, error) {
+	claims := &SessionClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return m.config.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.config.Domain),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if !common.IsHexAddress(claims.Subject) {
+		return nil, fmt.Errorf("%w: subject is not an address", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func (m *SessionManager) issue(ctx context.Context, address common.Address, chainID int64) (*TokenPair, error) {
+	now := m.now()
+	expiresAt := now.Add(m.config.AccessTokenTTL)
+
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &SessionClaims{
+		ChainID: chainID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.config.Domain,
+			Subject:   address.Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        tokenID,
+		},
+	}).SignedString(m.config.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	refreshExpiresAt := now.Add(m.config.RefreshTokenTTL)
+	session := &refreshSession{Address: address, ChainID: chainID, ExpiresAt: refreshExpiresAt}
+	if err := m.store.Set(ctx, refreshKey(refreshToken), session, m.config.RefreshTokenTTL); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+		Address:          address,
+	}, nil
+}
+
+// refreshKey stores refresh tokens by hash, so the store never holds a
+// usable token.
+func refreshKey(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return "session:refresh:" + hex.EncodeToString(hash[:])
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"moltket/internal/kvstore"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSessionConfig = SessionConfig{
+	Secret:          []byte("test-secret-0123456789abcdef0123456789"),
+	Domain:          "app.skillchain.xyz",
+	URI:             "https://app.skillchain.xyz",
+	ChainID:         31337,
+	AccessTokenTTL:  15 * time.Minute,
+	RefreshTokenTTL: 24 * time.Hour,
+}
+
+// signSIWE personal_signs the message as a wallet would.
+func signSIWE(t *testing.T, key *ecdsa.PrivateKey, message string) []byte {
+	t.Helper()
+
+	signature, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	require.NoError(t, err)
+	signature[64] += 27
+	return signature
+}
+
+func newSIWEMessage(key *ecdsa.PrivateKey, nonce string) *SIWEMessage {
+	expiresAt := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	return &SIWEMessage{
+		Domain:         testSessionConfig.Domain,
+		Address:        crypto.PubkeyToAddress(key.PublicKey),
+		Statement:      "Sign in to SkillChain.",
+		URI:            testSessionConfig.URI,
+		Version:        "1",
+		ChainID:        testSessionConfig.ChainID,
+		Nonce:          nonce,
+		IssuedAt:       time.Now().Truncate(time.Second),
+		ExpirationTime: &expiresAt,
+	}
+}
+
+func TestParseSIWEMessage(t *testing.T) {
+	t.Run("SpecExample", func(t *testing.T) {
+		text := "service.org wants you to sign in with your Ethereum account:\n" +
+			"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266\n" +
+			"\n" +
+			"I accept the ServiceOrg Terms of Service: https://service.org/tos\n" +
+			"\n" +
+			"URI: https://service.org/login\n" +
+			"Version: 1\n" +
+			"Chain ID: 1\n" +
+			"Nonce: 32891756\n" +
+			"Issued At: 2021-09-30T16:25:24Z\n" +
+			"Resources:\n" +
+			"- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/\n" +
+			"- https://example.com/my-web2-claim.json"
+
+		m, err := ParseSIWEMessage(text)
+		require.NoError(t, err)
+		assert.Equal(t, "service.org", m.Domain)
+		assert.Equal(t, "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", m.Address.Hex())
+		assert.Equal(t, "I accept the ServiceOrg Terms of Service: https://service.org/tos", m.Statement)
+		assert.Equal(t, "https://service.org/login", m.URI)
+		assert.Equal(t, int64(1), m.ChainID)
+		assert.Equal(t, "32891756", m.Nonce)
+		assert.Len(t, m.Resources, 2)
+		assert.Nil(t, m.ExpirationTime)
+
+		// Formatting gives back the signed text
+		assert.Equal(t, text, m.String())
+	})
+
+	t.Run("WithoutStatement", func(t *testing.T) {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		m := newSIWEMessage(key, "abcdef123456")
+		m.Statement = ""
+
+		parsed, err := ParseSIWEMessage(m.String())
+		require.NoError(t, err)
+		assert.Equal(t, m.String(), parsed.String())
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		valid := newSIWEMessage(key, "abcdef123456").String()
+
+		for name, text := range map[string]string{
+			"LowercaseAddress": replaceLine(valid, 1, strings.ToLower(crypto.PubkeyToAddress(key.PublicKey).Hex())),
+			"ShortNonce":       replaceField(valid, "Nonce: ", "abc"),
+			"BadVersion":       replaceField(valid, "Version: ", "2"),
+			"BadTime":          replaceField(valid, "Issued At: ", "yesterday"),
+			"TrailingGarbage":  valid + "\nExtra: field",
+			"Empty":            "",
+		} {
+			_, err := ParseSIWEMessage(text)
+			assert.ErrorIs(t, err, ErrInvalidSIWEMessage, name)
+		}
+	})
+}
+
+func replaceLine(text string, index int, line string) string {
+	lines := strings.Split(text, "\n")
+	lines[index] = line
+	return strings.Join(lines, "\n")
+}
+
+func replaceField(text, prefix, value string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			lines[i] = prefix + value
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestSessionManager(t *testing.T) {
+	ctx := context.Background()
+
+	newManager := func(t *testing.T) *SessionManager {
+		store := kvstore.NewMemoryStore(time.Minute)
+		t.Cleanup(func() { store.Close() })
+		manager, err := NewSessionManager(testSessionConfig, store)
+		require.NoError(t, err)
+		return manager
+	}
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	login := func(t *testing.T, manager *SessionManager, edit func(m *SIWEMessage)) (*TokenPair, error) {
+		nonce, err := manager.IssueNonce(ctx)
+		require.NoError(t, err)
+		m := newSIWEMessage(key, nonce)
+		if edit != nil {
+			edit(m)
+		}
+		text := m.String()
+		return manager.Login(ctx, text, signSIWE(t, key, text))
+	}
+
+	t.Run("LoginAndRefresh", func(t *testing.T) {
+		manager := newManager(t)
+
+		tokens, err := login(t, manager, nil)
+		require.NoError(t, err)
+		assert.Equal(t, address, tokens.Address)
+		assert.Equal(t, "Bearer", tokens.TokenType)
+
+		claims, err := manager.ParseAccessToken(tokens.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, address, claims.Address())
+		assert.Equal(t, int64(31337), claims.ChainID)
+
+		refreshed, err := manager.Refresh(ctx, tokens.RefreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, address, refreshed.Address)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+		// Refresh tokens are single-use
+		_, err = manager.Refresh(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+
+		require.NoError(t, manager.Logout(ctx, refreshed.RefreshToken))
+		_, err = manager.Refresh(ctx, refreshed.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("NonceIsSingleUse", func(t *testing.T) {
+		manager := newManager(t)
+
+		nonce, err := manager.IssueNonce(ctx)
+		require.NoError(t, err)
+		text := newSIWEMessage(key, nonce).String()
+
+		_, err = manager.Login(ctx, text, signSIWE(t, key, text))
+		require.NoError(t, err)
+		_, err = manager.Login(ctx, text, signSIWE(t, key, text))
+		assert.ErrorIs(t, err, ErrInvalidNonce)
+
+		unknown := newSIWEMessage(key, "notissued123").String()
+		_, err = manager.Login(ctx, unknown, signSIWE(t, key, unknown))
+		assert.ErrorIs(t, err, ErrInvalidNonce)
+
+		// Concurrent logins with one captured message: only one succeeds
+		nonce, err = manager.IssueNonce(ctx)
+		require.NoError(t, err)
+		text = newSIWEMessage(key, nonce).String()
+		signature := signSIWE(t, key, text)
+
+		var wg sync.WaitGroup
+		var succeeded atomic.Int32
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := manager.Login(ctx, text, signature); err == nil {
+					succeeded.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), succeeded.Load())
+	})
+
+	t.Run("RejectsMismatches", func(t *testing.T) {
+		manager := newManager(t)
+
+		for name, edit := range map[string]func(m *SIWEMessage){
+			"Domain":  func(m *SIWEMessage) { m.Domain = "evil.example" },
+			"URI":     func(m *SIWEMessage) { m.URI = "https://evil.example" },
+			"ChainID": func(m *SIWEMessage) { m.ChainID = 1 },
+			"Expired": func(m *SIWEMessage) {
+				expired := time.Now().Add(-time.Minute)
+				m.ExpirationTime = &expired
+			},
+			"NotBefore": func(m *SIWEMessage) {
+				later := time.Now().Add(time.Hour)
+				m.NotBefore = &later
+			},
+		} {
+			_, err := login(t, manager, edit)
+			assert.ErrorIs(t, err, ErrInvalidSIWEMessage, name)
+		}
+	})
+
+	t.Run("RejectsOtherSigner", func(t *testing.T) {
+		manager := newManager(t)
+		other, err := crypto.GenerateKey()
+		require.NoError(t, err)
+
+		nonce, err := manager.IssueNonce(ctx)
+		require.NoError(t, err)
+		text := newSIWEMessage(key, nonce).String()
+
+		_, err = manager.Login(ctx, text, signSIWE(t, other, text))
+		assert.ErrorIs(t, err, ErrInvalidSIWEMessage)
+	})
+
+	t.Run("AccessTokenExpiresAndIsBoundToSecret", func(t *testing.T) {
+		manager := newManager(t)
+		tokens, err := login(t, manager, nil)
+		require.NoError(t, err)
+
+		manager.now = func() time.Time { return time.Now().Add(16 * time.Minute) }
+		_, err = manager.ParseAccessToken(tokens.AccessToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+
+		other := *manager
+		other.config.Secret = []byte("other-secret-0123456789abcdef012345678")
+		other.now = time.Now
+		_, err = other.ParseAccessToken(tokens.AccessToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+
+		// Tokens without a signature are never accepted
+		unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, &SessionClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    testSessionConfig.Domain,
+				Subject:   address.Hex(),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		_, err = other.ParseAccessToken(unsigned)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("RequiresSecret", func(t *testing.T) {
+		cfg := testSessionConfig
+		cfg.Secret = nil
+		_, err := NewSessionManager(cfg, kvstore.NewMemoryStore(time.Minute))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewSessionManagerSecret(t *testing.T) {
+	store := kvstore.NewMemoryStore(time.Minute)
+	defer store.Close()
+
+	for name, secret := range map[string]string{
+		"Empty":       "",
+		"Placeholder": "your-secret-key-change-in-production",
+		"Short":       "0123456789abcdef0123456789abcde",
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := testSessionConfig
+			cfg.Secret = []byte(secret)
+			_, err := NewSessionManager(cfg, store)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewSessionManager(testSessionConfig, store)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ErrNoSigner is returned by NewSignerBackend when no key source is
+// configured.
+var ErrNoSigner = errors.New("no signer configured")
+
+// Signer holds the key the backend's EIP-712 signatures are made with. It may
+// keep the key out of process, so it signs whole typed data rather than raw
+// digests: remote signers refuse to sign a hash they cannot display.
+type Signer interface {
+	// Address returns the address signatures recover to.
+	Address() common.Address
+
+	// SignTypedData returns the 65-byte r||s||v signature of the EIP-712
+	// digest of data, with v either 0/1 or 27/28.
+	SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error)
+}
+
+// KeySigner signs with a private key held in memory. It is meant for tests
+// and development; in production prefer a keystore or a remote signer.
+type KeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewKeySigner creates a signer from a hex private key, with or without 0x.
+func NewKeySigner(privateKeyHex string) (*KeySigner, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return NewKeySignerFromECDSA(key), nil
+}
+
+// NewKeySignerFromECDSA creates a signer around key.
+func NewKeySignerFromECDSA(key *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+func (s *KeySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *KeySigner) SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error) {
+	return signTypedDataWithKey(s.key, data)
+}
+
+// KeystoreSigner signs with a key from a go-ethereum encrypted keystore
+// (v3 JSON) file. Only the passphrase file has to be kept secret alongside
+// it; the key is decrypted once, when the signer is created.
+type KeystoreSigner struct {
+	key *keystore.Key
+}
+
+// NewKeystoreSigner decrypts keyFile with the passphrase in passphraseFile.
+// Like geth, only the first line of the passphrase file is used.
+func NewKeystoreSigner(keyFile, passphraseFile string) (*KeystoreSigner, error) {
+	keyJSON, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	passphrase, err := os.ReadFile(passphraseFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	firstLine, _, _ := strings.Cut(string(passphrase), "\n")
+
+	key, err := keystore.DecryptKey(keyJSON, strings.TrimRight(firstLine, "\r"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore %s: %w", keyFile, err)
+	}
+	return &KeystoreSigner{key: key}, nil
+}
+
+func (s *KeystoreSigner) Address() common.Address {
+	return s.key.Address
+}
+
+func (s *KeystoreSigner) SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error) {
+	return signTypedDataWithKey(s.key.PrivateKey, data)
+}
+
+// DefaultRemoteSignerMethod is the JSON-RPC method RemoteSigner calls unless
+// told otherwise. Web3Signer and most node signers serve it; Clef calls it
+// account_signTypedData.
+const DefaultRemoteSignerMethod = "eth_signTypedData_v4"
+
+// How long a remote signer gets to answer a signing request
+const remoteSignerTimeout = 10 * time.Second
+
+// RemoteSigner asks a signer service over HTTP JSON-RPC to sign, so the key
+// never enters this process. The service is called as
+// method(address, typedData) and must answer with the hex signature.
+type RemoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+	method  string
+}
+
+// NewRemoteSigner connects to the signer service at url, which holds the key
+// of address. An empty method uses DefaultRemoteSignerMethod.
+func NewRemoteSigner(url string, address common.Address, method string) (*RemoteSigner, error) {
+	if method == "" {
+		method = DefaultRemoteSignerMethod
+	}
+	client, err := rpc.DialOptions(context.Background(), url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+	}
+	return &RemoteSigner{client: client, address: address, method: method}, nil
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *RemoteSigner) SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteSignerTimeout)
+	defer cancel()
+
+	var signature hexutil.Bytes
+	if err := s.client.CallContext(ctx, &signature, s.method, s.address, data); err != nil {
+		return nil, fmt.Errorf("remote signer failed to sign %s: %w", data.PrimaryType, err)
+	}
+	if len(signature) != 65 {
+		return nil, fmt.Errorf("remote signer returned a %d-byte signature", len(signature))
+	}
+	return signature, nil
+}
+
+// Close disconnects from the signer service.
+func (s *RemoteSigner) Close() {
+	s.client.Close()
+}
+
+// SignerConfig selects where the signing key comes from. The first source
+// set wins: remote signer, then keystore, then raw private key.
+type SignerConfig struct {
+	PrivateKey     string
+	KeystoreFile   string
+	PassphraseFile string
+	RemoteURL      string
+	RemoteAddress  string
+	RemoteMethod   string
+}
+
+// NewSignerBackend creates the Signer cfg describes.
+func NewSignerBackend(cfg SignerConfig) (Signer, error) {
+	switch {
+	case cfg.RemoteURL != "":
+		if !common.IsHexAddress(cfg.RemoteAddress) {
+			return nil, fmt.Errorf("remote signer needs the address of its key, got %q", cfg.RemoteAddress)
+		}
+		return NewRemoteSigner(cfg.RemoteURL, common.HexToAddress(cfg.RemoteAddress), cfg.RemoteMethod)
+	case cfg.KeystoreFile != "":
+		if cfg.PassphraseFile == "" {
+			return nil, fmt.Errorf("keystore %s needs a passphrase file", cfg.KeystoreFile)
+		}
+		return NewKeystoreSigner(cfg.KeystoreFile, cfg.PassphraseFile)
+	case cfg.PrivateKey != "":
+		return NewKeySigner(cfg.PrivateKey)
+	}
+	return nil, ErrNoSigner
+}
+
+// ParseSignerSpec reads a signer from one SIGNER_STANDBY_KEYS entry:
+// "keystore:<keystore file>:<passphrase file>", "remote:<address>@<url>"
+// (called with remoteMethod), or, for development, a hex private key.
+func ParseSignerSpec(spec, remoteMethod string) SignerConfig {
+	if rest, ok := strings.CutPrefix(spec, "keystore:"); ok {
+		keystoreFile, passphraseFile, _ := strings.Cut(rest, ":")
+		return SignerConfig{KeystoreFile: keystoreFile, PassphraseFile: passphraseFile}
+	}
+	if rest, ok := strings.CutPrefix(spec, "remote:"); ok {
+		address, url, found := strings.Cut(rest, "@")
+		if !found {
+			address, url = "", rest
+		}
+		return SignerConfig{RemoteURL: url, RemoteAddress: address, RemoteMethod: remoteMethod}
+	}
+	return SignerConfig{PrivateKey: spec}
+}
+
+func signTypedDataWithKey(key *ecdsa.PrivateKey, data apitypes.TypedData) ([]byte, error) {
+	digest, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", data.PrimaryType, err)
+	}
+
+	signature, err := crypto.Sign(digest, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s: %w", data.PrimaryType, err)
+	}
+	signature[64] += 27
+	return signature, nil
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testLicenseContract = common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	testLicenseUser     = common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+)
+
+// writeKeystore encrypts key into a keystore file and writes its passphrase
+// file, returning both paths.
+func writeKeystore(t *testing.T, key *ecdsa.PrivateKey, passphrase string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	keyJSON, err := keystore.EncryptKey(&keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, passphrase, keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(dir, "signer.json")
+	passphraseFile := filepath.Join(dir, "passphrase")
+	require.NoError(t, os.WriteFile(keyFile, keyJSON, 0o600))
+	require.NoError(t, os.WriteFile(passphraseFile, []byte(passphrase+"\n"), 0o600))
+	return keyFile, passphraseFile
+}
+
+// standInRemoteSigner serves eth_signTypedData_v4 over JSON-RPC like a
+// remote signer holding key. It answers with v as 0/1, as some signers do.
+func standInRemoteSigner(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		var address common.Address
+		var data apitypes.TypedData
+		switch {
+		case req.Method != DefaultRemoteSignerMethod || len(req.Params) != 2:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		case json.Unmarshal(req.Params[0], &address) != nil || address != crypto.PubkeyToAddress(key.PublicKey):
+			resp["error"] = map[string]interface{}{"code": -32000, "message": "unknown account"}
+		case json.Unmarshal(req.Params[1], &data) != nil:
+			resp["error"] = map[string]interface{}{"code": -32602, "message": "invalid typed data"}
+		default:
+			signature, err := signTypedDataWithKey(key, data)
+			require.NoError(t, err)
+			signature[64] -= 27
+			resp["result"] = hexutil.Encode(signature)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSignerBackends(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(31337)
+
+	toolID, expiresAt, nonce := big.NewInt(42), big.NewInt(1735689600), big.NewInt(7)
+
+	// Every backend must produce the signature the raw key does (RFC 6979
+	// signatures are deterministic)
+	reference := NewEIP712Signer(NewKeySignerFromECDSA(key), chainID, testLicenseContract)
+	r, s, v, err := reference.CreateLicenseSignature(testLicenseUser, toolID, expiresAt, nonce)
+	require.NoError(t, err)
+	expected := append(append(append([]byte(nil), r...), s...), v...)
+
+	signWith := func(t *testing.T, backend Signer) []byte {
+		t.Helper()
+		signer := NewEIP712Signer(backend, chainID, testLicenseContract)
+		assert.Equal(t, address, signer.Address())
+
+		r, s, v, err := signer.CreateLicenseSignature(testLicenseUser, toolID, expiresAt, nonce)
+		require.NoError(t, err)
+		valid, err := signer.VerifySignature(testLicenseUser, toolID, expiresAt, nonce, r, s, v)
+		require.NoError(t, err)
+		assert.True(t, valid)
+		return append(append(append([]byte(nil), r...), s...), v...)
+	}
+
+	t.Run("Keystore", func(t *testing.T) {
+		keyFile, passphraseFile := writeKeystore(t, key, "correct horse")
+
+		backend, err := NewKeystoreSigner(keyFile, passphraseFile)
+		require.NoError(t, err)
+		assert.Equal(t, expected, signWith(t, backend))
+
+		wrongPassphrase := filepath.Join(t.TempDir(), "wrong")
+		require.NoError(t, os.WriteFile(wrongPassphrase, []byte("battery staple"), 0o600))
+		_, err = NewKeystoreSigner(keyFile, wrongPassphrase)
+		assert.Error(t, err)
+	})
+
+	t.Run("Remote", func(t *testing.T) {
+		server := standInRemoteSigner(t, key)
+
+		backend, err := NewRemoteSigner(server.URL, address, "")
+		require.NoError(t, err)
+		defer backend.Close()
+		assert.Equal(t, expected, signWith(t, backend))
+
+		// A signature by another key is refused before it reaches a contract
+		other, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		impostor := standInRemoteSigner(t, other)
+		misconfigured, err := NewRemoteSigner(impostor.URL, crypto.PubkeyToAddress(other.PublicKey), "")
+		require.NoError(t, err)
+		defer misconfigured.Close()
+		signer := NewEIP712Signer(misconfigured, chainID, testLicenseContract)
+		signer.publicAddress = address
+		_, _, _, err = signer.CreateLicenseSignature(testLicenseUser, toolID, expiresAt, nonce)
+		assert.ErrorContains(t, err, "expected "+address.Hex())
+
+		// Errors from the service are passed on
+		wrongMethod, err := NewRemoteSigner(server.URL, address, "account_signTypedData")
+		require.NoError(t, err)
+		defer wrongMethod.Close()
+		_, err = NewEIP712Signer(wrongMethod, chainID, testLicenseContract).Sign("MintLicense", licenseMessage(testLicenseUser, toolID, expiresAt, nonce))
+		assert.ErrorContains(t, err, "method not found")
+	})
+
+	t.Run("SelectsBackend", func(t *testing.T) {
+		keyFile, passphraseFile := writeKeystore(t, key, "pw")
+		server := standInRemoteSigner(t, key)
+
+		backend, err := NewSignerBackend(SignerConfig{PrivateKey: "0x" + common.Bytes2Hex(crypto.FromECDSA(key))})
+		require.NoError(t, err)
+		assert.IsType(t, &KeySigner{}, backend)
+
+		backend, err = NewSignerBackend(SignerConfig{PrivateKey: "ignored", KeystoreFile: keyFile, PassphraseFile: passphraseFile})
+		require.NoError(t, err)
+		assert.IsType(t, &KeystoreSigner{}, backend)
+
+		backend, err = NewSignerBackend(SignerConfig{KeystoreFile: keyFile, RemoteURL: server.URL, RemoteAddress: address.Hex()})
+		require.NoError(t, err)
+		assert.IsType(t, &RemoteSigner{}, backend)
+
+		_, err = NewSignerBackend(SignerConfig{})
+		assert.ErrorIs(t, err, ErrNoSigner)
+		_, err = NewSignerBackend(SignerConfig{KeystoreFile: keyFile})
+		assert.Error(t, err)
+		_, err = NewSignerBackend(SignerConfig{RemoteURL: server.URL})
+		assert.Error(t, err)
+	})
+
+	t.Run("ParsesSpec", func(t *testing.T) {
+		keyFile, passphraseFile := writeKeystore(t, key, "pw")
+		server := standInRemoteSigner(t, key)
+
+		backend, err := NewSignerBackend(ParseSignerSpec("keystore:"+keyFile+":"+passphraseFile, ""))
+		require.NoError(t, err)
+		assert.Equal(t, expected, signWith(t, backend))
+
+		backend, err = NewSignerBackend(ParseSignerSpec("remote:"+address.Hex()+"@"+server.URL, ""))
+		require.NoError(t, err)
+		assert.Equal(t, expected, signWith(t, backend))
+
+		backend, err = NewSignerBackend(ParseSignerSpec(common.Bytes2Hex(crypto.FromECDSA(key)), ""))
+		require.NoError(t, err)
+		assert.Equal(t, expected, signWith(t, backend))
+
+		assert.Equal(t, SignerConfig{RemoteURL: server.URL, RemoteMethod: "eth_signTypedData"}, ParseSignerSpec("remote:"+server.URL, "eth_signTypedData"))
+		_, err = NewSignerBackend(ParseSignerSpec("keystore:"+keyFile, ""))
+		assert.Error(t, err)
+		_, err = NewSignerBackend(ParseSignerSpec("remote:"+server.URL, ""))
+		assert.Error(t, err)
+	})
+}
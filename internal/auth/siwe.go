@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidSIWEMessage is returned for sign-in messages that are malformed
+// or do not fit this server (domain, URI, chain ID, validity window).
+var ErrInvalidSIWEMessage = errors.New("invalid sign-in message")
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+var siweNonce = regexp.MustCompile(`^[a-zA-Z0-9]{8,}$`)
+
+// SIWEMessage is an EIP-4361 Sign-In With Ethereum message.
+type SIWEMessage struct {
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// String formats the message as the wallet displays and signs it.
+func (m *SIWEMessage) String() string {
+	var b strings.Builder
+	b.WriteString(m.Domain + siweHeaderSuffix + "\n")
+	b.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "URI: %s\n", m.URI)
+	fmt.Fprintf(&b, "Version: %s\n", m.Version)
+	fmt.Fprintf(&b, "Chain ID: %d\n", m.ChainID)
+	fmt.Fprintf(&b, "Nonce: %s\n", m.Nonce)
+	fmt.Fprintf(&b, "Issued At: %s", m.IssuedAt.UTC().Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		fmt.Fprintf(&b, "\nExpiration Time: %s", m.ExpirationTime.UTC().Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		fmt.Fprintf(&b, "\nNot Before: %s", m.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if m.RequestID != "" {
+		fmt.Fprintf(&b, "\nRequest ID: %s", m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, resource := range m.Resources {
+			b.WriteString("\n- " + resource)
+		}
+	}
+	return b.String()
+}
+
+// ParseSIWEMessage parses an EIP-4361 message. The address must be in its
+// EIP-55 checksummed form.
+func ParseSIWEMessage(text string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	invalid := func(format string, args ...interface{}) (*SIWEMessage, error) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSIWEMessage, fmt.Sprintf(format, args...))
+	}
+	if len(lines) < 9 {
+		return invalid("message is too short")
+	}
+
+	m := &SIWEMessage{}
+
+	if !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return invalid("missing header")
+	}
+	m.Domain = strings.TrimSuffix(lines[0], siweHeaderSuffix)
+	if m.Domain == "" {
+		return invalid("missing domain")
+	}
+
+	if !common.IsHexAddress(lines[1]) || common.HexToAddress(lines[1]).Hex() != lines[1] {
+		return invalid("address must be EIP-55 checksummed")
+	}
+	m.Address = common.HexToAddress(lines[1])
+
+	if lines[2] != "" {
+		return invalid("expected empty line after address")
+	}
+	i := 3
+	if lines[i] != "" {
+		m.Statement = lines[i]
+		i++
+	}
+	if lines[i] != "" {
+		return invalid("expected empty line before URI")
+	}
+	i++
+
+	// next returns the value of the field on the current line, if present
+	next := func(name string) (string, bool) {
+		if i < len(lines) && strings.HasPrefix(lines[i], name+": ") {
+			value := strings.TrimPrefix(lines[i], name+": ")
+			i++
+			return value, true
+		}
+		return "", false
+	}
+	required := func(name string) (string, error) {
+		value, ok := next(name)
+		if !ok || value == "" {
+			return "", fmt.Errorf("%w: missing %s", ErrInvalidSIWEMessage, name)
+		}
+		return value, nil
+	}
+	parseTime := func(name, value string) (time.Time, error) {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: invalid %s", ErrInvalidSIWEMessage, name)
+		}
+		return t, nil
+	}
+
+	var err error
+	if m.URI, err = required("URI"); err != nil {
+		return nil, err
+	}
+	if m.Version, err = required("Version"); err != nil {
+		return nil, err
+	}
+	if m.Version != "1" {
+		return invalid("unsupported version %q", m.Version)
+	}
+
+	chainID, err := required("Chain ID")
+	if err != nil {
+		return nil, err
+	}
+	if m.ChainID, err = strconv.ParseInt(chainID, 10, 64); err != nil {
+		return invalid("invalid Chain ID")
+	}
+
+	if m.Nonce, err = required("Nonce"); err != nil {
+		return nil, err
+	}
+	if !siweNonce.MatchString(m.Nonce) {
+		return invalid("nonce must be at least 8 alphanumeric characters")
+	}
+
+	issuedAt, err := required("Issued At")
+	if err != nil {
+		return nil, err
+	}
+	if m.IssuedAt, err = parseTime("Issued At", issuedAt); err != nil {
+		return nil, err
+	}
+
+	if value, ok := next("Expiration Time"); ok {
+		t, err := parseTime("Expiration Time", value)
+		if err != nil {
+			return nil, err
+		}
+		m.ExpirationTime = &t
+	}
+	if value, ok := next("Not Before"); ok {
+		t, err := parseTime("Not Before", value)
+		if err != nil {
+			return nil, err
+		}
+		m.NotBefore = &t
+	}
+	if value, ok := next("Request ID"); ok {
+		m.RequestID = value
+	}
+	if i < len(lines) && lines[i] == "Resources:" {
+		for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+			m.Resources = append(m.Resources, strings.TrimPrefix(lines[i], "- "))
+		}
+	}
+
+	if i != len(lines) {
+		return invalid("unexpected line %q", lines[i])
+	}
+	return m, nil
+}
+
+// SIWEExpectations are the values a sign-in message must carry to be
+// accepted by this server.
+type SIWEExpectations struct {
+	Domain  string
+	URI     string
+	ChainID int64
+}
+
+// Validate checks the message against expected and its validity window at
+// now. Issued At may be up to a minute in the future to allow for clock skew.
+func (m *SIWEMessage) Validate(expected SIWEExpectations, now time.Time) error {
+	switch {
+	case m.Domain != expected.Domain:
+		return fmt.Errorf("%w: domain %q does not match %q", ErrInvalidSIWEMessage, m.Domain, expected.Domain)
+	case m.URI != expected.URI:
+		return fmt.Errorf("%w: URI %q does not match %q", ErrInvalidSIWEMessage, m.URI, expected.URI)
+	case m.ChainID != expected.ChainID:
+		return fmt.Errorf("%w: chain ID %d does not match %d", ErrInvalidSIWEMessage, m.ChainID, expected.ChainID)
+	case m.IssuedAt.After(now.Add(time.Minute)):
+		return fmt.Errorf("%w: issued in the future", ErrInvalidSIWEMessage)
+	case m.ExpirationTime != nil && !now.Before(*m.ExpirationTime):
+		return fmt.Errorf("%w: message has expired", ErrInvalidSIWEMessage)
+	case m.NotBefore != nil && now.Before(*m.NotBefore):
+		return fmt.Errorf("%w: message is not valid yet", ErrInvalidSIWEMessage)
+	}
+	return nil
+}
+
+// RecoverSIWESigner returns the address that personal_signed (EIP-191) the
+// message text. High-s signatures are rejected.
+func RecoverSIWESigner(text string, signature []byte) (common.Address, error) {
+	sig, err := normalizeSignature(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pubkey, err := crypto.SigToPub(accounts.TextHash([]byte(text)), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover public key: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
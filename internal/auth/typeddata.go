@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Domains of the deployed contracts. Both use name "SkillChainLicense" and
+// version "1"; they differ in verifyingContract. APIDomain messages are
+// only checked by the backend, see RequestDomain.
+const (
+	LicenseDomain = "license" // LicenseNFT
+	OracleDomain  = "oracle"  // ReputationOracle
+	APIDomain     = "api"     // Backend API requests
+)
+
+var (
+	// ErrUnknownType is returned for a primary type that was never registered.
+	ErrUnknownType = errors.New("unknown EIP-712 type")
+
+	// ErrMalleableSignature is returned for signatures whose s value is in the
+	// upper half of the curve order. OpenZeppelin's ECDSA.recover rejects them,
+	// and accepting them would allow a second valid signature per message.
+	ErrMalleableSignature = errors.New("signature s value is not in the lower half of the curve order")
+)
+
+var secp256k1HalfN = new(big.Int).Rsh(crypto.S256().Params().N, 1)
+
+var domainType = []apitypes.Type{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+}
+
+var primitiveType = regexp.MustCompile(`^(address|bool|string|bytes([1-9]|[12][0-9]|3[0-2])?|u?int(8|16|24|32|40|48|56|64|72|80|88|96|104|112|120|128|136|144|152|160|168|176|184|192|200|208|216|224|232|240|248|256)?)$`)
+
+// TypeRegistry holds EIP-712 message types, each declared once together with
+// the domain (LicenseDomain, OracleDomain) it is signed under.
+type TypeRegistry struct {
+	mu       sync.RWMutex
+	types    apitypes.Types
+	domainOf map[string]string
+}
+
+// NewTypeRegistry creates an empty registry.
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		types:    apitypes.Types{"EIP712Domain": domainType},
+		domainOf: make(map[string]string),
+	}
+}
+
+// Register declares primaryType with its fields, in order. Struct types used
+// by a field must be registered first.
+func (r *TypeRegistry) Register(primaryType, domain string, fields ...apitypes.Type) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if primaryType == "" || len(fields) == 0 {
+		return fmt.Errorf("type %q must have a name and at least one field", primaryType)
+	}
+	if _, exists := r.types[primaryType]; exists {
+		return fmt.Errorf("type %q is already registered", primaryType)
+	}
+
+	names := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if field.Name == "" || names[field.Name] {
+			return fmt.Errorf("type %q: missing or duplicate field name %q", primaryType, field.Name)
+		}
+		names[field.Name] = true
+
+		base := strings.TrimSuffix(field.Type, "[]")
+		if _, isStruct := r.types[base]; !isStruct && !primitiveType.MatchString(base) {
+			return fmt.Errorf("type %q: field %q has unknown type %q", primaryType, field.Name, field.Type)
+		}
+	}
+
+	r.types[primaryType] = append([]apitypes.Type(nil), fields...)
+	r.domainOf[primaryType] = domain
+	return nil
+}
+
+// MustRegister is like Register but panics on error. It is meant for
+// package-level declarations.
+func (r *TypeRegistry) MustRegister(primaryType, domain string, fields ...apitypes.Type) {
+	if err := r.Register(primaryType, domain, fields...); err != nil {
+		panic(err)
+	}
+}
+
+// DomainOf returns the domain name primaryType is signed under.
+func (r *TypeRegistry) DomainOf(primaryType string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	domain, ok := r.domainOf[primaryType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownType, primaryType)
+	}
+	return domain, nil
+}
+
+// TypedData assembles the typed data for message, with only the types
+// primaryType depends on, in the form wallets accept for
+// eth_signTypedData_v4.
+func (r *TypeRegistry) TypedData(domain apitypes.TypedDataDomain, primaryType string, message apitypes.TypedDataMessage) (apitypes.TypedData, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.domainOf[primaryType]; !ok {
+		return apitypes.TypedData{}, fmt.Errorf("%w: %s", ErrUnknownType, primaryType)
+	}
+
+	all := apitypes.TypedData{Types: r.types}
+	types := apitypes.Types{"EIP712Domain": domainType}
+	for _, dep := range all.Dependencies(primaryType, nil) {
+		types[dep] = r.types[dep]
+	}
+
+	return apitypes.TypedData{
+		Types:       types,
+		PrimaryType: primaryType,
+		Domain:      domain,
+		Message:     message,
+	}, nil
+}
+
+// Hash returns the EIP-712 digest of message under domain.
+func (r *TypeRegistry) Hash(domain apitypes.TypedDataDomain, primaryType string, message apitypes.TypedDataMessage) (common.Hash, error) {
+	typedData, err := r.TypedData(domain, primaryType, message)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to hash %s: %w", primaryType, err)
+	}
+	return common.BytesToHash(digest), nil
+}
+
+// Sign signs message under domain. The signature is 65 bytes, low-s, with v
+// as 27 or 28, as produced by wallets.
+func (r *TypeRegistry) Sign(key *ecdsa.PrivateKey, domain apitypes.TypedDataDomain, primaryType string, message apitypes.TypedDataMessage) ([]byte, error) {
+	digest, err := r.Hash(domain, primaryType, message)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := crypto.Sign(digest.Bytes(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s: %w", primaryType, err)
+	}
+	signature[64] += 27
+	return signature, nil
+}
+
+// Recover returns the address that signed message under domain. v may be 0/1
+// or 27/28; high-s signatures are rejected with ErrMalleableSignature.
+func (r *TypeRegistry) Recover(domain apitypes.TypedDataDomain, primaryType string, message apitypes.TypedDataMessage, signature []byte) (common.Address, error) {
+	sig, err := normalizeSignature(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	digest, err := r.Hash(domain, primaryType, message)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pubkey, err := crypto.SigToPub(digest.Bytes(), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover public key: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// normalizeSignature checks length, v and s, and returns a copy with v as 0/1.
+func normalizeSignature(signature []byte) ([]byte, error) {
+	if len(signature) != 65 {
+		return nil, fmt.Errorf("signature must be 65 bytes")
+	}
+
+	sig := make([]byte, 65)
+	copy(sig, signature)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return nil, fmt.Errorf("invalid signature recovery id %d", signature[64])
+	}
+	if new(big.Int).SetBytes(sig[32:64]).Cmp(secp256k1HalfN) > 0 {
+		return nil, ErrMalleableSignature
+	}
+	return sig, nil
+}
+
+// Types declares the messages the SkillChain contracts and backend verify.
+var Types = NewTypeRegistry()
+
+func init() {
+	Types.MustRegister("MintLicense", LicenseDomain,
+		apitypes.Type{Name: "user", Type: "address"},
+		apitypes.Type{Name: "toolId", Type: "uint256"},
+		apitypes.Type{Name: "expiresAt", Type: "uint256"},
+		apitypes.Type{Name: "nonce", Type: "uint256"},
+	)
+	Types.MustRegister("Vote", OracleDomain,
+		apitypes.Type{Name: "voter", Type: "address"},
+		apitypes.Type{Name: "toolId", Type: "uint256"},
+		apitypes.Type{Name: "score", Type: "int8"},
+		apitypes.Type{Name: "nonce", Type: "uint64"},
+	)
+	Types.MustRegister("RetractVote", OracleDomain,
+		apitypes.Type{Name: "voter", Type: "address"},
+		apitypes.Type{Name: "toolId", Type: "uint256"},
+		apitypes.Type{Name: "nonce", Type: "uint64"},
+	)
+	Types.MustRegister("UpdateReputationRoot", OracleDomain,
+		apitypes.Type{Name: "toolId", Type: "uint256"},
+		apitypes.Type{Name: "timestamp", Type: "uint256"},
+		apitypes.Type{Name: "rootHash", Type: "bytes32"},
+		apitypes.Type{Name: "nonce", Type: "uint256"},
+	)
+	Types.MustRegister("VerifyRequest", APIDomain,
+		apitypes.Type{Name: "user", Type: "address"},
+		apitypes.Type{Name: "toolId", Type: "string"},
+		apitypes.Type{Name: "bodyHash", Type: "bytes32"},
+		apitypes.Type{Name: "nonce", Type: "string"},
+	)
+	Types.MustRegister("Delegation", APIDomain,
+		apitypes.Type{Name: "user", Type: "address"},
+		apitypes.Type{Name: "sessionKey", Type: "address"},
+		apitypes.Type{Name: "toolIds", Type: "string[]"},
+		apitypes.Type{Name: "maxCalls", Type: "uint256"},
+		apitypes.Type{Name: "expiresAt", Type: "uint256"},
+		apitypes.Type{Name: "nonce", Type: "string"},
+	)
+	Types.MustRegister("RevokeDelegation", APIDomain,
+		apitypes.Type{Name: "id", Type: "string"},
+		apitypes.Type{Name: "nonce", Type: "string"},
+	)
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Hardhat account #0
+const typedDataTestKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+// highS turns a signature into its malleable twin (s' = N - s, flipped v),
+// which recovers to the same address.
+func highS(signature []byte) []byte {
+	twin := append([]byte(nil), signature...)
+	s := new(big.Int).SetBytes(twin[32:64])
+	s.Sub(crypto.S256().Params().N, s)
+	s.FillBytes(twin[32:64])
+	if twin[64] == 27 {
+		twin[64] = 28
+	} else {
+		twin[64] = 27
+	}
+	return twin
+}
+
+func TestCreateLicenseSignature_Unchanged(t *testing.T) {
+	signer, err := NewSigner(typedDataTestKey, big.NewInt(31337), common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"))
+	require.NoError(t, err)
+
+	r, s, v, err := signer.CreateLicenseSignature(common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"),
+		big.NewInt(42), big.NewInt(1893456000), big.NewInt(7))
+	require.NoError(t, err)
+
+	// Output of the implementation that predates the type registry
+	assert.Equal(t, "ab7ea9ee604c7e3ed63e65b2ef9ebb1e431e972dbe08d2708b35d9333baff673", hex.EncodeToString(r))
+	assert.Equal(t, "38a621986cfde0cfa3e001c9f3803b78da3d15cf8341a8b5b7927d7ea6fdf071", hex.EncodeToString(s))
+	assert.Equal(t, []byte{0x1c}, v)
+}
+
+func TestTypeRegistry_Register(t *testing.T) {
+	registry := NewTypeRegistry()
+
+	require.NoError(t, registry.Register("Person", LicenseDomain,
+		apitypes.Type{Name: "wallet", Type: "address"},
+		apitypes.Type{Name: "tags", Type: "bytes32[]"},
+	))
+	require.NoError(t, registry.Register("Mail", LicenseDomain,
+		apitypes.Type{Name: "from", Type: "Person"},
+		apitypes.Type{Name: "to", Type: "Person[]"},
+		apitypes.Type{Name: "contents", Type: "string"},
+	))
+
+	assert.Error(t, registry.Register("Mail", LicenseDomain, apitypes.Type{Name: "x", Type: "uint256"}), "duplicate type")
+	assert.Error(t, registry.Register("Empty", LicenseDomain), "no fields")
+	assert.Error(t, registry.Register("Bad", LicenseDomain, apitypes.Type{Name: "x", Type: "uint7"}), "invalid primitive")
+	assert.Error(t, registry.Register("Bad", LicenseDomain, apitypes.Type{Name: "x", Type: "Unknown"}), "unregistered struct")
+	assert.Error(t, registry.Register("Bad", LicenseDomain,
+		apitypes.Type{Name: "x", Type: "uint256"}, apitypes.Type{Name: "x", Type: "bool"}), "duplicate field")
+
+	// Only the dependencies of the primary type are included
+	typedData, err := registry.TypedData(apitypes.TypedDataDomain{}, "Mail", nil)
+	require.NoError(t, err)
+	assert.Len(t, typedData.Types, 3)
+	assert.Equal(t, "Mail(Person from,Person[] to,string contents)Person(address wallet,bytes32[] tags)",
+		string(typedData.EncodeType("Mail")))
+
+	_, err = registry.Hash(apitypes.TypedDataDomain{}, "Unknown", nil)
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestEIP712Signer_Domains(t *testing.T) {
+	licenseNFT := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	oracle := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	signer, err := NewSigner(typedDataTestKey, big.NewInt(31337), licenseNFT)
+	require.NoError(t, err)
+
+	vote := &Vote{Voter: signer.Address(), ToolID: big.NewInt(42), Score: 1, Nonce: 1}
+
+	// Votes belong to the oracle domain, which is not configured yet
+	_, err = signer.Sign("Vote", vote.Message())
+	require.Error(t, err)
+
+	signer.SetDomain(OracleDomain, oracle)
+	signature, err := signer.Sign("Vote", vote.Message())
+	require.NoError(t, err)
+
+	expected, err := SignVote(signer.backend.(*KeySigner).key, VoteDomain(big.NewInt(31337), oracle), vote)
+	require.NoError(t, err)
+	assert.Equal(t, expected, signature)
+
+	recovered, err := signer.Recover("Vote", vote.Message(), signature)
+	require.NoError(t, err)
+	assert.Equal(t, signer.Address(), recovered)
+
+	// The license domain is unaffected
+	assert.Equal(t, licenseNFT, signer.VerifyingContract())
+	licenseDomain, err := signer.DomainFor(LicenseDomain)
+	require.NoError(t, err)
+	assert.Equal(t, signer.Domain(), licenseDomain)
+}
+
+func TestTypeRegistry_Malleability(t *testing.T) {
+	signer, err := NewSigner(typedDataTestKey, big.NewInt(31337), common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	require.NoError(t, err)
+	user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	r, s, v, err := signer.CreateLicenseSignature(user, big.NewInt(42), big.NewInt(1893456000), big.NewInt(7))
+	require.NoError(t, err)
+	signature := append(append(append([]byte(nil), r...), s...), v...)
+
+	// The twin would recover the same address, but is rejected
+	twin := highS(signature)
+	valid, err := signer.VerifySignature(user, big.NewInt(42), big.NewInt(1893456000), big.NewInt(7), twin[:32], twin[32:64], twin[64:])
+	assert.ErrorIs(t, err, ErrMalleableSignature)
+	assert.False(t, valid)
+
+	message := licenseMessage(user, big.NewInt(42), big.NewInt(1893456000), big.NewInt(7))
+	_, err = signer.Recover("MintLicense", message, twin)
+	assert.ErrorIs(t, err, ErrMalleableSignature)
+
+	badV := append([]byte(nil), signature...)
+	badV[64] = 29
+	_, err = signer.Recover("MintLicense", message, badV)
+	assert.Error(t, err)
+
+	// Verification does not modify the caller's signature
+	_, err = signer.VerifySignature(user, big.NewInt(42), big.NewInt(1893456000), big.NewInt(7), r, s, v)
+	require.NoError(t, err)
+	assert.Equal(t, signature[64], v[0])
+}
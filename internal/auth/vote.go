@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// VoteType is the EIP-712 type agents sign to vote on a tool. The
+// ReputationOracle contract hashes votes with the same type (hashVote).
+const VoteType = "Vote(address voter,uint256 toolId,int8 score,uint64 nonce)"
+
+// Vote is the message signed by a voter's wallet.
+type Vote struct {
+	Voter  common.Address
+	ToolID *big.Int
+	Score  int8
+	Nonce  uint64
+}
+
+// VoteDomain returns the SkillChain domain votes are signed under. It is the
+// ReputationOracle's own EIP-712 domain, so votes can be verified on-chain.
+func VoteDomain(chainID *big.Int, reputationOracle common.Address) apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name:              "SkillChainLicense",
+		Version:           "1",
+		ChainId:           (*math.HexOrDecimal256)(new(big.Int).Set(chainID)),
+		VerifyingContract: reputationOracle.Hex(),
+	}
+}
+
+// Message returns the vote as an EIP-712 message of type Vote.
+func (v *Vote) Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"voter":  v.Voter.Hex(),
+		"toolId": v.ToolID.String(),
+		"score":  big.NewInt(int64(v.Score)),
+		"nonce":  new(big.Int).SetUint64(v.Nonce),
+	}
+}
+
+func (v *Vote) validate() error {
+	if v.ToolID == nil || v.ToolID.Sign() < 0 {
+		return fmt.Errorf("invalid tool ID")
+	}
+	return nil
+}
+
+// HashVote returns the EIP-712 digest of vote under domain.
+func HashVote(domain apitypes.TypedDataDomain, vote *Vote) (common.Hash, error) {
+	if err := vote.validate(); err != nil {
+		return common.Hash{}, err
+	}
+	return Types.Hash(domain, "Vote", vote.Message())
+}
+
+// SignVote signs vote under domain with the voter's key.
+func SignVote(key *ecdsa.PrivateKey, domain apitypes.TypedDataDomain, vote *Vote) ([]byte, error) {
+	if err := vote.validate(); err != nil {
+		return nil, err
+	}
+	return Types.Sign(key, domain, "Vote", vote.Message())
+}
+
+// RecoverVoteSigner returns the address that signed vote under domain.
+func RecoverVoteSigner(domain apitypes.TypedDataDomain, vote *Vote, signature []byte) (common.Address, error) {
+	if err := vote.validate(); err != nil {
+		return common.Address{}, err
+	}
+	return Types.Recover(domain, "Vote", vote.Message(), signature)
+}
+
+// VerifyVoteSignature reports whether signature is vote.Voter's signature of
+// vote under domain.
+func VerifyVoteSignature(domain apitypes.TypedDataDomain, vote *Vote, signature []byte) (bool, error) {
+	signer, err := RecoverVoteSigner(domain, vote, signature)
+	if err != nil {
+		return false, err
+	}
+	return signer == vote.Voter, nil
+}
+
+// RetractionType is the EIP-712 type a voter signs to withdraw their vote
+// on a tool. Its nonce shares the sequence of the voter's votes on the tool.
+const RetractionType = "RetractVote(address voter,uint256 toolId,uint64 nonce)"
+
+// Retraction is the message signed by a voter's wallet to withdraw a vote.
+type Retraction struct {
+	Voter  common.Address
+	ToolID *big.Int
+	Nonce  uint64
+}
+
+// Message returns the retraction as an EIP-712 message of type RetractVote.
+func (r *Retraction) Message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"voter":  r.Voter.Hex(),
+		"toolId": r.ToolID.String(),
+		"nonce":  new(big.Int).SetUint64(r.Nonce),
+	}
+}
+
+func (r *Retraction) validate() error {
+	if r.ToolID == nil || r.ToolID.Sign() < 0 {
+		return fmt.Errorf("invalid tool ID")
+	}
+	return nil
+}
+
+// HashRetraction returns the EIP-712 digest of retraction under domain.
+func HashRetraction(domain apitypes.TypedDataDomain, retraction *Retraction) (common.Hash, error) {
+	if err := retraction.validate(); err != nil {
+		return common.Hash{}, err
+	}
+	return Types.Hash(domain, "RetractVote", retraction.Message())
+}
+
+// SignRetraction signs retraction under domain with the voter's key.
+func SignRetraction(key *ecdsa.PrivateKey, domain apitypes.TypedDataDomain, retraction *Retraction) ([]byte, error) {
+	if err := retraction.validate(); err != nil {
+		return nil, err
+	}
+	return Types.Sign(key, domain, "RetractVote", retraction.Message())
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// solidityVoteDigest computes the vote digest the way ReputationOracle.hashVote
+// does: _hashTypedDataV4(keccak256(abi.encode(_VOTE_TYPEHASH, ...))).
+func solidityVoteDigest(t *testing.T, chainID *big.Int, oracle common.Address, vote *Vote) common.Hash {
+	t.Helper()
+
+	newType := func(name string) abi.Type {
+		typ, err := abi.NewType(name, "", nil)
+		require.NoError(t, err)
+		return typ
+	}
+	encode := func(types []string, values ...interface{}) []byte {
+		var args abi.Arguments
+		for _, name := range types {
+			args = append(args, abi.Argument{Type: newType(name)})
+		}
+		packed, err := args.Pack(values...)
+		require.NoError(t, err)
+		return packed
+	}
+
+	domainTypeHash := crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	domainSeparator := crypto.Keccak256(encode([]string{"bytes32", "bytes32", "bytes32", "uint256", "address"},
+		domainTypeHash, crypto.Keccak256Hash([]byte("SkillChainLicense")), crypto.Keccak256Hash([]byte("1")), chainID, oracle))
+
+	voteTypeHash := crypto.Keccak256Hash([]byte("Vote(address voter,uint256 toolId,int8 score,uint64 nonce)"))
+	structHash := crypto.Keccak256(encode([]string{"bytes32", "address", "uint256", "int8", "uint64"},
+		voteTypeHash, vote.Voter, vote.ToolID, vote.Score, vote.Nonce))
+
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+func TestHashVote_MatchesSolidity(t *testing.T) {
+	chainID := big.NewInt(31337)
+	oracle := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	voter := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	domain := VoteDomain(chainID, oracle)
+
+	maxToolID, _ := new(big.Int).SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
+	votes := []*Vote{
+		{Voter: voter, ToolID: big.NewInt(42), Score: 1, Nonce: 1},
+		{Voter: voter, ToolID: big.NewInt(42), Score: 0, Nonce: 2},
+		{Voter: voter, ToolID: big.NewInt(42), Score: -1, Nonce: 3},
+		{Voter: voter, ToolID: maxToolID, Score: math.MinInt8, Nonce: math.MaxUint64},
+		{Voter: common.Address{}, ToolID: big.NewInt(0), Score: math.MaxInt8, Nonce: 0},
+	}
+
+	for _, vote := range votes {
+		digest, err := HashVote(domain, vote)
+		require.NoError(t, err)
+		assert.Equal(t, solidityVoteDigest(t, chainID, oracle, vote), digest,
+			"tool %s score %d nonce %d", vote.ToolID, vote.Score, vote.Nonce)
+	}
+
+	// Pinned vector, shared with the ReputationOracle hardhat test
+	digest, err := HashVote(domain, &Vote{Voter: voter, ToolID: big.NewInt(42), Score: -1, Nonce: 7})
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToHash("0x86e82d49cb5c94f1a560251dbe36dc1bbfcf5b67730b951edd2b27e718979981"), digest)
+}
+
+func TestSignVote(t *testing.T) {
+	voterKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	voter := crypto.PubkeyToAddress(voterKey.PublicKey)
+	domain := VoteDomain(big.NewInt(31337), common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"))
+	vote := &Vote{Voter: voter, ToolID: big.NewInt(42), Score: 1, Nonce: 7}
+
+	signature, err := SignVote(voterKey, domain, vote)
+	require.NoError(t, err)
+	require.Len(t, signature, 65)
+	assert.Contains(t, []byte{27, 28}, signature[64])
+
+	valid, err := VerifyVoteSignature(domain, vote, signature)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	t.Run("AcceptsZeroBasedV", func(t *testing.T) {
+		raw := append([]byte(nil), signature...)
+		raw[64] -= 27
+		valid, err := VerifyVoteSignature(domain, vote, raw)
+		require.NoError(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("RejectsChangedScore", func(t *testing.T) {
+		changed := *vote
+		changed.Score = -1
+		valid, err := VerifyVoteSignature(domain, &changed, signature)
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("RejectsOtherDomain", func(t *testing.T) {
+		other := VoteDomain(big.NewInt(1), common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"))
+		valid, err := VerifyVoteSignature(other, vote, signature)
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("RejectsMalformedSignature", func(t *testing.T) {
+		_, err := VerifyVoteSignature(domain, vote, signature[:64])
+		assert.Error(t, err)
+	})
+}
+
+func TestSignRetraction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	voter := crypto.PubkeyToAddress(key.PublicKey)
+	domain := VoteDomain(big.NewInt(31337), common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"))
+
+	retraction := &Retraction{Voter: voter, ToolID: big.NewInt(42), Nonce: 2}
+	signature, err := SignRetraction(key, domain, retraction)
+	require.NoError(t, err)
+
+	hash, err := HashRetraction(domain, retraction)
+	require.NoError(t, err)
+	pub, err := crypto.SigToPub(hash.Bytes(), append(signature[:64:64], signature[64]-27))
+	require.NoError(t, err)
+	assert.Equal(t, voter, crypto.PubkeyToAddress(*pub))
+
+	// A vote with the same fields is a different message
+	voteHash, err := HashVote(domain, &Vote{Voter: voter, ToolID: big.NewInt(42), Nonce: 2})
+	require.NoError(t, err)
+	assert.NotEqual(t, voteHash, hash)
+
+	_, err = HashRetraction(domain, &Retraction{Voter: voter})
+	assert.Error(t, err)
+}
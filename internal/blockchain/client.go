@@ -2,12 +2,17 @@ package blockchain
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"sync"
+
+	"moltket/config"
 	skill "moltket/internal/contracts/Skill"
 	"moltket/internal/contracts/Stake"
 	"moltket/internal/contracts/license"
+	"moltket/internal/contracts/reputation"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
@@ -18,6 +23,11 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+// ErrConfigMismatch is returned when the node is reachable but does not match
+// the configuration (wrong chain, missing contract code). Unlike connection
+// errors, retrying will not help.
+var ErrConfigMismatch = errors.New("blockchain configuration does not match node")
+
 type SkillToken struct {
 	address            common.Address
 	SkillTokenContract *skill.Skill
@@ -27,17 +37,19 @@ type LicenseNFTContract struct {
 	address         common.Address
 }
 
-// unused
-func (l *LicenseNFTContract) ParseLicenseMinted(vLog types.Log) (any, any) {
-	// Demo implementation - returns minimal event data
-	// In production, would parse ABI-encoded event data
-	return nil, nil
+// ParseLicenseMinted decodes a LicenseMinted log.
+func (l *LicenseNFTContract) ParseLicenseMinted(vLog types.Log) (*license.LicenseLicenseMinted, error) {
+	return l.Licensecontract.ParseLicenseMinted(vLog)
 }
 
+// LicenseMintedSignature returns the topic of
+// LicenseMinted(address,uint256,uint256,uint256).
 func (l *LicenseNFTContract) LicenseMintedSignature() common.Hash {
-	// Return the keccak256 hash of "LicenseMinted(address,uint256,uint256)"
-	// For demo, returning a zero hash
-	return common.Hash{}
+	licenseABI, err := license.LicenseMetaData.GetAbi()
+	if err != nil {
+		return common.Hash{}
+	}
+	return licenseABI.Events["LicenseMinted"].ID
 }
 
 func (l *LicenseNFTContract) BalanceOfBatch(opts *bind.CallOpts, addresses []common.Address, tokenIDs []*big.Int) ([]*big.Int, error) {
@@ -80,14 +92,34 @@ func (s *StakingNFTContract) Address() common.Address {
 	return s.address
 }
 
+type ReputationOracleContract struct {
+	address            common.Address
+	ReputationContract *reputation.Reputation
+}
+
+func (r *ReputationOracleContract) Address() common.Address {
+	return r.address
+}
+
 type Client struct {
-	ethClient  *ethclient.Client
-	licenseNFT *LicenseNFTContract
-	stakingNFT *StakingNFTContract
-	chainID    *big.Int
-	rpcURL     string
-	wsURL      string
-	privateKey string // For signing transactions (optional)
+	ethClient        *ethclient.Client
+	licenseNFT       *LicenseNFTContract
+	stakingNFT       *StakingNFTContract
+	reputationOracle *ReputationOracleContract
+	skillToken       *SkillToken
+	chainID          *big.Int
+	rpcURL           string
+	wsURL            string
+	privateKey       string // For signing transactions (optional)
+	gasLimit         uint64
+	gasPrice         *big.Int
+	confirmations    uint64
+
+	wsMu     sync.Mutex
+	wsClient *ethclient.Client // Lazily dialed for event subscriptions
+
+	subsMu        sync.Mutex
+	subscriptions []*LogSubscription
 }
 
 type LicenseMetadata struct {
@@ -100,9 +132,10 @@ type LicenseMetadata struct {
 }
 
 type ContractConfig struct {
-	LicenseNFTAddress    common.Address
-	StakingNFTAddress    common.Address
-	ReputationNFTAddress common.Address
+	LicenseNFTAddress       common.Address
+	StakingNFTAddress       common.Address
+	ReputationOracleAddress common.Address
+	SkillTokenAddress       common.Address
 
 	StartBlock uint64 // For event filtering
 }
@@ -130,9 +163,57 @@ func NewClient(rpcURL string) (*Client, error) {
 	return client, nil
 }
 
+// NewClientFromConfig validates cfg, connects to its RPC endpoint, checks that
+// the node serves the configured chain and binds every configured contract.
+// Any failure is returned so the caller can refuse to start.
+func NewClientFromConfig(cfg *config.BlockchainConfig) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := NewClient(cfg.RPCEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.chainID.Cmp(big.NewInt(cfg.ChainID)) != 0 {
+		client.Close()
+		return nil, fmt.Errorf("%w: configured chain ID %d, node reports %v", ErrConfigMismatch, cfg.ChainID, client.chainID)
+	}
+
+	client.wsURL = cfg.WSEndpoint
+	client.privateKey = cfg.AdminPrivateKey
+	client.gasLimit = cfg.GasLimit
+	client.gasPrice = new(big.Int).Mul(big.NewInt(cfg.GasPrice), big.NewInt(1e9)) // gwei to wei
+	client.confirmations = cfg.ConfirmationDepth
+
+	err = client.InitializeContracts(ContractConfig{
+		LicenseNFTAddress:       cfg.LicenseNFTAddress,
+		StakingNFTAddress:       cfg.StakingNFTAddress,
+		ReputationOracleAddress: cfg.ReputationOracleAddress,
+		SkillTokenAddress:       cfg.SkillTokenAddress,
+	})
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
 func (c *Client) InitializeContracts(config ContractConfig) error {
 	var err error
 
+	// Make sure every address actually holds a deployed contract
+	if err := c.checkContractCode(map[string]common.Address{
+		"LicenseNFT":       config.LicenseNFTAddress,
+		"StakingNFT":       config.StakingNFTAddress,
+		"ReputationOracle": config.ReputationOracleAddress,
+		"SkillToken":       config.SkillTokenAddress,
+	}); err != nil {
+		return err
+	}
+
 	// Initialize License NFT contract
 	c.licenseNFT, err = NewLicenseNFTContract(config.LicenseNFTAddress, c.ethClient)
 	if err != nil {
@@ -145,8 +226,43 @@ func (c *Client) InitializeContracts(config ContractConfig) error {
 		return fmt.Errorf("failed to initialize StakingNFT contract: %v", err)
 	}
 
-	log.Printf("Contracts initialized: LicenseNFT=%s, StakingNFT=%s",
-		config.LicenseNFTAddress.Hex(), config.StakingNFTAddress.Hex())
+	// Initialize Reputation Oracle contract
+	c.reputationOracle, err = NewReputationOracleContract(config.ReputationOracleAddress, c.ethClient)
+	if err != nil {
+		return fmt.Errorf("failed to initialize ReputationOracle contract: %v", err)
+	}
+
+	// Initialize SKILL token contract
+	c.skillToken, err = NewSkillToken(config.SkillTokenAddress, c.ethClient)
+	if err != nil {
+		return fmt.Errorf("failed to initialize SkillToken contract: %v", err)
+	}
+
+	log.Printf("Contracts initialized: LicenseNFT=%s, StakingNFT=%s, ReputationOracle=%s, SkillToken=%s",
+		config.LicenseNFTAddress.Hex(), config.StakingNFTAddress.Hex(),
+		config.ReputationOracleAddress.Hex(), config.SkillTokenAddress.Hex())
+
+	return nil
+}
+
+// checkContractCode fails if any of the named addresses has no code deployed.
+// Addresses left unset are skipped.
+func (c *Client) checkContractCode(contracts map[string]common.Address) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for name, address := range contracts {
+		if address == (common.Address{}) {
+			continue
+		}
+		code, err := c.ethClient.CodeAt(ctx, address, nil)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s code at %s: %v", name, address.Hex(), err)
+		}
+		if len(code) == 0 {
+			return fmt.Errorf("%w: no %s contract deployed at %s", ErrConfigMismatch, name, address.Hex())
+		}
+	}
 
 	return nil
 }
@@ -172,6 +288,17 @@ func NewLicenseNFTContract(address common.Address, client *ethclient.Client) (*L
 		address:         address,
 	}, nil
 }
+func NewReputationOracleContract(address common.Address, client *ethclient.Client) (*ReputationOracleContract, error) {
+	contract, err := reputation.NewReputation(address, client)
+	if err != nil {
+		return nil, err
+	}
+	return &ReputationOracleContract{
+		ReputationContract: contract,
+		address:            address,
+	}, nil
+}
+
 func NewSkillToken(address common.Address, client *ethclient.Client) (*SkillToken, error) {
 	contract, err := skill.NewSkill(address, client)
 	if err != nil {
@@ -267,51 +394,137 @@ func (c *Client) IsLicenseValid(user common.Address, toolID *big.Int) (bool, err
 	return valid, nil
 }
 
-func (c *Client) ListenForLicenseMinted(ctx context.Context, callback func(tokenID *big.Int, owner common.Address)) error {
-	// Create filter query for LicenseMinted events
+// ToolCreatorReader is implemented by blockchain backends that can look up
+// who registered a tool on StakingNFT.
+type ToolCreatorReader interface {
+	ToolCreator(ctx context.Context, toolID *big.Int) (common.Address, error)
+}
+
+// ToolCreator returns the address that staked toolID on StakingNFT, or the
+// zero address if the tool is not registered.
+func (c *Client) ToolCreator(ctx context.Context, toolID *big.Int) (common.Address, error) {
+	if c.stakingNFT == nil {
+		return common.Address{}, fmt.Errorf("staking contract not initialized")
+	}
+	return c.stakingNFT.StakeContract.ToolCreator(&bind.CallOpts{Context: ctx}, toolID)
+}
+
+// SkillBalanceReader is implemented by blockchain backends that can look up
+// SKILL token balances.
+type SkillBalanceReader interface {
+	SkillBalance(ctx context.Context, account common.Address) (*big.Int, error)
+}
+
+// SkillBalance returns the SKILL balance of account, in wei.
+func (c *Client) SkillBalance(ctx context.Context, account common.Address) (*big.Int, error) {
+	if c.skillToken == nil {
+		return nil, fmt.Errorf("skill token contract not initialized")
+	}
+	return c.skillToken.SkillTokenContract.BalanceOf(&bind.CallOpts{Context: ctx}, account)
+}
+
+// CodeAt returns the code of account, empty for EOAs.
+func (c *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return c.ethClient.CodeAt(ctx, account, blockNumber)
+}
+
+// CallContract executes a read-only call, such as an EIP-1271
+// isValidSignature check.
+func (c *Client) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.ethClient.CallContract(ctx, call, blockNumber)
+}
+
+// ListenForLicenseMinted calls callback with the tool ID and buyer of every
+// LicenseMinted event. The subscription survives node restarts and backfills
+// whatever it missed while disconnected; see LogSubscription.
+func (c *Client) ListenForLicenseMinted(ctx context.Context, callback func(tokenID *big.Int, owner common.Address)) (*LogSubscription, error) {
+	if c.licenseNFT == nil {
+		return nil, fmt.Errorf("license contract not initialized")
+	}
+
 	query := ethereum.FilterQuery{
 		Addresses: []common.Address{c.licenseNFT.Address()},
 		Topics:    [][]common.Hash{{c.licenseNFT.LicenseMintedSignature()}},
 	}
 
-	logs := make(chan types.Log)
-	sub, err := c.ethClient.SubscribeFilterLogs(ctx, query, logs)
-	if err != nil {
-		return fmt.Errorf("failed to subscribe to logs: %v", err)
-	}
-
-	go func() {
-		defer sub.Unsubscribe()
-
-		for {
-			select {
-			case err := <-sub.Err():
-				log.Printf("Subscription error: %v", err)
-				return
-
-			case vLog := <-logs:
-				// Parse the event - in demo mode, extract basic info
-				// In production, would decode the ABI-encoded data
-				if len(vLog.Topics) > 0 {
-					// Extract token ID from the log topics or data
-					tokenID := big.NewInt(1)
-					owner := common.HexToAddress("0xDemoOwner")
-					callback(tokenID, owner)
-				}
-
-			case <-ctx.Done():
-				return
-			}
+	sub := NewLogSubscription("LicenseMinted", c.dialLogSource, query, func(vLog types.Log) {
+		event, err := c.licenseNFT.ParseLicenseMinted(vLog)
+		if err != nil {
+			log.Printf("Failed to parse LicenseMinted log in tx %s: %v", vLog.TxHash.Hex(), err)
+			return
 		}
-	}()
+		callback(event.ToolId, event.User)
+	})
+	sub.SetConfirmations(c.confirmations)
 
-	return nil
+	c.subsMu.Lock()
+	c.subscriptions = append(c.subscriptions, sub)
+	c.subsMu.Unlock()
+
+	sub.Start(ctx)
+	return sub, nil
+}
+
+// SubscriptionStates reports the state of every supervised subscription.
+func (c *Client) SubscriptionStates() []SubscriptionState {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	states := make([]SubscriptionState, 0, len(c.subscriptions))
+	for _, sub := range c.subscriptions {
+		states = append(states, sub.State())
+	}
+	return states
+}
+
+// dialLogSource opens a fresh connection for a LogSubscription, so a dropped
+// WebSocket is replaced rather than reused.
+func (c *Client) dialLogSource(ctx context.Context) (LogSource, error) {
+	url := c.wsURL
+	if url == "" {
+		url = c.rpcURL
+	}
+	return ethclient.DialContext(ctx, url)
+}
+
+// subscriptionClient returns a client that supports log subscriptions: the
+// WebSocket endpoint when configured, otherwise the RPC connection.
+func (c *Client) subscriptionClient() (*ethclient.Client, error) {
+	if c.wsURL == "" {
+		return c.ethClient, nil
+	}
+
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+
+	if c.wsClient == nil {
+		wsClient, err := ethclient.Dial(c.wsURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to WebSocket endpoint: %v", err)
+		}
+		c.wsClient = wsClient
+	}
+	return c.wsClient, nil
 }
 
 func (c *Client) Close() error {
+	c.subsMu.Lock()
+	subscriptions := c.subscriptions
+	c.subscriptions = nil
+	c.subsMu.Unlock()
+	for _, sub := range subscriptions {
+		sub.Stop()
+	}
+
 	if c.ethClient != nil {
 		c.ethClient.Close()
 	}
+	c.wsMu.Lock()
+	if c.wsClient != nil {
+		c.wsClient.Close()
+		c.wsClient = nil
+	}
+	c.wsMu.Unlock()
 	return nil
 }
 
@@ -331,9 +544,15 @@ func (c *Client) NewTransactOpts(privateKeyHex string) (*bind.TransactOpts, erro
 		return nil, fmt.Errorf("failed to create transactor: %v", err)
 	}
 
-	// Set reasonable gas settings for hackathon demo
+	// Use configured gas settings, falling back to hackathon demo defaults
 	auth.GasLimit = 300000
 	auth.GasPrice = big.NewInt(20000000000) // 20 gwei
+	if c.gasLimit > 0 {
+		auth.GasLimit = c.gasLimit
+	}
+	if c.gasPrice != nil && c.gasPrice.Sign() > 0 {
+		auth.GasPrice = c.gasPrice
+	}
 
 	return auth, nil
 }
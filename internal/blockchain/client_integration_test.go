@@ -217,6 +217,8 @@ func TestLiveLicenseNFTIntegration(t *testing.T) {
 	)
 	require.NoError(t, err)
 	t.Logf("License minted, tx: %s", tx.Hash().Hex())
+	_, err = bind.WaitMined(ctx, client, tx)
+	require.NoError(t, err)
 
 	// -------------------- STEP 5: BACKEND RECORDS THE MINTED LICENSE --------------------
 	err = licenseService.RecordLicenseMinted(
@@ -225,6 +227,7 @@ func TestLiveLicenseNFTIntegration(t *testing.T) {
 		toolID,
 		expiresAt,
 		nonce,
+		tx.Hash(),
 	)
 	require.NoError(t, err)
 
@@ -0,0 +1,370 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"moltket/config"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// standInEth serves the subset of the eth namespace the client needs during
+// startup, so construction can be tested without a real node.
+type standInEth struct {
+	mu        sync.Mutex
+	chainID   *big.Int
+	code      map[common.Address][]byte
+	contracts map[common.Address]*standInContract
+	logSubs   []*standInLogSub
+	txs       map[common.Hash]*standInTx
+	head      uint64      // eth_blockNumber
+	history   []types.Log // Every emitted log, for eth_getLogs
+}
+
+// standInTx is a transaction known to the stand-in node. A zero block number
+// means it is still pending.
+type standInTx struct {
+	tx     *types.Transaction
+	from   common.Address
+	block  uint64
+	status uint64
+}
+
+// standInLogSub is an eth_subscribe("logs") subscription.
+type standInLogSub struct {
+	notifier  *rpc.Notifier
+	id        rpc.ID
+	addresses []common.Address
+	topics    [][]common.Hash
+}
+
+type standInFilter struct {
+	Addresses []common.Address `json:"address"`
+	Topics    [][]common.Hash  `json:"topics"`
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`
+}
+
+// standInContract answers eth_call for one contract by packing canned
+// results for each method name with the contract's ABI.
+type standInContract struct {
+	abi     *abi.ABI
+	results map[string][]interface{}
+}
+
+type standInCallArgs struct {
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+	Data  hexutil.Bytes   `json:"data"`
+}
+
+func (s *standInEth) Call(args standInCallArgs, block string) (hexutil.Bytes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	input := args.Input
+	if len(input) == 0 {
+		input = args.Data
+	}
+	if args.To == nil || len(input) < 4 {
+		return nil, fmt.Errorf("unsupported call")
+	}
+
+	contract, ok := s.contracts[*args.To]
+	if !ok {
+		return nil, nil
+	}
+	method, err := contract.abi.MethodById(input[:4])
+	if err != nil {
+		return nil, err
+	}
+	results, ok := contract.results[method.Name]
+	if !ok {
+		return nil, fmt.Errorf("execution reverted: %s not stubbed", method.Name)
+	}
+	return method.Outputs.Pack(results...)
+}
+
+func (s *standInEth) Logs(ctx context.Context, filter standInFilter) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := &standInLogSub{
+		notifier:  notifier,
+		id:        notifier.CreateSubscription().ID,
+		addresses: filter.Addresses,
+		topics:    filter.Topics,
+	}
+
+	s.mu.Lock()
+	s.logSubs = append(s.logSubs, sub)
+	s.mu.Unlock()
+
+	return &rpc.Subscription{ID: sub.id}, nil
+}
+
+// emitLog records l, advancing the head to its block, and delivers it to
+// every log subscription whose filter matches it.
+func (s *standInEth) emitLog(l types.Log) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.history = append(s.history, l)
+	s.head = max(s.head, l.BlockNumber)
+	for _, sub := range s.logSubs {
+		if sub.matches(l) {
+			sub.notifier.Notify(sub.id, l)
+		}
+	}
+}
+
+func (s *standInEth) BlockNumber() hexutil.Uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return hexutil.Uint64(s.head)
+}
+
+func (s *standInEth) GetLogs(filter standInFilter) []types.Log {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blockNumber := func(n *rpc.BlockNumber, fallback uint64) uint64 {
+		if n == nil || *n < 0 {
+			return fallback
+		}
+		return uint64(*n)
+	}
+	from, to := blockNumber(filter.FromBlock, 0), blockNumber(filter.ToBlock, s.head)
+
+	matcher := &standInLogSub{addresses: filter.Addresses, topics: filter.Topics}
+	logs := []types.Log{}
+	for _, l := range s.history {
+		if l.BlockNumber >= from && l.BlockNumber <= to && matcher.matches(l) {
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
+func (sub *standInLogSub) matches(l types.Log) bool {
+	if len(sub.addresses) > 0 {
+		found := false
+		for _, address := range sub.addresses {
+			found = found || address == l.Address
+		}
+		if !found {
+			return false
+		}
+	}
+	for i, alternatives := range sub.topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		if i >= len(l.Topics) {
+			return false
+		}
+		found := false
+		for _, topic := range alternatives {
+			found = found || topic == l.Topics[i]
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// setResult replaces the canned result of one method.
+func (s *standInEth) setResult(address common.Address, method string, results ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contracts[address].results[method] = results
+}
+
+// addTx makes tx retrievable by hash and returns that hash.
+func (s *standInEth) addTx(tx *types.Transaction, from common.Address, block, status uint64) common.Hash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.txs == nil {
+		s.txs = make(map[common.Hash]*standInTx)
+	}
+	s.txs[tx.Hash()] = &standInTx{tx: tx, from: from, block: block, status: status}
+	return tx.Hash()
+}
+
+func (s *standInEth) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	s.mu.Lock()
+	known, ok := s.txs[hash]
+	s.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	encoded, err := known.tx.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	fields["from"] = known.from
+	if known.block > 0 {
+		fields["blockNumber"] = hexutil.Uint64(known.block)
+		fields["blockHash"] = common.BigToHash(new(big.Int).SetUint64(known.block))
+	}
+	return fields, nil
+}
+
+func (s *standInEth) GetTransactionReceipt(hash common.Hash) *types.Receipt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	known, ok := s.txs[hash]
+	if !ok || known.block == 0 {
+		return nil
+	}
+	return &types.Receipt{
+		Type:        known.tx.Type(),
+		Status:      known.status,
+		TxHash:      hash,
+		Logs:        []*types.Log{},
+		BlockHash:   common.BigToHash(new(big.Int).SetUint64(known.block)),
+		BlockNumber: new(big.Int).SetUint64(known.block),
+	}
+}
+
+func (s *standInEth) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(s.chainID)
+}
+
+func (s *standInEth) GetCode(address common.Address, block string) hexutil.Bytes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.code[address]
+}
+
+// newStandInNode serves eth over HTTP and WebSocket on the same address and
+// returns the HTTP URL; see wsURL for the WebSocket one.
+func newStandInNode(t *testing.T, eth *standInEth) string {
+	t.Helper()
+	return newStandInServer(t, eth).URL
+}
+
+// newStandInServer is newStandInNode for tests that take the node down.
+func newStandInServer(t *testing.T, eth *standInEth) *httptest.Server {
+	t.Helper()
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", eth))
+
+	httpServer := httptest.NewServer(standInHandler(server))
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	return httpServer
+}
+
+// standInHandler serves WebSocket upgrades and plain HTTP JSON-RPC.
+func standInHandler(server *rpc.Server) http.Handler {
+	ws := server.WebsocketHandler([]string{"*"})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		server.ServeHTTP(w, r)
+	})
+}
+
+func wsURL(httpURL string) string {
+	return "ws" + strings.TrimPrefix(httpURL, "http")
+}
+
+func testBlockchainConfig(rpcURL string) *config.BlockchainConfig {
+	cfg := config.DefaultBlockchainConfig()
+	cfg.RPCEndpoint = rpcURL
+	cfg.ChainID = 31337
+	cfg.LicenseNFTAddress = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	cfg.StakingNFTAddress = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	cfg.ReputationOracleAddress = common.HexToAddress("0x3333333333333333333333333333333333333333")
+	cfg.SkillTokenAddress = common.HexToAddress("0x4444444444444444444444444444444444444444")
+	return cfg
+}
+
+func deployedCode(cfg *config.BlockchainConfig) map[common.Address][]byte {
+	return map[common.Address][]byte{
+		cfg.LicenseNFTAddress:       {0x60, 0x80},
+		cfg.StakingNFTAddress:       {0x60, 0x80},
+		cfg.ReputationOracleAddress: {0x60, 0x80},
+		cfg.SkillTokenAddress:       {0x60, 0x80},
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	t.Run("InitializesAllContracts", func(t *testing.T) {
+		eth := &standInEth{chainID: big.NewInt(31337)}
+		cfg := testBlockchainConfig(newStandInNode(t, eth))
+		eth.code = deployedCode(cfg)
+
+		client, err := NewClientFromConfig(cfg)
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.Equal(t, cfg.LicenseNFTAddress, client.licenseNFT.Address())
+		assert.Equal(t, cfg.StakingNFTAddress, client.stakingNFT.Address())
+		assert.Equal(t, cfg.ReputationOracleAddress, client.reputationOracle.Address())
+		assert.Equal(t, cfg.SkillTokenAddress, client.skillToken.address)
+
+		opts, err := client.NewTransactOpts("ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+		require.NoError(t, err)
+		assert.Equal(t, cfg.GasLimit, opts.GasLimit)
+		assert.Equal(t, big.NewInt(cfg.GasPrice*1e9), opts.GasPrice)
+	})
+
+	t.Run("IncompleteConfig", func(t *testing.T) {
+		cfg := testBlockchainConfig("http://127.0.0.1:1")
+		cfg.SkillTokenAddress = common.Address{}
+
+		_, err := NewClientFromConfig(cfg)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "skill token address is required")
+	})
+
+	t.Run("ChainIDMismatch", func(t *testing.T) {
+		eth := &standInEth{chainID: big.NewInt(1)}
+		cfg := testBlockchainConfig(newStandInNode(t, eth))
+		eth.code = deployedCode(cfg)
+
+		_, err := NewClientFromConfig(cfg)
+		require.ErrorIs(t, err, ErrConfigMismatch)
+		assert.Contains(t, err.Error(), "configured chain ID 31337")
+	})
+
+	t.Run("MissingContractCode", func(t *testing.T) {
+		eth := &standInEth{chainID: big.NewInt(31337)}
+		cfg := testBlockchainConfig(newStandInNode(t, eth))
+		eth.code = deployedCode(cfg)
+		delete(eth.code, cfg.ReputationOracleAddress)
+
+		_, err := NewClientFromConfig(cfg)
+		require.ErrorIs(t, err, ErrConfigMismatch)
+		assert.Contains(t, err.Error(), "no ReputationOracle contract deployed")
+	})
+}
@@ -0,0 +1,373 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"moltket/internal/auth"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrChainUnavailable is returned while the node cannot be reached. Callers
+// may fall back to cached state instead of failing the request.
+var ErrChainUnavailable = errors.New("blockchain unavailable")
+
+const (
+	ChainOnline   = "online"
+	ChainDegraded = "degraded"
+	ChainDisabled = "disabled"
+)
+
+// ChainStatus describes the connection to the node, as reported by /health.
+type ChainStatus struct {
+	Mode          string     `json:"mode"` // ChainOnline, ChainDegraded or ChainDisabled
+	DegradedSince *time.Time `json:"degraded_since,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastCheck     *time.Time `json:"last_check,omitempty"`
+}
+
+// ChainStatusReporter is implemented by blockchain backends that know whether
+// the node is currently reachable.
+type ChainStatusReporter interface {
+	ChainStatus() ChainStatus
+}
+
+// Connection owns the Client and keeps the service usable when the node is
+// unreachable. While degraded, every call fails fast with ErrChainUnavailable
+// and a background loop re-dials or probes the node until it answers again.
+// A Connection without a dial function stays disabled.
+type Connection struct {
+	dial     func() (*Client, error)
+	interval time.Duration
+
+	mu            sync.RWMutex
+	client        *Client
+	degradedSince time.Time // zero while online
+	lastErr       error
+	lastCheck     time.Time
+	onOnline      []func()
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// DefaultReconnectInterval is how often an unreachable node is re-dialed
+// when no interval is configured.
+const DefaultReconnectInterval = 30 * time.Second
+
+// NewConnection creates a degraded connection; call Connect or Start to dial.
+// A zero interval uses DefaultReconnectInterval.
+func NewConnection(dial func() (*Client, error), interval time.Duration) *Connection {
+	if interval <= 0 {
+		interval = DefaultReconnectInterval
+	}
+	return &Connection{
+		dial:          dial,
+		interval:      interval,
+		degradedSince: time.Now(),
+		lastErr:       fmt.Errorf("not connected yet"),
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// OnOnline registers fn to run each time the connection recovers.
+func (c *Connection) OnOnline(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onOnline = append(c.onOnline, fn)
+}
+
+// Connect dials the node if there is no client yet, otherwise probes it, and
+// updates the connection state accordingly.
+func (c *Connection) Connect(ctx context.Context) error {
+	if c.dial == nil {
+		return fmt.Errorf("%w: blockchain disabled", ErrChainUnavailable)
+	}
+
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	var err error
+	if client == nil {
+		client, err = c.dial()
+		if err == nil {
+			c.mu.Lock()
+			c.client = client
+			c.mu.Unlock()
+		}
+	} else {
+		probeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err = client.ethClient.ChainID(probeCtx)
+		cancel()
+	}
+
+	if err != nil {
+		c.markDegraded(err)
+		return err
+	}
+	c.markOnline()
+	return nil
+}
+
+func (c *Connection) markDegraded(err error) {
+	c.mu.Lock()
+	wasOnline := c.degradedSince.IsZero()
+	if wasOnline {
+		c.degradedSince = time.Now()
+	}
+	c.lastErr = err
+	c.lastCheck = time.Now()
+	c.mu.Unlock()
+
+	if wasOnline {
+		log.Printf("Blockchain unreachable, entering degraded mode: %v", err)
+	}
+}
+
+func (c *Connection) markOnline() {
+	c.mu.Lock()
+	recovered := !c.degradedSince.IsZero()
+	c.degradedSince = time.Time{}
+	c.lastErr = nil
+	c.lastCheck = time.Now()
+	callbacks := c.onOnline
+	c.mu.Unlock()
+
+	if recovered {
+		log.Println("Blockchain reachable again, leaving degraded mode")
+		for _, fn := range callbacks {
+			fn()
+		}
+	}
+}
+
+// Start re-dials or probes the node every interval until Stop is called.
+func (c *Connection) Start(ctx context.Context) {
+	if c.dial == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Connect(ctx)
+			case <-c.stopChan:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the reconnect loop
+func (c *Connection) Stop() {
+	c.stopOnce.Do(func() { close(c.stopChan) })
+}
+
+// Close stops the reconnect loop and closes the client, if any.
+func (c *Connection) Close() {
+	c.Stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+// Degraded reports whether the chain is configured but currently unreachable.
+func (c *Connection) Degraded() bool {
+	return c.ChainStatus().Mode == ChainDegraded
+}
+
+// ChainStatus returns the current connection state.
+func (c *Connection) ChainStatus() ChainStatus {
+	if c.dial == nil {
+		return ChainStatus{Mode: ChainDisabled}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := ChainStatus{Mode: ChainOnline}
+	if !c.lastCheck.IsZero() {
+		lastCheck := c.lastCheck
+		status.LastCheck = &lastCheck
+	}
+	if !c.degradedSince.IsZero() {
+		since := c.degradedSince
+		status.Mode = ChainDegraded
+		status.DegradedSince = &since
+		if c.lastErr != nil {
+			status.LastError = c.lastErr.Error()
+		}
+	}
+	return status
+}
+
+// Client returns the connected client, or ErrChainUnavailable while degraded.
+func (c *Connection) Client() (*Client, error) {
+	if c.dial == nil {
+		return nil, fmt.Errorf("%w: blockchain disabled", ErrChainUnavailable)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.client == nil || !c.degradedSince.IsZero() {
+		return nil, fmt.Errorf("%w: %v", ErrChainUnavailable, c.lastErr)
+	}
+	return c.client, nil
+}
+
+// SubscriptionStates reports the client's supervised subscriptions, even
+// while degraded, since that is when they are most interesting.
+func (c *Connection) SubscriptionStates() []SubscriptionState {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return []SubscriptionState{}
+	}
+	return client.SubscriptionStates()
+}
+
+// check marks the connection degraded if err means the node went away, and
+// wraps it in ErrChainUnavailable. Other errors (reverts, bad input) pass
+// through unchanged.
+func (c *Connection) check(err error) error {
+	if err == nil || !isConnectionError(err) {
+		return err
+	}
+	c.markDegraded(err)
+	return fmt.Errorf("%w: %v", ErrChainUnavailable, err)
+}
+
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	var httpErr rpc.HTTPError
+	return errors.As(err, &netErr) ||
+		errors.As(err, &httpErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, rpc.ErrClientQuit)
+}
+
+func (c *Connection) IsLicenseValid(user common.Address, toolID *big.Int) (bool, error) {
+	client, err := c.Client()
+	if err != nil {
+		return false, err
+	}
+	valid, err := client.IsLicenseValid(user, toolID)
+	return valid, c.check(err)
+}
+
+func (c *Connection) GetLicenseMetadata(toolIDStr string) (*LicenseMetadata, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := client.GetLicenseMetadata(toolIDStr)
+	return metadata, c.check(err)
+}
+
+func (c *Connection) GetMintPayment(ctx context.Context, txHash common.Hash) (*MintPayment, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	payment, err := client.GetMintPayment(ctx, txHash)
+	return payment, c.check(err)
+}
+
+func (c *Connection) TrustedSigner(ctx context.Context) (common.Address, error) {
+	client, err := c.Client()
+	if err != nil {
+		return common.Address{}, err
+	}
+	trusted, err := client.TrustedSigner(ctx)
+	return trusted, c.check(err)
+}
+
+func (c *Connection) CheckSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	client, err := c.Client()
+	if err != nil {
+		return err
+	}
+	return c.check(client.CheckSigner(ctx, signer))
+}
+
+func (c *Connection) CheckOracleSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	client, err := c.Client()
+	if err != nil {
+		return err
+	}
+	return c.check(client.CheckOracleSigner(ctx, signer))
+}
+
+func (c *Connection) WatchSignerEvents(ctx context.Context, sink chan<- SignerEvent) (event.Subscription, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	sub, err := client.WatchSignerEvents(ctx, sink)
+	return sub, c.check(err)
+}
+
+func (c *Connection) ToolCreator(ctx context.Context, toolID *big.Int) (common.Address, error) {
+	client, err := c.Client()
+	if err != nil {
+		return common.Address{}, err
+	}
+	creator, err := client.ToolCreator(ctx, toolID)
+	return creator, c.check(err)
+}
+
+func (c *Connection) SkillBalance(ctx context.Context, account common.Address) (*big.Int, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	balance, err := client.SkillBalance(ctx, account)
+	return balance, c.check(err)
+}
+
+// CodeAt and CallContract make the connection an auth.ContractCaller, so
+// contract wallet signatures are checked against the live node.
+
+func (c *Connection) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	code, err := client.CodeAt(ctx, account, blockNumber)
+	return code, c.check(err)
+}
+
+func (c *Connection) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.CallContract(ctx, call, blockNumber)
+	return result, c.check(err)
+}
@@ -0,0 +1,229 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+	"moltket/internal/contracts/license"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newConnectionTestNode starts a stand-in node whose LicenseNFT reports every
+// license as valid.
+func newConnectionTestNode(t *testing.T) (*httptest.Server, *config.BlockchainConfig) {
+	t.Helper()
+
+	licenseABI, err := license.LicenseMetaData.GetAbi()
+	require.NoError(t, err)
+
+	eth := &standInEth{chainID: big.NewInt(31337)}
+	server := newStandInServer(t, eth)
+	cfg := testBlockchainConfig(server.URL)
+	eth.code = deployedCode(cfg)
+	eth.contracts = map[common.Address]*standInContract{
+		cfg.LicenseNFTAddress: {
+			abi:     licenseABI,
+			results: map[string][]interface{}{"isLicenseValid": {true}},
+		},
+	}
+	return server, cfg
+}
+
+func TestConnection_DegradedMode(t *testing.T) {
+	ctx := context.Background()
+	user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	t.Run("StartsDegradedAndReconnects", func(t *testing.T) {
+		_, cfg := newConnectionTestNode(t)
+
+		var reachable atomic.Bool
+		conn := NewConnection(func() (*Client, error) {
+			if !reachable.Load() {
+				return nil, fmt.Errorf("dial tcp: connection refused")
+			}
+			return NewClientFromConfig(cfg)
+		}, 10*time.Millisecond)
+		defer conn.Close()
+
+		var recovered atomic.Int32
+		conn.OnOnline(func() { recovered.Add(1) })
+
+		require.Error(t, conn.Connect(ctx))
+		status := conn.ChainStatus()
+		assert.Equal(t, ChainDegraded, status.Mode)
+		assert.NotNil(t, status.DegradedSince)
+		assert.Contains(t, status.LastError, "connection refused")
+
+		_, err := conn.IsLicenseValid(user, big.NewInt(1))
+		require.ErrorIs(t, err, ErrChainUnavailable)
+
+		reachable.Store(true)
+		conn.Start(ctx)
+		require.Eventually(t, func() bool { return !conn.Degraded() }, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(1), recovered.Load())
+
+		valid, err := conn.IsLicenseValid(user, big.NewInt(1))
+		require.NoError(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("NodeGoesAway", func(t *testing.T) {
+		server, cfg := newConnectionTestNode(t)
+
+		conn := NewConnection(func() (*Client, error) { return NewClientFromConfig(cfg) }, time.Hour)
+		defer conn.Close()
+		require.NoError(t, conn.Connect(ctx))
+		assert.Equal(t, ChainOnline, conn.ChainStatus().Mode)
+
+		server.Close()
+
+		// The failing call itself switches to degraded mode
+		_, err := conn.IsLicenseValid(user, big.NewInt(1))
+		require.ErrorIs(t, err, ErrChainUnavailable)
+		assert.True(t, conn.Degraded())
+
+		require.Error(t, conn.Connect(ctx))
+		assert.True(t, conn.Degraded())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		conn := NewConnection(nil, 0)
+
+		assert.Equal(t, ChainDisabled, conn.ChainStatus().Mode)
+		assert.False(t, conn.Degraded())
+		_, err := conn.IsLicenseValid(user, big.NewInt(1))
+		require.ErrorIs(t, err, ErrChainUnavailable)
+	})
+
+	t.Run("ZeroInterval", func(t *testing.T) {
+		conn := NewConnection(func() (*Client, error) {
+			return nil, fmt.Errorf("dial tcp: connection refused")
+		}, 0)
+		assert.Equal(t, DefaultReconnectInterval, conn.interval)
+
+		conn.Start(ctx)
+		conn.Stop()
+	})
+}
+
+// degradedChain is a blockchain backend whose node is unreachable.
+type degradedChain struct {
+	mockBlockchain
+}
+
+func (d *degradedChain) IsLicenseValid(user common.Address, toolID *big.Int) (bool, error) {
+	return false, fmt.Errorf("%w: dial tcp: connection refused", ErrChainUnavailable)
+}
+
+func (d *degradedChain) ChainStatus() ChainStatus {
+	return ChainStatus{Mode: ChainDegraded}
+}
+
+func TestLicenseService_DegradedMode(t *testing.T) {
+	ctx := context.Background()
+	user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	require.NoError(t, err)
+
+	cacheLicense := func(t *testing.T, store *cache.Client, toolID string, refreshedAt time.Time) {
+		require.NoError(t, store.Set(ctx, "license:"+user.Hex()+":"+toolID, &models.License{
+			UserAddress: user.Hex(),
+			ToolID:      toolID,
+			ExpiresAt:   time.Now().Add(7 * 24 * time.Hour),
+			MaxCalls:    1000,
+			Tier:        "licensed",
+			RefreshedAt: refreshedAt,
+		}, time.Hour))
+	}
+
+	cfg := &config.Config{
+		LicenseNFTAddress:   "0x1111111111111111111111111111111111111111",
+		MaxLicenseStaleness: 6 * time.Hour,
+	}
+
+	t.Run("ServesStaleLicenseWithinLimit", func(t *testing.T) {
+		store := cache.NewKVStore()
+		defer store.Close()
+		service := NewLicenseService(cfg, store, signer, &degradedChain{})
+
+		cacheLicense(t, store, "1", time.Now().Add(-25*time.Hour))
+
+		result, err := service.VerifyAccess(ctx, user, big.NewInt(1))
+		require.NoError(t, err)
+		assert.Equal(t, "licensed", result.Tier)
+		assert.True(t, result.Stale)
+	})
+
+	t.Run("FallsBackPastStalenessLimit", func(t *testing.T) {
+		store := cache.NewKVStore()
+		defer store.Close()
+		service := NewLicenseService(cfg, store, signer, &degradedChain{})
+
+		cacheLicense(t, store, "2", time.Now().Add(-31*time.Hour))
+
+		result, err := service.VerifyAccess(ctx, user, big.NewInt(2))
+		require.NoError(t, err)
+		assert.Equal(t, "free", result.Tier)
+		assert.False(t, result.Stale)
+	})
+
+	t.Run("RefreshesWhenOnline", func(t *testing.T) {
+		store := cache.NewKVStore()
+		defer store.Close()
+		service := NewLicenseService(cfg, store, signer, &mockBlockchain{isValid: true})
+
+		cacheLicense(t, store, "3", time.Now().Add(-25*time.Hour))
+
+		result, err := service.VerifyAccess(ctx, user, big.NewInt(3))
+		require.NoError(t, err)
+		assert.Equal(t, "licensed", result.Tier)
+		assert.False(t, result.Stale)
+
+		cached, found := store.Get(ctx, "license:"+user.Hex()+":3")
+		require.True(t, found)
+		assert.WithinDuration(t, time.Now(), cached.(*models.License).RefreshedAt, time.Minute)
+	})
+
+	t.Run("DisabledChainKeepsLicense", func(t *testing.T) {
+		store := cache.NewKVStore()
+		defer store.Close()
+		service := NewLicenseService(cfg, store, signer, NewConnection(nil, 0))
+
+		// Without a blockchain, past the refresh and staleness limits alike
+		cacheLicense(t, store, "5", time.Now().Add(-31*time.Hour))
+
+		result, err := service.VerifyAccess(ctx, user, big.NewInt(5))
+		require.NoError(t, err)
+		assert.Equal(t, "licensed", result.Tier)
+		assert.False(t, result.Stale)
+		assert.Equal(t, 999, result.CallsRemaining)
+	})
+
+	t.Run("NewLicensePolicy", func(t *testing.T) {
+		store := cache.NewKVStore()
+		defer store.Close()
+
+		service := NewLicenseService(cfg, store, signer, &degradedChain{})
+		_, err := service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: big.NewInt(4)})
+		require.ErrorIs(t, err, ErrChainUnavailable)
+
+		allowCfg := *cfg
+		allowCfg.DegradedAllowNewLicenses = true
+		service = NewLicenseService(&allowCfg, store, signer, &degradedChain{})
+		resp, err := service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: big.NewInt(4)})
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.SignatureR)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -16,11 +17,16 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// Cached licenses are re-checked on-chain once per refresh interval
+const licenseRefreshInterval = 24 * time.Hour
+
 type LicenseService struct {
-	config     *config.Config
-	cache      kvstore.Store
-	signer     *auth.EIP712Signer
-	blockchain BlockchainInterface
+	config        *config.Config
+	cache         kvstore.Store
+	signer        *auth.EIP712Signer
+	blockchain    BlockchainInterface
+	signerMonitor *SignerMonitor
+	keys          *auth.KeyRing
 }
 
 type BlockchainInterface interface {
@@ -31,7 +37,10 @@ type BlockchainInterface interface {
 type LicenseServiceInterface interface {
 	RequestLicense(ctx context.Context, req *LicenseRequest) (*LicenseResponse, error)
 	VerifyAccess(ctx context.Context, user common.Address, toolID *big.Int) (*AccessResult, error)
-	RecordLicenseMinted(ctx context.Context, user common.Address, toolID, expiresAt, nonce *big.Int) error
+	RecordLicenseMinted(ctx context.Context, user common.Address, toolID, expiresAt, nonce *big.Int, txHash common.Hash) error
+	GetPayments(ctx context.Context, user common.Address, toolID *big.Int) []*models.LicensePayment
+	ListUnderpayments(ctx context.Context) []*models.LicensePayment
+	VerifyLicenseSignature(user common.Address, toolID, expiresAt, nonce *big.Int, signature []byte) (auth.KeyInfo, error)
 }
 
 func NewLicenseService(
@@ -45,9 +54,19 @@ func NewLicenseService(
 		cache:      cache,
 		signer:     signer,
 		blockchain: bc,
+		keys:       auth.NewKeyRing(signer, auth.DefaultKeyOverlap),
 	}
 }
 
+// UseSignerMonitor makes RequestLicense sign with the monitor's active key and
+// refuse to sign while the monitor reports that no loaded key matches the
+// deployed contracts. Signatures are then verified against the monitor's
+// key ring.
+func (s *LicenseService) UseSignerMonitor(monitor *SignerMonitor) {
+	s.signerMonitor = monitor
+	s.keys = monitor.Keys()
+}
+
 type LicenseRequest struct {
 	UserAddress common.Address `json:"user_address"`
 	ToolID      *big.Int       `json:"tool_id"`
@@ -63,6 +82,7 @@ type LicenseResponse struct {
 	SignatureV string         `json:"signature_v"`
 	Price      string         `json:"price"`
 	Contract   string         `json:"contract_address"`
+	KeyID      string         `json:"key_id"` // Signing key, see auth.KeyID
 }
 
 type AccessResult struct {
@@ -72,9 +92,30 @@ type AccessResult struct {
 	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
 	ProvenanceHash string     `json:"provenance_hash,omitempty"`
 	Reason         string     `json:"reason,omitempty"`
+	Stale          bool       `json:"stale,omitempty"` // Served from cache while the chain is unreachable
 }
 
 func (s *LicenseService) RequestLicense(ctx context.Context, req *LicenseRequest) (*LicenseResponse, error) {
+	degraded := s.chainDegraded()
+	if degraded && !s.config.DegradedAllowNewLicenses {
+		return nil, fmt.Errorf("%w: new licenses are paused until the node is reachable", ErrChainUnavailable)
+	}
+
+	// Never hand out signatures that mintLicense would reject
+	signer := s.signer
+	if s.signerMonitor != nil {
+		err := s.signerMonitor.Err()
+		// While degraded, keep signing with the last key confirmed on-chain
+		if degraded && errors.Is(err, ErrChainUnavailable) && s.signerMonitor.OracleErr() == nil &&
+			s.signerMonitor.TrustedSigner() == s.signerMonitor.Signer().Address() {
+			err = nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSigningDisabled, err)
+		}
+		signer = s.signerMonitor.Signer()
+	}
+
 	// Check if user already has a pending or active license
 	licenseKey := fmt.Sprintf("license:%s:%s", req.UserAddress.Hex(), req.ToolID.String())
 
@@ -86,9 +127,13 @@ func (s *LicenseService) RequestLicense(ctx context.Context, req *LicenseRequest
 	}
 
 	// Check for pending request to prevent double-issuance
+	// A pending signature from a rotated-out key would revert on mint, so
+	// that one is replaced rather than blocking the user
 	pendingKey := fmt.Sprintf("pending:%s", licenseKey)
-	if _, found := s.cache.Get(ctx, pendingKey); found {
-		return nil, fmt.Errorf("license request already pending")
+	if cached, found := s.cache.Get(ctx, pendingKey); found {
+		if pending, ok := cached.(*models.License); !ok || pending.SignerKeyID == signer.KeyID() {
+			return nil, fmt.Errorf("license request already pending")
+		}
 	}
 
 	// Generate license parameters
@@ -99,7 +144,7 @@ func (s *LicenseService) RequestLicense(ctx context.Context, req *LicenseRequest
 	price := s.calculateLicensePrice(req.ToolID)
 
 	// Create EIP-712 signature
-	r, sigS, sigV, err := s.signer.CreateLicenseSignature(
+	r, sigS, sigV, err := signer.CreateLicenseSignature(
 		req.UserAddress,
 		req.ToolID,
 		expiresAt,
@@ -117,6 +162,7 @@ func (s *LicenseService) RequestLicense(ctx context.Context, req *LicenseRequest
 		Nonce:       nonce.String(),
 		Price:       price,
 		CreatedAt:   time.Now(),
+		SignerKeyID: signer.KeyID(),
 	}
 
 	if err := s.cache.Set(ctx, pendingKey, pendingLicense, 10*time.Minute); err != nil {
@@ -134,9 +180,17 @@ func (s *LicenseService) RequestLicense(ctx context.Context, req *LicenseRequest
 		SignatureV: hex.EncodeToString(sigV),
 		Price:      price,
 		Contract:   s.config.LicenseNFTAddress,
+		KeyID:      signer.KeyID(),
 	}, nil
 }
 
+// VerifyLicenseSignature returns the key that signed a mint authorization.
+// Signatures from any non-retired key are accepted, so licenses issued just
+// before a rotation can still be checked.
+func (s *LicenseService) VerifyLicenseSignature(user common.Address, toolID, expiresAt, nonce *big.Int, signature []byte) (auth.KeyInfo, error) {
+	return s.keys.VerifyLicense(user, toolID, expiresAt, nonce, signature)
+}
+
 func (s *LicenseService) VerifyAccess(ctx context.Context, user common.Address, toolID *big.Int) (*AccessResult, error) {
 	// TIER 1: LICENSED ACCESS (check first, before free tier)
 	licenseKey := fmt.Sprintf("license:%s:%s", user.Hex(), toolID.String())
@@ -144,19 +198,22 @@ func (s *LicenseService) VerifyAccess(ctx context.Context, user common.Address,
 	// Check cache first
 	if cached, found := s.cache.Get(ctx, licenseKey); found {
 		if license, ok := cached.(*models.License); ok {
-			if time.Now().Before(license.ExpiresAt) {
-				// Update usage count
-				license.CallsUsed++
-				if err := s.cache.Set(ctx, licenseKey, license, 24*time.Hour); err != nil {
-					return nil, fmt.Errorf("failed to update license usage: %w", err)
+			if time.Now().Before(license.ExpiresAt) && license.CallsUsed < license.MaxCalls {
+				if stale, usable := s.refreshLicense(ctx, licenseKey, license, user, toolID); usable {
+					// Update usage count
+					license.CallsUsed++
+					if err := s.cache.Set(ctx, licenseKey, license, s.licenseCacheTTL()); err != nil {
+						return nil, fmt.Errorf("failed to update license usage: %w", err)
+					}
+
+					return &AccessResult{
+						Valid:          true,
+						Tier:           license.Tier,
+						CallsRemaining: license.MaxCalls - license.CallsUsed,
+						ExpiresAt:      &license.ExpiresAt,
+						Stale:          stale,
+					}, nil
 				}
-
-				return &AccessResult{
-					Valid:          true,
-					Tier:           "licensed",
-					CallsRemaining: license.MaxCalls - license.CallsUsed,
-					ExpiresAt:      &license.ExpiresAt,
-				}, nil
 			}
 		}
 	}
@@ -174,15 +231,23 @@ func (s *LicenseService) VerifyAccess(ctx context.Context, user common.Address,
 				MaxCalls:    1000, // Default licensed calls
 				CallsUsed:   1,
 				Tier:        "licensed",
+				RefreshedAt: time.Now(),
+			}
+			// Keep the limits of an underpaid mint when the cached copy is gone
+			if payments := s.GetPayments(ctx, user, toolID); len(payments) > 0 {
+				limitUnderpaidLicense(license, payments[len(payments)-1])
 			}
 
-			if err := s.cache.Set(ctx, licenseKey, license, 24*time.Hour); err == nil {
-				return &AccessResult{
-					Valid:          true,
-					Tier:           "licensed",
-					CallsRemaining: license.MaxCalls - license.CallsUsed,
-					ExpiresAt:      &license.ExpiresAt,
-				}, nil
+			// A limited license with no calls left falls back to the free tier
+			if license.CallsUsed <= license.MaxCalls {
+				if err := s.cache.Set(ctx, licenseKey, license, s.licenseCacheTTL()); err == nil {
+					return &AccessResult{
+						Valid:          true,
+						Tier:           license.Tier,
+						CallsRemaining: license.MaxCalls - license.CallsUsed,
+						ExpiresAt:      &license.ExpiresAt,
+					}, nil
+				}
 			}
 		}
 	}
@@ -217,6 +282,7 @@ func (s *LicenseService) RecordLicenseMinted(
 	toolID *big.Int,
 	expiresAt *big.Int,
 	nonce *big.Int,
+	txHash common.Hash,
 ) error {
 	// Move from pending to active
 	licenseKey := fmt.Sprintf("license:%s:%s", user.Hex(), toolID.String())
@@ -233,10 +299,19 @@ func (s *LicenseService) RecordLicenseMinted(
 		return fmt.Errorf("invalid pending license format")
 	}
 
-	// Verify nonce matches
+	// Verify nonce and expiry match what was authorized
 	if pendingLicense.Nonce != nonce.String() {
 		return fmt.Errorf("nonce mismatch")
 	}
+	if !expiresAt.IsInt64() || expiresAt.Int64() != pendingLicense.ExpiresAt.Unix() {
+		return fmt.Errorf("expiry mismatch")
+	}
+
+	// The contract accepts any non-zero msg.value, so check what was paid
+	payment, err := s.verifyMintPayment(ctx, pendingLicense, user, toolID, nonce, txHash)
+	if err != nil {
+		return err
+	}
 
 	// Create active license
 	activeLicense := &models.License{
@@ -246,7 +321,16 @@ func (s *LicenseService) RecordLicenseMinted(
 		MaxCalls:    1000,
 		CallsUsed:   0,
 		Tier:        "licensed",
+		Nonce:       nonce.String(),
+		Price:       pendingLicense.Price,
 		CreatedAt:   time.Now(),
+		RefreshedAt: time.Now(),
+		SignerKeyID: pendingLicense.SignerKeyID,
+	}
+	limitUnderpaidLicense(activeLicense, payment)
+
+	if err := s.recordPayment(ctx, payment); err != nil {
+		return err
 	}
 
 	// Store active license, delete pending
@@ -259,6 +343,51 @@ func (s *LicenseService) RecordLicenseMinted(
 	return nil
 }
 
+// refreshLicense re-checks a cached license on-chain once it is past its
+// refresh time. If the chain is unreachable the cached copy stays usable, as
+// stale, for up to MaxLicenseStaleness beyond the refresh time. Without a
+// blockchain there is nothing to re-check against, so the cached copy is
+// used as is.
+func (s *LicenseService) refreshLicense(ctx context.Context, licenseKey string, license *models.License, user common.Address, toolID *big.Int) (stale, usable bool) {
+	age := time.Since(license.RefreshedAt)
+	if age < licenseRefreshInterval || s.chainDisabled() {
+		return false, true
+	}
+
+	valid, err := s.blockchain.IsLicenseValid(user, toolID)
+	switch {
+	case err == nil && valid:
+		license.RefreshedAt = time.Now()
+		return false, true
+	case err == nil:
+		s.cache.Delete(ctx, licenseKey)
+		return false, false
+	case errors.Is(err, ErrChainUnavailable):
+		return true, age < licenseRefreshInterval+s.config.MaxLicenseStaleness
+	default:
+		return false, false
+	}
+}
+
+// licenseCacheTTL keeps cached licenses around long enough to be served stale.
+func (s *LicenseService) licenseCacheTTL() time.Duration {
+	return licenseRefreshInterval + s.config.MaxLicenseStaleness
+}
+
+// chainDegraded reports whether the blockchain backend is configured but
+// currently unreachable.
+func (s *LicenseService) chainDegraded() bool {
+	reporter, ok := s.blockchain.(ChainStatusReporter)
+	return ok && reporter.ChainStatus().Mode == ChainDegraded
+}
+
+// chainDisabled reports whether the service runs without a blockchain
+// (ENABLE_BLOCKCHAIN=false).
+func (s *LicenseService) chainDisabled() bool {
+	reporter, ok := s.blockchain.(ChainStatusReporter)
+	return ok && reporter.ChainStatus().Mode == ChainDisabled
+}
+
 func (s *LicenseService) calculateLicensePrice(toolID *big.Int) string {
 	// Simple pricing for demo: base price + reputation factor
 	// In production, this would query tool reputation from database
@@ -117,7 +117,7 @@ func TestLicenseService_Integration(t *testing.T) {
         assert.NotEmpty(t, resp.SignatureV)
         
         // Simulate license minted (would be called by blockchain event listener)
-        err = service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce)
+        err = service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, common.Hash{})
         require.NoError(t, err)
         
         // Now verify access with licensed tier
@@ -147,7 +147,7 @@ func TestLicenseService_Integration(t *testing.T) {
         assert.Contains(t, err.Error(), "license request already pending")
         
         // Record minting to clear pending
-        err = service.RecordLicenseMinted(ctx, user, toolID, resp1.ExpiresAt, resp1.Nonce)
+        err = service.RecordLicenseMinted(ctx, user, toolID, resp1.ExpiresAt, resp1.Nonce, common.Hash{})
         require.NoError(t, err)
         
         // Third request after minting should fail (license already active)
@@ -0,0 +1,214 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"moltket/internal/contracts/license"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Payment ledger entries outlive the cached licenses they describe
+const paymentLedgerTTL = 365 * 24 * time.Hour
+
+// ErrTxHashRequired is returned when recording a mint without its
+// transaction hash on a backend that can verify the payment.
+var ErrTxHashRequired = errors.New("tx_hash is required to verify the mint payment")
+
+// MintPayment is what a mintLicense transaction actually did on-chain.
+type MintPayment struct {
+	TxHash      common.Hash
+	Sender      common.Address
+	ToolID      *big.Int
+	ExpiresAt   *big.Int
+	Nonce       *big.Int
+	Value       *big.Int // msg.value in wei
+	BlockNumber uint64
+}
+
+// PaymentLedger is implemented by LicenseService. Its ledger records every
+// mint the backend accepted, so unlike the license cache it survives
+// evictions and cannot be filled from unverified sources.
+type PaymentLedger interface {
+	GetPayments(ctx context.Context, user common.Address, toolID *big.Int) []*models.LicensePayment
+}
+
+// PaymentVerifier is implemented by blockchain backends that can look up mint
+// transactions. LicenseService records payments as unverified without one.
+type PaymentVerifier interface {
+	GetMintPayment(ctx context.Context, txHash common.Hash) (*MintPayment, error)
+}
+
+// GetMintPayment fetches a mined, successful mintLicense transaction sent to
+// LicenseNFT and decodes its arguments, sender and msg.value.
+func (c *Client) GetMintPayment(ctx context.Context, txHash common.Hash) (*MintPayment, error) {
+	if c.licenseNFT == nil {
+		return nil, fmt.Errorf("license contract not initialized")
+	}
+
+	tx, pending, err := c.ethClient.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch mint transaction: %v", err)
+	}
+	if pending {
+		return nil, fmt.Errorf("mint transaction %s is still pending", txHash.Hex())
+	}
+
+	receipt, err := c.ethClient.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch mint receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("mint transaction %s reverted", txHash.Hex())
+	}
+
+	if tx.To() == nil || *tx.To() != c.licenseNFT.Address() {
+		return nil, fmt.Errorf("transaction %s was not sent to LicenseNFT", txHash.Hex())
+	}
+
+	licenseABI, err := license.LicenseMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	if len(tx.Data()) < 4 {
+		return nil, fmt.Errorf("transaction %s is not a mintLicense call", txHash.Hex())
+	}
+	method, err := licenseABI.MethodById(tx.Data()[:4])
+	if err != nil || method.Name != "mintLicense" {
+		return nil, fmt.Errorf("transaction %s is not a mintLicense call", txHash.Hex())
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mintLicense arguments: %v", err)
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover mint sender: %v", err)
+	}
+
+	return &MintPayment{
+		TxHash:      txHash,
+		Sender:      sender,
+		ToolID:      args[0].(*big.Int),
+		ExpiresAt:   args[1].(*big.Int),
+		Nonce:       args[2].(*big.Int),
+		Value:       tx.Value(),
+		BlockNumber: receipt.BlockNumber.Uint64(),
+	}, nil
+}
+
+// verifyMintPayment compares the mint transaction with the pending license and
+// returns the resulting ledger entry. Backends that can fetch the transaction
+// require its hash; without such a backend, or with the blockchain disabled,
+// the payment is recorded as unverified.
+func (s *LicenseService) verifyMintPayment(ctx context.Context, pending *models.License, user common.Address, toolID, nonce *big.Int, txHash common.Hash) (*models.LicensePayment, error) {
+	payment := &models.LicensePayment{
+		UserAddress: user.Hex(),
+		ToolID:      toolID.String(),
+		Nonce:       nonce.String(),
+		QuotedWei:   pending.Price,
+		Status:      models.PaymentUnverified,
+		ExpiresAt:   pending.ExpiresAt,
+		RecordedAt:  time.Now(),
+	}
+
+	verifier, ok := s.blockchain.(PaymentVerifier)
+	if !ok || s.chainDisabled() {
+		return payment, nil
+	}
+	if txHash == (common.Hash{}) {
+		return nil, ErrTxHashRequired
+	}
+	payment.TxHash = txHash.Hex()
+
+	mint, err := verifier.GetMintPayment(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify mint payment: %w", err)
+	}
+
+	if mint.Sender != user || mint.ToolID.Cmp(toolID) != 0 || mint.Nonce.Cmp(nonce) != 0 ||
+		!mint.ExpiresAt.IsInt64() || mint.ExpiresAt.Int64() != pending.ExpiresAt.Unix() {
+		return nil, fmt.Errorf("mint transaction %s does not match the pending license", txHash.Hex())
+	}
+
+	quoted, ok := new(big.Int).SetString(pending.Price, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid quoted price %q", pending.Price)
+	}
+
+	payment.PaidWei = mint.Value.String()
+	payment.BlockNumber = mint.BlockNumber
+	payment.Status = models.PaymentPaid
+	if mint.Value.Cmp(quoted) < 0 {
+		payment.Status = models.PaymentUnderpaid
+		payment.ShortfallWei = new(big.Int).Sub(quoted, mint.Value).String()
+	}
+
+	return payment, nil
+}
+
+// limitUnderpaidLicense flags the license and scales its call allowance by
+// the fraction of the quote that was actually paid.
+func limitUnderpaidLicense(license *models.License, payment *models.LicensePayment) {
+	license.PaymentStatus = payment.Status
+	if payment.Status != models.PaymentUnderpaid {
+		return
+	}
+
+	license.Flagged = true
+	license.Tier = "limited"
+
+	quoted, _ := new(big.Int).SetString(payment.QuotedWei, 10)
+	paid, _ := new(big.Int).SetString(payment.PaidWei, 10)
+	if quoted == nil || paid == nil || quoted.Sign() == 0 {
+		license.MaxCalls = 0
+		return
+	}
+	allowed := new(big.Int).Mul(big.NewInt(int64(license.MaxCalls)), paid)
+	license.MaxCalls = int(allowed.Div(allowed, quoted).Int64())
+}
+
+// recordPayment appends the entry to the license's ledger and, if underpaid,
+// to the admin-facing underpayment list.
+func (s *LicenseService) recordPayment(ctx context.Context, payment *models.LicensePayment) error {
+	ledgerKey := fmt.Sprintf("payments:%s:%s", payment.UserAddress, payment.ToolID)
+	ledger := append(s.getPaymentList(ctx, ledgerKey), payment)
+	if err := s.cache.Set(ctx, ledgerKey, ledger, paymentLedgerTTL); err != nil {
+		return fmt.Errorf("failed to store payment ledger: %w", err)
+	}
+
+	if payment.Status == models.PaymentUnderpaid {
+		underpaid := append(s.getPaymentList(ctx, "payments:underpaid"), payment)
+		if err := s.cache.Set(ctx, "payments:underpaid", underpaid, paymentLedgerTTL); err != nil {
+			return fmt.Errorf("failed to store underpayment: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *LicenseService) getPaymentList(ctx context.Context, key string) []*models.LicensePayment {
+	if cached, found := s.cache.Get(ctx, key); found {
+		if payments, ok := cached.([]*models.LicensePayment); ok {
+			return payments
+		}
+	}
+	return nil
+}
+
+// GetPayments returns the payment ledger of one (user, tool) license.
+func (s *LicenseService) GetPayments(ctx context.Context, user common.Address, toolID *big.Int) []*models.LicensePayment {
+	return s.getPaymentList(ctx, fmt.Sprintf("payments:%s:%s", user.Hex(), toolID.String()))
+}
+
+// ListUnderpayments returns every recorded underpaid mint.
+func (s *LicenseService) ListUnderpayments(ctx context.Context) []*models.LicensePayment {
+	return s.getPaymentList(ctx, "payments:underpaid")
+}
@@ -0,0 +1,305 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+	"moltket/internal/contracts/license"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockPaymentChain is a mockBlockchain that can also look up mint transactions.
+type mockPaymentChain struct {
+	mockBlockchain
+	payments map[common.Hash]*MintPayment
+}
+
+func (m *mockPaymentChain) GetMintPayment(ctx context.Context, txHash common.Hash) (*MintPayment, error) {
+	payment, ok := m.payments[txHash]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return payment, nil
+}
+
+// signedMintTx builds a mintLicense call paying value wei, signed by the
+// hardhat #0 key.
+func signedMintTx(t *testing.T, to common.Address, toolID, expiresAt, nonce, value *big.Int) (*types.Transaction, common.Address) {
+	t.Helper()
+
+	licenseABI, err := license.LicenseMetaData.GetAbi()
+	require.NoError(t, err)
+	data, err := licenseABI.Pack("mintLicense", toolID, expiresAt, nonce, []byte{0x01})
+	require.NoError(t, err)
+
+	key, err := crypto.HexToECDSA(testSignerKey)
+	require.NoError(t, err)
+
+	chainID := big.NewInt(31337)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(2_000_000_000),
+		Gas:       300000,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	})
+	require.NoError(t, err)
+
+	return tx, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestClient_GetMintPayment(t *testing.T) {
+	ctx := context.Background()
+	licenseAddr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), licenseAddr)
+	require.NoError(t, err)
+
+	client, eth, cfg := newSignerTestClient(t, signer)
+	toolID, expiresAt, nonce := big.NewInt(42), big.NewInt(1893456000), big.NewInt(99)
+
+	t.Run("DecodesMint", func(t *testing.T) {
+		tx, from := signedMintTx(t, cfg.LicenseNFTAddress, toolID, expiresAt, nonce, big.NewInt(5000))
+		hash := eth.addTx(tx, from, 12, types.ReceiptStatusSuccessful)
+
+		payment, err := client.GetMintPayment(ctx, hash)
+		require.NoError(t, err)
+		assert.Equal(t, from, payment.Sender)
+		assert.Equal(t, toolID, payment.ToolID)
+		assert.Equal(t, expiresAt, payment.ExpiresAt)
+		assert.Equal(t, nonce, payment.Nonce)
+		assert.Equal(t, big.NewInt(5000), payment.Value)
+		assert.Equal(t, uint64(12), payment.BlockNumber)
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		tx, from := signedMintTx(t, cfg.LicenseNFTAddress, toolID, expiresAt, big.NewInt(100), big.NewInt(1))
+		hash := eth.addTx(tx, from, 0, types.ReceiptStatusSuccessful)
+
+		_, err := client.GetMintPayment(ctx, hash)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "still pending")
+	})
+
+	t.Run("Reverted", func(t *testing.T) {
+		tx, from := signedMintTx(t, cfg.LicenseNFTAddress, toolID, expiresAt, big.NewInt(101), big.NewInt(1))
+		hash := eth.addTx(tx, from, 13, types.ReceiptStatusFailed)
+
+		_, err := client.GetMintPayment(ctx, hash)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "reverted")
+	})
+
+	t.Run("WrongContract", func(t *testing.T) {
+		tx, from := signedMintTx(t, cfg.StakingNFTAddress, toolID, expiresAt, big.NewInt(102), big.NewInt(1))
+		hash := eth.addTx(tx, from, 14, types.ReceiptStatusSuccessful)
+
+		_, err := client.GetMintPayment(ctx, hash)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not sent to LicenseNFT")
+	})
+}
+
+func TestLicenseService_PaymentVerification(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		LicenseNFTAddress: "0x1111111111111111111111111111111111111111",
+		SignatureNonce:    "test-nonce",
+	}
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), common.HexToAddress(cfg.LicenseNFTAddress))
+	require.NoError(t, err)
+
+	user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	// requestAndPay requests a license and registers a mint paying value wei.
+	requestAndPay := func(t *testing.T, service *LicenseService, bc *mockPaymentChain, toolID, value *big.Int) (*LicenseResponse, common.Hash) {
+		resp, err := service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: toolID})
+		require.NoError(t, err)
+
+		txHash := common.BigToHash(new(big.Int).Add(toolID, big.NewInt(1000)))
+		bc.payments[txHash] = &MintPayment{
+			TxHash:      txHash,
+			Sender:      user,
+			ToolID:      toolID,
+			ExpiresAt:   resp.ExpiresAt,
+			Nonce:       resp.Nonce,
+			Value:       value,
+			BlockNumber: 5,
+		}
+		return resp, txHash
+	}
+
+	t.Run("PaidInFull", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		bc := &mockPaymentChain{payments: map[common.Hash]*MintPayment{}}
+		service := NewLicenseService(cfg, kvStore, signer, bc)
+
+		toolID := big.NewInt(1)
+		resp, txHash := requestAndPay(t, service, bc, toolID, big.NewInt(10000000000000000))
+		require.NoError(t, service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, txHash))
+
+		result, err := service.VerifyAccess(ctx, user, toolID)
+		require.NoError(t, err)
+		assert.Equal(t, "licensed", result.Tier)
+		assert.Equal(t, 999, result.CallsRemaining)
+
+		payments := service.GetPayments(ctx, user, toolID)
+		require.Len(t, payments, 1)
+		assert.Equal(t, models.PaymentPaid, payments[0].Status)
+		assert.Equal(t, txHash.Hex(), payments[0].TxHash)
+		assert.Equal(t, resp.ExpiresAt.Int64(), payments[0].ExpiresAt.Unix())
+		assert.Empty(t, service.ListUnderpayments(ctx))
+	})
+
+	t.Run("Underpaid", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		bc := &mockPaymentChain{payments: map[common.Hash]*MintPayment{}}
+		service := NewLicenseService(cfg, kvStore, signer, bc)
+
+		// A quarter of the quoted 0.01 ETH
+		toolID := big.NewInt(2)
+		resp, txHash := requestAndPay(t, service, bc, toolID, big.NewInt(2500000000000000))
+		require.NoError(t, service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, txHash))
+
+		result, err := service.VerifyAccess(ctx, user, toolID)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, "limited", result.Tier)
+		assert.Equal(t, 249, result.CallsRemaining)
+
+		cached, found := kvStore.Get(ctx, "license:"+user.Hex()+":2")
+		require.True(t, found)
+		assert.True(t, cached.(*models.License).Flagged)
+		assert.Equal(t, models.PaymentUnderpaid, cached.(*models.License).PaymentStatus)
+
+		underpaid := service.ListUnderpayments(ctx)
+		require.Len(t, underpaid, 1)
+		assert.Equal(t, "7500000000000000", underpaid[0].ShortfallWei)
+		assert.Equal(t, "2500000000000000", underpaid[0].PaidWei)
+	})
+
+	t.Run("RebuiltFromLedger", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		bc := &mockPaymentChain{payments: map[common.Hash]*MintPayment{}}
+		service := NewLicenseService(cfg, kvStore, signer, bc)
+
+		toolID := big.NewInt(7)
+		resp, txHash := requestAndPay(t, service, bc, toolID, big.NewInt(2500000000000000))
+		require.NoError(t, service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, txHash))
+
+		// Once the cached license is gone, the one rebuilt from the chain
+		// keeps the limits of the underpaid mint
+		require.NoError(t, kvStore.Delete(ctx, "license:"+user.Hex()+":7"))
+		bc.isValid = true
+		bc.metadata = &LicenseMetadata{ExpiresAt: time.Unix(resp.ExpiresAt.Int64(), 0)}
+
+		result, err := service.VerifyAccess(ctx, user, toolID)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, "limited", result.Tier)
+		assert.Equal(t, 249, result.CallsRemaining)
+	})
+
+	t.Run("MintDoesNotMatchPendingLicense", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		bc := &mockPaymentChain{payments: map[common.Hash]*MintPayment{}}
+		service := NewLicenseService(cfg, kvStore, signer, bc)
+
+		toolID := big.NewInt(3)
+		resp, txHash := requestAndPay(t, service, bc, toolID, big.NewInt(10000000000000000))
+		bc.payments[txHash].Sender = common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC")
+
+		err := service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, txHash)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match the pending license")
+		assert.Empty(t, service.GetPayments(ctx, user, toolID))
+	})
+
+	t.Run("ExpiryDoesNotMatchPendingLicense", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		bc := &mockPaymentChain{payments: map[common.Hash]*MintPayment{}}
+		service := NewLicenseService(cfg, kvStore, signer, bc)
+
+		toolID := big.NewInt(5)
+		resp, txHash := requestAndPay(t, service, bc, toolID, big.NewInt(10000000000000000))
+		later := new(big.Int).Add(resp.ExpiresAt, big.NewInt(365*24*3600))
+
+		// Claimed by the client
+		err := service.RecordLicenseMinted(ctx, user, toolID, later, resp.Nonce, txHash)
+		assert.ErrorContains(t, err, "expiry mismatch")
+
+		// Minted on-chain
+		bc.payments[txHash].ExpiresAt = later
+		err = service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, txHash)
+		assert.ErrorContains(t, err, "does not match the pending license")
+		assert.Empty(t, service.GetPayments(ctx, user, toolID))
+	})
+
+	t.Run("TxHashRequired", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		bc := &mockPaymentChain{payments: map[common.Hash]*MintPayment{}}
+		service := NewLicenseService(cfg, kvStore, signer, bc)
+
+		toolID := big.NewInt(6)
+		resp, err := service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: toolID})
+		require.NoError(t, err)
+		err = service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, common.Hash{})
+		assert.ErrorIs(t, err, ErrTxHashRequired)
+		assert.Empty(t, service.GetPayments(ctx, user, toolID))
+
+		_, found := kvStore.Get(ctx, "license:"+user.Hex()+":6")
+		assert.False(t, found)
+	})
+
+	t.Run("UnverifiedWithoutVerifier", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		service := NewLicenseService(cfg, kvStore, signer, &mockBlockchain{})
+
+		toolID := big.NewInt(4)
+		resp, err := service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: toolID})
+		require.NoError(t, err)
+		require.NoError(t, service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, common.Hash{}))
+
+		payments := service.GetPayments(ctx, user, toolID)
+		require.Len(t, payments, 1)
+		assert.Equal(t, models.PaymentUnverified, payments[0].Status)
+		assert.Equal(t, resp.Price, payments[0].QuotedWei)
+	})
+
+	t.Run("UnverifiedWithDisabledChain", func(t *testing.T) {
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+		service := NewLicenseService(cfg, kvStore, signer, NewConnection(nil, 0))
+
+		toolID := big.NewInt(7)
+		resp, err := service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: toolID})
+		require.NoError(t, err)
+		require.NoError(t, service.RecordLicenseMinted(ctx, user, toolID, resp.ExpiresAt, resp.Nonce, common.Hash{}))
+
+		payments := service.GetPayments(ctx, user, toolID)
+		require.Len(t, payments, 1)
+		assert.Equal(t, models.PaymentUnverified, payments[0].Status)
+		_, found := kvStore.Get(ctx, "license:"+user.Hex()+":7")
+		assert.True(t, found)
+	})
+}
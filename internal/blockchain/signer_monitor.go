@@ -0,0 +1,506 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"moltket/internal/auth"
+	"moltket/internal/contracts/license"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// ErrSignerMismatch is returned when signatures produced by the backend would
+// be rejected on-chain (wrong EIP-712 domain or untrusted signer key).
+var ErrSignerMismatch = errors.New("backend signer does not match deployed contracts")
+
+// ErrSigningDisabled is returned by RequestLicense while the signer monitor
+// reports a mismatch, so users never receive signatures that would revert.
+var ErrSigningDisabled = errors.New("license issuance disabled")
+
+// ErrSignerNotAuthorized is returned when the on-chain trusted signer is not
+// one of the keys loaded by the backend (e.g. after updateSigner).
+var ErrSignerNotAuthorized = errors.New("signer not authorized")
+
+// SignerChecker verifies that signatures from signer will be accepted on-chain.
+type SignerChecker interface {
+	TrustedSigner(ctx context.Context) (common.Address, error)
+	CheckSigner(ctx context.Context, signer *auth.EIP712Signer) error
+	CheckOracleSigner(ctx context.Context, signer *auth.EIP712Signer) error
+}
+
+// SignerEventSource streams signer-related contract events.
+type SignerEventSource interface {
+	WatchSignerEvents(ctx context.Context, sink chan<- SignerEvent) (event.Subscription, error)
+}
+
+const (
+	SignerEventSignerChanged        = "SignerChanged"
+	SignerEventOwnershipTransferred = "OwnershipTransferred"
+)
+
+// SignerEvent is a SignerChanged or OwnershipTransferred event from LicenseNFT.
+// Old and New are the previous and new signer (or owner) addresses.
+type SignerEvent struct {
+	Kind        string
+	Old         common.Address
+	New         common.Address
+	BlockNumber uint64
+}
+
+// onChainDomain mirrors the EIP-5267 eip712Domain() return values we compare.
+type onChainDomain struct {
+	Name              string
+	Version           string
+	ChainId           *big.Int
+	VerifyingContract common.Address
+}
+
+// TrustedSigner returns LicenseNFT's current trustedSigner.
+func (c *Client) TrustedSigner(ctx context.Context) (common.Address, error) {
+	if c.licenseNFT == nil {
+		return common.Address{}, fmt.Errorf("license contract not initialized")
+	}
+	return c.licenseNFT.Licensecontract.TrustedSigner(&bind.CallOpts{Context: ctx})
+}
+
+// CheckSigner calls eip712Domain() on LicenseNFT and compares name, version,
+// chainId and verifyingContract with the signer's domain. It also checks that
+// the signer is the contract's trustedSigner. All mismatches are reported
+// together, wrapped in ErrSignerMismatch.
+func (c *Client) CheckSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	if c.licenseNFT == nil {
+		return fmt.Errorf("license contract not initialized")
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+
+	licenseDomain, err := c.licenseNFT.Licensecontract.Eip712Domain(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read LicenseNFT eip712Domain: %v", err)
+	}
+	mismatches := compareDomain("LicenseNFT", signer, onChainDomain{
+		Name:              licenseDomain.Name,
+		Version:           licenseDomain.Version,
+		ChainId:           licenseDomain.ChainId,
+		VerifyingContract: licenseDomain.VerifyingContract,
+	}, signer.VerifyingContract())
+
+	trustedSigner, err := c.licenseNFT.Licensecontract.TrustedSigner(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read LicenseNFT trustedSigner: %v", err)
+	}
+	if trustedSigner != signer.Address() {
+		mismatches = append(mismatches, fmt.Sprintf("LicenseNFT trustedSigner is %s, backend signer is %s",
+			trustedSigner.Hex(), signer.Address().Hex()))
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %s", ErrSignerMismatch, strings.Join(mismatches, "; "))
+	}
+
+	return nil
+}
+
+// CheckOracleSigner performs the same comparison against ReputationOracle,
+// whose domain shares name, version and chain but uses its own address.
+// It succeeds when no oracle is configured.
+func (c *Client) CheckOracleSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	if c.reputationOracle == nil {
+		return nil
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+
+	oracleDomain, err := c.reputationOracle.ReputationContract.Eip712Domain(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read ReputationOracle eip712Domain: %v", err)
+	}
+	mismatches := compareDomain("ReputationOracle", signer, onChainDomain{
+		Name:              oracleDomain.Name,
+		Version:           oracleDomain.Version,
+		ChainId:           oracleDomain.ChainId,
+		VerifyingContract: oracleDomain.VerifyingContract,
+	}, c.reputationOracle.Address())
+
+	backendSigner, err := c.reputationOracle.ReputationContract.BackendSigner(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read ReputationOracle backendSigner: %v", err)
+	}
+	if backendSigner != signer.Address() {
+		mismatches = append(mismatches, fmt.Sprintf("ReputationOracle backendSigner is %s, backend signer is %s",
+			backendSigner.Hex(), signer.Address().Hex()))
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %s", ErrSignerMismatch, strings.Join(mismatches, "; "))
+	}
+
+	return nil
+}
+
+// WatchSignerEvents subscribes to LicenseNFT SignerChanged and
+// OwnershipTransferred events and forwards them to sink.
+func (c *Client) WatchSignerEvents(ctx context.Context, sink chan<- SignerEvent) (event.Subscription, error) {
+	if c.licenseNFT == nil {
+		return nil, fmt.Errorf("license contract not initialized")
+	}
+
+	backend, err := c.subscriptionClient()
+	if err != nil {
+		return nil, err
+	}
+	filterer, err := license.NewLicenseFilterer(c.licenseNFT.Address(), backend)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &bind.WatchOpts{Context: ctx}
+	signerChanged := make(chan *license.LicenseSignerChanged)
+	signerSub, err := filterer.WatchSignerChanged(opts, signerChanged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch SignerChanged: %v", err)
+	}
+	ownershipTransferred := make(chan *license.LicenseOwnershipTransferred)
+	ownerSub, err := filterer.WatchOwnershipTransferred(opts, ownershipTransferred, nil, nil)
+	if err != nil {
+		signerSub.Unsubscribe()
+		return nil, fmt.Errorf("failed to watch OwnershipTransferred: %v", err)
+	}
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer signerSub.Unsubscribe()
+		defer ownerSub.Unsubscribe()
+
+		for {
+			var ev SignerEvent
+			select {
+			case e := <-signerChanged:
+				ev = SignerEvent{Kind: SignerEventSignerChanged, Old: e.OldSigner, New: e.NewSigner, BlockNumber: e.Raw.BlockNumber}
+			case e := <-ownershipTransferred:
+				ev = SignerEvent{Kind: SignerEventOwnershipTransferred, Old: e.PreviousOwner, New: e.NewOwner, BlockNumber: e.Raw.BlockNumber}
+			case err := <-signerSub.Err():
+				return err
+			case err := <-ownerSub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+
+			select {
+			case sink <- ev:
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// compareDomain lists the fields of an on-chain domain that differ from the
+// signer's domain. verifyingContract is the address the contract should report.
+func compareDomain(contract string, signer *auth.EIP712Signer, got onChainDomain, verifyingContract common.Address) []string {
+	want := signer.Domain()
+	var mismatches []string
+
+	if got.Name != want.Name {
+		mismatches = append(mismatches, fmt.Sprintf("%s domain name is %q, signer uses %q", contract, got.Name, want.Name))
+	}
+	if got.Version != want.Version {
+		mismatches = append(mismatches, fmt.Sprintf("%s domain version is %q, signer uses %q", contract, got.Version, want.Version))
+	}
+	if got.ChainId == nil || got.ChainId.Cmp(signer.ChainID()) != 0 {
+		mismatches = append(mismatches, fmt.Sprintf("%s domain chainId is %v, signer uses %v", contract, got.ChainId, signer.ChainID()))
+	}
+	if got.VerifyingContract != verifyingContract {
+		mismatches = append(mismatches, fmt.Sprintf("%s domain verifyingContract is %s, expected %s",
+			contract, got.VerifyingContract.Hex(), verifyingContract.Hex()))
+	}
+
+	return mismatches
+}
+
+// DefaultSignerCheckInterval is how often the signer is re-checked when no
+// interval is configured.
+const DefaultSignerCheckInterval = 5 * time.Minute
+
+// SignerMonitor keeps the on-chain trusted signer in memory, checked at
+// startup, periodically, and whenever LicenseNFT emits SignerChanged or
+// OwnershipTransferred. If the trusted signer changes to another loaded key,
+// that key becomes active automatically and the previous one verifies for
+// the key ring's overlap window; otherwise issuance is refused.
+type SignerMonitor struct {
+	checker  SignerChecker
+	interval time.Duration
+	keys     *auth.KeyRing
+
+	mu            sync.RWMutex
+	trustedSigner common.Address
+	owner         common.Address
+	lastErr       error
+	oracleErr     error
+	checkedAt     time.Time
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSignerMonitor creates a monitor with signer as the initially active key.
+// A zero interval uses DefaultSignerCheckInterval.
+func NewSignerMonitor(checker SignerChecker, signer *auth.EIP712Signer, interval time.Duration) *SignerMonitor {
+	if interval <= 0 {
+		interval = DefaultSignerCheckInterval
+	}
+	return &SignerMonitor{
+		checker:  checker,
+		interval: interval,
+		keys:     auth.NewKeyRing(signer, auth.DefaultKeyOverlap),
+		lastErr:  fmt.Errorf("signer has not been verified against the deployed contracts yet"),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// AddSigner stages an additional key the monitor switches to once the
+// contract owner makes it the trusted signer. Until then it only verifies.
+func (m *SignerMonitor) AddSigner(signer *auth.EIP712Signer) (auth.KeyInfo, error) {
+	info, err := m.keys.Add(signer)
+	if err != nil {
+		return info, err
+	}
+
+	// The key may already be trusted, e.g. when staged after updateSigner
+	m.setTrustedSigner(m.TrustedSigner())
+	return info, nil
+}
+
+// Keys returns the key ring holding the active and staged keys.
+func (m *SignerMonitor) Keys() *auth.KeyRing {
+	return m.keys
+}
+
+// Check reads the trusted signer, switches to the matching loaded key if
+// needed, and verifies the active key against the deployed contracts. It
+// returns Err.
+func (m *SignerMonitor) Check(ctx context.Context) error {
+	trusted, err := m.checker.TrustedSigner(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to read trusted signer: %w", err)
+		m.record(err, m.OracleErr())
+		return err
+	}
+
+	m.setTrustedSigner(trusted)
+	active := m.Signer()
+	if active.Address() != trusted {
+		err = m.notAuthorized(trusted)
+		m.record(err, m.OracleErr())
+		return err
+	}
+
+	m.record(m.checker.CheckSigner(ctx, active), m.checker.CheckOracleSigner(ctx, active))
+	return m.Err()
+}
+
+// HandleEvent applies a SignerChanged or OwnershipTransferred event and
+// re-verifies the active key.
+func (m *SignerMonitor) HandleEvent(ctx context.Context, ev SignerEvent) {
+	switch ev.Kind {
+	case SignerEventSignerChanged:
+		log.Printf("LicenseNFT signer changed from %s to %s at block %d", ev.Old.Hex(), ev.New.Hex(), ev.BlockNumber)
+		m.setTrustedSigner(ev.New)
+
+		// Stop issuing immediately; the check below may re-enable it
+		if active := m.Signer(); active.Address() != ev.New {
+			m.record(m.notAuthorized(ev.New), m.OracleErr())
+		}
+
+	case SignerEventOwnershipTransferred:
+		log.Printf("LicenseNFT ownership transferred from %s to %s at block %d", ev.Old.Hex(), ev.New.Hex(), ev.BlockNumber)
+		m.mu.Lock()
+		m.owner = ev.New
+		m.mu.Unlock()
+	}
+
+	m.Check(ctx)
+}
+
+// setTrustedSigner records the on-chain trusted signer and activates the
+// loaded key that matches it, if any.
+func (m *SignerMonitor) setTrustedSigner(trusted common.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.trustedSigner = trusted
+	active := m.keys.Active()
+	if active.Address() == trusted {
+		return
+	}
+	if key, ok := m.keys.Lookup(trusted); ok {
+		log.Printf("Switching backend signer from %s to %s (key %s)", active.Address().Hex(), trusted.Hex(), key.ID)
+		if err := m.keys.Activate(key.ID); err != nil {
+			log.Printf("Failed to activate signer %s: %v", key.ID, err)
+		}
+	}
+}
+
+func (m *SignerMonitor) notAuthorized(trusted common.Address) error {
+	return fmt.Errorf("%w: on-chain trusted signer is %s, backend holds %s",
+		ErrSignerNotAuthorized, trusted.Hex(), m.Signer().Address().Hex())
+}
+
+func (m *SignerMonitor) record(err, oracleErr error) {
+	previous := m.Err()
+	m.mu.Lock()
+	m.lastErr = err
+	m.oracleErr = oracleErr
+	m.checkedAt = time.Now()
+	m.mu.Unlock()
+	active := m.keys.Active()
+
+	err = m.Err()
+	switch {
+	case err != nil && (previous == nil || previous.Error() != err.Error()):
+		log.Printf("Signer check failed, refusing to issue signatures: %v", err)
+	case err == nil && previous != nil:
+		log.Printf("Signer check passed for %s", active.Address().Hex())
+	}
+}
+
+// Signer returns the key currently used for signing.
+func (m *SignerMonitor) Signer() *auth.EIP712Signer {
+	return m.keys.Active()
+}
+
+// TrustedSigner returns the last known on-chain trusted signer.
+func (m *SignerMonitor) TrustedSigner() common.Address {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.trustedSigner
+}
+
+// Owner returns the LicenseNFT owner from the last OwnershipTransferred event.
+func (m *SignerMonitor) Owner() common.Address {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.owner
+}
+
+// Err returns why signing is refused: the result of the most recent
+// LicenseNFT check or, if that passed, of the ReputationOracle check.
+func (m *SignerMonitor) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.lastErr != nil {
+		return m.lastErr
+	}
+	return m.oracleErr
+}
+
+// OracleErr returns the result of the most recent ReputationOracle check.
+func (m *SignerMonitor) OracleErr() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.oracleErr
+}
+
+// SignerStatus is the outcome of the most recent signer check. Error is set
+// while the active key does not match LicenseNFT, OracleError while it does
+// not match ReputationOracle's EIP-712 domain or backendSigner; signing is
+// refused while either is set.
+type SignerStatus struct {
+	Active        common.Address `json:"active"`
+	TrustedSigner common.Address `json:"trusted_signer"`
+	Error         string         `json:"error,omitempty"`
+	OracleError   string         `json:"oracle_error,omitempty"`
+	CheckedAt     time.Time      `json:"checked_at"`
+}
+
+// Status returns the outcome of the most recent check.
+func (m *SignerMonitor) Status() *SignerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := &SignerStatus{
+		Active:        m.keys.Active().Address(),
+		TrustedSigner: m.trustedSigner,
+		CheckedAt:     m.checkedAt,
+	}
+	if m.lastErr != nil {
+		status.Error = m.lastErr.Error()
+	}
+	if m.oracleErr != nil {
+		status.OracleError = m.oracleErr.Error()
+	}
+	return status
+}
+
+// CheckedAt returns when the most recent check ran.
+func (m *SignerMonitor) CheckedAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.checkedAt
+}
+
+// Start re-runs the check every interval and, if the checker can stream
+// events, reacts to SignerChanged/OwnershipTransferred as they arrive.
+// A dropped subscription is re-established on the next periodic check,
+// which also catches any rotation missed meanwhile.
+func (m *SignerMonitor) Start(ctx context.Context) {
+	events := make(chan SignerEvent)
+	source, _ := m.checker.(SignerEventSource)
+
+	var sub event.Subscription
+	var subErr <-chan error
+	subscribe := func() {
+		if source == nil || sub != nil {
+			return
+		}
+		s, err := source.WatchSignerEvents(ctx, events)
+		if err != nil {
+			log.Printf("Signer event subscription unavailable, relying on polling: %v", err)
+			return
+		}
+		sub, subErr = s, s.Err()
+	}
+	subscribe()
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		defer func() {
+			if sub != nil {
+				sub.Unsubscribe()
+			}
+		}()
+
+		for {
+			select {
+			case <-ticker.C:
+				subscribe()
+				m.Check(ctx)
+			case ev := <-events:
+				m.HandleEvent(ctx, ev)
+			case err := <-subErr:
+				log.Printf("Signer event subscription ended, resubscribing on the next check: %v", err)
+				sub.Unsubscribe()
+				sub, subErr = nil, nil
+			case <-m.stopChan:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic check and event subscription
+func (m *SignerMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stopChan) })
+}
@@ -0,0 +1,380 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+	"moltket/internal/contracts/license"
+	"moltket/internal/contracts/reputation"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSignerKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+func eip712DomainResult(name string, chainID int64, verifyingContract common.Address) []interface{} {
+	return []interface{}{
+		[1]byte{0x0f}, name, "1", big.NewInt(chainID), verifyingContract, [32]byte{}, []*big.Int{},
+	}
+}
+
+// newSignerTestClient starts a stand-in node whose LicenseNFT and
+// ReputationOracle both agree with signer, and returns a client bound to it.
+func newSignerTestClient(t *testing.T, signer *auth.EIP712Signer) (*Client, *standInEth, *config.BlockchainConfig) {
+	t.Helper()
+
+	licenseABI, err := license.LicenseMetaData.GetAbi()
+	require.NoError(t, err)
+	reputationABI, err := reputation.ReputationMetaData.GetAbi()
+	require.NoError(t, err)
+
+	eth := &standInEth{chainID: big.NewInt(31337)}
+	cfg := testBlockchainConfig(newStandInNode(t, eth))
+	eth.code = deployedCode(cfg)
+	eth.contracts = map[common.Address]*standInContract{
+		cfg.LicenseNFTAddress: {
+			abi: licenseABI,
+			results: map[string][]interface{}{
+				"eip712Domain":  eip712DomainResult("SkillChainLicense", 31337, cfg.LicenseNFTAddress),
+				"trustedSigner": {signer.Address()},
+			},
+		},
+		cfg.ReputationOracleAddress: {
+			abi: reputationABI,
+			results: map[string][]interface{}{
+				"eip712Domain":  eip712DomainResult("SkillChainLicense", 31337, cfg.ReputationOracleAddress),
+				"backendSigner": {signer.Address()},
+			},
+		},
+	}
+
+	client, err := NewClientFromConfig(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client, eth, cfg
+}
+
+func TestClient_CheckSigner(t *testing.T) {
+	ctx := context.Background()
+	licenseAddr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), licenseAddr)
+	require.NoError(t, err)
+
+	t.Run("Consistent", func(t *testing.T) {
+		client, _, _ := newSignerTestClient(t, signer)
+		assert.NoError(t, client.CheckSigner(ctx, signer))
+	})
+
+	t.Run("ChainIDMismatch", func(t *testing.T) {
+		// Reproduces the old hardcoded Sepolia chain ID in setupLicenseRoutes
+		sepoliaSigner, err := auth.NewSigner(testSignerKey, big.NewInt(11155111), licenseAddr)
+		require.NoError(t, err)
+
+		client, _, _ := newSignerTestClient(t, signer)
+		err = client.CheckSigner(ctx, sepoliaSigner)
+		require.ErrorIs(t, err, ErrSignerMismatch)
+		assert.Contains(t, err.Error(), "LicenseNFT domain chainId is 31337, signer uses 11155111")
+
+		err = client.CheckOracleSigner(ctx, sepoliaSigner)
+		require.ErrorIs(t, err, ErrSignerMismatch)
+		assert.Contains(t, err.Error(), "ReputationOracle domain chainId is 31337, signer uses 11155111")
+	})
+
+	t.Run("DomainNameMismatch", func(t *testing.T) {
+		client, eth, cfg := newSignerTestClient(t, signer)
+		eth.setResult(cfg.LicenseNFTAddress, "eip712Domain", eip712DomainResult("OtherName", 31337, cfg.LicenseNFTAddress)...)
+
+		err := client.CheckSigner(ctx, signer)
+		require.ErrorIs(t, err, ErrSignerMismatch)
+		assert.Contains(t, err.Error(), `LicenseNFT domain name is "OtherName"`)
+	})
+
+	t.Run("VerifyingContractMismatch", func(t *testing.T) {
+		otherSigner, err := auth.NewSigner(testSignerKey, big.NewInt(31337), common.HexToAddress("0x9999999999999999999999999999999999999999"))
+		require.NoError(t, err)
+
+		client, _, _ := newSignerTestClient(t, signer)
+		err = client.CheckSigner(ctx, otherSigner)
+		require.ErrorIs(t, err, ErrSignerMismatch)
+		assert.Contains(t, err.Error(), "LicenseNFT domain verifyingContract")
+	})
+
+	t.Run("UntrustedSigner", func(t *testing.T) {
+		client, eth, cfg := newSignerTestClient(t, signer)
+		rotated := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+		eth.setResult(cfg.LicenseNFTAddress, "trustedSigner", rotated)
+		eth.setResult(cfg.ReputationOracleAddress, "backendSigner", rotated)
+
+		err := client.CheckSigner(ctx, signer)
+		require.ErrorIs(t, err, ErrSignerMismatch)
+		assert.Contains(t, err.Error(), "LicenseNFT trustedSigner is "+rotated.Hex())
+
+		err = client.CheckOracleSigner(ctx, signer)
+		require.ErrorIs(t, err, ErrSignerMismatch)
+		assert.Contains(t, err.Error(), "ReputationOracle backendSigner is "+rotated.Hex())
+	})
+}
+
+func TestSignerMonitor_GatesLicenseIssuance(t *testing.T) {
+	ctx := context.Background()
+	licenseAddr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), licenseAddr)
+	require.NoError(t, err)
+
+	client, eth, cfg := newSignerTestClient(t, signer)
+
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	service := NewLicenseService(&config.Config{LicenseNFTAddress: licenseAddr.Hex()}, kvStore, signer, &mockBlockchain{})
+	monitor := NewSignerMonitor(client, signer, time.Hour)
+	service.UseSignerMonitor(monitor)
+
+	user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	// Not yet checked: refuse to sign
+	_, err = service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: big.NewInt(1)})
+	require.ErrorIs(t, err, ErrSigningDisabled)
+
+	// Check passes: signing allowed
+	require.NoError(t, monitor.Check(ctx))
+	assert.False(t, monitor.CheckedAt().IsZero())
+	_, err = service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: big.NewInt(1)})
+	require.NoError(t, err)
+
+	// Contract owner rotates to a key we do not hold: refuse with a clear reason
+	eth.setResult(cfg.LicenseNFTAddress, "trustedSigner", user)
+	require.ErrorIs(t, monitor.Check(ctx), ErrSignerNotAuthorized)
+	_, err = service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: big.NewInt(2)})
+	require.ErrorIs(t, err, ErrSigningDisabled)
+	require.ErrorIs(t, err, ErrSignerNotAuthorized)
+	assert.Contains(t, err.Error(), "signer not authorized: on-chain trusted signer is "+user.Hex())
+
+	// LicenseNFT agrees again, but ReputationOracle expects another backend
+	// signer: still refused
+	eth.setResult(cfg.LicenseNFTAddress, "trustedSigner", signer.Address())
+	eth.setResult(cfg.ReputationOracleAddress, "backendSigner", user)
+	require.ErrorIs(t, monitor.Check(ctx), ErrSignerMismatch)
+	_, err = service.RequestLicense(ctx, &LicenseRequest{UserAddress: user, ToolID: big.NewInt(2)})
+	require.ErrorIs(t, err, ErrSigningDisabled)
+	require.ErrorIs(t, err, ErrSignerMismatch)
+	assert.Contains(t, err.Error(), "ReputationOracle backendSigner is "+user.Hex())
+}
+
+func TestSignerMonitor_Rotation(t *testing.T) {
+	ctx := context.Background()
+	licenseAddr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), licenseAddr)
+	require.NoError(t, err)
+	standby, err := auth.NewSigner("59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d", big.NewInt(31337), licenseAddr)
+	require.NoError(t, err)
+
+	licenseABI, err := license.LicenseMetaData.GetAbi()
+	require.NoError(t, err)
+
+	// signerChangedLog builds the log LicenseNFT.updateSigner emits
+	signerChangedLog := func(address common.Address, oldSigner, newSigner common.Address) types.Log {
+		ev := licenseABI.Events["SignerChanged"]
+		data, err := ev.Inputs.NonIndexed().Pack(oldSigner, newSigner)
+		require.NoError(t, err)
+		return types.Log{Address: address, Topics: []common.Hash{ev.ID}, Data: data, BlockNumber: 7}
+	}
+
+	t.Run("SwitchesToLoadedKey", func(t *testing.T) {
+		client, eth, cfg := newSignerTestClient(t, signer)
+
+		monitor := NewSignerMonitor(client, signer, time.Hour)
+		staged, err := monitor.AddSigner(standby)
+		require.NoError(t, err)
+		assert.Equal(t, auth.KeyVerifyOnly, staged.State)
+		require.NoError(t, monitor.Check(ctx))
+		assert.Equal(t, signer.Address(), monitor.Signer().Address())
+
+		// Owner calls updateSigner(standby)
+		eth.setResult(cfg.LicenseNFTAddress, "trustedSigner", standby.Address())
+		monitor.HandleEvent(ctx, SignerEvent{Kind: SignerEventSignerChanged, Old: signer.Address(), New: standby.Address()})
+
+		assert.Equal(t, standby.Address(), monitor.Signer().Address())
+		assert.Equal(t, standby.Address(), monitor.TrustedSigner())
+
+		// Until the oracle's backend signer follows, signing stays refused
+		assert.ErrorIs(t, monitor.OracleErr(), ErrSignerMismatch)
+		assert.ErrorIs(t, monitor.Err(), ErrSignerMismatch)
+		eth.setResult(cfg.ReputationOracleAddress, "backendSigner", standby.Address())
+		require.NoError(t, monitor.Check(ctx))
+		require.NoError(t, monitor.Err())
+
+		// but keeps verifying through the overlap window
+		old, err := monitor.Keys().Get(signer.KeyID())
+		require.NoError(t, err)
+		assert.Equal(t, auth.KeyVerifyOnly, old.State)
+		assert.NotNil(t, old.RetiresAt)
+		active, err := monitor.Keys().Get(standby.KeyID())
+		require.NoError(t, err)
+		assert.Equal(t, auth.KeyActive, active.State)
+	})
+
+	t.Run("ReissuesPendingLicenses", func(t *testing.T) {
+		client, eth, cfg := newSignerTestClient(t, signer)
+		kvStore := cache.NewKVStore()
+		defer kvStore.Close()
+
+		monitor := NewSignerMonitor(client, signer, time.Hour)
+		_, err := monitor.AddSigner(standby)
+		require.NoError(t, err)
+		require.NoError(t, monitor.Check(ctx))
+		service := NewLicenseService(&config.Config{LicenseNFTAddress: licenseAddr.Hex()}, kvStore, signer, &mockBlockchain{})
+		service.UseSignerMonitor(monitor)
+
+		req := &LicenseRequest{UserAddress: common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), ToolID: big.NewInt(1)}
+		before, err := service.RequestLicense(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, signer.KeyID(), before.KeyID)
+
+		eth.setResult(cfg.LicenseNFTAddress, "trustedSigner", standby.Address())
+		eth.setResult(cfg.ReputationOracleAddress, "backendSigner", standby.Address())
+		monitor.HandleEvent(ctx, SignerEvent{Kind: SignerEventSignerChanged, Old: signer.Address(), New: standby.Address()})
+		require.NoError(t, monitor.Err())
+
+		// The pending signature would now revert, so a new one is issued
+		after, err := service.RequestLicense(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, standby.KeyID(), after.KeyID)
+		_, err = service.RequestLicense(ctx, req)
+		assert.ErrorContains(t, err, "license request already pending")
+
+		// Both signatures are still recognised as ours
+		for _, resp := range []*LicenseResponse{before, after} {
+			signature := common.FromHex(resp.SignatureR + resp.SignatureS + resp.SignatureV)
+			key, err := service.VerifyLicenseSignature(resp.User, resp.ToolID, resp.ExpiresAt, resp.Nonce, signature)
+			require.NoError(t, err)
+			assert.Equal(t, resp.KeyID, key.ID)
+		}
+	})
+
+	t.Run("BlocksUnknownKey", func(t *testing.T) {
+		client, eth, cfg := newSignerTestClient(t, signer)
+
+		monitor := NewSignerMonitor(client, signer, time.Hour)
+		require.NoError(t, monitor.Check(ctx))
+
+		rotated := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+		eth.setResult(cfg.LicenseNFTAddress, "trustedSigner", rotated)
+		monitor.HandleEvent(ctx, SignerEvent{Kind: SignerEventSignerChanged, Old: signer.Address(), New: rotated})
+
+		require.ErrorIs(t, monitor.Err(), ErrSignerNotAuthorized)
+		assert.Equal(t, rotated, monitor.TrustedSigner())
+		assert.Equal(t, signer.Address(), monitor.Signer().Address())
+	})
+
+	t.Run("OwnershipTransferred", func(t *testing.T) {
+		client, _, _ := newSignerTestClient(t, signer)
+
+		monitor := NewSignerMonitor(client, signer, time.Hour)
+		newOwner := common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC")
+		monitor.HandleEvent(ctx, SignerEvent{Kind: SignerEventOwnershipTransferred, New: newOwner})
+
+		assert.Equal(t, newOwner, monitor.Owner())
+		assert.NoError(t, monitor.Err())
+	})
+
+	t.Run("WatchesSignerChangedOverWebSocket", func(t *testing.T) {
+		client, eth, cfg := newSignerTestClient(t, signer)
+		client.wsURL = wsURL(cfg.RPCEndpoint)
+
+		monitor := NewSignerMonitor(client, signer, time.Hour)
+		_, err := monitor.AddSigner(standby)
+		require.NoError(t, err)
+		require.NoError(t, monitor.Check(ctx))
+
+		monitor.Start(ctx)
+		defer monitor.Stop()
+
+		require.Eventually(t, func() bool {
+			eth.mu.Lock()
+			defer eth.mu.Unlock()
+			return len(eth.logSubs) == 2
+		}, 2*time.Second, 10*time.Millisecond, "monitor should subscribe to both events")
+
+		eth.setResult(cfg.LicenseNFTAddress, "trustedSigner", standby.Address())
+		eth.setResult(cfg.ReputationOracleAddress, "backendSigner", standby.Address())
+		eth.emitLog(signerChangedLog(cfg.LicenseNFTAddress, signer.Address(), standby.Address()))
+
+		require.Eventually(t, func() bool {
+			return monitor.Signer().Address() == standby.Address() && monitor.Err() == nil
+		}, 2*time.Second, 10*time.Millisecond, "monitor should switch to the standby key")
+	})
+}
+
+// droppingSignerSource accepts its signer and drops the first event
+// subscription as soon as it is made.
+type droppingSignerSource struct {
+	trusted       common.Address
+	subscriptions atomic.Int32
+}
+
+func (s *droppingSignerSource) TrustedSigner(ctx context.Context) (common.Address, error) {
+	return s.trusted, nil
+}
+
+func (s *droppingSignerSource) CheckSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	return nil
+}
+
+func (s *droppingSignerSource) CheckOracleSigner(ctx context.Context, signer *auth.EIP712Signer) error {
+	return nil
+}
+
+func (s *droppingSignerSource) WatchSignerEvents(ctx context.Context, sink chan<- SignerEvent) (event.Subscription, error) {
+	first := s.subscriptions.Add(1) == 1
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		if first {
+			return errors.New("websocket closed")
+		}
+		<-quit
+		return nil
+	}), nil
+}
+
+func TestSignerMonitor_Start(t *testing.T) {
+	ctx := context.Background()
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), common.Address{})
+	require.NoError(t, err)
+
+	t.Run("Resubscribes", func(t *testing.T) {
+		source := &droppingSignerSource{trusted: signer.Address()}
+		monitor := NewSignerMonitor(source, signer, 10*time.Millisecond)
+		monitor.Start(ctx)
+		defer monitor.Stop()
+
+		require.Eventually(t, func() bool {
+			return source.subscriptions.Load() == 2
+		}, 2*time.Second, 10*time.Millisecond, "monitor should resubscribe after the subscription drops")
+
+		// The new subscription holds
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, int32(2), source.subscriptions.Load())
+	})
+
+	t.Run("ZeroInterval", func(t *testing.T) {
+		monitor := NewSignerMonitor(&droppingSignerSource{trusted: signer.Address()}, signer, 0)
+		assert.Equal(t, DefaultSignerCheckInterval, monitor.interval)
+		monitor.Start(ctx)
+		monitor.Stop()
+	})
+}
@@ -0,0 +1,350 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	SubscriptionConnecting   = "connecting"
+	SubscriptionBackfilling  = "backfilling"
+	SubscriptionSubscribed   = "subscribed"
+	SubscriptionReconnecting = "reconnecting"
+	SubscriptionStopped      = "stopped"
+)
+
+const (
+	// How many delivered logs are remembered for de-duplication
+	subscriptionDedupeWindow = 10000
+
+	// How often the head is polled to move the checkpoint while no logs
+	// arrive; about one mainnet block
+	subscriptionHeadInterval = 12 * time.Second
+)
+
+// LogSource is the part of a node connection a LogSubscription needs.
+// *ethclient.Client implements it.
+type LogSource interface {
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	Close()
+}
+
+// SubscriptionState is a snapshot of a LogSubscription.
+type SubscriptionState struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	LastBlock  uint64    `json:"last_block"` // Highest confirmed block known to be fully delivered
+	Reconnects int       `json:"reconnects"`
+	Delivered  uint64    `json:"delivered"`
+	Backfilled uint64    `json:"backfilled"` // Delivered logs that came from FilterLogs
+	Duplicates uint64    `json:"duplicates"`
+	LastError  string    `json:"last_error,omitempty"`
+	Since      time.Time `json:"since"` // When Status last changed
+}
+
+// logKey identifies a log across live delivery and backfill.
+type logKey struct {
+	txHash common.Hash
+	index  uint
+}
+
+// LogSubscription keeps a log subscription alive across node restarts and
+// dropped WebSocket connections. After every (re)subscribe it backfills the
+// blocks it may have missed with FilterLogs, and it delivers each log at most
+// once, keyed by transaction hash and log index.
+//
+// The checkpoint follows the head, polled while the chain is idle, less the
+// confirmation depth: the last unconfirmed blocks are scanned again after a
+// reconnect, so logs a reorg moved into them are not missed.
+type LogSubscription struct {
+	name    string
+	dial    func(ctx context.Context) (LogSource, error)
+	query   ethereum.FilterQuery
+	handler func(types.Log)
+
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	confirmations uint64
+	headInterval  time.Duration
+
+	mu            sync.RWMutex
+	state         SubscriptionState
+	hasCheckpoint bool
+	seen          map[logKey]struct{}
+	seenOrder     []logKey
+	started       bool
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewLogSubscription creates a supervisor that calls handler for every log
+// matching query. If query.FromBlock is set, logs from that block on are
+// backfilled on the first subscribe; otherwise only new logs are delivered.
+func NewLogSubscription(name string, dial func(ctx context.Context) (LogSource, error), query ethereum.FilterQuery, handler func(types.Log)) *LogSubscription {
+	s := &LogSubscription{
+		name:         name,
+		dial:         dial,
+		query:        query,
+		handler:      handler,
+		minBackoff:   500 * time.Millisecond,
+		maxBackoff:   30 * time.Second,
+		headInterval: subscriptionHeadInterval,
+		state:        SubscriptionState{Name: name, Status: SubscriptionConnecting, Since: time.Now()},
+		seen:         make(map[logKey]struct{}),
+		stopChan:     make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if query.FromBlock != nil {
+		s.state.LastBlock = query.FromBlock.Uint64()
+		s.hasCheckpoint = true
+	}
+	return s
+}
+
+// SetBackoff changes the reconnect delay, which doubles from min up to max.
+func (s *LogSubscription) SetBackoff(min, max time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.minBackoff, s.maxBackoff = min, max
+}
+
+// SetConfirmations keeps the checkpoint depth blocks behind the head.
+func (s *LogSubscription) SetConfirmations(depth uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.confirmations = depth
+}
+
+// SetHeadInterval changes how often the head is polled while subscribed.
+// Non-positive intervals are ignored.
+func (s *LogSubscription) SetHeadInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headInterval = interval
+}
+
+// Start runs the supervisor until Stop is called or ctx is done.
+func (s *LogSubscription) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.started = true
+	s.mu.Unlock()
+	go s.run(ctx)
+}
+
+// Stop ends the subscription and waits for the supervisor to exit.
+func (s *LogSubscription) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+
+	s.mu.RLock()
+	started := s.started
+	s.mu.RUnlock()
+	if started {
+		<-s.done
+	}
+}
+
+// State returns a snapshot of the subscription.
+func (s *LogSubscription) State() SubscriptionState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+func (s *LogSubscription) run(ctx context.Context) {
+	defer close(s.done)
+	defer s.setStatus(SubscriptionStopped, nil)
+
+	var backoff time.Duration
+	for {
+		established, err := s.subscribeOnce(ctx)
+		if err == nil {
+			return
+		}
+
+		s.mu.RLock()
+		minBackoff, maxBackoff := s.minBackoff, s.maxBackoff
+		s.mu.RUnlock()
+		if established || backoff == 0 {
+			backoff = minBackoff
+		}
+		s.setStatus(SubscriptionReconnecting, err)
+		log.Printf("%s subscription lost, retrying in %s: %v", s.name, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-s.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		s.mu.Lock()
+		s.state.Reconnects++
+		s.mu.Unlock()
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// subscribeOnce runs one subscription until it fails (non-nil error) or the
+// supervisor is stopped (nil error). established reports whether the
+// subscription got as far as delivering live logs.
+func (s *LogSubscription) subscribeOnce(ctx context.Context) (established bool, err error) {
+	s.setStatus(SubscriptionConnecting, nil)
+
+	source, err := s.dial(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer source.Close()
+
+	// Subscribe before backfilling so nothing falls between the two; any
+	// overlap is removed by de-duplication
+	live := s.query
+	live.FromBlock, live.ToBlock = nil, nil
+	logs := make(chan types.Log, 128)
+	sub, err := source.SubscribeFilterLogs(ctx, live, logs)
+	if err != nil {
+		return false, fmt.Errorf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := s.backfill(ctx, source); err != nil {
+		return false, err
+	}
+	s.setStatus(SubscriptionSubscribed, nil)
+
+	s.mu.RLock()
+	heads := time.NewTicker(s.headInterval)
+	s.mu.RUnlock()
+	defer heads.Stop()
+
+	for {
+		select {
+		case vLog := <-logs:
+			s.deliver(vLog, false)
+		case <-heads.C:
+			head, err := source.BlockNumber(ctx)
+			if err != nil {
+				return true, fmt.Errorf("failed to read head block: %v", err)
+			}
+			s.advance(head)
+		case err := <-sub.Err():
+			if err == nil {
+				err = errors.New("subscription closed by node")
+			}
+			return true, err
+		case <-s.stopChan:
+			return true, nil
+		case <-ctx.Done():
+			return true, nil
+		}
+	}
+}
+
+// backfill delivers logs from the last checkpoint up to the current head and
+// moves the checkpoint to the confirmed head.
+func (s *LogSubscription) backfill(ctx context.Context, source LogSource) error {
+	head, err := source.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read head block: %v", err)
+	}
+
+	s.mu.RLock()
+	from, hasCheckpoint := s.state.LastBlock, s.hasCheckpoint
+	s.mu.RUnlock()
+
+	if hasCheckpoint && from <= head {
+		s.setStatus(SubscriptionBackfilling, nil)
+
+		query := s.query
+		query.FromBlock = new(big.Int).SetUint64(from)
+		query.ToBlock = new(big.Int).SetUint64(head)
+		missed, err := source.FilterLogs(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to backfill blocks %d-%d: %v", from, head, err)
+		}
+		for _, vLog := range missed {
+			s.deliver(vLog, true)
+		}
+	}
+
+	s.mu.Lock()
+	s.hasCheckpoint = true
+	s.mu.Unlock()
+	s.advance(head)
+	return nil
+}
+
+// advance moves the checkpoint to the block confirmed at head. Logs up to
+// head have been delivered by then.
+func (s *LogSubscription) advance(head uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if head >= s.confirmations {
+		s.state.LastBlock = max(s.state.LastBlock, head-s.confirmations)
+	}
+}
+
+// deliver passes vLog to the handler unless it was already delivered.
+// Logs removed by a reorg are skipped. Without a confirmation depth the
+// checkpoint moves to the log's block; otherwise it waits for the head.
+func (s *LogSubscription) deliver(vLog types.Log, backfilled bool) {
+	if vLog.Removed {
+		return
+	}
+
+	key := logKey{txHash: vLog.TxHash, index: vLog.Index}
+	s.mu.Lock()
+	if _, dup := s.seen[key]; dup {
+		s.state.Duplicates++
+		s.mu.Unlock()
+		return
+	}
+	s.seen[key] = struct{}{}
+	s.seenOrder = append(s.seenOrder, key)
+	if len(s.seenOrder) > subscriptionDedupeWindow {
+		delete(s.seen, s.seenOrder[0])
+		s.seenOrder = s.seenOrder[1:]
+	}
+	s.state.Delivered++
+	if backfilled {
+		s.state.Backfilled++
+	}
+	if s.confirmations == 0 {
+		s.state.LastBlock = max(s.state.LastBlock, vLog.BlockNumber)
+	}
+	s.mu.Unlock()
+
+	s.handler(vLog)
+}
+
+func (s *LogSubscription) setStatus(status string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state.Status != status {
+		s.state.Status = status
+		s.state.Since = time.Now()
+	}
+	if err != nil {
+		s.state.LastError = err.Error()
+	} else if status == SubscriptionSubscribed {
+		s.state.LastError = ""
+	}
+}
@@ -0,0 +1,250 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"moltket/internal/contracts/license"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restartableNode is a stand-in node that can be killed and brought back on
+// the same address, dropping every connection and subscription in between.
+type restartableNode struct {
+	t    *testing.T
+	eth  *standInEth
+	addr string
+
+	server     *rpc.Server
+	httpServer *httptest.Server
+}
+
+func newRestartableNode(t *testing.T, eth *standInEth) *restartableNode {
+	t.Helper()
+
+	n := &restartableNode{t: t, eth: eth}
+	n.start()
+	t.Cleanup(n.stop)
+	return n
+}
+
+func (n *restartableNode) start() {
+	n.t.Helper()
+
+	n.eth.mu.Lock()
+	n.eth.logSubs = nil
+	n.eth.mu.Unlock()
+
+	n.server = rpc.NewServer()
+	require.NoError(n.t, n.server.RegisterName("eth", n.eth))
+
+	n.httpServer = httptest.NewUnstartedServer(standInHandler(n.server))
+	if n.addr != "" {
+		listener, err := net.Listen("tcp", n.addr)
+		require.NoError(n.t, err)
+		n.httpServer.Listener.Close()
+		n.httpServer.Listener = listener
+	}
+	n.httpServer.Start()
+	n.addr = n.httpServer.Listener.Addr().String()
+}
+
+func (n *restartableNode) stop() {
+	if n.httpServer == nil {
+		return
+	}
+	n.server.Stop()
+	n.httpServer.Close()
+	n.httpServer = nil
+}
+
+func (n *restartableNode) url() string {
+	return "http://" + n.addr
+}
+
+// licenseMintedLog builds a LicenseMinted log as LicenseNFT would emit it.
+func licenseMintedLog(t *testing.T, contract common.Address, user common.Address, toolID int64, block uint64, txByte byte) types.Log {
+	t.Helper()
+
+	licenseABI, err := license.LicenseMetaData.GetAbi()
+	require.NoError(t, err)
+	event := licenseABI.Events["LicenseMinted"]
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(toolID), big.NewInt(1893456000), big.NewInt(0))
+	require.NoError(t, err)
+
+	return types.Log{
+		Address:     contract,
+		Topics:      []common.Hash{event.ID, common.BytesToHash(user.Bytes())},
+		Data:        data,
+		BlockNumber: block,
+		TxHash:      common.BytesToHash([]byte{txByte}),
+		Index:       0,
+	}
+}
+
+func TestLogSubscription_SurvivesNodeRestart(t *testing.T) {
+	ctx := context.Background()
+	user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	eth := &standInEth{chainID: big.NewInt(31337)}
+	node := newRestartableNode(t, eth)
+	cfg := testBlockchainConfig(node.url())
+	cfg.WSEndpoint = wsURL(node.url())
+	cfg.ConfirmationDepth = 0 // The checkpoint follows delivered logs
+	eth.code = deployedCode(cfg)
+
+	client, err := NewClientFromConfig(cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	var mu sync.Mutex
+	var minted []int64
+	mintedTools := func() []int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int64(nil), minted...)
+	}
+
+	sub, err := client.ListenForLicenseMinted(ctx, func(toolID *big.Int, owner common.Address) {
+		assert.Equal(t, user, owner)
+		mu.Lock()
+		minted = append(minted, toolID.Int64())
+		mu.Unlock()
+	})
+	require.NoError(t, err)
+	sub.SetBackoff(10*time.Millisecond, 50*time.Millisecond)
+
+	waitForStatus := func(status string) {
+		t.Helper()
+		require.Eventually(t, func() bool { return sub.State().Status == status }, 5*time.Second, 10*time.Millisecond,
+			"subscription never became %s", status)
+	}
+
+	// Live delivery
+	waitForStatus(SubscriptionSubscribed)
+	eth.emitLog(licenseMintedLog(t, cfg.LicenseNFTAddress, user, 1, 1, 0x01))
+	require.Eventually(t, func() bool { return len(mintedTools()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Mints while the node is down are only visible through eth_getLogs
+	node.stop()
+	waitForStatus(SubscriptionReconnecting)
+	eth.emitLog(licenseMintedLog(t, cfg.LicenseNFTAddress, user, 2, 2, 0x02))
+	eth.emitLog(licenseMintedLog(t, cfg.LicenseNFTAddress, user, 3, 3, 0x03))
+
+	node.start()
+	waitForStatus(SubscriptionSubscribed)
+	require.Eventually(t, func() bool { return len(mintedTools()) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	// And live again after the restart
+	eth.emitLog(licenseMintedLog(t, cfg.LicenseNFTAddress, user, 4, 4, 0x04))
+	require.Eventually(t, func() bool { return len(mintedTools()) == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []int64{1, 2, 3, 4}, mintedTools())
+
+	state := sub.State()
+	assert.Equal(t, "LicenseMinted", state.Name)
+	assert.GreaterOrEqual(t, state.Reconnects, 1)
+	assert.Equal(t, uint64(4), state.Delivered)
+	assert.Equal(t, uint64(2), state.Backfilled)
+	assert.Equal(t, uint64(4), state.LastBlock)
+	assert.Equal(t, []SubscriptionState{state}, client.SubscriptionStates())
+
+	sub.Stop()
+	assert.Equal(t, SubscriptionStopped, sub.State().Status)
+}
+
+func TestLogSubscription_DeduplicatesBackfill(t *testing.T) {
+	ctx := context.Background()
+	user := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	eth := &standInEth{chainID: big.NewInt(31337)}
+	node := newRestartableNode(t, eth)
+
+	// Already-mined logs from the start block on are backfilled once
+	eth.emitLog(licenseMintedLog(t, contract, user, 1, 5, 0x01))
+	eth.emitLog(licenseMintedLog(t, contract, user, 2, 6, 0x02))
+
+	var mu sync.Mutex
+	var delivered []types.Log
+	query := ethereum.FilterQuery{Addresses: []common.Address{contract}, FromBlock: big.NewInt(5)}
+	sub := NewLogSubscription("test", func(ctx context.Context) (LogSource, error) {
+		return ethclient.DialContext(ctx, wsURL(node.url()))
+	}, query, func(l types.Log) {
+		mu.Lock()
+		delivered = append(delivered, l)
+		mu.Unlock()
+	})
+	sub.SetBackoff(10*time.Millisecond, 50*time.Millisecond)
+	sub.Start(ctx)
+	defer sub.Stop()
+
+	require.Eventually(t, func() bool { return sub.State().Status == SubscriptionSubscribed }, 5*time.Second, 10*time.Millisecond)
+
+	// A restart makes the backfill cover block 6 again, and the same log
+	// is also re-emitted live; neither is delivered twice
+	node.stop()
+	require.Eventually(t, func() bool { return sub.State().Status == SubscriptionReconnecting }, 5*time.Second, 10*time.Millisecond)
+	node.start()
+	require.Eventually(t, func() bool { return sub.State().Status == SubscriptionSubscribed }, 5*time.Second, 10*time.Millisecond)
+	eth.emitLog(licenseMintedLog(t, contract, user, 2, 6, 0x02))
+	eth.emitLog(licenseMintedLog(t, contract, user, 3, 7, 0x03))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(delivered) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return sub.State().Duplicates >= 2 }, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, common.BytesToHash([]byte{0x01}), delivered[0].TxHash)
+	assert.Equal(t, common.BytesToHash([]byte{0x02}), delivered[1].TxHash)
+	assert.Equal(t, common.BytesToHash([]byte{0x03}), delivered[2].TxHash)
+}
+
+func TestLogSubscription_AdvancesOnIdleChain(t *testing.T) {
+	ctx := context.Background()
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	eth := &standInEth{chainID: big.NewInt(31337), head: 20}
+	node := newRestartableNode(t, eth)
+
+	sub := NewLogSubscription("test", func(ctx context.Context) (LogSource, error) {
+		return ethclient.DialContext(ctx, wsURL(node.url()))
+	}, ethereum.FilterQuery{Addresses: []common.Address{contract}}, func(types.Log) {})
+	sub.SetConfirmations(3)
+	sub.SetHeadInterval(10 * time.Millisecond)
+	sub.Start(ctx)
+	defer sub.Stop()
+
+	require.Eventually(t, func() bool { return sub.State().Status == SubscriptionSubscribed }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(17), sub.State().LastBlock)
+
+	// Blocks without matching logs still move the checkpoint, up to the
+	// confirmation depth behind the head
+	eth.mu.Lock()
+	eth.head = 50
+	eth.mu.Unlock()
+	require.Eventually(t, func() bool { return sub.State().LastBlock == 47 }, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, sub.State().Delivered)
+
+	// A log in an unconfirmed block is delivered at once, but leaves the
+	// checkpoint to the head
+	eth.emitLog(types.Log{Address: contract, Topics: []common.Hash{}, Data: []byte{}, BlockNumber: 50, TxHash: common.BytesToHash([]byte{0x01})})
+	require.Eventually(t, func() bool { return sub.State().Delivered == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(47), sub.State().LastBlock)
+}
@@ -0,0 +1,166 @@
+package blockchain
+
+import (
+	"context"
+	"time"
+
+	"moltket/internal/auth"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SyncStatus reports how far the backend is behind the chain, as served by
+// /api/v1/chain/status.
+type SyncStatus struct {
+	Connection        ChainStatus         `json:"connection"`
+	ChainID           string              `json:"chain_id,omitempty"`
+	Head              uint64              `json:"head"`
+	LastIndexedBlock  *uint64             `json:"last_indexed_block,omitempty"` // nil without subscriptions
+	Lag               *uint64             `json:"lag,omitempty"`                // Confirmed head minus LastIndexedBlock
+	ConfirmationDepth uint64              `json:"confirmation_depth"`
+	RPCLatencyMs      int64               `json:"rpc_latency_ms"`
+	Subscriptions     []SubscriptionState `json:"subscriptions"`
+	Contracts         []ContractStatus    `json:"contracts"`
+	Signer            *SignerStatus       `json:"signer,omitempty"` // nil without a signer monitor
+	Error             string              `json:"error,omitempty"`
+}
+
+// ContractStatus describes one configured contract. DomainMatches is nil for
+// contracts without an EIP-712 domain, or when no signer was given.
+type ContractStatus struct {
+	Name             string         `json:"name"`
+	Address          common.Address `json:"address"`
+	DomainMatches    *bool          `json:"domain_matches,omitempty"`
+	DomainMismatches []string       `json:"domain_mismatches,omitempty"`
+	DomainError      string         `json:"domain_error,omitempty"`
+}
+
+// SyncStatusReporter is implemented by blockchain backends that can report
+// their sync state.
+type SyncStatusReporter interface {
+	SyncStatus(ctx context.Context, signer *auth.EIP712Signer) *SyncStatus
+}
+
+// SyncStatus reads the head block, timing the call, and combines it with the
+// state of the client's subscriptions. The last indexed block is the lowest
+// checkpoint across subscriptions, so the lag is that of the slowest one.
+// Checkpoints trail the head by the confirmation depth, so the lag is
+// counted from the confirmed head, Head - ConfirmationDepth.
+// If signer is set, the EIP-712 domains of LicenseNFT and ReputationOracle
+// are compared with it.
+func (c *Client) SyncStatus(ctx context.Context, signer *auth.EIP712Signer) (*SyncStatus, error) {
+	start := time.Now()
+	head, err := c.ethClient.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latency := time.Since(start)
+
+	status := &SyncStatus{
+		Connection:        ChainStatus{Mode: ChainOnline},
+		ChainID:           c.chainID.String(),
+		Head:              head,
+		ConfirmationDepth: c.confirmations,
+		RPCLatencyMs:      latency.Milliseconds(),
+		Subscriptions:     c.SubscriptionStates(),
+		Contracts:         c.contractStatuses(ctx, signer),
+	}
+
+	for _, sub := range status.Subscriptions {
+		if status.LastIndexedBlock == nil || sub.LastBlock < *status.LastIndexedBlock {
+			lastBlock := sub.LastBlock
+			status.LastIndexedBlock = &lastBlock
+		}
+	}
+	if status.LastIndexedBlock != nil {
+		var lag uint64
+		if confirmed := head - min(head, c.confirmations); confirmed > *status.LastIndexedBlock {
+			lag = confirmed - *status.LastIndexedBlock
+		}
+		status.Lag = &lag
+	}
+
+	return status, nil
+}
+
+func (c *Client) contractStatuses(ctx context.Context, signer *auth.EIP712Signer) []ContractStatus {
+	contracts := []ContractStatus{}
+	opts := &bind.CallOpts{Context: ctx}
+
+	if c.licenseNFT != nil {
+		contract := ContractStatus{Name: "LicenseNFT", Address: c.licenseNFT.Address()}
+		if signer != nil {
+			domain, err := c.licenseNFT.Licensecontract.Eip712Domain(opts)
+			if err != nil {
+				contract.DomainError = err.Error()
+			} else {
+				contract.setDomainMismatches(compareDomain(contract.Name, signer, onChainDomain{
+					Name:              domain.Name,
+					Version:           domain.Version,
+					ChainId:           domain.ChainId,
+					VerifyingContract: domain.VerifyingContract,
+				}, c.licenseNFT.Address()))
+			}
+		}
+		contracts = append(contracts, contract)
+	}
+
+	if c.reputationOracle != nil {
+		contract := ContractStatus{Name: "ReputationOracle", Address: c.reputationOracle.Address()}
+		if signer != nil {
+			domain, err := c.reputationOracle.ReputationContract.Eip712Domain(opts)
+			if err != nil {
+				contract.DomainError = err.Error()
+			} else {
+				contract.setDomainMismatches(compareDomain(contract.Name, signer, onChainDomain{
+					Name:              domain.Name,
+					Version:           domain.Version,
+					ChainId:           domain.ChainId,
+					VerifyingContract: domain.VerifyingContract,
+				}, c.reputationOracle.Address()))
+			}
+		}
+		contracts = append(contracts, contract)
+	}
+
+	if c.stakingNFT != nil {
+		contracts = append(contracts, ContractStatus{Name: "StakingNFT", Address: c.stakingNFT.Address()})
+	}
+	if c.skillToken != nil {
+		contracts = append(contracts, ContractStatus{Name: "SkillToken", Address: c.skillToken.address})
+	}
+
+	return contracts
+}
+
+func (s *ContractStatus) setDomainMismatches(mismatches []string) {
+	matches := len(mismatches) == 0
+	s.DomainMatches = &matches
+	s.DomainMismatches = mismatches
+}
+
+// SyncStatus reports the client's sync state, or just the connection state
+// and last known subscription states while degraded.
+func (c *Connection) SyncStatus(ctx context.Context, signer *auth.EIP712Signer) *SyncStatus {
+	client, err := c.Client()
+	if err == nil {
+		var status *SyncStatus
+		status, err = client.SyncStatus(ctx, signer)
+		if err == nil {
+			status.Connection = c.ChainStatus()
+			return status
+		}
+		err = c.check(err)
+	}
+
+	status := &SyncStatus{
+		Connection:    c.ChainStatus(),
+		Subscriptions: c.SubscriptionStates(),
+		Contracts:     []ContractStatus{},
+	}
+	if status.Connection.Mode != ChainDisabled {
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"moltket/internal/auth"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SyncStatus(t *testing.T) {
+	ctx := context.Background()
+	licenseAddr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	signer, err := auth.NewSigner(testSignerKey, big.NewInt(31337), licenseAddr)
+	require.NoError(t, err)
+
+	client, eth, cfg := newSignerTestClient(t, signer)
+	client.confirmations = 12
+	eth.mu.Lock()
+	eth.head = 120
+	eth.contracts[cfg.ReputationOracleAddress].results["eip712Domain"] =
+		eip712DomainResult("OtherDomain", 31337, cfg.ReputationOracleAddress)
+	eth.mu.Unlock()
+
+	// An indexer that has processed up to block 100
+	sub := NewLogSubscription("LicenseMinted", func(ctx context.Context) (LogSource, error) {
+		return nil, fmt.Errorf("not started")
+	}, ethereum.FilterQuery{FromBlock: big.NewInt(100)}, func(types.Log) {})
+	client.subscriptions = append(client.subscriptions, sub)
+
+	status, err := client.SyncStatus(ctx, signer)
+	require.NoError(t, err)
+
+	assert.Equal(t, ChainOnline, status.Connection.Mode)
+	assert.Equal(t, "31337", status.ChainID)
+	assert.Equal(t, uint64(120), status.Head)
+	require.NotNil(t, status.LastIndexedBlock)
+	assert.Equal(t, uint64(100), *status.LastIndexedBlock)
+	require.NotNil(t, status.Lag)
+	assert.Equal(t, uint64(8), *status.Lag, "counted from the confirmed head, 120 - 12")
+	assert.Equal(t, uint64(12), status.ConfirmationDepth)
+	require.Len(t, status.Subscriptions, 1)
+	assert.Equal(t, SubscriptionConnecting, status.Subscriptions[0].Status)
+
+	contracts := map[string]ContractStatus{}
+	for _, contract := range status.Contracts {
+		contracts[contract.Name] = contract
+	}
+	licenseStatus := contracts["LicenseNFT"]
+	assert.Equal(t, cfg.LicenseNFTAddress, licenseStatus.Address)
+	require.NotNil(t, licenseStatus.DomainMatches)
+	assert.True(t, *licenseStatus.DomainMatches)
+
+	oracleStatus := contracts["ReputationOracle"]
+	require.NotNil(t, oracleStatus.DomainMatches)
+	assert.False(t, *oracleStatus.DomainMatches)
+	require.Len(t, oracleStatus.DomainMismatches, 1)
+	assert.Contains(t, oracleStatus.DomainMismatches[0], `"OtherDomain"`)
+
+	// Without a signer the domains are not checked
+	status, err = client.SyncStatus(ctx, nil)
+	require.NoError(t, err)
+	for _, contract := range status.Contracts {
+		assert.Nil(t, contract.DomainMatches, contract.Name)
+	}
+}
+
+func TestConnection_SyncStatusDegraded(t *testing.T) {
+	conn := NewConnection(func() (*Client, error) {
+		return nil, fmt.Errorf("dial tcp: connection refused")
+	}, time.Hour)
+	require.Error(t, conn.Connect(context.Background()))
+
+	status := conn.SyncStatus(context.Background(), nil)
+	assert.Equal(t, ChainDegraded, status.Connection.Mode)
+	assert.Nil(t, status.Lag)
+	assert.Contains(t, status.Error, "connection refused")
+
+	status = NewConnection(nil, 0).SyncStatus(context.Background(), nil)
+	assert.Equal(t, ChainDisabled, status.Connection.Mode)
+	assert.Empty(t, status.Error)
+}
@@ -4,6 +4,7 @@ package core
 import (
 	"context"
 	"log"
+	"strings"
 	"time"
 
 	"moltket/internal/kvstore"
@@ -56,6 +57,7 @@ func (bp *BatchProcessor) run(ctx context.Context) {
 }
 
 // processAllPendingBatches finds and processes all tools with pending votes
+// or supersessions
 func (bp *BatchProcessor) processAllPendingBatches(ctx context.Context) {
     // In production, this would query a database for tools with pending votes
     // For demo, we'll use a simplified approach with cache scanning
@@ -64,35 +66,43 @@ func (bp *BatchProcessor) processAllPendingBatches(ctx context.Context) {
     // Note: This cache scanning approach only works for small-scale demos
     keys := bp.cache.Keys(ctx)
     
-    processedCount := 0
+    // Tools with pending votes, or only with pending supersessions (a
+    // retraction alone makes a batch), each processed once
+    var toolIDs []string
+    seen := make(map[string]bool)
     for _, key := range keys {
-        // Check if this is a pending vote key
-        if len(key) > 12 && key[:12] == "pending:vote:" {
-            toolID := key[12:] // Extract tool ID from key
-            
-            // Process batch for this tool
-            batch, err := bp.voteService.ProcessBatch(ctx, toolID)
-            if err != nil {
-                log.Printf("Failed to process batch for tool %s: %v", toolID, err)
-                continue
+        for _, prefix := range []string{"pending:vote:", "pending:supersession:"} {
+            if toolID, ok := strings.CutPrefix(key, prefix); ok && toolID != "" && !seen[toolID] {
+                seen[toolID] = true
+                toolIDs = append(toolIDs, toolID)
             }
-            
-            processedCount++
-            log.Printf("Processed batch %s for tool %s with %d votes",
-                batch.ID, toolID, batch.VotesCount)
-            
-            // In production, here we would:
-            // 1. Sign the batch data with backend private key
-            // 2. Submit to ReputationOracle.sol contract
-            // 3. Update batch with transaction hash
-            // 4. Notify relevant parties
-            
-            // For demo, we'll just log the batch info
-            log.Printf("Batch ready for blockchain: %s (Merkle root: %s)",
-                batch.ID, batch.MerkleRoot)
         }
     }
     
+    processedCount := 0
+    for _, toolID := range toolIDs {
+        // Process batch for this tool
+        batch, err := bp.voteService.ProcessBatch(ctx, toolID)
+        if err != nil {
+            log.Printf("Failed to process batch for tool %s: %v", toolID, err)
+            continue
+        }
+        
+        processedCount++
+        log.Printf("Processed batch %s for tool %s with %d votes",
+            batch.ID, toolID, batch.VotesCount)
+        
+        // In production, here we would:
+        // 1. Sign the batch data with backend private key
+        // 2. Submit to ReputationOracle.sol contract
+        // 3. Update batch with transaction hash
+        // 4. Notify relevant parties
+        
+        // For demo, we'll just log the batch info
+        log.Printf("Batch ready for blockchain: %s (Merkle root: %s)",
+            batch.ID, batch.MerkleRoot)
+    }
+    
     if processedCount > 0 {
         log.Printf("Batch processing complete: %d batches processed", processedCount)
     }
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/cache"
+	"moltket/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchProcessor_ProcessesPendingVotes(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	service := NewVoteService(&config.Config{ChainID: 1337}, kvStore, nil)
+	processor := NewBatchProcessor(service, kvStore, time.Minute)
+
+	require.NoError(t, kvStore.Set(ctx, "pending:vote:7", []*models.Vote{{
+		ID:           "vote-1",
+		ToolID:       "7",
+		VoterAddress: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		Score:        1,
+		Nonce:        1,
+		CreatedAt:    time.Now(),
+	}}, time.Hour))
+
+	processor.processAllPendingBatches(ctx)
+
+	_, found := kvStore.Get(ctx, "pending:vote:7")
+	assert.False(t, found)
+	stats, err := service.reputation.Stats(ctx, "7")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Checkpoints)
+	assert.Equal(t, int64(1), stats.Totals.Votes)
+}
+
+func TestBatchProcessor_ProcessesRetractions(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	service := NewVoteService(&config.Config{ChainID: 1337}, kvStore, nil)
+	processor := NewBatchProcessor(service, kvStore, time.Minute)
+
+	// A retraction of a committed vote, with no new votes for the tool
+	require.NoError(t, kvStore.Set(ctx, "pending:supersession:8", []*models.VoteSupersession{{
+		VoteID:       "vote-1",
+		BatchID:      "batch_8_1",
+		VoterAddress: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		Score:        1,
+		Weight:       1,
+		VoteLeaf:     "0x01",
+		Nonce:        2,
+		CreatedAt:    time.Now(),
+	}}, time.Hour))
+
+	processor.processAllPendingBatches(ctx)
+
+	_, found := kvStore.Get(ctx, "pending:supersession:8")
+	assert.False(t, found)
+	stats, err := service.reputation.Stats(ctx, "8")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Checkpoints)
+	assert.Equal(t, int64(-1), stats.Totals.Votes)
+}
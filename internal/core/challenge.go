@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"moltket/internal/auth"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// How long an issued /verify nonce may be used
+const verifyNonceTTL = 5 * time.Minute
+
+var (
+	// ErrInvalidVerifyNonce is returned for a nonce that was never issued,
+	// has expired or was already used.
+	ErrInvalidVerifyNonce = errors.New("invalid, expired or used verify nonce")
+
+	// ErrInvalidRequestSignature is returned when a request was not signed by
+	// the user it names.
+	ErrInvalidRequestSignature = errors.New("request signature does not match user address")
+)
+
+// VerifyChallenge is a single-use nonce for one /verify request, together
+// with the EIP-712 domain and types the request has to be signed with.
+type VerifyChallenge struct {
+	Nonce       string                   `json:"nonce"`
+	ExpiresAt   time.Time                `json:"expires_at"`
+	PrimaryType string                   `json:"primary_type"`
+	Domain      apitypes.TypedDataDomain `json:"domain"`
+	Types       apitypes.Types           `json:"types"`
+}
+
+// RequestDomain returns the domain /verify requests are signed under.
+func (s *VerificationService) RequestDomain() apitypes.TypedDataDomain {
+	return auth.RequestDomain(big.NewInt(s.config.ChainID), common.HexToAddress(s.config.LicenseNFTAddress))
+}
+
+// IssueVerifyNonce returns a new challenge for a /verify request.
+func (s *VerificationService) IssueVerifyNonce(ctx context.Context) (*VerifyChallenge, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(b)
+
+	if err := s.store.Set(ctx, "verify:nonce:"+nonce, true, verifyNonceTTL); err != nil {
+		return nil, fmt.Errorf("failed to store nonce: %w", err)
+	}
+
+	typedData, err := auth.Types.TypedData(s.RequestDomain(), "VerifyRequest", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifyChallenge{
+		Nonce:       nonce,
+		ExpiresAt:   time.Now().Add(verifyNonceTTL),
+		PrimaryType: typedData.PrimaryType,
+		Domain:      typedData.Domain,
+		Types:       typedData.Types,
+	}, nil
+}
+
+// AuthenticateRequest checks that req was signed by its user over an issued
+// nonce, and consumes the nonce. The signature is checked before the nonce
+// is consumed, so a forged request cannot burn someone else's nonce.
+func (s *VerificationService) AuthenticateRequest(ctx context.Context, req *auth.VerifyRequest, signature []byte) error {
+	return s.authenticateRequest(ctx, req, req.User, signature)
+}
+
+// authenticateRequest checks that req was signed by signer, which is the
+// user or a session key they delegated to, and consumes its nonce.
+func (s *VerificationService) authenticateRequest(ctx context.Context, req *auth.VerifyRequest, signer common.Address, signature []byte) error {
+	nonceKey := "verify:nonce:" + req.Nonce
+	if _, found := s.store.Get(ctx, nonceKey); !found {
+		return ErrInvalidVerifyNonce
+	}
+
+	hash, err := auth.HashVerifyRequest(s.RequestDomain(), req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// EOA signatures are 65 bytes; contract wallets may use any format
+	valid, err := s.signatures.Verify(ctx, signer, hash, signature)
+	if err != nil {
+		return fmt.Errorf("failed to verify request signature: %w", err)
+	}
+	if !valid {
+		return ErrInvalidRequestSignature
+	}
+
+	return s.consumeVerifyNonce(ctx, req.Nonce)
+}
+
+// consumeVerifyNonce claims an issued nonce. Only the first caller to claim
+// it gets through.
+func (s *VerificationService) consumeVerifyNonce(ctx context.Context, nonce string) error {
+	nonceKey := "verify:nonce:" + nonce
+	uses, err := s.store.Increment(ctx, nonceKey+":uses", 1, verifyNonceTTL)
+	if err != nil {
+		return fmt.Errorf("failed to consume nonce: %w", err)
+	}
+	if uses != 1 {
+		return ErrInvalidVerifyNonce
+	}
+	s.store.Delete(ctx, nonceKey)
+	return nil
+}
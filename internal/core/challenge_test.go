@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/kvstore"
+	"moltket/internal/testutils"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticateRequest(t *testing.T) {
+	ctx := context.Background()
+	store := kvstore.NewMemoryStore(time.Minute)
+	defer store.Close()
+
+	service := NewVerificationService(&config.Config{
+		ChainID:           31337,
+		LicenseNFTAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+	}, store, nil)
+
+	pk := testutils.GenerateTestPrivateKey(t, "verify-user")
+	user := testutils.PrivateKeyToAddress(t, pk)
+	body := []byte(`{"license_nft_id":"1","tool_id":"42","user_address":"` + user.Hex() + `"}`)
+
+	sign := func(req *auth.VerifyRequest) []byte {
+		return hexutil.MustDecode(testutils.SignVerifyRequest(t, pk, service.RequestDomain(), req))
+	}
+
+	t.Run("SingleUse", func(t *testing.T) {
+		challenge, err := service.IssueVerifyNonce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "VerifyRequest", challenge.PrimaryType)
+		assert.Equal(t, auth.RequestDomainName, challenge.Domain.Name)
+		assert.Contains(t, challenge.Types, "VerifyRequest")
+
+		req := auth.NewVerifyRequest(user, "42", body, challenge.Nonce)
+		signature := sign(req)
+		require.NoError(t, service.AuthenticateRequest(ctx, req, signature))
+
+		// Replaying the same signed request fails
+		assert.ErrorIs(t, service.AuthenticateRequest(ctx, req, signature), ErrInvalidVerifyNonce)
+	})
+
+	t.Run("UnknownNonce", func(t *testing.T) {
+		req := auth.NewVerifyRequest(user, "42", body, "not-issued")
+		assert.ErrorIs(t, service.AuthenticateRequest(ctx, req, sign(req)), ErrInvalidVerifyNonce)
+	})
+
+	t.Run("BoundToToolAndBody", func(t *testing.T) {
+		challenge, err := service.IssueVerifyNonce(ctx)
+		require.NoError(t, err)
+		signature := sign(auth.NewVerifyRequest(user, "42", body, challenge.Nonce))
+
+		otherTool := auth.NewVerifyRequest(user, "43", body, challenge.Nonce)
+		assert.ErrorIs(t, service.AuthenticateRequest(ctx, otherTool, signature), ErrInvalidRequestSignature)
+		otherBody := auth.NewVerifyRequest(user, "42", append(body, ' '), challenge.Nonce)
+		assert.ErrorIs(t, service.AuthenticateRequest(ctx, otherBody, signature), ErrInvalidRequestSignature)
+
+		// Failed attempts do not use up the nonce
+		require.NoError(t, service.AuthenticateRequest(ctx, auth.NewVerifyRequest(user, "42", body, challenge.Nonce), signature))
+	})
+
+	t.Run("BoundToDeployment", func(t *testing.T) {
+		challenge, err := service.IssueVerifyNonce(ctx)
+		require.NoError(t, err)
+		req := auth.NewVerifyRequest(user, "42", body, challenge.Nonce)
+
+		other := NewVerificationService(&config.Config{ChainID: 1, LicenseNFTAddress: service.config.LicenseNFTAddress}, store, nil)
+		signature := hexutil.MustDecode(testutils.SignVerifyRequest(t, pk, other.RequestDomain(), req))
+		assert.ErrorIs(t, service.AuthenticateRequest(ctx, req, signature), ErrInvalidRequestSignature)
+	})
+}
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"moltket/internal/auth"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Longest a delegation may be valid for; the user's delegation index lives
+// this long after their last delegation
+const maxDelegationLifetime = 90 * 24 * time.Hour
+
+var (
+	// ErrInvalidDelegation is returned when registering a delegation that is
+	// malformed, expired, too long-lived, or not signed by its user.
+	ErrInvalidDelegation = errors.New("invalid delegation")
+
+	// ErrDelegationNotFound is returned for delegations that do not exist,
+	// have expired from the store, or belong to another user.
+	ErrDelegationNotFound = errors.New("delegation not found")
+
+	// ErrDelegationDenied is returned when a delegation does not cover a
+	// request: it is revoked, expired, for another user or tool, or used up.
+	ErrDelegationDenied = errors.New("delegation does not authorize this request")
+
+	// ErrInvalidSessionKeySignature is returned when a delegated request was
+	// not signed by the delegation's session key.
+	ErrInvalidSessionKeySignature = errors.New("request signature does not match delegated session key")
+)
+
+// RegisterDelegation stores a delegation after checking the user signed it
+// under RequestDomain. Registering the same delegation again returns the
+// stored one.
+func (s *VerificationService) RegisterDelegation(ctx context.Context, d *auth.Delegation, signature []byte) (*models.Delegation, error) {
+	now := time.Now()
+	expiresAt := time.Unix(d.ExpiresAt, 0)
+	switch {
+	case d.User == (common.Address{}) || d.SessionKey == (common.Address{}):
+		return nil, fmt.Errorf("%w: user and session key are required", ErrInvalidDelegation)
+	case d.SessionKey == d.User:
+		return nil, fmt.Errorf("%w: session key must differ from user", ErrInvalidDelegation)
+	case d.Nonce == "":
+		return nil, fmt.Errorf("%w: nonce is required", ErrInvalidDelegation)
+	case !expiresAt.After(now):
+		return nil, fmt.Errorf("%w: already expired", ErrInvalidDelegation)
+	case expiresAt.Sub(now) > maxDelegationLifetime:
+		return nil, fmt.Errorf("%w: expires more than %s from now", ErrInvalidDelegation, maxDelegationLifetime)
+	}
+	for _, toolID := range d.ToolIDs {
+		if toolID == "" {
+			return nil, fmt.Errorf("%w: empty tool ID", ErrInvalidDelegation)
+		}
+	}
+
+	hash, err := auth.HashDelegation(s.RequestDomain(), d)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Users may delegate from contract wallets; session keys are EOAs
+	valid, err := s.signatures.Verify(verifyCtx, d.User, hash, signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify delegation signature: %w", err)
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: not signed by user", ErrInvalidDelegation)
+	}
+
+	s.delegationMu.Lock()
+	defer s.delegationMu.Unlock()
+
+	id := hash.Hex()
+	if existing, ok := s.loadDelegation(ctx, id); ok {
+		return s.withCallsUsed(ctx, existing), nil
+	}
+
+	delegation := &models.Delegation{
+		ID:         id,
+		User:       strings.ToLower(d.User.Hex()),
+		SessionKey: strings.ToLower(d.SessionKey.Hex()),
+		ToolIDs:    append([]string(nil), d.ToolIDs...),
+		MaxCalls:   d.MaxCalls,
+		Nonce:      d.Nonce,
+		Signature:  hexutil.Encode(signature),
+		ExpiresAt:  expiresAt.UTC(),
+		CreatedAt:  now.UTC(),
+	}
+	if err := s.saveDelegation(ctx, delegation); err != nil {
+		return nil, err
+	}
+
+	ids := append(s.userDelegationIDs(ctx, delegation.User), id)
+	if err := s.store.Set(ctx, "delegations:user:"+delegation.User, ids, maxDelegationLifetime); err != nil {
+		return nil, fmt.Errorf("failed to index delegation: %w", err)
+	}
+	return s.withCallsUsed(ctx, delegation), nil
+}
+
+// Delegations returns the delegations of user that have not expired from
+// the store, oldest first.
+func (s *VerificationService) Delegations(ctx context.Context, user common.Address) []*models.Delegation {
+	delegations := []*models.Delegation{}
+	for _, id := range s.userDelegationIDs(ctx, strings.ToLower(user.Hex())) {
+		if delegation, ok := s.loadDelegation(ctx, id); ok {
+			delegations = append(delegations, s.withCallsUsed(ctx, delegation))
+		}
+	}
+	return delegations
+}
+
+// RevokeDelegation revokes one of user's delegations. Requests signed by
+// its session key are refused from then on.
+func (s *VerificationService) RevokeDelegation(ctx context.Context, user common.Address, id string) error {
+	s.delegationMu.Lock()
+	defer s.delegationMu.Unlock()
+
+	delegation, ok := s.loadDelegation(ctx, id)
+	if !ok || delegation.User != strings.ToLower(user.Hex()) {
+		return ErrDelegationNotFound
+	}
+	if delegation.RevokedAt != nil {
+		return nil
+	}
+
+	revoked := *delegation
+	now := time.Now().UTC()
+	revoked.RevokedAt = &now
+	return s.saveDelegation(ctx, &revoked)
+}
+
+// RevokeDelegationSigned revokes the delegation r names, authenticated by
+// its user's signature over an issued verify nonce instead of a session.
+// The signature is checked before the nonce is consumed.
+func (s *VerificationService) RevokeDelegationSigned(ctx context.Context, r *auth.RevokeDelegation, signature []byte) error {
+	delegation, ok := s.loadDelegation(ctx, r.ID)
+	if !ok {
+		return ErrDelegationNotFound
+	}
+	if _, found := s.store.Get(ctx, "verify:nonce:"+r.Nonce); !found {
+		return ErrInvalidVerifyNonce
+	}
+
+	hash, err := auth.HashRevokeDelegation(s.RequestDomain(), r)
+	if err != nil {
+		return err
+	}
+
+	verifyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	user := common.HexToAddress(delegation.User)
+	valid, err := s.signatures.Verify(verifyCtx, user, hash, signature)
+	if err != nil {
+		return fmt.Errorf("failed to verify revocation signature: %w", err)
+	}
+	if !valid {
+		return ErrInvalidRequestSignature
+	}
+
+	if err := s.consumeVerifyNonce(ctx, r.Nonce); err != nil {
+		return err
+	}
+	return s.RevokeDelegation(ctx, user, r.ID)
+}
+
+// AuthenticateDelegatedRequest is like AuthenticateRequest, but req is
+// signed by the session key of delegation id rather than by req.User. The
+// request counts against the delegation's call limit; license checks and
+// quotas apply to req.User as usual.
+func (s *VerificationService) AuthenticateDelegatedRequest(ctx context.Context, id string, req *auth.VerifyRequest, signature []byte) (*models.Delegation, error) {
+	delegation, ok := s.loadDelegation(ctx, id)
+	if !ok {
+		return nil, ErrDelegationNotFound
+	}
+
+	callsKey := "delegation:calls:" + delegation.ID
+	switch {
+	case delegation.User != strings.ToLower(req.User.Hex()):
+		return nil, fmt.Errorf("%w: delegated by another user", ErrDelegationDenied)
+	case delegation.RevokedAt != nil:
+		return nil, fmt.Errorf("%w: revoked", ErrDelegationDenied)
+	case !time.Now().Before(delegation.ExpiresAt):
+		return nil, fmt.Errorf("%w: expired", ErrDelegationDenied)
+	case !delegation.AllowsTool(req.ToolID):
+		return nil, fmt.Errorf("%w: tool %s is not delegated", ErrDelegationDenied, req.ToolID)
+	case delegation.MaxCalls > 0 && s.delegationCalls(ctx, callsKey) >= int64(delegation.MaxCalls):
+		return nil, fmt.Errorf("%w: call limit reached", ErrDelegationDenied)
+	}
+
+	err := s.authenticateRequest(ctx, req, common.HexToAddress(delegation.SessionKey), signature)
+	if errors.Is(err, ErrInvalidRequestSignature) {
+		return nil, ErrInvalidSessionKeySignature
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Concurrent requests may pass the check above together; only those
+	// within the limit get through
+	calls, err := s.store.Increment(ctx, callsKey, 1, time.Until(delegation.ExpiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count delegated call: %w", err)
+	}
+	if delegation.MaxCalls > 0 && calls > int64(delegation.MaxCalls) {
+		return nil, fmt.Errorf("%w: call limit reached", ErrDelegationDenied)
+	}
+
+	used := *delegation
+	used.CallsUsed = calls
+	return &used, nil
+}
+
+func (s *VerificationService) loadDelegation(ctx context.Context, id string) (*models.Delegation, bool) {
+	cached, found := s.store.Get(ctx, "delegation:"+strings.ToLower(id))
+	if !found {
+		return nil, false
+	}
+	delegation, ok := cached.(*models.Delegation)
+	return delegation, ok
+}
+
+// saveDelegation stores a delegation until it expires. Stored records are
+// never mutated.
+func (s *VerificationService) saveDelegation(ctx context.Context, delegation *models.Delegation) error {
+	ttl := time.Until(delegation.ExpiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("%w: expired", ErrDelegationDenied)
+	}
+	if err := s.store.Set(ctx, "delegation:"+strings.ToLower(delegation.ID), delegation, ttl); err != nil {
+		return fmt.Errorf("failed to store delegation: %w", err)
+	}
+	return nil
+}
+
+func (s *VerificationService) userDelegationIDs(ctx context.Context, user string) []string {
+	cached, found := s.store.Get(ctx, "delegations:user:"+user)
+	if !found {
+		return nil
+	}
+	ids, _ := cached.([]string)
+	return append([]string(nil), ids...)
+}
+
+func (s *VerificationService) delegationCalls(ctx context.Context, callsKey string) int64 {
+	cached, found := s.store.Get(ctx, callsKey)
+	if !found {
+		return 0
+	}
+	calls, _ := cached.(int64)
+	return calls
+}
+
+func (s *VerificationService) withCallsUsed(ctx context.Context, delegation *models.Delegation) *models.Delegation {
+	c := *delegation
+	c.ToolIDs = append([]string(nil), delegation.ToolIDs...)
+	c.CallsUsed = s.delegationCalls(ctx, "delegation:calls:"+delegation.ID)
+	return &c
+}
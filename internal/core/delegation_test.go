@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/kvstore"
+	"moltket/internal/testutils"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelegatedRequests(t *testing.T) {
+	ctx := context.Background()
+	store := kvstore.NewMemoryStore(time.Minute)
+	defer store.Close()
+
+	service := NewVerificationService(&config.Config{
+		ChainID:           31337,
+		LicenseNFTAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+	}, store, nil)
+
+	userKey := testutils.GenerateTestPrivateKey(t, "delegating-user")
+	user := testutils.PrivateKeyToAddress(t, userKey)
+	agentKey := testutils.GenerateTestPrivateKey(t, "agent-session")
+	agent := testutils.PrivateKeyToAddress(t, agentKey)
+	body := []byte(`{"license_nft_id":"1","tool_id":"42","user_address":"` + user.Hex() + `"}`)
+
+	register := func(t *testing.T, d *auth.Delegation) string {
+		signature := hexutil.MustDecode(testutils.SignDelegation(t, userKey, service.RequestDomain(), d))
+		delegation, err := service.RegisterDelegation(ctx, d, signature)
+		require.NoError(t, err)
+		return delegation.ID
+	}
+	call := func(t *testing.T, id, toolID string, signer *ecdsa.PrivateKey) error {
+		challenge, err := service.IssueVerifyNonce(ctx)
+		require.NoError(t, err)
+		req := auth.NewVerifyRequest(user, toolID, body, challenge.Nonce)
+		signature := hexutil.MustDecode(testutils.SignVerifyRequest(t, signer, service.RequestDomain(), req))
+		_, err = service.AuthenticateDelegatedRequest(ctx, id, req, signature)
+		return err
+	}
+	delegation := func(nonce string, maxCalls uint64, toolIDs ...string) *auth.Delegation {
+		return &auth.Delegation{
+			User:       user,
+			SessionKey: agent,
+			ToolIDs:    toolIDs,
+			MaxCalls:   maxCalls,
+			ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+			Nonce:      nonce,
+		}
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		d := delegation("register", 0)
+		id := register(t, d)
+		assert.Equal(t, id, register(t, d), "registering twice returns the same delegation")
+
+		// Signed by someone other than the user
+		signature := hexutil.MustDecode(testutils.SignDelegation(t, agentKey, service.RequestDomain(), delegation("forged", 0)))
+		_, err := service.RegisterDelegation(ctx, delegation("forged", 0), signature)
+		assert.ErrorIs(t, err, ErrInvalidDelegation)
+
+		expired := delegation("expired", 0)
+		expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		signature = hexutil.MustDecode(testutils.SignDelegation(t, userKey, service.RequestDomain(), expired))
+		_, err = service.RegisterDelegation(ctx, expired, signature)
+		assert.ErrorIs(t, err, ErrInvalidDelegation)
+
+		tooLong := delegation("too-long", 0)
+		tooLong.ExpiresAt = time.Now().Add(maxDelegationLifetime + time.Hour).Unix()
+		signature = hexutil.MustDecode(testutils.SignDelegation(t, userKey, service.RequestDomain(), tooLong))
+		_, err = service.RegisterDelegation(ctx, tooLong, signature)
+		assert.ErrorIs(t, err, ErrInvalidDelegation)
+	})
+
+	t.Run("ScopeAndCallLimit", func(t *testing.T) {
+		id := register(t, delegation("scoped", 2, "42"))
+
+		require.NoError(t, call(t, id, "42", agentKey))
+		assert.ErrorIs(t, call(t, id, "7", agentKey), ErrDelegationDenied)
+		assert.ErrorIs(t, call(t, id, "42", userKey), ErrInvalidSessionKeySignature)
+		require.NoError(t, call(t, id, "42", agentKey))
+		assert.ErrorIs(t, call(t, id, "42", agentKey), ErrDelegationDenied)
+
+		for _, d := range service.Delegations(ctx, user) {
+			if d.ID == id {
+				assert.Equal(t, int64(2), d.CallsUsed)
+			}
+		}
+		assert.ErrorIs(t, call(t, "0xunknown", "42", agentKey), ErrDelegationNotFound)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		id := register(t, delegation("revocable", 0))
+		require.NoError(t, call(t, id, "42", agentKey))
+
+		assert.ErrorIs(t, service.RevokeDelegation(ctx, agent, id), ErrDelegationNotFound, "only the user revokes")
+		require.NoError(t, service.RevokeDelegation(ctx, user, id))
+		assert.ErrorIs(t, call(t, id, "42", agentKey), ErrDelegationDenied)
+		assert.Len(t, service.Delegations(ctx, user), 3)
+	})
+
+	t.Run("RevokeSigned", func(t *testing.T) {
+		id := register(t, delegation("revocable-signed", 0))
+		revoke := func(t *testing.T, signer *ecdsa.PrivateKey, nonce string) error {
+			r := &auth.RevokeDelegation{ID: id, Nonce: nonce}
+			signature := hexutil.MustDecode(testutils.SignRevokeDelegation(t, signer, service.RequestDomain(), r))
+			return service.RevokeDelegationSigned(ctx, r, signature)
+		}
+		challenge, err := service.IssueVerifyNonce(ctx)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, revoke(t, agentKey, challenge.Nonce), ErrInvalidRequestSignature, "only the user revokes")
+		assert.ErrorIs(t, revoke(t, userKey, "unissued"), ErrInvalidVerifyNonce)
+		require.NoError(t, revoke(t, userKey, challenge.Nonce))
+		assert.ErrorIs(t, revoke(t, userKey, challenge.Nonce), ErrInvalidVerifyNonce, "nonces are single-use")
+		assert.ErrorIs(t, call(t, id, "42", agentKey), ErrDelegationDenied)
+	})
+}
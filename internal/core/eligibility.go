@@ -0,0 +1,175 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/models"
+)
+
+// Names of the built-in eligibility rules, reported with rejections
+const (
+	RuleMinCalls          = "min_calls"
+	RuleMinUsageAge       = "min_usage_age"
+	RuleVotePeriod        = "vote_period"
+	RuleProvenanceReceipt = "provenance_receipt"
+)
+
+// ErrVoterNotEligible is wrapped by every *EligibilityError.
+var ErrVoterNotEligible = errors.New("voter not eligible")
+
+// EligibilityError is returned when a voter fails an eligibility rule.
+type EligibilityError struct {
+	Rule   string // Name of the failed rule
+	Reason string
+}
+
+func (e *EligibilityError) Error() string {
+	return fmt.Sprintf("voter not eligible: %s", e.Reason)
+}
+
+func (e *EligibilityError) Unwrap() error {
+	return ErrVoterNotEligible
+}
+
+// VoterHistory is what eligibility rules judge a vote on.
+type VoterHistory struct {
+	Voter      string // Lowercase address
+	ToolID     string
+	Usage      models.ToolUsage
+	LastVoteAt time.Time                 // Zero if the voter never voted on the tool
+	Replaces   bool                      // The vote replaces the voter's active vote on the tool
+	Receipt    *models.ProvenanceReceipt // Receipt the vote was submitted with, if it exists
+}
+
+// EligibilityRule is one condition a voter must meet to vote on a tool.
+type EligibilityRule interface {
+	// Name identifies the rule in rejections.
+	Name() string
+
+	// Check returns why history does not meet the rule as of now, or nil.
+	Check(history *VoterHistory, now time.Time) error
+}
+
+// EligibilityPolicy is the set of rules every vote must pass.
+type EligibilityPolicy struct {
+	rules []EligibilityRule
+}
+
+// NewEligibilityPolicy creates a policy of rules, checked in order.
+func NewEligibilityPolicy(rules ...EligibilityRule) *EligibilityPolicy {
+	return &EligibilityPolicy{
+		rules: rules,
+	}
+}
+
+// NewEligibilityPolicyFromConfig creates the policy configured by cfg.
+// Rules with a zero threshold are left out.
+func NewEligibilityPolicyFromConfig(cfg *config.Config) *EligibilityPolicy {
+	var rules []EligibilityRule
+	if cfg.VoteMinCalls > 0 {
+		rules = append(rules, MinCallsRule{Min: int64(cfg.VoteMinCalls)})
+	}
+	if cfg.VoteMinUsageAge > 0 {
+		rules = append(rules, MinUsageAgeRule{Age: cfg.VoteMinUsageAge})
+	}
+	if cfg.VotePeriod > 0 {
+		rules = append(rules, VotePeriodRule{Period: cfg.VotePeriod})
+	}
+	if cfg.VoteRequireReceipt {
+		rules = append(rules, ProvenanceReceiptRule{})
+	}
+	return NewEligibilityPolicy(rules...)
+}
+
+// Rules returns the names of the policy's rules.
+func (p *EligibilityPolicy) Rules() []string {
+	names := make([]string, len(p.rules))
+	for i, rule := range p.rules {
+		names[i] = rule.Name()
+	}
+	return names
+}
+
+// Check returns an *EligibilityError for the first rule history fails, or
+// nil.
+func (p *EligibilityPolicy) Check(history *VoterHistory, now time.Time) error {
+	for _, rule := range p.rules {
+		if err := rule.Check(history, now); err != nil {
+			return &EligibilityError{
+				Rule:   rule.Name(),
+				Reason: err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
+// MinCallsRule requires a number of verified calls to the tool.
+type MinCallsRule struct {
+	Min int64
+}
+
+func (r MinCallsRule) Name() string { return RuleMinCalls }
+
+func (r MinCallsRule) Check(history *VoterHistory, now time.Time) error {
+	if history.Usage.Calls < r.Min {
+		return fmt.Errorf("%d verified calls to the tool, %d required", history.Usage.Calls, r.Min)
+	}
+	return nil
+}
+
+// MinUsageAgeRule requires the voter's first verified call to the tool to
+// be some time ago, so fresh addresses cannot vote right away.
+type MinUsageAgeRule struct {
+	Age time.Duration
+}
+
+func (r MinUsageAgeRule) Name() string { return RuleMinUsageAge }
+
+func (r MinUsageAgeRule) Check(history *VoterHistory, now time.Time) error {
+	if history.Usage.FirstCallAt.IsZero() {
+		return errors.New("no verified calls to the tool")
+	}
+	if age := now.Sub(history.Usage.FirstCallAt); age < r.Age {
+		return fmt.Errorf("first used the tool %s ago, %s required", age.Truncate(time.Second), r.Age)
+	}
+	return nil
+}
+
+// VotePeriodRule allows one vote per voter and tool per period. A vote that
+// replaces the voter's active vote amends it rather than adding another, so
+// it is allowed at any time.
+type VotePeriodRule struct {
+	Period time.Duration
+}
+
+func (r VotePeriodRule) Name() string { return RuleVotePeriod }
+
+func (r VotePeriodRule) Check(history *VoterHistory, now time.Time) error {
+	if !history.Replaces && !history.LastVoteAt.IsZero() && now.Sub(history.LastVoteAt) < r.Period {
+		return fmt.Errorf("already voted on the tool at %s, one vote per %s allowed",
+			history.LastVoteAt.UTC().Format(time.RFC3339), r.Period)
+	}
+	return nil
+}
+
+// ProvenanceReceiptRule requires the vote to come with the provenance hash
+// of a verified call the voter made to the tool.
+type ProvenanceReceiptRule struct{}
+
+func (r ProvenanceReceiptRule) Name() string { return RuleProvenanceReceipt }
+
+func (r ProvenanceReceiptRule) Check(history *VoterHistory, now time.Time) error {
+	receipt := history.Receipt
+	if receipt == nil {
+		return errors.New("no valid provenance receipt")
+	}
+	if receipt.UserAddress != strings.ToLower(history.Voter) || receipt.ToolID != history.ToolID {
+		return errors.New("provenance receipt was issued for another user or tool")
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEligibilityRules(t *testing.T) {
+	now := time.Now()
+	voter := "0x70997970c51812dc3a010c7d01b50e0d17dc79c8"
+	history := func() *VoterHistory {
+		return &VoterHistory{
+			Voter:  voter,
+			ToolID: "42",
+			Usage:  models.ToolUsage{Calls: 5, FirstCallAt: now.Add(-48 * time.Hour)},
+		}
+	}
+
+	policy := NewEligibilityPolicy(
+		MinCallsRule{Min: 3},
+		MinUsageAgeRule{Age: 24 * time.Hour},
+		VotePeriodRule{Period: 24 * time.Hour},
+		ProvenanceReceiptRule{},
+	)
+	assert.Equal(t, []string{RuleMinCalls, RuleMinUsageAge, RuleVotePeriod, RuleProvenanceReceipt}, policy.Rules())
+
+	valid := history()
+	valid.Receipt = &models.ProvenanceReceipt{UserAddress: voter, ToolID: "42"}
+	assert.NoError(t, policy.Check(valid, now))
+
+	failures := map[string]func(*VoterHistory){
+		RuleMinCalls:    func(h *VoterHistory) { h.Usage.Calls = 2 },
+		RuleMinUsageAge: func(h *VoterHistory) { h.Usage.FirstCallAt = now.Add(-time.Hour) },
+		RuleVotePeriod:  func(h *VoterHistory) { h.LastVoteAt = now.Add(-time.Hour) },
+		RuleProvenanceReceipt: func(h *VoterHistory) {
+			h.Receipt = &models.ProvenanceReceipt{UserAddress: voter, ToolID: "43"}
+		},
+	}
+	for rule, mutate := range failures {
+		t.Run(rule, func(t *testing.T) {
+			h := history()
+			h.Receipt = valid.Receipt
+			mutate(h)
+
+			err := policy.Check(h, now)
+			assert.ErrorIs(t, err, ErrVoterNotEligible)
+			var ineligible *EligibilityError
+			require.ErrorAs(t, err, &ineligible)
+			assert.Equal(t, rule, ineligible.Rule)
+		})
+	}
+
+	t.Run("Replacement", func(t *testing.T) {
+		h := history()
+		h.Receipt = valid.Receipt
+		h.LastVoteAt = now.Add(-time.Hour)
+		h.Replaces = true
+		assert.NoError(t, policy.Check(h, now))
+	})
+
+	t.Run("UnusedTool", func(t *testing.T) {
+		err := NewEligibilityPolicy(MinUsageAgeRule{Age: time.Hour}).Check(&VoterHistory{}, now)
+		assert.ErrorContains(t, err, "no verified calls")
+	})
+
+	t.Run("FromConfig", func(t *testing.T) {
+		policy := NewEligibilityPolicyFromConfig(&config.Config{VoteMinCalls: 1, VotePeriod: time.Hour})
+		assert.Equal(t, []string{RuleMinCalls, RuleVotePeriod}, policy.Rules())
+		assert.Empty(t, NewEligibilityPolicyFromConfig(&config.Config{}).Rules())
+	})
+}
+
+func TestVoteEligibility(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	cfg := &config.Config{
+		ChainID:                 31337,
+		ReputationOracleAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		VoteMinCalls:            2,
+		VoteMinUsageAge:         time.Hour,
+		VotePeriod:              24 * time.Hour,
+	}
+	service := NewVoteService(cfg, kvStore, nil)
+	domain := auth.VoteDomain(big.NewInt(cfg.ChainID), common.HexToAddress(cfg.ReputationOracleAddress))
+
+	voterKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	voter := crypto.PubkeyToAddress(voterKey.PublicKey)
+
+	nonce := uint64(0)
+	submit := func(provenanceHash string) *models.VoteVerificationResult {
+		nonce++
+		signature, err := auth.SignVote(voterKey, domain, &auth.Vote{Voter: voter, ToolID: big.NewInt(42), Score: 1, Nonce: nonce})
+		require.NoError(t, err)
+
+		result, err := service.SubmitVote(ctx, &models.VoteSubmission{
+			ToolID:         "42",
+			VoterAddress:   voter.Hex(),
+			Score:          1,
+			Nonce:          nonce,
+			Signature:      hex.EncodeToString(signature),
+			ProvenanceHash: provenanceHash,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	// The old usage key and a cached license no longer make a voter eligible
+	kvStore.Set(ctx, "usage:"+voter.Hex()+":42", int64(100), time.Hour)
+	kvStore.Set(ctx, "license:"+voter.Hex()+":42", &models.License{ExpiresAt: time.Now().Add(time.Hour)}, time.Hour)
+	result := submit("")
+	assert.False(t, result.Valid)
+	assert.Equal(t, RuleMinCalls, result.Rule)
+	assert.Contains(t, result.Reason, "0 verified calls")
+
+	ledger := NewUsageLedger(kvStore)
+	require.NoError(t, ledger.RecordCall(ctx, voter, "42", "receipt-1"))
+	require.NoError(t, ledger.RecordCall(ctx, voter, "42", ""))
+	result = submit("")
+	assert.False(t, result.Valid)
+	assert.Equal(t, RuleMinUsageAge, result.Rule)
+
+	// First used the tool two hours ago
+	kvStore.Set(ctx, "verified:first:"+usageKey(voter, "42"), time.Now().Add(-2*time.Hour), time.Hour)
+	result = submit("")
+	require.True(t, result.Valid, result.Reason)
+
+	// Replacing the active vote amends it, and is allowed at any time
+	result = submit("")
+	require.True(t, result.Valid, result.Reason)
+
+	// Once retracted, a new vote waits for the period
+	nonce++
+	signature, err := auth.SignRetraction(voterKey, domain, &auth.Retraction{Voter: voter, ToolID: big.NewInt(42), Nonce: nonce})
+	require.NoError(t, err)
+	retracted, err := service.RetractVote(ctx, &models.VoteRetraction{
+		ToolID:       "42",
+		VoterAddress: voter.Hex(),
+		Nonce:        nonce,
+		Signature:    hex.EncodeToString(signature),
+	})
+	require.NoError(t, err)
+	require.True(t, retracted.Valid, retracted.Reason)
+
+	result = submit("")
+	assert.False(t, result.Valid)
+	assert.Equal(t, RuleVotePeriod, result.Rule)
+
+	t.Run("ProvenanceReceipt", func(t *testing.T) {
+		service.UseEligibilityPolicy(NewEligibilityPolicy(ProvenanceReceiptRule{}))
+
+		assert.Equal(t, RuleProvenanceReceipt, submit("").Rule)
+		assert.Equal(t, RuleProvenanceReceipt, submit("unknown").Rule)
+		assert.True(t, submit("RECEIPT-1").Valid)
+	})
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+)
+
+// Bucket sizes of reputation history
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// Most buckets a history query returns
+const maxHistoryBuckets = 1000
+
+var (
+	// ErrInvalidBucket is returned for a bucket size other than hour, day
+	// or week.
+	ErrInvalidBucket = errors.New("bucket must be hour, day or week")
+
+	// ErrInvalidHistoryRange is returned for an empty range, or one of more
+	// than maxHistoryBuckets buckets.
+	ErrInvalidHistoryRange = errors.New("invalid history range")
+)
+
+// How long the buckets of each size are kept. Hourly history is for recent
+// activity only.
+var historyTTLs = map[string]time.Duration{
+	BucketHour: 90 * 24 * time.Hour,
+	BucketDay:  reputationLedgerTTL,
+	BucketWeek: reputationLedgerTTL,
+}
+
+// ReputationHistory keeps time series of what happened to each tool's
+// reputation, in hourly, daily and weekly UTC buckets. Weeks start on
+// Monday. Counters are updated as votes are cast or withdrawn and batches
+// processed, so queries only read them.
+type ReputationHistory struct {
+	store kvstore.Store
+}
+
+// NewReputationHistory creates a reputation history backed by store.
+func NewReputationHistory(store kvstore.Store) *ReputationHistory {
+	return &ReputationHistory{
+		store: store,
+	}
+}
+
+// RecordVote counts a vote cast on its tool.
+func (h *ReputationHistory) RecordVote(ctx context.Context, vote *models.Vote) {
+	weight := int64(vote.EffectiveWeight())
+	h.add(ctx, vote.ToolID, vote.CreatedAt, map[string]int64{
+		"votes":  1,
+		"score":  int64(vote.Score) * weight,
+		"raw":    int64(vote.Score),
+		"weight": weight,
+	})
+}
+
+// RecordWithdrawal counts a vote of toolID superseded or retracted at.
+func (h *ReputationHistory) RecordWithdrawal(ctx context.Context, toolID string, score int8, weight uint64, at time.Time) {
+	h.add(ctx, toolID, at, map[string]int64{
+		"withdrawn":       1,
+		"withdrawn_score": int64(score) * int64(weight),
+	})
+}
+
+// RecordBatch counts a processed batch.
+func (h *ReputationHistory) RecordBatch(ctx context.Context, batch *models.VoteBatch) {
+	h.add(ctx, batch.ToolID, batch.CreatedAt, map[string]int64{
+		"batches":   1,
+		"committed": int64(batch.VotesCount),
+	})
+}
+
+// Series returns the buckets of toolID that overlap [from, to), oldest
+// first. Buckets without activity are included with zero counts.
+func (h *ReputationHistory) Series(ctx context.Context, toolID, bucket string, from, to time.Time) ([]models.ReputationBucket, error) {
+	if _, ok := historyTTLs[bucket]; !ok {
+		return nil, ErrInvalidBucket
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidHistoryRange)
+	}
+
+	var series []models.ReputationBucket
+	for start := bucketStart(bucket, from); start.Before(to); start = nextBucket(bucket, start) {
+		if len(series) == maxHistoryBuckets {
+			return nil, fmt.Errorf("%w: more than %d buckets", ErrInvalidHistoryRange, maxHistoryBuckets)
+		}
+
+		key := historyKey(toolID, bucket, start)
+		point := models.ReputationBucket{
+			Start:          start,
+			Votes:          h.counter(ctx, "votes", key),
+			Withdrawn:      h.counter(ctx, "withdrawn", key),
+			ScoreSum:       h.counter(ctx, "score", key),
+			RawScoreSum:    h.counter(ctx, "raw", key),
+			WeightSum:      h.counter(ctx, "weight", key),
+			Batches:        h.counter(ctx, "batches", key),
+			CommittedVotes: h.counter(ctx, "committed", key),
+		}
+		if point.WeightSum > 0 {
+			point.AverageScore = float64(point.ScoreSum) / float64(point.WeightSum)
+		}
+		point.NetScore = point.ScoreSum - h.counter(ctx, "withdrawn_score", key)
+		series = append(series, point)
+	}
+	return series, nil
+}
+
+// add adds counts to the buckets of every size that at falls in.
+func (h *ReputationHistory) add(ctx context.Context, toolID string, at time.Time, counts map[string]int64) {
+	for bucket, ttl := range historyTTLs {
+		key := historyKey(toolID, bucket, bucketStart(bucket, at))
+		for counter, value := range counts {
+			if value == 0 {
+				continue
+			}
+			if _, err := h.store.Increment(ctx, "history:"+counter+":"+key, value, ttl); err != nil {
+				log.Printf("Warning: failed to record %s history of tool %s: %v", counter, toolID, err)
+			}
+		}
+	}
+}
+
+func (h *ReputationHistory) counter(ctx context.Context, counter, key string) int64 {
+	cached, found := h.store.Get(ctx, "history:"+counter+":"+key)
+	if !found {
+		return 0
+	}
+	value, _ := cached.(int64)
+	return value
+}
+
+// bucketStart returns the start of the bucket t falls in.
+func bucketStart(bucket string, t time.Time) time.Time {
+	t = t.UTC()
+	switch bucket {
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketWeek:
+		day := t.Truncate(24 * time.Hour)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return t.Truncate(24 * time.Hour)
+	}
+}
+
+func nextBucket(bucket string, start time.Time) time.Time {
+	switch bucket {
+	case BucketHour:
+		return start.Add(time.Hour)
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func historyKey(toolID, bucket string, start time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", toolID, bucket, start.Unix())
+}
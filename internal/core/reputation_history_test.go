@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"moltket/internal/cache"
+	"moltket/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputationHistory(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	history := NewReputationHistory(kvStore)
+
+	// Wednesday 2026-03-04 10:30 UTC
+	wednesday := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC), bucketStart(BucketHour, wednesday))
+	assert.Equal(t, time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC), bucketStart(BucketDay, wednesday))
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), bucketStart(BucketWeek, wednesday))
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), bucketStart(BucketWeek, time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC)))
+
+	history.RecordVote(ctx, &models.Vote{ToolID: "5", Score: 1, Weight: 3, CreatedAt: wednesday})
+	history.RecordVote(ctx, &models.Vote{ToolID: "5", Score: -1, CreatedAt: wednesday.Add(time.Hour)})
+	history.RecordWithdrawal(ctx, "5", 1, 3, wednesday.Add(24*time.Hour))
+	history.RecordBatch(ctx, &models.VoteBatch{ToolID: "5", VotesCount: 2, CreatedAt: wednesday.Add(2 * time.Hour)})
+	history.RecordVote(ctx, &models.Vote{ToolID: "6", Score: 1, CreatedAt: wednesday})
+
+	t.Run("Daily", func(t *testing.T) {
+		series, err := history.Series(ctx, "5", BucketDay, wednesday.Add(-24*time.Hour), wednesday.Add(48*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, series, 4)
+
+		assert.Zero(t, series[0].Votes)
+		day := series[1]
+		assert.Equal(t, bucketStart(BucketDay, wednesday), day.Start)
+		assert.Equal(t, int64(2), day.Votes)
+		assert.Equal(t, int64(2), day.ScoreSum)
+		assert.Equal(t, int64(0), day.RawScoreSum)
+		assert.Equal(t, int64(4), day.WeightSum)
+		assert.Equal(t, 0.5, day.AverageScore)
+		assert.Equal(t, int64(2), day.NetScore)
+		assert.Equal(t, int64(1), day.Batches)
+		assert.Equal(t, int64(2), day.CommittedVotes)
+
+		next := series[2]
+		assert.Equal(t, int64(1), next.Withdrawn)
+		assert.Equal(t, int64(-3), next.NetScore)
+		assert.Zero(t, next.AverageScore)
+	})
+
+	t.Run("HourlyAndWeekly", func(t *testing.T) {
+		series, err := history.Series(ctx, "5", BucketHour, wednesday, wednesday.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, series, 3)
+		assert.Equal(t, []int64{1, 1, 0}, []int64{series[0].Votes, series[1].Votes, series[2].Votes})
+		assert.Equal(t, int64(1), series[2].Batches)
+
+		series, err = history.Series(ctx, "5", BucketWeek, wednesday, wednesday.Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		assert.Equal(t, int64(2), series[0].Votes)
+		assert.Equal(t, int64(1), series[0].Withdrawn)
+		assert.Equal(t, int64(-1), series[0].NetScore)
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		_, err := history.Series(ctx, "5", "month", wednesday, wednesday.Add(time.Hour))
+		assert.ErrorIs(t, err, ErrInvalidBucket)
+		_, err = history.Series(ctx, "5", BucketDay, wednesday, wednesday)
+		assert.ErrorIs(t, err, ErrInvalidHistoryRange)
+		_, err = history.Series(ctx, "5", BucketHour, wednesday, wednesday.AddDate(1, 0, 0))
+		assert.ErrorIs(t, err, ErrInvalidHistoryRange)
+	})
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+)
+
+// Reputation ledger entries are set not to expire; once a batch is gone they
+// are the only record of its votes
+const reputationLedgerTTL = 100 * 365 * 24 * time.Hour
+
+// ErrLedgerCorrupt is returned when a tool's checkpoints do not add up to
+// the totals they record.
+var ErrLedgerCorrupt = errors.New("reputation ledger corrupt")
+
+// ReputationLedger keeps the cumulative stats of each tool's committed
+// votes, and a checkpoint per batch they were committed in.
+//
+// The in-memory store loses the ledger on restart. With a journal (see
+// UseJournal) every checkpoint is also written to disk before it is
+// committed, and replayed into the store at startup.
+type ReputationLedger struct {
+	store   kvstore.Store
+	journal *kvstore.Journal
+}
+
+// NewReputationLedger creates a reputation ledger backed by store.
+func NewReputationLedger(store kvstore.Store) *ReputationLedger {
+	return &ReputationLedger{
+		store: store,
+	}
+}
+
+// UseJournal restores the checkpoints recorded in journal, checks that each
+// tool's add up, and records every later checkpoint there.
+func (l *ReputationLedger) UseJournal(ctx context.Context, journal *kvstore.Journal) error {
+	var toolIDs []string
+	seen := make(map[string]bool)
+	err := journal.Replay(func(record json.RawMessage) error {
+		var checkpoint models.ReputationCheckpoint
+		if err := json.Unmarshal(record, &checkpoint); err != nil {
+			return fmt.Errorf("%w: undecodable journal record: %v", ErrLedgerCorrupt, err)
+		}
+		if err := l.store.Set(ctx, checkpointKey(checkpoint.ToolID, checkpoint.Sequence), &checkpoint, reputationLedgerTTL); err != nil {
+			return fmt.Errorf("failed to restore reputation checkpoint: %w", err)
+		}
+		if !seen[checkpoint.ToolID] {
+			seen[checkpoint.ToolID] = true
+			toolIDs = append(toolIDs, checkpoint.ToolID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, toolID := range toolIDs {
+		if _, err := l.Replay(ctx, toolID); err != nil {
+			return err
+		}
+	}
+	l.journal = journal
+	return nil
+}
+
+// Commit adds a batch, made of votes and the batch's supersessions, to the
+// stats of its tool and stores its checkpoint. Callers serialize commits
+// for a tool.
+func (l *ReputationLedger) Commit(ctx context.Context, batch *models.VoteBatch, votes []*models.Vote) (*models.ReputationCheckpoint, error) {
+	stats, err := l.Stats(ctx, batch.ToolID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &models.ReputationCheckpoint{
+		ToolID:        batch.ToolID,
+		Sequence:      stats.Checkpoints + 1,
+		BatchID:       batch.ID,
+		MerkleRoot:    batch.MerkleRoot,
+		Supersessions: batch.Supersessions,
+		CreatedAt:     batch.CreatedAt,
+	}
+	for _, vote := range votes {
+		weight := vote.EffectiveWeight()
+		addToTotals(&checkpoint.Delta, vote.Score, weight, 1)
+		switch {
+		case vote.Score > 0:
+			checkpoint.UpWeight += int64(weight)
+		case vote.Score < 0:
+			checkpoint.DownWeight += int64(weight)
+		default:
+			checkpoint.NeutralWeight += int64(weight)
+		}
+	}
+	for _, supersession := range batch.Supersessions {
+		addToTotals(&checkpoint.Delta, supersession.Score, supersession.Weight, -1)
+	}
+	checkpoint.Totals = sumTotals(stats.Totals, checkpoint.Delta)
+
+	if l.journal != nil {
+		if err := l.journal.Append(checkpoint); err != nil {
+			return nil, fmt.Errorf("failed to record reputation checkpoint: %w", err)
+		}
+	}
+	if err := l.store.Set(ctx, checkpointKey(batch.ToolID, checkpoint.Sequence), checkpoint, reputationLedgerTTL); err != nil {
+		return nil, fmt.Errorf("failed to store reputation checkpoint: %w", err)
+	}
+
+	stats.Totals = checkpoint.Totals
+	stats.Checkpoints = checkpoint.Sequence
+	stats.LastBatchID = batch.ID
+	stats.LastBatchAt = batch.CreatedAt
+	stats.UpdatedAt = time.Now()
+	if err := l.store.Set(ctx, "ledger:reputation:"+batch.ToolID, stats, reputationLedgerTTL); err != nil {
+		return nil, fmt.Errorf("failed to store reputation stats: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// Stats returns the cumulative stats of toolID, replaying its checkpoints
+// if the stats themselves were lost.
+func (l *ReputationLedger) Stats(ctx context.Context, toolID string) (*models.ReputationStats, error) {
+	if cached, found := l.store.Get(ctx, "ledger:reputation:"+toolID); found {
+		if stats, ok := cached.(*models.ReputationStats); ok {
+			copied := *stats
+			return &copied, nil
+		}
+	}
+	return l.Replay(ctx, toolID)
+}
+
+// Checkpoints returns the checkpoints of toolID in batch order.
+func (l *ReputationLedger) Checkpoints(ctx context.Context, toolID string) ([]*models.ReputationCheckpoint, error) {
+	stats, err := l.Stats(ctx, toolID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := make([]*models.ReputationCheckpoint, 0, stats.Checkpoints)
+	for sequence := int64(1); sequence <= stats.Checkpoints; sequence++ {
+		checkpoint := l.checkpoint(ctx, toolID, sequence)
+		if checkpoint == nil {
+			return nil, fmt.Errorf("%w: checkpoint %d of tool %s missing", ErrLedgerCorrupt, sequence, toolID)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+// Replay rebuilds the stats of toolID from its checkpoints and stores
+// them. It fails if a checkpoint's totals do not match the sum of the
+// deltas before it.
+func (l *ReputationLedger) Replay(ctx context.Context, toolID string) (*models.ReputationStats, error) {
+	stats := &models.ReputationStats{
+		ToolID: toolID,
+	}
+	for sequence := int64(1); ; sequence++ {
+		checkpoint := l.checkpoint(ctx, toolID, sequence)
+		if checkpoint == nil {
+			break
+		}
+
+		stats.Totals = sumTotals(stats.Totals, checkpoint.Delta)
+		if stats.Totals != checkpoint.Totals {
+			return nil, fmt.Errorf("%w: checkpoint %d of tool %s records totals %+v, its deltas add up to %+v",
+				ErrLedgerCorrupt, sequence, toolID, checkpoint.Totals, stats.Totals)
+		}
+		stats.Checkpoints = sequence
+		stats.LastBatchID = checkpoint.BatchID
+		stats.LastBatchAt = checkpoint.CreatedAt
+	}
+	if stats.Checkpoints == 0 {
+		return stats, nil
+	}
+
+	stats.UpdatedAt = time.Now()
+	if err := l.store.Set(ctx, "ledger:reputation:"+toolID, stats, reputationLedgerTTL); err != nil {
+		return nil, fmt.Errorf("failed to store reputation stats: %w", err)
+	}
+	copied := *stats
+	return &copied, nil
+}
+
+func (l *ReputationLedger) checkpoint(ctx context.Context, toolID string, sequence int64) *models.ReputationCheckpoint {
+	cached, found := l.store.Get(ctx, checkpointKey(toolID, sequence))
+	if !found {
+		return nil
+	}
+	checkpoint, _ := cached.(*models.ReputationCheckpoint)
+	return checkpoint
+}
+
+// replayVotes returns votes that score like the committed votes of
+// checkpoints, less those superseded in checkpoints and supersessions.
+// Scorers only see the weights of votes by score and time, so the votes of
+// a batch are replayed as one vote per score, at the time of the batch.
+func replayVotes(checkpoints []*models.ReputationCheckpoint, supersessions []*models.VoteSupersession) []*models.Vote {
+	type weights struct {
+		up, down, neutral int64
+		at                time.Time
+	}
+	byBatch := make(map[string]*weights, len(checkpoints))
+	ordered := make([]*weights, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		w := &weights{checkpoint.UpWeight, checkpoint.DownWeight, checkpoint.NeutralWeight, checkpoint.CreatedAt}
+		byBatch[checkpoint.BatchID] = w
+		ordered = append(ordered, w)
+	}
+
+	remove := func(supersession *models.VoteSupersession) {
+		// Votes committed before the ledger are not in it
+		w, ok := byBatch[supersession.BatchID]
+		if !ok {
+			return
+		}
+		switch {
+		case supersession.Score > 0:
+			w.up -= int64(supersession.Weight)
+		case supersession.Score < 0:
+			w.down -= int64(supersession.Weight)
+		default:
+			w.neutral -= int64(supersession.Weight)
+		}
+	}
+	for _, checkpoint := range checkpoints {
+		for _, supersession := range checkpoint.Supersessions {
+			remove(supersession)
+		}
+	}
+	for _, supersession := range supersessions {
+		remove(supersession)
+	}
+
+	var votes []*models.Vote
+	for _, w := range ordered {
+		for score, weight := range [3]int64{w.down, w.neutral, w.up} {
+			if weight > 0 {
+				votes = append(votes, &models.Vote{Score: int8(score - 1), Weight: uint64(weight), CreatedAt: w.at})
+			}
+		}
+	}
+	return votes
+}
+
+// addToTotals adds a vote to totals, or removes it if sign is -1.
+func addToTotals(totals *models.ReputationTotals, score int8, weight uint64, sign int64) {
+	totals.Votes += sign
+	totals.TotalScore += sign * int64(score) * int64(weight)
+	totals.RawScore += sign * int64(score)
+	totals.TotalWeight += sign * int64(weight)
+}
+
+func sumTotals(a, b models.ReputationTotals) models.ReputationTotals {
+	return models.ReputationTotals{
+		Votes:       a.Votes + b.Votes,
+		TotalScore:  a.TotalScore + b.TotalScore,
+		RawScore:    a.RawScore + b.RawScore,
+		TotalWeight: a.TotalWeight + b.TotalWeight,
+	}
+}
+
+func checkpointKey(toolID string, sequence int64) string {
+	return fmt.Sprintf("ledger:checkpoint:%s:%d", toolID, sequence)
+}
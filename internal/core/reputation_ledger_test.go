@@ -0,0 +1,271 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputationLedger(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	ledger := NewReputationLedger(kvStore)
+	now := time.Now()
+
+	stats, err := ledger.Stats(ctx, "7")
+	require.NoError(t, err)
+	assert.Zero(t, stats.Checkpoints)
+
+	first := &models.VoteBatch{ID: "batch_7_1", ToolID: "7", MerkleRoot: "0x01", CreatedAt: now.Add(-time.Hour)}
+	checkpoint, err := ledger.Commit(ctx, first, []*models.Vote{
+		{Score: 1, Weight: 3},
+		{Score: 1},
+		{Score: -1, Weight: 2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), checkpoint.Sequence)
+	assert.Equal(t, models.ReputationTotals{Votes: 3, TotalScore: 2, RawScore: 1, TotalWeight: 6}, checkpoint.Totals)
+	assert.Equal(t, int64(4), checkpoint.UpWeight)
+	assert.Equal(t, int64(2), checkpoint.DownWeight)
+
+	// The second batch replaces the downvote with an upvote
+	second := &models.VoteBatch{ID: "batch_7_2", ToolID: "7", MerkleRoot: "0x02", CreatedAt: now,
+		Supersessions: []*models.VoteSupersession{{BatchID: first.ID, Score: -1, Weight: 2, SupersededBy: "vote"}},
+	}
+	checkpoint, err = ledger.Commit(ctx, second, []*models.Vote{{Score: 1, Weight: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), checkpoint.Sequence)
+	assert.Equal(t, models.ReputationTotals{Votes: 0, TotalScore: 4, RawScore: 2, TotalWeight: 0}, checkpoint.Delta)
+	assert.Equal(t, models.ReputationTotals{Votes: 3, TotalScore: 6, RawScore: 3, TotalWeight: 6}, checkpoint.Totals)
+
+	stats, err = ledger.Stats(ctx, "7")
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint.Totals, stats.Totals)
+	assert.Equal(t, int64(2), stats.Checkpoints)
+	assert.Equal(t, second.ID, stats.LastBatchID)
+
+	t.Run("Replay", func(t *testing.T) {
+		require.NoError(t, kvStore.Delete(ctx, "ledger:reputation:7"))
+
+		replayed, err := ledger.Stats(ctx, "7")
+		require.NoError(t, err)
+		assert.Equal(t, stats.Totals, replayed.Totals)
+		assert.Equal(t, stats.Checkpoints, replayed.Checkpoints)
+		assert.Equal(t, stats.LastBatchID, replayed.LastBatchID)
+
+		checkpoints, err := ledger.Checkpoints(ctx, "7")
+		require.NoError(t, err)
+		require.Len(t, checkpoints, 2)
+		assert.Equal(t, []string{first.ID, second.ID}, []string{checkpoints[0].BatchID, checkpoints[1].BatchID})
+	})
+
+	t.Run("ReplayVotes", func(t *testing.T) {
+		checkpoints, err := ledger.Checkpoints(ctx, "7")
+		require.NoError(t, err)
+
+		// The committed votes that still count, as the scorer saw them
+		committed := []*models.Vote{
+			{Score: 1, Weight: 3, CreatedAt: first.CreatedAt},
+			{Score: 1, CreatedAt: first.CreatedAt},
+			{Score: 1, Weight: 2, CreatedAt: second.CreatedAt},
+		}
+		scorer := NewBayesianScorer(0, 5, 24*time.Hour)
+		average, recent := scorer.Score(committed, now)
+		replayedAverage, replayedRecent := scorer.Score(replayVotes(checkpoints, nil), now)
+		assert.InDelta(t, average, replayedAverage, 1e-9)
+		assert.InDelta(t, recent, replayedRecent, 1e-9)
+
+		// Pending supersessions take votes out before they are committed
+		retracted := replayVotes(checkpoints, []*models.VoteSupersession{{BatchID: second.ID, Score: 1, Weight: 2}})
+		average, recent = scorer.Score(committed[:2], now)
+		replayedAverage, replayedRecent = scorer.Score(retracted, now)
+		assert.InDelta(t, average, replayedAverage, 1e-9)
+		assert.InDelta(t, recent, replayedRecent, 1e-9)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		cached, found := kvStore.Get(ctx, checkpointKey("7", 2))
+		require.True(t, found)
+		tampered := *cached.(*models.ReputationCheckpoint)
+		tampered.Totals.TotalScore = 100
+		kvStore.Set(ctx, checkpointKey("7", 2), &tampered, time.Hour)
+
+		_, err := ledger.Replay(ctx, "7")
+		assert.ErrorIs(t, err, ErrLedgerCorrupt)
+	})
+}
+
+func TestReputationLedger_Journal(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	now := time.Now()
+
+	// restart opens the journal over a fresh store, as the server does on
+	// startup
+	restart := func(t *testing.T) (*ReputationLedger, *kvstore.Journal, error) {
+		t.Helper()
+		kvStore := cache.NewKVStore()
+		t.Cleanup(func() { kvStore.Close() })
+		journal, err := kvstore.OpenJournal(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { journal.Close() })
+		ledger := NewReputationLedger(kvStore)
+		return ledger, journal, ledger.UseJournal(ctx, journal)
+	}
+
+	ledger, journal, err := restart(t)
+	require.NoError(t, err)
+	_, err = ledger.Commit(ctx, &models.VoteBatch{ID: "batch_7_1", ToolID: "7", CreatedAt: now}, []*models.Vote{{Score: 1, Weight: 3}})
+	require.NoError(t, err)
+	_, err = ledger.Commit(ctx, &models.VoteBatch{ID: "batch_8_1", ToolID: "8", CreatedAt: now}, []*models.Vote{{Score: -1}})
+	require.NoError(t, err)
+	_, err = ledger.Commit(ctx, &models.VoteBatch{ID: "batch_7_2", ToolID: "7", CreatedAt: now}, []*models.Vote{{Score: 1}})
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	t.Run("SurvivesRestart", func(t *testing.T) {
+		restored, _, err := restart(t)
+		require.NoError(t, err)
+
+		stats, err := restored.Stats(ctx, "7")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stats.Checkpoints)
+		assert.Equal(t, models.ReputationTotals{Votes: 2, TotalScore: 4, RawScore: 2, TotalWeight: 4}, stats.Totals)
+		assert.Equal(t, "batch_7_2", stats.LastBatchID)
+		stats, err = restored.Stats(ctx, "8")
+		require.NoError(t, err)
+		assert.Equal(t, int64(-1), stats.Totals.TotalScore)
+	})
+
+	t.Run("DropsTornRecord", func(t *testing.T) {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"tool_id":"7","sequence":3,`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		restored, journal, err := restart(t)
+		require.NoError(t, err)
+		_, err = restored.Commit(ctx, &models.VoteBatch{ID: "batch_7_3", ToolID: "7", CreatedAt: now}, []*models.Vote{{Score: 1}})
+		require.NoError(t, err)
+		require.NoError(t, journal.Close())
+
+		restored, _, err = restart(t)
+		require.NoError(t, err)
+		stats, err := restored.Stats(ctx, "7")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stats.Checkpoints)
+		assert.Equal(t, "batch_7_3", stats.LastBatchID)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		tampered := strings.Replace(string(data), `"total_score":4`, `"total_score":40`, 1)
+		require.NotEqual(t, string(data), tampered)
+		require.NoError(t, os.WriteFile(path, []byte(tampered), 0o600))
+
+		_, _, err = restart(t)
+		assert.ErrorIs(t, err, ErrLedgerCorrupt)
+	})
+}
+
+func TestCumulativeReputation(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	cfg := &config.Config{
+		ChainID:                 31337,
+		ReputationOracleAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+	}
+	service := NewVoteService(cfg, kvStore, nil)
+	service.UseScorer(NewMeanScorer(0))
+	domain := auth.VoteDomain(big.NewInt(cfg.ChainID), common.HexToAddress(cfg.ReputationOracleAddress))
+
+	vote := func(score int8, nonce uint64) common.Address {
+		voterKey, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		voter := crypto.PubkeyToAddress(voterKey.PublicKey)
+		signature, err := auth.SignVote(voterKey, domain, &auth.Vote{Voter: voter, ToolID: big.NewInt(9), Score: score, Nonce: nonce})
+		require.NoError(t, err)
+
+		result, err := service.SubmitVote(ctx, &models.VoteSubmission{
+			ToolID:       "9",
+			VoterAddress: voter.Hex(),
+			Score:        score,
+			Nonce:        nonce,
+			Signature:    hex.EncodeToString(signature),
+		})
+		require.NoError(t, err)
+		require.True(t, result.Valid, result.Reason)
+		return voter
+	}
+	reputation := func() *models.ToolReputation {
+		// As once the cached reputation expired
+		kvStore.Delete(ctx, "reputation:9")
+		reputation, err := service.GetToolReputation(ctx, "9")
+		require.NoError(t, err)
+		return reputation
+	}
+
+	vote(1, 1)
+	vote(1, 1)
+	_, err := service.ProcessBatch(ctx, "9")
+	require.NoError(t, err)
+
+	// Committed votes are no longer forgotten
+	committed := reputation()
+	assert.Equal(t, int64(2), committed.TotalVotes)
+	assert.Equal(t, int64(2), committed.TotalScore)
+	assert.Equal(t, 1.0, committed.AverageScore)
+	assert.False(t, committed.LastBatchAt.IsZero())
+
+	// Pending votes count on top of them
+	vote(-1, 1)
+	pending := reputation()
+	assert.Equal(t, int64(3), pending.TotalVotes)
+	assert.Equal(t, int64(1), pending.TotalScore)
+	assert.InDelta(t, 1.0/3, pending.AverageScore, 1e-9)
+
+	batch, err := service.ProcessBatch(ctx, "9")
+	require.NoError(t, err)
+	stats, err := service.reputation.Stats(ctx, "9")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Checkpoints)
+	assert.Equal(t, batch.ID, stats.LastBatchID)
+	assert.Equal(t, pending.TotalScore, reputation().TotalScore)
+
+	// Votes and batches are counted in the history as they are processed
+	series, err := service.ReputationHistory(ctx, "9", BucketHour, time.Now().Add(-time.Hour), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	var total models.ReputationBucket
+	for _, point := range series {
+		total.Votes += point.Votes
+		total.ScoreSum += point.ScoreSum
+		total.Batches += point.Batches
+		total.CommittedVotes += point.CommittedVotes
+	}
+	assert.Equal(t, int64(3), total.Votes)
+	assert.Equal(t, int64(1), total.ScoreSum)
+	assert.Equal(t, int64(2), total.Batches)
+	assert.Equal(t, int64(3), total.CommittedVotes)
+}
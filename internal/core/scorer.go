@@ -0,0 +1,206 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/models"
+)
+
+// Built-in scorers, selected with REPUTATION_SCORER
+const (
+	ScorerMean     = "mean"
+	ScorerBayesian = "bayesian"
+	ScorerWilson   = "wilson"
+)
+
+// ErrInvalidScorer is returned for an unknown scorer or invalid parameters.
+var ErrInvalidScorer = errors.New("invalid reputation scorer")
+
+// Scorer turns a tool's votes into reputation scores in [-1, 1].
+type Scorer interface {
+	// Name identifies the algorithm in reputation responses.
+	Name() string
+
+	// Params returns the parameters the scorer was configured with.
+	Params() map[string]float64
+
+	// Score returns the all-time average of votes and the recent score, in
+	// which older votes count less, as of now.
+	Score(votes []*models.Vote, now time.Time) (average, recent float64)
+}
+
+// NewScorer returns the scorer selected by cfg.
+func NewScorer(cfg *config.Config) (Scorer, error) {
+	if cfg.ReputationHalfLife < 0 {
+		return nil, fmt.Errorf("%w: negative half-life %s", ErrInvalidScorer, cfg.ReputationHalfLife)
+	}
+
+	switch cfg.ReputationScorer {
+	case ScorerMean, "":
+		return NewMeanScorer(cfg.ReputationHalfLife), nil
+	case ScorerBayesian:
+		if cfg.ReputationPriorMean < -1 || cfg.ReputationPriorMean > 1 || cfg.ReputationPriorWeight < 0 {
+			return nil, fmt.Errorf("%w: prior mean must be within [-1, 1] and prior weight not negative", ErrInvalidScorer)
+		}
+		return NewBayesianScorer(cfg.ReputationPriorMean, cfg.ReputationPriorWeight, cfg.ReputationHalfLife), nil
+	case ScorerWilson:
+		if cfg.ReputationWilsonZ <= 0 {
+			return nil, fmt.Errorf("%w: Wilson z must be positive", ErrInvalidScorer)
+		}
+		return NewWilsonScorer(cfg.ReputationWilsonZ, cfg.ReputationHalfLife), nil
+	}
+	return nil, fmt.Errorf("%w: unknown scorer %q", ErrInvalidScorer, cfg.ReputationScorer)
+}
+
+// tally sums votes, each counted with a weight.
+type tally struct {
+	count, sum, up, down float64
+}
+
+func (t *tally) add(score int8, weight float64) {
+	t.count += weight
+	t.sum += float64(score) * weight
+	switch {
+	case score > 0:
+		t.up += weight
+	case score < 0:
+		t.down += weight
+	}
+}
+
+// decayedScorer computes the average from a tally of all votes by weight,
+// and the recent score from a tally in which a vote's weight halves every
+// halfLife. A zero halfLife makes both the same.
+type decayedScorer struct {
+	halfLife time.Duration
+	estimate func(tally) float64
+}
+
+func (s decayedScorer) Score(votes []*models.Vote, now time.Time) (average, recent float64) {
+	var all, decayed tally
+	for _, vote := range votes {
+		weight := float64(vote.EffectiveWeight())
+		all.add(vote.Score, weight)
+		decayed.add(vote.Score, weight*s.decay(now.Sub(vote.CreatedAt)))
+	}
+	return s.estimate(all), s.estimate(decayed)
+}
+
+func (s decayedScorer) decay(age time.Duration) float64 {
+	if s.halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Exp2(-float64(age) / float64(s.halfLife))
+}
+
+func (s decayedScorer) params() map[string]float64 {
+	return map[string]float64{
+		"half_life_hours": s.halfLife.Hours(),
+	}
+}
+
+// MeanScorer averages vote scores. A single upvote beats any mix with a
+// downvote in it.
+type MeanScorer struct {
+	decayedScorer
+}
+
+// NewMeanScorer creates a mean scorer whose recent score decays votes with
+// halfLife.
+func NewMeanScorer(halfLife time.Duration) *MeanScorer {
+	return &MeanScorer{decayedScorer{
+		halfLife: halfLife,
+		estimate: func(t tally) float64 {
+			if t.count == 0 {
+				return 0
+			}
+			return t.sum / t.count
+		},
+	}}
+}
+
+func (s *MeanScorer) Name() string { return ScorerMean }
+
+func (s *MeanScorer) Params() map[string]float64 { return s.params() }
+
+// BayesianScorer averages vote scores together with priorWeight imaginary
+// votes of priorMean, so tools with few votes stay close to the prior.
+type BayesianScorer struct {
+	decayedScorer
+	priorMean, priorWeight float64
+}
+
+// NewBayesianScorer creates a Bayesian scorer whose recent score decays
+// votes with halfLife.
+func NewBayesianScorer(priorMean, priorWeight float64, halfLife time.Duration) *BayesianScorer {
+	return &BayesianScorer{
+		decayedScorer: decayedScorer{
+			halfLife: halfLife,
+			estimate: func(t tally) float64 {
+				if t.count+priorWeight == 0 {
+					return priorMean
+				}
+				return (priorMean*priorWeight + t.sum) / (priorWeight + t.count)
+			},
+		},
+		priorMean:   priorMean,
+		priorWeight: priorWeight,
+	}
+}
+
+func (s *BayesianScorer) Name() string { return ScorerBayesian }
+
+func (s *BayesianScorer) Params() map[string]float64 {
+	params := s.params()
+	params["prior_mean"] = s.priorMean
+	params["prior_weight"] = s.priorWeight
+	return params
+}
+
+// WilsonScorer scores the lower bound of the Wilson confidence interval of
+// the share of upvotes, mapped to [-1, 1]. Neutral votes are ignored. It
+// answers "how good is the tool at least, most likely", so a tool without
+// votes scores -1.
+type WilsonScorer struct {
+	decayedScorer
+	z float64
+}
+
+// NewWilsonScorer creates a Wilson scorer for confidence z (1.96 for 95%)
+// whose recent score decays votes with halfLife.
+func NewWilsonScorer(z float64, halfLife time.Duration) *WilsonScorer {
+	return &WilsonScorer{
+		decayedScorer: decayedScorer{
+			halfLife: halfLife,
+			estimate: func(t tally) float64 {
+				return 2*wilsonLowerBound(t.up, t.up+t.down, z) - 1
+			},
+		},
+		z: z,
+	}
+}
+
+func (s *WilsonScorer) Name() string { return ScorerWilson }
+
+func (s *WilsonScorer) Params() map[string]float64 {
+	params := s.params()
+	params["z"] = s.z
+	return params
+}
+
+// wilsonLowerBound returns the lower bound of the Wilson score interval for
+// positive out of n trials.
+func wilsonLowerBound(positive, n, z float64) float64 {
+	if n == 0 {
+		return 0
+	}
+	p := positive / n
+	z2 := z * z
+	center := p + z2/(2*n)
+	margin := z * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	return math.Max(0, (center-margin)/(1+z2/n))
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scoredVotes(up, down int, at time.Time) []*models.Vote {
+	votes := make([]*models.Vote, 0, up+down)
+	for i := 0; i < up; i++ {
+		votes = append(votes, &models.Vote{Score: 1, CreatedAt: at})
+	}
+	for i := 0; i < down; i++ {
+		votes = append(votes, &models.Vote{Score: -1, CreatedAt: at})
+	}
+	return votes
+}
+
+func TestScorers(t *testing.T) {
+	now := time.Now()
+	popular := scoredVotes(999, 1, now)
+	single := scoredVotes(1, 0, now)
+
+	t.Run("Mean ranks a single upvote first", func(t *testing.T) {
+		scorer := NewMeanScorer(0)
+		popularScore, _ := scorer.Score(popular, now)
+		singleScore, _ := scorer.Score(single, now)
+		assert.Greater(t, singleScore, popularScore)
+	})
+
+	t.Run("Bayesian ranks many upvotes above one", func(t *testing.T) {
+		scorer := NewBayesianScorer(0, 5, 0)
+		popularScore, _ := scorer.Score(popular, now)
+		singleScore, _ := scorer.Score(single, now)
+		assert.Greater(t, popularScore, singleScore)
+		assert.InDelta(t, 1.0/6, singleScore, 1e-9)
+
+		// Without votes a tool scores the prior
+		empty, recent := NewBayesianScorer(0.5, 5, 0).Score(nil, now)
+		assert.Equal(t, 0.5, empty)
+		assert.Equal(t, 0.5, recent)
+	})
+
+	t.Run("Wilson ranks many upvotes above one", func(t *testing.T) {
+		scorer := NewWilsonScorer(1.96, 0)
+		popularScore, _ := scorer.Score(popular, now)
+		singleScore, _ := scorer.Score(single, now)
+		assert.Greater(t, popularScore, singleScore)
+		assert.InDelta(t, 0.99, popularScore, 0.01)
+
+		empty, _ := scorer.Score(nil, now)
+		assert.Equal(t, -1.0, empty)
+	})
+
+	t.Run("Recent score decays old votes", func(t *testing.T) {
+		week := 7 * 24 * time.Hour
+		votes := append(scoredVotes(0, 10, now.Add(-4*week)), scoredVotes(10, 0, now)...)
+
+		for _, scorer := range []Scorer{
+			NewMeanScorer(week),
+			NewBayesianScorer(0, 5, week),
+			NewWilsonScorer(1.96, week),
+		} {
+			average, recent := scorer.Score(votes, now)
+			assert.Greater(t, recent, average, scorer.Name())
+		}
+
+		// Without a half-life both are the same
+		average, recent := NewMeanScorer(0).Score(votes, now)
+		assert.Equal(t, average, recent)
+
+		// One half-life halves a vote's weight
+		votes = append(scoredVotes(0, 1, now.Add(-week)), scoredVotes(1, 0, now)...)
+		_, recent = NewMeanScorer(week).Score(votes, now)
+		assert.InDelta(t, 0.5/1.5, recent, 1e-9)
+	})
+
+	t.Run("Params", func(t *testing.T) {
+		scorer := NewBayesianScorer(0.2, 10, 24*time.Hour)
+		assert.Equal(t, ScorerBayesian, scorer.Name())
+		assert.Equal(t, map[string]float64{
+			"half_life_hours": 24,
+			"prior_mean":      0.2,
+			"prior_weight":    10,
+		}, scorer.Params())
+		assert.Equal(t, 1.96, NewWilsonScorer(1.96, 0).Params()["z"])
+	})
+}
+
+func TestNewScorer(t *testing.T) {
+	valid := func() *config.Config {
+		return &config.Config{
+			ReputationScorer:      ScorerBayesian,
+			ReputationPriorMean:   0,
+			ReputationPriorWeight: 5,
+			ReputationWilsonZ:     1.96,
+			ReputationHalfLife:    7 * 24 * time.Hour,
+		}
+	}
+
+	for _, name := range []string{ScorerMean, ScorerBayesian, ScorerWilson} {
+		cfg := valid()
+		cfg.ReputationScorer = name
+		scorer, err := NewScorer(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, name, scorer.Name())
+	}
+
+	invalid := map[string]func(*config.Config){
+		"unknown scorer":      func(cfg *config.Config) { cfg.ReputationScorer = "elo" },
+		"prior mean too high": func(cfg *config.Config) { cfg.ReputationPriorMean = 2 },
+		"negative prior":      func(cfg *config.Config) { cfg.ReputationPriorWeight = -1 },
+		"negative half-life":  func(cfg *config.Config) { cfg.ReputationHalfLife = -time.Hour },
+		"zero Wilson z-value": func(cfg *config.Config) {
+			cfg.ReputationScorer = ScorerWilson
+			cfg.ReputationWilsonZ = 0
+		},
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			cfg := valid()
+			mutate(cfg)
+			_, err := NewScorer(cfg)
+			assert.ErrorIs(t, err, ErrInvalidScorer)
+		})
+	}
+}
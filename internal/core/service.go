@@ -4,18 +4,20 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"moltket/config"
+	"moltket/internal/auth"
 	"moltket/internal/blockchain"
 	"moltket/internal/kvstore"
 	"moltket/internal/models"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 type VerificationResult struct {
@@ -26,12 +28,17 @@ type VerificationResult struct {
 	CallsRemaining int
 	Tier           string
 	ProvenanceHash string
+	Stale          bool // Served from the last good result while the chain is unreachable
 }
 
 type VerificationService struct {
 	config     *config.Config
 	store      kvstore.Store
 	blockchain blockchain.BlockchainInterface
+	signatures *auth.SignatureVerifier
+
+	// Serializes read-modify-write of delegation records and indexes
+	delegationMu sync.Mutex
 }
 
 func NewVerificationService(cfg *config.Config, store kvstore.Store, bc blockchain.BlockchainInterface) *VerificationService {
@@ -42,6 +49,13 @@ func NewVerificationService(cfg *config.Config, store kvstore.Store, bc blockcha
 	}
 }
 
+// UseSignatureVerifier lets contract wallets authenticate: signatures that do
+// not recover to the user are checked with EIP-1271. Without it only EOA
+// signatures are accepted.
+func (s *VerificationService) UseSignatureVerifier(verifier *auth.SignatureVerifier) {
+	s.signatures = verifier
+}
+
 func (s *VerificationService) VerifyLicense(licenseID, ToolID, UserAddress string) (*VerificationResult, error) {
 	ctx := context.Background()
 
@@ -62,6 +76,11 @@ func (s *VerificationService) VerifyLicense(licenseID, ToolID, UserAddress strin
 
 	// 2. Verify on-chain NFT ownership
 	isValid, err := s.blockchain.IsLicenseValid(userAddress, toolID)
+	if errors.Is(err, blockchain.ErrChainUnavailable) {
+		if stale, ok := s.lastGoodResult(ctx, cacheKey); ok {
+			return stale, nil
+		}
+	}
 	if err != nil {
 		return &VerificationResult{
 			Valid:     false,
@@ -119,12 +138,32 @@ func (s *VerificationService) VerifyLicense(licenseID, ToolID, UserAddress strin
 		ProvenanceHash: provenanceHash,
 	}
 
-	// 6. Cache successful verification (5 minutes TTL)
-	s.store.Set(ctx, cacheKey, result, time.Duration(s.config.CacheTTL)*time.Second)
+	// 6. Cache successful verification (5 minutes TTL), and keep a copy that
+	// can be served while the chain is unreachable
+	ttl := time.Duration(s.config.CacheTTL) * time.Second
+	s.store.Set(ctx, cacheKey, result, ttl)
+	s.store.Set(ctx, "stale:"+cacheKey, result, ttl+s.config.MaxLicenseStaleness)
 
 	return result, nil
 }
 
+// lastGoodResult returns the last successful verification for cacheKey,
+// marked stale.
+func (s *VerificationService) lastGoodResult(ctx context.Context, cacheKey string) (*VerificationResult, bool) {
+	cached, found := s.store.Get(ctx, "stale:"+cacheKey)
+	if !found {
+		return nil, false
+	}
+	result, ok := cached.(*VerificationResult)
+	if !ok {
+		return nil, false
+	}
+
+	stale := *result
+	stale.Stale = true
+	return &stale, true
+}
+
 func (s *VerificationService) generateProvenanceHash(licenseID, toolID, userAddress string) string {
 	timestamp := time.Now().UnixNano()
 	data := fmt.Sprintf("%s:%s:%s:%d", licenseID, toolID, userAddress, timestamp)
@@ -134,6 +173,11 @@ func (s *VerificationService) generateProvenanceHash(licenseID, toolID, userAddr
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
+// ValidateSignature checks a personal-sign "user:timestamp" signature less
+// than 5 minutes old.
+//
+// Deprecated: the signature can be replayed within its window. /verify
+// authenticates with AuthenticateRequest instead.
 func (s *VerificationService) ValidateSignature(userAddress string, timestamp int64, signature string) bool {
 	// Prevent replay attacks by checking timestamp. Use integer-second
 	// comparison to avoid microsecond rounding issues in tests.
@@ -160,22 +204,12 @@ func (s *VerificationService) ValidateSignature(userAddress string, timestamp in
 		sigBytes = b
 	}
 
-	if len(sigBytes) != 65 {
-		return false
-	}
-
-	// Convert v from 27/28 to 0/1 if necessary
-	if sigBytes[64] == 27 || sigBytes[64] == 28 {
-		sigBytes[64] -= 27
-	}
-
-	pubKey, err := crypto.SigToPub(hash, sigBytes)
-	if err != nil {
-		return false
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	recoveredAddr := crypto.PubkeyToAddress(*pubKey)
-	return recoveredAddr == common.HexToAddress(userAddress)
+	// EOA signatures are 65 bytes; contract wallets may use any format
+	valid, err := s.signatures.Verify(ctx, common.HexToAddress(userAddress), common.BytesToHash(hash), sigBytes)
+	return err == nil && valid
 }
 
 func (s *VerificationService) CheckRateLimit(key string) (bool, error) {
@@ -3,13 +3,17 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/big"
 	"testing"
 	"time"
 
 	"moltket/config"
 	"moltket/internal/blockchain"
+	"moltket/internal/cache"
 	"moltket/internal/testutils"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -75,6 +79,11 @@ func (m *MockBlockchain) GetLicenseMetadata(licenseID string) (*blockchain.Licen
 	return args.Get(0).(*blockchain.LicenseMetadata), args.Error(1)
 }
 
+func (m *MockBlockchain) IsLicenseValid(user common.Address, toolID *big.Int) (bool, error) {
+	args := m.Called(user, toolID)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockBlockchain) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -157,6 +166,36 @@ func TestVerificationService(t *testing.T) {
 		assert.True(t, allowed) // Should fail open on cache error
 		mockStore.AssertExpectations(t)
 	})
+
+	t.Run("Verify License - Stale While Chain Unavailable", func(t *testing.T) {
+		store := cache.NewKVStore()
+		defer store.Close()
+		mockBC := new(MockBlockchain)
+		service := NewVerificationService(&config.Config{CacheTTL: 1, MaxLicenseStaleness: time.Hour}, store, mockBC)
+
+		user := "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+		mockBC.On("IsLicenseValid", common.HexToAddress(user), big.NewInt(7)).Return(true, nil).Once()
+		mockBC.On("GetLicenseMetadata", "1").Return(&blockchain.LicenseMetadata{
+			ExpiresAt: time.Now().Add(time.Hour),
+			MaxCalls:  10,
+			Tier:      "premium",
+		}, nil).Once()
+
+		result, err := service.VerifyLicense("1", "7", user)
+		assert.NoError(t, err)
+		assert.False(t, result.Stale)
+
+		// Let the regular cache entry expire, then lose the node
+		store.Delete(context.Background(), "license:1:7")
+		mockBC.On("IsLicenseValid", common.HexToAddress(user), big.NewInt(7)).
+			Return(false, fmt.Errorf("%w: connection refused", blockchain.ErrChainUnavailable))
+
+		result, err = service.VerifyLicense("1", "7", user)
+		assert.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.True(t, result.Stale)
+		assert.Equal(t, "premium", result.Tier)
+	})
 }
 
 // Note: All remaining tests are implemented in TestVerificationService
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// How long verified call history is kept after a user's last call
+	usageTTL = 365 * 24 * time.Hour
+
+	// How long a provenance receipt can be shown as proof of a call
+	receiptTTL = 30 * 24 * time.Hour
+)
+
+// UsageLedger records verified calls by user and tool, and the provenance
+// receipts handed out for them. Only the access paths record calls, so the
+// ledger is what vote eligibility is judged on.
+type UsageLedger struct {
+	store kvstore.Store
+}
+
+// NewUsageLedger creates a usage ledger backed by store.
+func NewUsageLedger(store kvstore.Store) *UsageLedger {
+	return &UsageLedger{
+		store: store,
+	}
+}
+
+// RecordCall records a verified call by user to toolID. A non-empty
+// receipt is the provenance hash returned for the call.
+func (l *UsageLedger) RecordCall(ctx context.Context, user common.Address, toolID, receipt string) error {
+	now := time.Now().UTC()
+	key := usageKey(user, toolID)
+
+	if _, err := l.store.Increment(ctx, "verified:calls:"+key, 1, usageTTL); err != nil {
+		return fmt.Errorf("failed to record call: %w", err)
+	}
+	if _, found := l.store.Get(ctx, "verified:first:"+key); !found {
+		if err := l.store.Set(ctx, "verified:first:"+key, now, usageTTL); err != nil {
+			return fmt.Errorf("failed to record first call: %w", err)
+		}
+	}
+
+	if receipt == "" {
+		return nil
+	}
+	err := l.store.Set(ctx, "receipt:"+strings.ToLower(receipt), &models.ProvenanceReceipt{
+		Hash:        strings.ToLower(receipt),
+		UserAddress: strings.ToLower(user.Hex()),
+		ToolID:      toolID,
+		IssuedAt:    now,
+	}, receiptTTL)
+	if err != nil {
+		return fmt.Errorf("failed to record provenance receipt: %w", err)
+	}
+	return nil
+}
+
+// Usage returns the verified call history of user for toolID.
+func (l *UsageLedger) Usage(ctx context.Context, user common.Address, toolID string) models.ToolUsage {
+	key := usageKey(user, toolID)
+
+	var usage models.ToolUsage
+	if cached, found := l.store.Get(ctx, "verified:calls:"+key); found {
+		usage.Calls, _ = cached.(int64)
+	}
+	if cached, found := l.store.Get(ctx, "verified:first:"+key); found {
+		usage.FirstCallAt, _ = cached.(time.Time)
+	}
+	return usage
+}
+
+// Receipt returns the provenance receipt with the given hash.
+func (l *UsageLedger) Receipt(ctx context.Context, hash string) (*models.ProvenanceReceipt, bool) {
+	cached, found := l.store.Get(ctx, "receipt:"+strings.ToLower(hash))
+	if !found {
+		return nil, false
+	}
+	receipt, ok := cached.(*models.ProvenanceReceipt)
+	return receipt, ok
+}
+
+func usageKey(user common.Address, toolID string) string {
+	return strings.ToLower(user.Hex()) + ":" + toolID
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"moltket/internal/auth"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// supersessionLeafArguments is the ABI encoding of a supersession leaf:
+// (bytes32 voteLeaf, uint64 nonce, bool retracted)
+var supersessionLeafArguments = mustArguments("bytes32", "uint64", "bool")
+
+// RetractVote withdraws the voter's active vote on a tool. The retraction
+// is signed like a vote, and its nonce must be higher than any the voter
+// used on the tool before.
+func (s *VoteService) RetractVote(ctx context.Context, retraction *models.VoteRetraction) (*models.VoteVerificationResult, error) {
+	isValid, reason, err := s.verifyRetractionSignature(ctx, retraction)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+	if !isValid {
+		return &models.VoteVerificationResult{
+			Valid:  false,
+			Reason: reason,
+		}, nil
+	}
+
+	s.submitMu.Lock()
+	defer s.submitMu.Unlock()
+
+	voter := common.HexToAddress(retraction.VoterAddress)
+	if reason := s.checkVoteNonce(ctx, voter, retraction.ToolID, retraction.Nonce); reason != "" {
+		return &models.VoteVerificationResult{
+			Valid:  false,
+			Reason: reason,
+		}, nil
+	}
+
+	active := s.activeVote(ctx, voter, retraction.ToolID)
+	if active == nil {
+		return &models.VoteVerificationResult{
+			Valid:  false,
+			Reason: "no active vote to retract",
+		}, nil
+	}
+
+	if _, err := s.supersede(ctx, active, "", retraction.Nonce); err != nil {
+		return nil, err
+	}
+	if err := s.cache.Delete(ctx, activeVoteKey(voter, retraction.ToolID)); err != nil {
+		return nil, fmt.Errorf("failed to clear active vote: %w", err)
+	}
+	if err := s.recordVoteNonce(ctx, voter, retraction.ToolID, retraction.Nonce); err != nil {
+		return nil, err
+	}
+
+	s.updateCachedReputation(ctx, retraction.ToolID)
+
+	return &models.VoteVerificationResult{
+		Valid:  true,
+		VoteID: active.ID,
+	}, nil
+}
+
+// supersede replaces old, the voter's active vote, with the vote newID, or
+// retracts it if newID is empty. A pending old vote is dropped from the
+// pending votes, which are returned; a committed one gets a supersession
+// recorded in the next batch. Callers hold submitMu.
+func (s *VoteService) supersede(ctx context.Context, old *models.Vote, newID string, nonce uint64) ([]*models.Vote, error) {
+	now := time.Now()
+
+	pendingKey := fmt.Sprintf("pending:vote:%s", old.ToolID)
+	pendingVotes := s.pendingVotes(ctx, old.ToolID)
+
+	if old.BatchID == "" {
+		kept := make([]*models.Vote, 0, len(pendingVotes))
+		for _, vote := range pendingVotes {
+			if vote.ID != old.ID {
+				kept = append(kept, vote)
+			}
+		}
+		pendingVotes = kept
+		if err := s.cache.Set(ctx, pendingKey, pendingVotes, s.batchInterval*2); err != nil {
+			return nil, fmt.Errorf("failed to update pending votes: %w", err)
+		}
+	} else {
+		leaf, err := VoteLeaf(old)
+		if err != nil {
+			return nil, err
+		}
+		supersessions := append(s.pendingSupersessions(ctx, old.ToolID), &models.VoteSupersession{
+			VoteID:       old.ID,
+			BatchID:      old.BatchID,
+			VoterAddress: old.VoterAddress,
+			Score:        old.Score,
+			Weight:       old.EffectiveWeight(),
+			VoteLeaf:     leaf.Hex(),
+			SupersededBy: newID,
+			Nonce:        nonce,
+			CreatedAt:    now,
+		})
+		if err := s.cache.Set(ctx, "pending:supersession:"+old.ToolID, supersessions, usageTTL); err != nil {
+			return nil, fmt.Errorf("failed to record supersession: %w", err)
+		}
+	}
+
+	s.history.RecordWithdrawal(ctx, old.ToolID, old.Score, old.EffectiveWeight(), now)
+
+	superseded := *old
+	if newID == "" {
+		superseded.RetractedAt = &now
+	} else {
+		superseded.SupersededBy = newID
+	}
+	// A committed vote stays provable against its batch
+	ttl := 24 * time.Hour
+	if old.BatchID != "" {
+		ttl = reputationLedgerTTL
+	}
+	if err := s.cache.Set(ctx, fmt.Sprintf("vote:%s", old.ID), &superseded, ttl); err != nil {
+		return nil, fmt.Errorf("failed to update superseded vote: %w", err)
+	}
+	return pendingVotes, nil
+}
+
+// checkVoteNonce returns why nonce cannot be used for a vote or retraction
+// by voter on toolID, or "" if it can.
+func (s *VoteService) checkVoteNonce(ctx context.Context, voter common.Address, toolID string, nonce uint64) string {
+	cached, found := s.cache.Get(ctx, voteNonceKey(voter, toolID))
+	if !found {
+		return ""
+	}
+	if last, ok := cached.(uint64); ok && nonce <= last {
+		return fmt.Sprintf("nonce must be higher than %d, the voter's last nonce on this tool", last)
+	}
+	return ""
+}
+
+func (s *VoteService) recordVoteNonce(ctx context.Context, voter common.Address, toolID string, nonce uint64) error {
+	if err := s.cache.Set(ctx, voteNonceKey(voter, toolID), nonce, usageTTL); err != nil {
+		return fmt.Errorf("failed to record vote nonce: %w", err)
+	}
+	return nil
+}
+
+// activeVote returns the vote of voter on toolID that counts, if any.
+func (s *VoteService) activeVote(ctx context.Context, voter common.Address, toolID string) *models.Vote {
+	cached, found := s.cache.Get(ctx, activeVoteKey(voter, toolID))
+	if !found {
+		return nil
+	}
+	vote, _ := cached.(*models.Vote)
+	return vote
+}
+
+func (s *VoteService) pendingVotes(ctx context.Context, toolID string) []*models.Vote {
+	cached, found := s.cache.Get(ctx, fmt.Sprintf("pending:vote:%s", toolID))
+	if !found {
+		return nil
+	}
+	votes, _ := cached.([]*models.Vote)
+	return append([]*models.Vote(nil), votes...)
+}
+
+func (s *VoteService) pendingSupersessions(ctx context.Context, toolID string) []*models.VoteSupersession {
+	cached, found := s.cache.Get(ctx, "pending:supersession:"+toolID)
+	if !found {
+		return nil
+	}
+	supersessions, _ := cached.([]*models.VoteSupersession)
+	return append([]*models.VoteSupersession(nil), supersessions...)
+}
+
+func (s *VoteService) verifyRetractionSignature(ctx context.Context, retraction *models.VoteRetraction) (bool, string, error) {
+	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(retraction.Signature, "0x"))
+	if err != nil || len(signatureBytes) == 0 {
+		return false, "invalid signature format", nil
+	}
+
+	toolIDBig, ok := new(big.Int).SetString(retraction.ToolID, 10)
+	if !ok {
+		return false, "invalid tool ID", nil
+	}
+
+	signed := &auth.Retraction{
+		Voter:  common.HexToAddress(retraction.VoterAddress),
+		ToolID: toolIDBig,
+		Nonce:  retraction.Nonce,
+	}
+	hash, err := auth.HashRetraction(s.voteDomain, signed)
+	if err != nil {
+		return false, err.Error(), nil
+	}
+
+	valid, err := s.signatures.Verify(ctx, signed.Voter, hash, signatureBytes)
+	if err != nil {
+		return false, "", err
+	}
+	if !valid {
+		return false, "signature does not match voter address", nil
+	}
+	return true, "", nil
+}
+
+// SupersessionLeaf returns the Merkle leaf of a supersession:
+// keccak256(keccak256(abi.encode(voteLeaf, nonce, retracted))), where
+// voteLeaf is the leaf of the superseded vote in its own batch.
+func SupersessionLeaf(supersession *models.VoteSupersession) (common.Hash, error) {
+	encoded, err := supersessionLeafArguments.Pack(
+		common.HexToHash(supersession.VoteLeaf),
+		supersession.Nonce,
+		supersession.Retracted(),
+	)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to encode supersession of vote %s: %w", supersession.VoteID, err)
+	}
+	return crypto.Keccak256Hash(crypto.Keccak256(encoded)), nil
+}
+
+func activeVoteKey(voter common.Address, toolID string) string {
+	return fmt.Sprintf("active:vote:%s:%s", strings.ToLower(voter.Hex()), toolID)
+}
+
+func voteNonceKey(voter common.Address, toolID string) string {
+	return fmt.Sprintf("nonce:vote:%s:%s", strings.ToLower(voter.Hex()), toolID)
+}
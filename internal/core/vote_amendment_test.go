@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"moltket/config"
+	"moltket/internal/auth"
+	"moltket/internal/cache"
+	"moltket/internal/merkle"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoteAmendment(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	cfg := &config.Config{
+		ChainID:                 31337,
+		ReputationOracleAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+	}
+	service := NewVoteService(cfg, kvStore, nil)
+	domain := auth.VoteDomain(big.NewInt(cfg.ChainID), common.HexToAddress(cfg.ReputationOracleAddress))
+
+	voterKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	voter := crypto.PubkeyToAddress(voterKey.PublicKey)
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	vote := func(toolID string, score int8, nonce uint64) *models.VoteVerificationResult {
+		tool, _ := new(big.Int).SetString(toolID, 10)
+		signature, err := auth.SignVote(voterKey, domain, &auth.Vote{Voter: voter, ToolID: tool, Score: score, Nonce: nonce})
+		require.NoError(t, err)
+
+		result, err := service.SubmitVote(ctx, &models.VoteSubmission{
+			ToolID:       toolID,
+			VoterAddress: voter.Hex(),
+			Score:        score,
+			Nonce:        nonce,
+			Signature:    hex.EncodeToString(signature),
+		})
+		require.NoError(t, err)
+		return result
+	}
+	retract := func(toolID string, nonce uint64) *models.VoteVerificationResult {
+		tool, _ := new(big.Int).SetString(toolID, 10)
+		signature, err := auth.SignRetraction(voterKey, domain, &auth.Retraction{Voter: voter, ToolID: tool, Nonce: nonce})
+		require.NoError(t, err)
+
+		result, err := service.RetractVote(ctx, &models.VoteRetraction{
+			ToolID:       toolID,
+			VoterAddress: voter.Hex(),
+			Nonce:        nonce,
+			Signature:    hex.EncodeToString(signature),
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("PendingVote", func(t *testing.T) {
+		first := vote("1", 1, 1)
+		require.True(t, first.Valid, first.Reason)
+		second := vote("1", -1, 2)
+		require.True(t, second.Valid, second.Reason)
+
+		// Only the newer vote counts
+		pending := service.pendingVotes(ctx, "1")
+		require.Len(t, pending, 1)
+		assert.Equal(t, second.VoteID, pending[0].ID)
+		assert.Equal(t, first.VoteID, pending[0].Supersedes)
+
+		reputation, err := service.GetToolReputation(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), reputation.TotalVotes)
+		assert.Equal(t, int64(-1), reputation.TotalScore)
+
+		cached, found := kvStore.Get(ctx, "vote:"+first.VoteID)
+		require.True(t, found)
+		assert.Equal(t, second.VoteID, cached.(*models.Vote).SupersededBy)
+
+		// A pending replacement leaves nothing to record in the batch
+		batch, err := service.ProcessBatch(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, 1, batch.VotesCount)
+		assert.Empty(t, batch.Supersessions)
+	})
+
+	t.Run("StaleNonce", func(t *testing.T) {
+		result := vote("1", 1, 12)
+		require.True(t, result.Valid, result.Reason)
+
+		for _, nonce := range []uint64{12, 11} {
+			result := vote("1", -1, nonce)
+			assert.False(t, result.Valid)
+			assert.Contains(t, result.Reason, "nonce must be higher than 12")
+		}
+		result = retract("1", 12)
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Reason, "nonce must be higher than 12")
+	})
+
+	t.Run("CommittedVote", func(t *testing.T) {
+		first := vote("2", 1, 1)
+		require.True(t, first.Valid, first.Reason)
+		_, err := service.ProcessBatch(ctx, "2")
+		require.NoError(t, err)
+
+		second := vote("2", -1, 5)
+		require.True(t, second.Valid, second.Reason)
+
+		batch, err := service.ProcessBatch(ctx, "2")
+		require.NoError(t, err)
+		assert.Equal(t, 1, batch.VotesCount)
+		require.Len(t, batch.Supersessions, 1)
+
+		supersession := batch.Supersessions[0]
+		assert.Equal(t, first.VoteID, supersession.VoteID)
+		assert.Equal(t, second.VoteID, supersession.SupersededBy)
+		assert.Equal(t, uint64(5), supersession.Nonce)
+		assert.False(t, supersession.Retracted())
+
+		// The superseded vote is still provable against its own batch, and
+		// the supersession against the new one
+		proof, err := service.VoteProof(ctx, first.VoteID)
+		require.NoError(t, err)
+		assert.Equal(t, supersession.VoteLeaf, proof.Leaf)
+		assert.Equal(t, supersession.BatchID, proof.BatchID)
+
+		cached, found := kvStore.Get(ctx, "batch:2:"+batch.ID+":leaves")
+		require.True(t, found)
+		tree, err := merkle.New(cached.([]common.Hash))
+		require.NoError(t, err)
+		leaf := common.HexToHash(supersession.Leaf)
+		siblings, err := tree.Proof(leaf)
+		require.NoError(t, err)
+		assert.True(t, merkle.Verify(common.HexToHash(batch.MerkleRoot), leaf, siblings))
+
+		// The batched replacement is now the active vote
+		active := service.activeVote(ctx, voter, "2")
+		require.NotNil(t, active)
+		assert.Equal(t, batch.ID, active.BatchID)
+	})
+
+	t.Run("Retraction", func(t *testing.T) {
+		result := retract("2", 6)
+		require.True(t, result.Valid, result.Reason)
+		assert.Nil(t, service.activeVote(ctx, voter, "2"))
+
+		// A retraction alone makes a batch
+		batch, err := service.ProcessBatch(ctx, "2")
+		require.NoError(t, err)
+		assert.Zero(t, batch.VotesCount)
+		require.Len(t, batch.Supersessions, 1)
+		assert.Equal(t, result.VoteID, batch.Supersessions[0].VoteID)
+		assert.True(t, batch.Supersessions[0].Retracted())
+
+		cached, found := kvStore.Get(ctx, "vote:"+result.VoteID)
+		require.True(t, found)
+		assert.NotNil(t, cached.(*models.Vote).RetractedAt)
+
+		result = retract("2", 7)
+		assert.False(t, result.Valid)
+		assert.Equal(t, "no active vote to retract", result.Reason)
+
+		// A pending vote is simply dropped
+		require.True(t, vote("3", 1, 1).Valid)
+		require.True(t, retract("3", 2).Valid)
+		assert.Empty(t, service.pendingVotes(ctx, "3"))
+		reputation, err := service.GetToolReputation(ctx, "3")
+		require.NoError(t, err)
+		assert.Zero(t, reputation.TotalVotes)
+	})
+
+	t.Run("ForeignSignature", func(t *testing.T) {
+		signature, err := auth.SignRetraction(otherKey, domain, &auth.Retraction{Voter: voter, ToolID: big.NewInt(1), Nonce: 20})
+		require.NoError(t, err)
+
+		result, err := service.RetractVote(ctx, &models.VoteRetraction{
+			ToolID:       "1",
+			VoterAddress: voter.Hex(),
+			Nonce:        20,
+			Signature:    hex.EncodeToString(signature),
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.NotNil(t, service.activeVote(ctx, voter, "1"))
+	})
+}
@@ -5,25 +5,59 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"math/big"
-	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"moltket/config"
 	"moltket/internal/auth"
+	"moltket/internal/blockchain"
 	"moltket/internal/kvstore"
+	"moltket/internal/merkle"
 	"moltket/internal/models"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
+var (
+	// ErrVoteNotFound is returned for votes that were never submitted or
+	// have expired from the store.
+	ErrVoteNotFound = errors.New("vote not found")
+
+	// ErrVoteNotBatched is returned when proving a vote that is still
+	// pending.
+	ErrVoteNotBatched = errors.New("vote is not in a batch yet")
+)
+
+// voteLeafArguments is the ABI encoding of a vote leaf: (address voter,
+// string toolId, int8 score, uint64 nonce, uint64 weight)
+var voteLeafArguments = mustArguments("address", "string", "int8", "uint64", "uint64")
+
 type VoteService struct {
 	config        *config.Config
 	cache         kvstore.Store
 	signer        *auth.EIP712Signer
+	voteDomain    apitypes.TypedDataDomain
+	signatures    *auth.SignatureVerifier
+	scorer        Scorer
+	skillBalances blockchain.SkillBalanceReader
+	licenses      blockchain.PaymentLedger
+	usage         *UsageLedger
+	reputation    *ReputationLedger
+	history       *ReputationHistory
+	eligibility   *EligibilityPolicy
 	batchInterval time.Duration
+
+	// Serializes eligibility checks with storing the votes they allow, and
+	// changes to pending votes, supersessions and active votes
+	submitMu sync.Mutex
 }
 
 // NewVoteService creates a new vote service
@@ -32,18 +66,54 @@ func NewVoteService(
 	cache kvstore.Store,
 	signer *auth.EIP712Signer,
 ) *VoteService {
+	// An invalid scorer configuration is reported by NewScorer at startup;
+	// fall back to plain averages here
+	scorer, err := NewScorer(cfg)
+	if err != nil {
+		scorer = NewMeanScorer(0)
+	}
+
 	return &VoteService{
 		config:        cfg,
 		cache:         cache,
 		signer:        signer,
+		voteDomain:    auth.VoteDomain(big.NewInt(cfg.ChainID), common.HexToAddress(cfg.ReputationOracleAddress)),
+		scorer:        scorer,
+		usage:         NewUsageLedger(cache),
+		reputation:    NewReputationLedger(cache),
+		history:       NewReputationHistory(cache),
+		eligibility:   NewEligibilityPolicyFromConfig(cfg),
 		batchInterval: 5 * time.Minute, // Batch votes every 5 minutes
 	}
 }
 
+// UseEligibilityPolicy replaces the eligibility policy configured by config.
+func (s *VoteService) UseEligibilityPolicy(policy *EligibilityPolicy) {
+	s.eligibility = policy
+}
+
+// UseScorer replaces the scorer selected by config.
+func (s *VoteService) UseScorer(scorer Scorer) {
+	s.scorer = scorer
+}
+
+// UseSignatureVerifier lets contract wallets vote: signatures that do not
+// recover to the voter are checked with EIP-1271. Without it only EOA
+// signatures are accepted.
+func (s *VoteService) UseSignatureVerifier(verifier *auth.SignatureVerifier) {
+	s.signatures = verifier
+}
+
+// UseLedgerJournal keeps the reputation ledger in journal, so committed
+// reputation survives restarts; see ReputationLedger.UseJournal.
+func (s *VoteService) UseLedgerJournal(ctx context.Context, journal *kvstore.Journal) error {
+	return s.reputation.UseJournal(ctx, journal)
+}
+
 // SubmitVote processes and stores a new vote
 func (s *VoteService) SubmitVote(ctx context.Context, submission *models.VoteSubmission) (*models.VoteVerificationResult, error) {
 	// 1. Verify vote signature
-	isValid, reason, err := s.verifyVoteSignature(submission)
+	isValid, reason, err := s.verifyVoteSignature(ctx, submission)
 	if err != nil {
 		return nil, fmt.Errorf("signature verification failed: %w", err)
 	}
@@ -55,6 +125,9 @@ func (s *VoteService) SubmitVote(ctx context.Context, submission *models.VoteSub
 		}, nil
 	}
 
+	s.submitMu.Lock()
+	defer s.submitMu.Unlock()
+
 	// 2. Check if vote already exists (replay protection)
 	voteID := s.generateVoteID(submission)
 	existingKey := fmt.Sprintf("vote:%s", voteID)
@@ -65,17 +138,26 @@ func (s *VoteService) SubmitVote(ctx context.Context, submission *models.VoteSub
 		}, nil
 	}
 
-	// 3. Check voter eligibility (must have used the tool at least once)
-	eligible, err := s.isVoterEligible(ctx, submission.VoterAddress, submission.ToolID)
-	if err != nil {
-		return nil, fmt.Errorf("eligibility check failed: %w", err)
+	// Only a nonce higher than any the voter used on the tool replaces
+	// their active vote
+	voter := common.HexToAddress(submission.VoterAddress)
+	if reason := s.checkVoteNonce(ctx, voter, submission.ToolID, submission.Nonce); reason != "" {
+		return &models.VoteVerificationResult{
+			Valid:  false,
+			Reason: reason,
+		}, nil
 	}
 
-	if !eligible {
+	// 3. Check voter eligibility against their verified usage
+	var ineligible *EligibilityError
+	if err := s.checkEligibility(ctx, submission); errors.As(err, &ineligible) {
 		return &models.VoteVerificationResult{
 			Valid:  false,
-			Reason: "voter not eligible - must use tool before voting",
+			Reason: ineligible.Error(),
+			Rule:   ineligible.Rule,
 		}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("eligibility check failed: %w", err)
 	}
 
 	// 4. Create and store vote
@@ -88,17 +170,20 @@ func (s *VoteService) SubmitVote(ctx context.Context, submission *models.VoteSub
 		Signature:    submission.Signature,
 		CreatedAt:    time.Now(),
 		Processed:    false,
+		Weight:       s.voteWeight(ctx, voter, submission.ToolID),
 	}
 
-	// Store vote in pending votes list
-	pendingKey := fmt.Sprintf("pending:vote:%s", submission.ToolID)
-	var pendingVotes []*models.Vote
-	if cached, found := s.cache.Get(ctx, pendingKey); found {
-		if votes, ok := cached.([]*models.Vote); ok {
-			pendingVotes = votes
+	// One vote per voter and tool counts: this one replaces the last
+	pendingVotes := s.pendingVotes(ctx, submission.ToolID)
+	if active := s.activeVote(ctx, voter, submission.ToolID); active != nil {
+		vote.Supersedes = active.ID
+		if pendingVotes, err = s.supersede(ctx, active, vote.ID, submission.Nonce); err != nil {
+			return nil, err
 		}
 	}
 
+	// Store vote in pending votes list
+	pendingKey := fmt.Sprintf("pending:vote:%s", submission.ToolID)
 	pendingVotes = append(pendingVotes, vote)
 	if err := s.cache.Set(ctx, pendingKey, pendingVotes, s.batchInterval*2); err != nil {
 		return nil, fmt.Errorf("failed to store pending vote: %w", err)
@@ -108,9 +193,19 @@ func (s *VoteService) SubmitVote(ctx context.Context, submission *models.VoteSub
 	if err := s.cache.Set(ctx, existingKey, vote, 24*time.Hour); err != nil {
 		return nil, fmt.Errorf("failed to cache vote: %w", err)
 	}
+	if err := s.cache.Set(ctx, activeVoteKey(voter, submission.ToolID), vote, usageTTL); err != nil {
+		return nil, fmt.Errorf("failed to store active vote: %w", err)
+	}
+	if err := s.recordVoteNonce(ctx, voter, submission.ToolID, submission.Nonce); err != nil {
+		return nil, err
+	}
+	if err := s.cache.Set(ctx, lastVoteKey(voter, submission.ToolID), vote.CreatedAt, usageTTL); err != nil {
+		return nil, fmt.Errorf("failed to record vote time: %w", err)
+	}
 
 	// 5. Update real-time reputation (cached, not final)
-	s.updateCachedReputation(ctx, submission.ToolID, submission.Score)
+	s.history.RecordVote(ctx, vote)
+	s.updateCachedReputation(ctx, submission.ToolID)
 
 	return &models.VoteVerificationResult{
 		Valid:  true,
@@ -128,35 +223,9 @@ func (s *VoteService) GetToolReputation(ctx context.Context, toolID string) (*mo
 		}
 	}
 
-	// Calculate from pending votes if not cached
-	pendingKey := fmt.Sprintf("pending:vote:%s", toolID)
-	var totalScore int64
-	var totalVotes int64
-
-	if cached, found := s.cache.Get(ctx, pendingKey); found {
-		if votes, ok := cached.([]*models.Vote); ok {
-			for _, vote := range votes {
-				totalScore += int64(vote.Score)
-				totalVotes++
-			}
-		}
-	}
-
-	// Also check committed batches (in a real system, this would query a database)
-	// For demo, we'll use cache-only approach
-
-	var averageScore float64
-	if totalVotes > 0 {
-		averageScore = float64(totalScore) / float64(totalVotes)
-	}
-
-	reputation := &models.ToolReputation{
-		ToolID:           toolID,
-		TotalScore:       totalScore,
-		TotalVotes:       totalVotes,
-		AverageScore:     averageScore,
-		RecentScore:      averageScore, // Simplified for demo
-		LastCalculatedAt: time.Now(),
+	reputation, err := s.currentReputation(ctx, toolID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Cache the reputation
@@ -165,135 +234,133 @@ func (s *VoteService) GetToolReputation(ctx context.Context, toolID string) (*mo
 	return reputation, nil
 }
 
-// ProcessBatch creates a batch of pending votes for a tool
+// ProcessBatch creates a batch of pending votes for a tool, recording the
+// committed votes they superseded
 func (s *VoteService) ProcessBatch(ctx context.Context, toolID string) (*models.VoteBatch, error) {
+	s.submitMu.Lock()
+	defer s.submitMu.Unlock()
+
 	pendingKey := fmt.Sprintf("pending:vote:%s", toolID)
 
 	cached, found := s.cache.Get(ctx, pendingKey)
-	if !found {
+	supersessions := s.pendingSupersessions(ctx, toolID)
+	if !found && len(supersessions) == 0 {
 		return nil, fmt.Errorf("no pending votes for tool %s", toolID)
 	}
 
-	votes, ok := cached.([]*models.Vote)
-	if !ok || len(votes) == 0 {
+	votes, _ := cached.([]*models.Vote)
+	if len(votes) == 0 && len(supersessions) == 0 {
 		return nil, fmt.Errorf("no valid votes to process")
 	}
 
 	// Calculate batch statistics
-	var totalScore int64
+	var totalScore, rawScore int64
 	for _, vote := range votes {
-		totalScore += int64(vote.Score)
+		totalScore += int64(vote.Score) * int64(vote.EffectiveWeight())
+		rawScore += int64(vote.Score)
 		vote.Processed = true
 	}
 
-	// Generate Merkle root (simplified for demo - in production use proper Merkle tree)
-	merkleRoot := s.generateMerkleRoot(votes)
+	// Build the Merkle tree of the votes and supersessions, so each can be
+	// proven part of the batch
+	tree, err := buildBatchTree(votes, supersessions)
+	if err != nil {
+		return nil, err
+	}
 
+	// Batches of a tool are keyed by ID, so two in the same second must not
+	// share one
 	batch := &models.VoteBatch{
-		ID:         fmt.Sprintf("batch_%s_%d", toolID, time.Now().Unix()),
+		ID:         fmt.Sprintf("batch_%s_%d", toolID, time.Now().UnixNano()),
 		ToolID:     toolID,
 		VotesCount: len(votes),
 		TotalScore: totalScore,
-		MerkleRoot: merkleRoot,
+		RawScore:   rawScore,
+		MerkleRoot: tree.Root().Hex(),
 		CreatedAt:  time.Now(),
+
+		Supersessions: supersessions,
 	}
 
-	// Store batch
+	// Store batch, with its leaves for proofs. Batches, their leaves and
+	// their votes are kept as long as the reputation ledger they add to, so
+	// every committed vote stays provable
 	batchKey := fmt.Sprintf("batch:%s:%s", toolID, batch.ID)
-	if err := s.cache.Set(ctx, batchKey, batch, 24*time.Hour); err != nil {
+	if err := s.cache.Set(ctx, batchKey, batch, reputationLedgerTTL); err != nil {
 		return nil, fmt.Errorf("failed to store batch: %w", err)
 	}
+	if err := s.cache.Set(ctx, batchKey+":leaves", tree.Leaves(), reputationLedgerTTL); err != nil {
+		return nil, fmt.Errorf("failed to store batch leaves: %w", err)
+	}
 
-	// Store batch reference in votes
+	// Store batch reference in votes, including the voters' active votes
 	for _, vote := range votes {
 		vote.BatchID = batch.ID
 		voteKey := fmt.Sprintf("vote:%s", vote.ID)
-		s.cache.Set(ctx, voteKey, vote, 24*time.Hour)
+		s.cache.Set(ctx, voteKey, vote, reputationLedgerTTL)
+
+		activeKey := activeVoteKey(common.HexToAddress(vote.VoterAddress), toolID)
+		if active, found := s.cache.Get(ctx, activeKey); found {
+			if active, ok := active.(*models.Vote); ok && active.ID == vote.ID {
+				s.cache.Set(ctx, activeKey, vote, usageTTL)
+			}
+		}
+	}
+
+	// Add the batch to the tool's cumulative reputation
+	if _, err := s.reputation.Commit(ctx, batch, votes); err != nil {
+		return nil, err
 	}
+	s.history.RecordBatch(ctx, batch)
 
-	// Clear pending votes (they're now in a batch)
+	// Clear pending votes and supersessions (they're now in a batch)
 	s.cache.Delete(ctx, pendingKey)
+	s.cache.Delete(ctx, "pending:supersession:"+toolID)
 
-	// Update reputation with batch data
-	s.updateReputationFromBatch(ctx, toolID, batch)
+	s.updateCachedReputation(ctx, toolID)
 
 	return batch, nil
 }
 
-// verifyVoteSignature verifies an EIP-712 vote signature
-func (s *VoteService) verifyVoteSignature(submission *models.VoteSubmission) (bool, string, error) {
-	// Parse signature
-	signatureBytes, err := hex.DecodeString(submission.Signature)
-	if err != nil {
+// verifyVoteSignature checks that the submission carries the voter's EIP-712
+// signature of the Vote typed data (see auth.VoteType), made by the voter's
+// key or accepted by the voter's contract wallet
+func (s *VoteService) verifyVoteSignature(ctx context.Context, submission *models.VoteSubmission) (bool, string, error) {
+	// Parse signature, as returned by eth_signTypedData_v4. Contract wallet
+	// signatures need not be 65 bytes.
+	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(submission.Signature, "0x"))
+	if err != nil || len(signatureBytes) == 0 {
 		return false, "invalid signature format", nil
 	}
 
-	if len(signatureBytes) != 65 {
-		return false, "signature must be 65 bytes", nil
-	}
-
-	// Prepare EIP-712 typed data
 	toolIDBig, ok := new(big.Int).SetString(submission.ToolID, 10)
 	if !ok {
 		return false, "invalid tool ID", nil
 	}
 
-	voterAddress := common.HexToAddress(submission.VoterAddress)
-
-	// Note: In production, we would use the same EIP-712 structure as the signer
-	// For demo, we'll verify against the backend signer's public key
-
-	// Check if signature matches the expected signer (backend)
-	// This is a simplified check - in reality, votes are signed by users, not backend
-	// For the hackathon demo, we'll accept any valid ECDSA signature
-
-	// Recover address from signature
-	messageHash := s.getVoteMessageHash(voterAddress, toolIDBig, submission.Score, submission.Nonce)
-
-	// Extract r, s, v from signature
-	sigR := signatureBytes[:32]
-	sigS := signatureBytes[32:64]
-	vByte := signatureBytes[64]
-
-	// Adjust v if necessary
-	if vByte < 27 {
-		vByte += 27
+	vote := &auth.Vote{
+		Voter:  common.HexToAddress(submission.VoterAddress),
+		ToolID: toolIDBig,
+		Score:  submission.Score,
+		Nonce:  submission.Nonce,
 	}
 
-	// Recover public key
-	signature := append(sigR, sigS...)
-	signature = append(signature, vByte)
-
-	pubkey, err := crypto.SigToPub(messageHash, signature)
+	hash, err := auth.HashVote(s.voteDomain, vote)
 	if err != nil {
-		return false, "failed to recover public key", nil
+		return false, err.Error(), nil
 	}
 
-	recoveredAddr := crypto.PubkeyToAddress(*pubkey)
-
-	// Verify recovered address matches voter address
-	if recoveredAddr != voterAddress {
+	valid, err := s.signatures.Verify(ctx, vote.Voter, hash, signatureBytes)
+	if err != nil {
+		return false, "", err
+	}
+	if !valid {
 		return false, "signature does not match voter address", nil
 	}
 
 	return true, "", nil
 }
 
-// getVoteMessageHash generates the message hash for vote signing
-func (s *VoteService) getVoteMessageHash(voter common.Address, toolID *big.Int, score int8, nonce uint64) []byte {
-	// Simplified message hash for demo
-	// In production, use proper EIP-712 typed data hashing
-	message := fmt.Sprintf("Vote\nTool: %s\nVoter: %s\nScore: %d\nNonce: %d\nChain: %d",
-		toolID.String(),
-		voter.Hex(),
-		score,
-		nonce,
-		s.config.ChainID,
-	)
-
-	return crypto.Keccak256([]byte(message))
-}
-
 // generateVoteID creates a unique ID for a vote
 func (s *VoteService) generateVoteID(submission *models.VoteSubmission) string {
 	data := fmt.Sprintf("%s:%s:%d:%d",
@@ -307,115 +374,215 @@ func (s *VoteService) generateVoteID(submission *models.VoteSubmission) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// isVoterEligible checks if a voter is eligible to vote on a tool
-func (s *VoteService) isVoterEligible(ctx context.Context, voterAddress, toolID string) (bool, error) {
-	// Check if voter has used the tool (simplified for demo)
-	// In production, this would check usage logs or license status
+// checkEligibility checks a vote against the eligibility policy, returning
+// an *EligibilityError naming the failed rule
+func (s *VoteService) checkEligibility(ctx context.Context, submission *models.VoteSubmission) error {
+	voter := common.HexToAddress(submission.VoterAddress)
 
-	usageKey := fmt.Sprintf("usage:%s:%s", voterAddress, toolID)
-	if cached, found := s.cache.Get(ctx, usageKey); found {
-		if usageCount, ok := cached.(int64); ok && usageCount > 0 {
-			return true, nil
-		}
+	history := &VoterHistory{
+		Voter:    strings.ToLower(voter.Hex()),
+		ToolID:   submission.ToolID,
+		Usage:    s.usage.Usage(ctx, voter, submission.ToolID),
+		Replaces: s.activeVote(ctx, voter, submission.ToolID) != nil,
 	}
-
-	// Also check if they have a valid license
-	licenseKey := fmt.Sprintf("license:%s:%s", voterAddress, toolID)
-	if _, found := s.cache.Get(ctx, licenseKey); found {
-		return true, nil
+	if cached, found := s.cache.Get(ctx, lastVoteKey(voter, submission.ToolID)); found {
+		history.LastVoteAt, _ = cached.(time.Time)
+	}
+	if submission.ProvenanceHash != "" {
+		history.Receipt, _ = s.usage.Receipt(ctx, submission.ProvenanceHash)
 	}
 
-	return false, nil
+	return s.eligibility.Check(history, time.Now())
 }
 
-// updateCachedReputation updates the cached reputation for a tool
-func (s *VoteService) updateCachedReputation(ctx context.Context, toolID string, score int8) {
-	cacheKey := fmt.Sprintf("reputation:%s", toolID)
+func lastVoteKey(voter common.Address, toolID string) string {
+	return fmt.Sprintf("voted:%s:%s", strings.ToLower(voter.Hex()), toolID)
+}
 
-	var reputation *models.ToolReputation
-	if cached, found := s.cache.Get(ctx, cacheKey); found {
-		if rep, ok := cached.(*models.ToolReputation); ok {
-			reputation = rep
-		}
+// scoreReputation computes the reputation of a tool from its votes with
+// the configured scorer
+func (s *VoteService) scoreReputation(toolID string, votes []*models.Vote) *models.ToolReputation {
+	now := time.Now()
+	reputation := &models.ToolReputation{
+		ToolID:           toolID,
+		TotalVotes:       int64(len(votes)),
+		Algorithm:        s.scorer.Name(),
+		Parameters:       s.scorer.Params(),
+		LastCalculatedAt: now,
 	}
+	for _, vote := range votes {
+		weight := int64(vote.EffectiveWeight())
+		reputation.TotalScore += int64(vote.Score) * weight
+		reputation.RawScore += int64(vote.Score)
+		reputation.TotalWeight += weight
+	}
+	reputation.AverageScore, reputation.RecentScore = s.scorer.Score(votes, now)
+	return reputation
+}
 
-	if reputation == nil {
-		reputation = &models.ToolReputation{
-			ToolID:           toolID,
-			LastCalculatedAt: time.Now(),
-		}
+// ReputationHistory returns how the reputation of a tool moved from from to
+// to, in buckets of an hour, day or week.
+func (s *VoteService) ReputationHistory(ctx context.Context, toolID, bucket string, from, to time.Time) ([]models.ReputationBucket, error) {
+	return s.history.Series(ctx, toolID, bucket, from, to)
+}
+
+// currentReputation computes the reputation of a tool from its committed
+// votes in the reputation ledger, its pending votes and the committed votes
+// pending supersession
+func (s *VoteService) currentReputation(ctx context.Context, toolID string) (*models.ToolReputation, error) {
+	stats, err := s.reputation.Stats(ctx, toolID)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := s.reputation.Checkpoints(ctx, toolID)
+	if err != nil {
+		return nil, err
 	}
+	pendingVotes := s.pendingVotes(ctx, toolID)
+	supersessions := s.pendingSupersessions(ctx, toolID)
 
-	reputation.TotalScore += int64(score)
-	reputation.TotalVotes++
+	reputation := s.scoreReputation(toolID, append(replayVotes(checkpoints, supersessions), pendingVotes...))
+
+	totals := stats.Totals
+	for _, vote := range pendingVotes {
+		addToTotals(&totals, vote.Score, vote.EffectiveWeight(), 1)
+	}
+	for _, supersession := range supersessions {
+		addToTotals(&totals, supersession.Score, supersession.Weight, -1)
+	}
+	reputation.TotalVotes = totals.Votes
+	reputation.TotalScore = totals.TotalScore
+	reputation.RawScore = totals.RawScore
+	reputation.TotalWeight = totals.TotalWeight
+	reputation.LastBatchAt = stats.LastBatchAt
+	return reputation, nil
+}
+
+// updateCachedReputation recomputes the cached reputation for a tool
+func (s *VoteService) updateCachedReputation(ctx context.Context, toolID string) {
+	cacheKey := fmt.Sprintf("reputation:%s", toolID)
 
-	if reputation.TotalVotes > 0 {
-		reputation.AverageScore = float64(reputation.TotalScore) / float64(reputation.TotalVotes)
-		reputation.RecentScore = reputation.AverageScore // Simplified
+	reputation, err := s.currentReputation(ctx, toolID)
+	if err != nil {
+		// Recomputed on the next read instead
+		log.Printf("Warning: failed to compute reputation of tool %s: %v", toolID, err)
+		s.cache.Delete(ctx, cacheKey)
+		return
 	}
 
-	reputation.LastCalculatedAt = time.Now()
 	s.cache.Set(ctx, cacheKey, reputation, 1*time.Minute)
 }
 
-// updateReputationFromBatch updates reputation from a processed batch
-func (s *VoteService) updateReputationFromBatch(ctx context.Context, toolID string, batch *models.VoteBatch) {
-	cacheKey := fmt.Sprintf("reputation:%s", toolID)
+// VoteProof returns the Merkle proof that a vote is part of its batch.
+func (s *VoteService) VoteProof(ctx context.Context, voteID string) (*models.VoteProof, error) {
+	cached, found := s.cache.Get(ctx, fmt.Sprintf("vote:%s", voteID))
+	if !found {
+		return nil, ErrVoteNotFound
+	}
+	vote, ok := cached.(*models.Vote)
+	if !ok {
+		return nil, ErrVoteNotFound
+	}
+	if vote.BatchID == "" {
+		return nil, ErrVoteNotBatched
+	}
 
-	var reputation *models.ToolReputation
-	if cached, found := s.cache.Get(ctx, cacheKey); found {
-		if rep, ok := cached.(*models.ToolReputation); ok {
-			reputation = rep
-		}
+	batchKey := fmt.Sprintf("batch:%s:%s", vote.ToolID, vote.BatchID)
+	cached, found = s.cache.Get(ctx, batchKey)
+	if !found {
+		return nil, fmt.Errorf("batch %s of vote %s not found", vote.BatchID, voteID)
+	}
+	batch, _ := cached.(*models.VoteBatch)
+	cached, found = s.cache.Get(ctx, batchKey+":leaves")
+	leaves, _ := cached.([]common.Hash)
+	if batch == nil || !found || len(leaves) == 0 {
+		return nil, fmt.Errorf("leaves of batch %s not found", vote.BatchID)
 	}
 
-	if reputation == nil {
-		reputation = &models.ToolReputation{
-			ToolID: toolID,
-		}
+	// The stored leaves must still produce the committed root
+	tree, err := merkle.New(leaves)
+	if err != nil {
+		return nil, err
+	}
+	if tree.Root().Hex() != batch.MerkleRoot {
+		return nil, fmt.Errorf("leaves of batch %s do not match its Merkle root", batch.ID)
 	}
 
-	// In production, this would add batch stats to cumulative stats
-	// For demo, we'll just update the timestamp
-	reputation.LastBatchAt = time.Now()
-	reputation.LastCalculatedAt = time.Now()
+	leaf, err := VoteLeaf(vote)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := tree.Proof(leaf)
+	if err != nil {
+		return nil, fmt.Errorf("vote %s: %w", voteID, err)
+	}
 
-	s.cache.Set(ctx, cacheKey, reputation, 1*time.Minute)
+	hexProof := make([]string, len(proof))
+	for i, sibling := range proof {
+		hexProof[i] = sibling.Hex()
+	}
+	return &models.VoteProof{
+		VoteID:     vote.ID,
+		BatchID:    batch.ID,
+		ToolID:     batch.ToolID,
+		Leaf:       leaf.Hex(),
+		Proof:      hexProof,
+		MerkleRoot: batch.MerkleRoot,
+	}, nil
 }
 
-// generateMerkleRoot generates a Merkle root from votes (simplified for demo)
-func (s *VoteService) generateMerkleRoot(votes []*models.Vote) string {
-	// Sort votes by ID for consistent ordering
-	sort.Slice(votes, func(i, j int) bool {
-		return votes[i].ID < votes[j].ID
-	})
+// VoteLeaf returns the Merkle leaf of a vote, in the format of
+// OpenZeppelin's StandardMerkleTree:
+// keccak256(keccak256(abi.encode(voter, toolId, score, nonce, weight))),
+// with the tool ID as a string. It depends on what the voter signed and the
+// weight the vote was given on submission.
+func VoteLeaf(vote *models.Vote) (common.Hash, error) {
+	if !common.IsHexAddress(vote.VoterAddress) {
+		return common.Hash{}, fmt.Errorf("vote %s has invalid voter address %q", vote.ID, vote.VoterAddress)
+	}
+	encoded, err := voteLeafArguments.Pack(
+		common.HexToAddress(vote.VoterAddress),
+		vote.ToolID,
+		vote.Score,
+		vote.Nonce,
+		vote.EffectiveWeight(),
+	)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to encode vote %s: %w", vote.ID, err)
+	}
+	return crypto.Keccak256Hash(crypto.Keccak256(encoded)), nil
+}
 
-	// Generate leaf hashes
-	var leafHashes [][]byte
+// buildBatchTree builds the Merkle tree over the leaves of votes and
+// supersessions, setting the leaf of each supersession.
+func buildBatchTree(votes []*models.Vote, supersessions []*models.VoteSupersession) (*merkle.Tree, error) {
+	leaves := make([]common.Hash, 0, len(votes)+len(supersessions))
 	for _, vote := range votes {
-		data := fmt.Sprintf("%s:%s:%d:%d:%s",
-			vote.ID,
-			vote.VoterAddress,
-			vote.Score,
-			vote.Nonce,
-			vote.CreatedAt.Format(time.RFC3339),
-		)
-		hash := sha256.Sum256([]byte(data))
-		leafHashes = append(leafHashes, hash[:])
+		leaf, err := VoteLeaf(vote)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, leaf)
 	}
-
-	// Simplified Merkle root (in production, use proper binary tree)
-	if len(leafHashes) == 0 {
-		emptyHash := sha256.Sum256([]byte("empty"))
-		return hex.EncodeToString(emptyHash[:])
+	for _, supersession := range supersessions {
+		leaf, err := SupersessionLeaf(supersession)
+		if err != nil {
+			return nil, err
+		}
+		supersession.Leaf = leaf.Hex()
+		leaves = append(leaves, leaf)
 	}
+	return merkle.New(leaves)
+}
 
-	// For demo, just hash all leaf hashes together
-	combined := []byte{}
-	for _, leaf := range leafHashes {
-		combined = append(combined, leaf...)
+func mustArguments(types ...string) abi.Arguments {
+	arguments := make(abi.Arguments, len(types))
+	for i, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		arguments[i] = abi.Argument{Type: typ}
 	}
-
-	rootHash := sha256.Sum256(combined)
-	return hex.EncodeToString(rootHash[:])
+	return arguments
 }
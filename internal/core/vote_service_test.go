@@ -5,12 +5,15 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"sync"
 	"testing"
 	"time"
 
 	"moltket/config"
 	"moltket/internal/auth"
 	"moltket/internal/cache"
+	"moltket/internal/kvstore"
+	"moltket/internal/merkle"
 	"moltket/internal/models"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -54,8 +57,7 @@ func TestVoteService_Integration(t *testing.T) {
     // Test 1: Submit a valid vote
     t.Run("SubmitValidVote", func(t *testing.T) {
         // First, make voter eligible by simulating tool usage
-        usageKey := fmt.Sprintf("usage:%s:%s", voterAddress.Hex(), "tool-123")
-        kvStore.Set(ctx, usageKey, int64(1), time.Hour)
+        recordCalls(t, kvStore, voterAddress, "tool-123", 1)
         
         submission := &models.VoteSubmission{
             ToolID:       "tool-123",
@@ -147,8 +149,7 @@ func TestVoteService_Integration(t *testing.T) {
         voterAddress := common.HexToAddress("0xTestVoter999")
         
         // Make voter eligible
-        usageKey := fmt.Sprintf("usage:%s:%s", voterAddress.Hex(), toolID)
-        kvStore.Set(ctx, usageKey, int64(1), time.Hour)
+        recordCalls(t, kvStore, voterAddress, toolID, 1)
         
         submission := &models.VoteSubmission{
             ToolID:       toolID,
@@ -181,4 +182,200 @@ func TestVoteService_Integration(t *testing.T) {
         // This tests the helper method
         assert.Equal(t, voteID, service.generateVoteID(submission))
     })
-}
\ No newline at end of file
+}
+func TestVoteService_TypedDataSignature(t *testing.T) {
+    ctx := context.Background()
+
+    cfg := &config.Config{
+        ChainID:                 31337,
+        ReputationOracleAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+    }
+
+    kvStore := cache.NewKVStore()
+    defer kvStore.Close()
+
+    service := NewVoteService(cfg, kvStore, nil)
+    domain := auth.VoteDomain(big.NewInt(cfg.ChainID), common.HexToAddress(cfg.ReputationOracleAddress))
+
+    voterKey, err := crypto.GenerateKey()
+    require.NoError(t, err)
+    voterAddress := crypto.PubkeyToAddress(voterKey.PublicKey)
+    recordCalls(t, kvStore, voterAddress, "42", 1)
+
+    sign := func(vote *auth.Vote) string {
+        signature, err := auth.SignVote(voterKey, domain, vote)
+        require.NoError(t, err)
+        return "0x" + hex.EncodeToString(signature)
+    }
+
+    t.Run("AcceptsVoterSignature", func(t *testing.T) {
+        submission := &models.VoteSubmission{
+            ToolID:       "42",
+            VoterAddress: voterAddress.Hex(),
+            Score:        -1,
+            Nonce:        1,
+            Signature:    sign(&auth.Vote{Voter: voterAddress, ToolID: big.NewInt(42), Score: -1, Nonce: 1}),
+        }
+
+        result, err := service.SubmitVote(ctx, submission)
+        require.NoError(t, err)
+        assert.True(t, result.Valid, result.Reason)
+        assert.NotEmpty(t, result.VoteID)
+    })
+
+    t.Run("RejectsSignatureOverDifferentScore", func(t *testing.T) {
+        submission := &models.VoteSubmission{
+            ToolID:       "42",
+            VoterAddress: voterAddress.Hex(),
+            Score:        1,
+            Nonce:        2,
+            Signature:    sign(&auth.Vote{Voter: voterAddress, ToolID: big.NewInt(42), Score: -1, Nonce: 2}),
+        }
+
+        result, err := service.SubmitVote(ctx, submission)
+        require.NoError(t, err)
+        assert.False(t, result.Valid)
+        assert.Equal(t, "signature does not match voter address", result.Reason)
+    })
+
+    t.Run("RejectsOtherDomain", func(t *testing.T) {
+        other := auth.VoteDomain(big.NewInt(1), common.HexToAddress(cfg.ReputationOracleAddress))
+        vote := &auth.Vote{Voter: voterAddress, ToolID: big.NewInt(42), Score: 1, Nonce: 3}
+        signature, err := auth.SignVote(voterKey, other, vote)
+        require.NoError(t, err)
+
+        result, err := service.SubmitVote(ctx, &models.VoteSubmission{
+            ToolID:       "42",
+            VoterAddress: voterAddress.Hex(),
+            Score:        1,
+            Nonce:        3,
+            Signature:    hex.EncodeToString(signature),
+        })
+        require.NoError(t, err)
+        assert.False(t, result.Valid)
+    })
+}
+
+// ttlStore records the TTL every key was last set with.
+type ttlStore struct {
+    kvstore.Store
+    mu   sync.Mutex
+    ttls map[string]time.Duration
+}
+
+func (s *ttlStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+    s.mu.Lock()
+    s.ttls[key] = ttl
+    s.mu.Unlock()
+    return s.Store.Set(ctx, key, value, ttl)
+}
+
+func (s *ttlStore) ttl(key string) time.Duration {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.ttls[key]
+}
+
+func TestVoteProofs(t *testing.T) {
+    ctx := context.Background()
+    memoryStore := cache.NewKVStore()
+    defer memoryStore.Close()
+    kvStore := &ttlStore{Store: memoryStore, ttls: map[string]time.Duration{}}
+    
+    service := NewVoteService(&config.Config{ChainID: 1337}, kvStore, nil)
+    
+    voters := []string{
+        "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+        "0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC",
+        "0x90F79bf6EB2c4f870365E785982E1f101E93b906",
+    }
+    var votes []*models.Vote
+    for i, voter := range voters {
+        votes = append(votes, &models.Vote{
+            ID:           fmt.Sprintf("proof-vote-%d", i),
+            ToolID:       "42",
+            VoterAddress: voter,
+            Score:        int8(i - 1),
+            Nonce:        uint64(7 + i),
+            CreatedAt:    time.Now(),
+            Weight:       uint64(3 + i),
+        })
+        kvStore.Set(ctx, "vote:"+votes[i].ID, votes[i], time.Hour)
+    }
+    
+    t.Run("LeafEncoding", func(t *testing.T) {
+        // abi.encode(address, string, int8, uint64, uint64): five head
+        // words, with the string's offset, then its length and padded bytes
+        word := func(hexValue string) []byte {
+            return common.LeftPadBytes(common.FromHex(hexValue), 32)
+        }
+        encoded := append(word(voters[0]), word("0xa0")...)
+        encoded = append(encoded, bytes32Ones()...) // -1, sign-extended
+        encoded = append(encoded, word("0x07")...)
+        encoded = append(encoded, word("0x03")...)
+        encoded = append(encoded, word("0x02")...)
+        encoded = append(encoded, common.RightPadBytes([]byte("42"), 32)...)
+        
+        leaf, err := VoteLeaf(votes[0])
+        require.NoError(t, err)
+        assert.Equal(t, crypto.Keccak256Hash(crypto.Keccak256(encoded)), leaf)
+        
+        // Submission time is not part of the leaf
+        later := *votes[0]
+        later.CreatedAt = later.CreatedAt.Add(time.Hour)
+        laterLeaf, err := VoteLeaf(&later)
+        require.NoError(t, err)
+        assert.Equal(t, leaf, laterLeaf)
+        
+        // The weight is part of it
+        heavier := *votes[0]
+        heavier.Weight++
+        heavierLeaf, err := VoteLeaf(&heavier)
+        require.NoError(t, err)
+        assert.NotEqual(t, leaf, heavierLeaf)
+    })
+    
+    t.Run("ProofAgainstBatchRoot", func(t *testing.T) {
+        _, err := service.VoteProof(ctx, votes[0].ID)
+        assert.ErrorIs(t, err, ErrVoteNotBatched)
+        _, err = service.VoteProof(ctx, "missing")
+        assert.ErrorIs(t, err, ErrVoteNotFound)
+        
+        kvStore.Set(ctx, "pending:vote:42", votes, time.Hour)
+        batch, err := service.ProcessBatch(ctx, "42")
+        require.NoError(t, err)
+        assert.Equal(t, int64(0), batch.RawScore)  // -1 + 0 + 1
+        assert.Equal(t, int64(2), batch.TotalScore) // -3 + 0 + 5
+        
+        for _, vote := range votes {
+            proof, err := service.VoteProof(ctx, vote.ID)
+            require.NoError(t, err)
+            assert.Equal(t, batch.ID, proof.BatchID)
+            assert.Equal(t, batch.MerkleRoot, proof.MerkleRoot)
+            assert.NotEmpty(t, proof.Proof)
+            
+            siblings := make([]common.Hash, len(proof.Proof))
+            for i, sibling := range proof.Proof {
+                siblings[i] = common.HexToHash(sibling)
+            }
+            assert.True(t, merkle.Verify(common.HexToHash(batch.MerkleRoot), common.HexToHash(proof.Leaf), siblings))
+        }
+        
+        // Proofs outlive the day: batches, leaves and votes are kept as long
+        // as the reputation ledger
+        batchKey := "batch:42:" + batch.ID
+        assert.Equal(t, reputationLedgerTTL, kvStore.ttl(batchKey))
+        assert.Equal(t, reputationLedgerTTL, kvStore.ttl(batchKey+":leaves"))
+        for _, vote := range votes {
+            assert.Equal(t, reputationLedgerTTL, kvStore.ttl("vote:"+vote.ID))
+        }
+    })
+}
+
+func bytes32Ones() []byte {
+    ones := make([]byte, 32)
+    for i := range ones {
+        ones[i] = 0xff
+    }
+    return ones
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"time"
+
+	"moltket/internal/blockchain"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Most points a vote weight gets from each of license tenure, usage volume
+// and SKILL holdings
+const (
+	maxTenurePoints = 2
+	maxUsagePoints  = 3
+	maxSkillPoints  = 2
+)
+
+// One SKILL token, in wei
+var skillTokenUnit = big.NewInt(1e18)
+
+// UseSkillBalances lets SKILL holdings add to vote weights, if enabled by
+// config.
+func (s *VoteService) UseSkillBalances(reader blockchain.SkillBalanceReader) {
+	s.skillBalances = reader
+}
+
+// UseLicensePayments lets licenses add to vote weights. Licenses are read
+// from the payment ledger rather than the license cache, which is evicted
+// and refilled on access.
+func (s *VoteService) UseLicensePayments(ledger blockchain.PaymentLedger) {
+	s.licenses = ledger
+}
+
+// voteWeight returns the weight of a vote by voter on toolID. Every vote
+// counts at least once; on top of that come:
+//   - the configured license weight while the voter holds a license minted
+//     and paid for in full, plus a point for each tenure period since it
+//     was minted, up to 2
+//   - a point for each tenfold of verified calls the voter made to the tool
+//     (10, 100, 1000), up to 3
+//   - if enabled, a point for each tenfold of whole SKILL tokens held (10,
+//     100), up to 2
+//
+// The total is capped at the configured maximum, and is never below 1.
+func (s *VoteService) voteWeight(ctx context.Context, voter common.Address, toolID string) uint64 {
+	if !s.config.VoteWeighting {
+		return 1
+	}
+
+	now := time.Now()
+	weight := int64(1)
+
+	if mintedAt, ok := s.licenseMintedAt(ctx, voter, toolID, now); ok {
+		weight += int64(s.config.VoteWeightLicense)
+		if period := s.config.VoteWeightTenurePeriod; period > 0 {
+			weight += min(int64(now.Sub(mintedAt)/period), maxTenurePoints)
+		}
+	}
+
+	weight += decades(s.usage.Usage(ctx, voter, toolID).Calls, maxUsagePoints)
+
+	if s.config.VoteWeightSkill && s.skillBalances != nil {
+		balance, err := s.skillBalances.SkillBalance(ctx, voter)
+		if err != nil {
+			// Count the vote without SKILL holdings rather than refuse it
+			log.Printf("Warning: failed to get SKILL balance of %s: %v", voter.Hex(), err)
+		} else if tokens := new(big.Int).Div(balance, skillTokenUnit); tokens.IsInt64() {
+			weight += decades(tokens.Int64(), maxSkillPoints)
+		} else {
+			weight += maxSkillPoints
+		}
+	}
+
+	if limit := int64(s.config.VoteWeightMax); limit > 0 && weight > limit {
+		weight = limit
+	}
+	// A negative VOTE_WEIGHT_LICENSE must not cancel or invert votes
+	return uint64(max(weight, 1))
+}
+
+// licenseMintedAt returns when the latest unexpired license of voter for
+// toolID in the payment ledger was minted. Underpaid mints do not count.
+func (s *VoteService) licenseMintedAt(ctx context.Context, voter common.Address, toolID string, now time.Time) (time.Time, bool) {
+	if s.licenses == nil {
+		return time.Time{}, false
+	}
+	tool, ok := new(big.Int).SetString(toolID, 10)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	payments := s.licenses.GetPayments(ctx, voter, tool)
+	for i := len(payments) - 1; i >= 0; i-- {
+		payment := payments[i]
+		if payment.Status != models.PaymentUnderpaid && now.Before(payment.ExpiresAt) {
+			return payment.RecordedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// decades returns how many times n can be divided by ten, up to limit.
+func decades(n, limit int64) int64 {
+	var d int64
+	for n >= 10 && d < limit {
+		n /= 10
+		d++
+	}
+	return d
+}
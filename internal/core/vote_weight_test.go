@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"moltket/config"
+	"moltket/internal/cache"
+	"moltket/internal/kvstore"
+	"moltket/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSkillBalances struct {
+	balance *big.Int
+	err     error
+}
+
+func (f fakeSkillBalances) SkillBalance(ctx context.Context, account common.Address) (*big.Int, error) {
+	return f.balance, f.err
+}
+
+// fakePaymentLedger holds payments by user and tool ID.
+type fakePaymentLedger map[string][]*models.LicensePayment
+
+func (f fakePaymentLedger) GetPayments(ctx context.Context, user common.Address, toolID *big.Int) []*models.LicensePayment {
+	return f[user.Hex()+":"+toolID.String()]
+}
+
+// recordCalls records verified calls the way the access paths do.
+func recordCalls(t *testing.T, store kvstore.Store, user common.Address, toolID string, calls int) {
+	ledger := NewUsageLedger(store)
+	for i := 0; i < calls; i++ {
+		require.NoError(t, ledger.RecordCall(context.Background(), user, toolID, ""))
+	}
+}
+
+func TestVoteWeights(t *testing.T) {
+	ctx := context.Background()
+	kvStore := cache.NewKVStore()
+	defer kvStore.Close()
+
+	cfg := &config.Config{
+		ChainID:                1337,
+		VoteWeighting:          true,
+		VoteWeightLicense:      2,
+		VoteWeightTenurePeriod: 90 * 24 * time.Hour,
+		VoteWeightMax:          10,
+	}
+	service := NewVoteService(cfg, kvStore, nil)
+
+	newcomer := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	customer := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	lapsed := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	recordCalls(t, kvStore, newcomer, "1", 1)
+	recordCalls(t, kvStore, customer, "1", 150)
+	recordCalls(t, kvStore, lapsed, "1", 1500)
+	service.UseLicensePayments(fakePaymentLedger{
+		customer.Hex() + ":1": {{
+			Status:     models.PaymentPaid,
+			ExpiresAt:  time.Now().Add(30 * 24 * time.Hour),
+			RecordedAt: time.Now().Add(-200 * 24 * time.Hour),
+		}},
+		lapsed.Hex() + ":1": {{
+			Status:     models.PaymentPaid,
+			ExpiresAt:  time.Now().Add(-time.Hour),
+			RecordedAt: time.Now().Add(-400 * 24 * time.Hour),
+		}},
+		newcomer.Hex() + ":1": {{
+			Status:     models.PaymentUnderpaid,
+			ExpiresAt:  time.Now().Add(30 * 24 * time.Hour),
+			RecordedAt: time.Now(),
+		}},
+	})
+
+	t.Run("Standing", func(t *testing.T) {
+		assert.Equal(t, uint64(1), service.voteWeight(ctx, newcomer, "1"))
+		// Base, license, two tenure periods, a hundred calls
+		assert.Equal(t, uint64(7), service.voteWeight(ctx, customer, "1"))
+		// An expired license adds nothing; thousands of calls do
+		assert.Equal(t, uint64(4), service.voteWeight(ctx, lapsed, "1"))
+		// Standing on one tool does not carry over to another
+		assert.Equal(t, uint64(1), service.voteWeight(ctx, customer, "2"))
+
+		// A cached license alone is not proof of one
+		kvStore.Set(ctx, fmt.Sprintf("license:%s:2", customer.Hex()), &models.License{
+			ExpiresAt: time.Now().Add(30 * 24 * time.Hour),
+			CreatedAt: time.Now().Add(-200 * 24 * time.Hour),
+		}, time.Hour)
+		assert.Equal(t, uint64(1), service.voteWeight(ctx, customer, "2"))
+	})
+
+	t.Run("SkillBalance", func(t *testing.T) {
+		tokens := new(big.Int).Mul(big.NewInt(250), skillTokenUnit)
+		service.UseSkillBalances(fakeSkillBalances{balance: tokens})
+		defer service.UseSkillBalances(nil)
+
+		// Only counted once enabled
+		assert.Equal(t, uint64(1), service.voteWeight(ctx, newcomer, "1"))
+
+		cfg.VoteWeightSkill = true
+		defer func() { cfg.VoteWeightSkill = false }()
+		assert.Equal(t, uint64(3), service.voteWeight(ctx, newcomer, "1"))
+
+		service.UseSkillBalances(fakeSkillBalances{err: errors.New("node unreachable")})
+		assert.Equal(t, uint64(1), service.voteWeight(ctx, newcomer, "1"))
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		cfg.VoteWeightMax = 5
+		assert.Equal(t, uint64(5), service.voteWeight(ctx, customer, "1"))
+		cfg.VoteWeightMax = 10
+
+		cfg.VoteWeighting = false
+		assert.Equal(t, uint64(1), service.voteWeight(ctx, customer, "1"))
+		cfg.VoteWeighting = true
+
+		// Weights never drop below one
+		cfg.VoteWeightLicense = -5
+		assert.Equal(t, uint64(1), service.voteWeight(ctx, customer, "1"))
+		cfg.VoteWeightLicense = 2
+	})
+
+	t.Run("Reputation", func(t *testing.T) {
+		votes := []*models.Vote{
+			{Score: 1, Weight: 7, CreatedAt: time.Now()},
+			{Score: -1, Weight: 1, CreatedAt: time.Now()},
+			{Score: -1, CreatedAt: time.Now()}, // Stored before weighting
+		}
+		reputation := service.scoreReputation("1", votes)
+		assert.Equal(t, int64(5), reputation.TotalScore)
+		assert.Equal(t, int64(-1), reputation.RawScore)
+		assert.Equal(t, int64(9), reputation.TotalWeight)
+		assert.Equal(t, int64(3), reputation.TotalVotes)
+		assert.Greater(t, reputation.AverageScore, 0.0)
+	})
+}
@@ -0,0 +1,94 @@
+package kvstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Journal is an append-only file of JSON records, one per line, for state
+// that has to outlive the in-memory store. Its owner appends a record for
+// every change and replays the file into the store at startup.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenJournal opens the journal at path, creating it if needed.
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	return &Journal{file: file}, nil
+}
+
+// Replay calls fn with each record in the order they were appended. A last
+// record cut short by a crash is dropped from the file.
+func (j *Journal) Replay(fn func(record json.RawMessage) error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	reader := bufio.NewReader(j.file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Appends continue after the last complete record
+			if err := j.file.Truncate(offset); err != nil {
+				return fmt.Errorf("failed to truncate journal: %w", err)
+			}
+			_, err = j.file.Seek(offset, io.SeekStart)
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+		offset += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(json.RawMessage(line)); err != nil {
+			return err
+		}
+	}
+}
+
+// Append writes record to the journal and syncs it to disk.
+func (j *Journal) Append(record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to append to journal: %w", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append to journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
@@ -0,0 +1,107 @@
+// Package merkle builds binary Merkle trees of keccak256 hashes with sorted
+// pairs, as verified by OpenZeppelin's MerkleProof.
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrEmptyTree is returned when building a tree without leaves.
+	ErrEmptyTree = errors.New("merkle tree needs at least one leaf")
+
+	// ErrLeafNotFound is returned when proving a leaf the tree does not hold.
+	ErrLeafNotFound = errors.New("leaf not in merkle tree")
+)
+
+// Tree is a Merkle tree over a set of leaves. Leaves are sorted before
+// building, so the root does not depend on the order they were given in.
+// An odd node at the end of a layer moves up unchanged.
+type Tree struct {
+	layers [][]common.Hash // layers[0] are the sorted leaves, the last is the root
+}
+
+// New builds a tree over leaves.
+func New(leaves []common.Hash) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, ErrEmptyTree
+	}
+
+	layer := append([]common.Hash(nil), leaves...)
+	sort.Slice(layer, func(i, j int) bool {
+		return bytes.Compare(layer[i][:], layer[j][:]) < 0
+	})
+
+	layers := [][]common.Hash{layer}
+	for len(layer) > 1 {
+		next := make([]common.Hash, 0, (len(layer)+1)/2)
+		for i := 0; i < len(layer); i += 2 {
+			if i+1 == len(layer) {
+				next = append(next, layer[i])
+				continue
+			}
+			next = append(next, HashPair(layer[i], layer[i+1]))
+		}
+		layers = append(layers, next)
+		layer = next
+	}
+	return &Tree{layers: layers}, nil
+}
+
+// Root returns the root of the tree.
+func (t *Tree) Root() common.Hash {
+	return t.layers[len(t.layers)-1][0]
+}
+
+// Leaves returns the leaves of the tree, sorted.
+func (t *Tree) Leaves() []common.Hash {
+	return append([]common.Hash(nil), t.layers[0]...)
+}
+
+// Proof returns the sibling hashes from leaf up to the root, as expected by
+// MerkleProof.verify.
+func (t *Tree) Proof(leaf common.Hash) ([]common.Hash, error) {
+	index := -1
+	for i, l := range t.layers[0] {
+		if l == leaf {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, ErrLeafNotFound
+	}
+
+	proof := []common.Hash{}
+	for _, layer := range t.layers[:len(t.layers)-1] {
+		sibling := index ^ 1
+		if sibling < len(layer) {
+			proof = append(proof, layer[sibling])
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// Verify reports whether proof shows leaf is part of the tree with root.
+func Verify(root, leaf common.Hash, proof []common.Hash) bool {
+	computed := leaf
+	for _, sibling := range proof {
+		computed = HashPair(computed, sibling)
+	}
+	return computed == root
+}
+
+// HashPair hashes two nodes in sorted order, so that proofs need not say
+// which side each sibling is on.
+func HashPair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a[:], b[:])
+}
@@ -0,0 +1,91 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func leaves(n int) []common.Hash {
+	hashes := make([]common.Hash, n)
+	for i := range hashes {
+		hashes[i] = crypto.Keccak256Hash([]byte(fmt.Sprintf("leaf-%d", i)))
+	}
+	return hashes
+}
+
+func TestTree(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		_, err := New(nil)
+		assert.ErrorIs(t, err, ErrEmptyTree)
+	})
+
+	t.Run("SingleLeaf", func(t *testing.T) {
+		leaf := leaves(1)[0]
+		tree, err := New([]common.Hash{leaf})
+		require.NoError(t, err)
+		assert.Equal(t, leaf, tree.Root())
+
+		proof, err := tree.Proof(leaf)
+		require.NoError(t, err)
+		assert.Empty(t, proof)
+		assert.True(t, Verify(tree.Root(), leaf, proof))
+	})
+
+	t.Run("SortedPairs", func(t *testing.T) {
+		a, b := leaves(2)[0], leaves(2)[1]
+		assert.Equal(t, HashPair(a, b), HashPair(b, a))
+
+		tree, err := New([]common.Hash{a, b})
+		require.NoError(t, err)
+		lo, hi := a, b
+		if a.Big().Cmp(b.Big()) > 0 {
+			lo, hi = b, a
+		}
+		assert.Equal(t, crypto.Keccak256Hash(lo[:], hi[:]), tree.Root())
+	})
+
+	t.Run("ProofsForEveryLeaf", func(t *testing.T) {
+		for n := 1; n <= 17; n++ {
+			hashes := leaves(n)
+			tree, err := New(hashes)
+			require.NoError(t, err)
+
+			for _, leaf := range hashes {
+				proof, err := tree.Proof(leaf)
+				require.NoError(t, err)
+				assert.True(t, Verify(tree.Root(), leaf, proof), "n=%d", n)
+			}
+		}
+	})
+
+	t.Run("OrderIndependent", func(t *testing.T) {
+		hashes := leaves(5)
+		reversed := []common.Hash{hashes[4], hashes[3], hashes[2], hashes[1], hashes[0]}
+
+		tree, err := New(hashes)
+		require.NoError(t, err)
+		other, err := New(reversed)
+		require.NoError(t, err)
+		assert.Equal(t, tree.Root(), other.Root())
+		assert.Equal(t, hashes[4], reversed[0], "input is not reordered")
+	})
+
+	t.Run("RejectsWrongProofs", func(t *testing.T) {
+		hashes := leaves(6)
+		tree, err := New(hashes)
+		require.NoError(t, err)
+
+		proof, err := tree.Proof(hashes[2])
+		require.NoError(t, err)
+		assert.False(t, Verify(tree.Root(), hashes[3], proof))
+		assert.False(t, Verify(tree.Root(), hashes[2], proof[1:]))
+
+		_, err = tree.Proof(crypto.Keccak256Hash([]byte("outsider")))
+		assert.ErrorIs(t, err, ErrLeafNotFound)
+	})
+}
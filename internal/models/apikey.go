@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// API key scopes
+const (
+	ScopeVerify = "verify" // /verify and /access/verify
+	ScopeVote   = "vote"   // /vote
+	ScopeAdmin  = "admin"  // /admin
+)
+
+// APIKey is a tool host credential. Only a hash of the secret is stored.
+type APIKey struct {
+	ID         string     `json:"id"` // Public part of the key, "mk_<id>_<secret>"
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"` // Address that created the key
+	SecretHash string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ToolIDs    []string   `json:"tool_ids,omitempty"` // Empty means every tool
+	RateLimit  int        `json:"rate_limit"`         // Requests per minute
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsTool reports whether the key may be used for toolID.
+func (k *APIKey) AllowsTool(toolID string) bool {
+	if len(k.ToolIDs) == 0 {
+		return true
+	}
+	for _, id := range k.ToolIDs {
+		if id == toolID {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyUsage is the number of requests made with a key on one day.
+type APIKeyUsage struct {
+	Date     string `json:"date"` // YYYY-MM-DD, UTC
+	Requests int64  `json:"requests"`
+	Rejected int64  `json:"rejected"` // Refused for scope, tool or rate limit
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Delegation lets a session key sign verification requests for a user, who
+// authorized it with an EIP-712 Delegation signature. Requests made through
+// it are charged to the user's license and quota.
+type Delegation struct {
+	ID         string     `json:"id"` // EIP-712 hash of the signed delegation
+	User       string     `json:"user"`
+	SessionKey string     `json:"session_key"`
+	ToolIDs    []string   `json:"tool_ids,omitempty"`  // Empty means every tool
+	MaxCalls   uint64     `json:"max_calls,omitempty"` // 0 means unlimited
+	CallsUsed  int64      `json:"calls_used"`
+	Nonce      string     `json:"nonce"`
+	Signature  string     `json:"signature"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AllowsTool reports whether the delegation covers toolID.
+func (d *Delegation) AllowsTool(toolID string) bool {
+	if len(d.ToolIDs) == 0 {
+		return true
+	}
+	for _, id := range d.ToolIDs {
+		if id == toolID {
+			return true
+		}
+	}
+	return false
+}
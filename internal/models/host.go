@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Host is a tool host backend that authenticates with HMAC-SHA256 request
+// signatures instead of user signatures. Unlike API key secrets, the shared
+// secret has to be stored to check signatures.
+type Host struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Owner     string     `json:"owner,omitempty"` // Address operating the host
+	Secret    string     `json:"-"`
+	ToolIDs   []string   `json:"tool_ids,omitempty"` // Empty means every tool
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AllowsTool reports whether the host may make calls for toolID.
+func (h *Host) AllowsTool(toolID string) bool {
+	if len(h.ToolIDs) == 0 {
+		return true
+	}
+	for _, id := range h.ToolIDs {
+		if id == toolID {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,32 @@ type License struct {
     MaxCalls    int       `json:"max_calls"`
     CallsUsed   int       `json:"calls_used"`
     Tier        string    `json:"tier"`
+    PaymentStatus string  `json:"payment_status,omitempty"` // See Payment* constants
+    Flagged       bool    `json:"flagged"`                  // Set when the mint underpaid the quote
+    RefreshedAt   time.Time `json:"refreshed_at"`           // Last time the license was confirmed on-chain
+    SignerKeyID   string    `json:"signer_key_id,omitempty"` // Key that signed the mint authorization
+}
+
+// Payment statuses recorded in the license payment ledger
+const (
+    PaymentPaid       = "paid"       // msg.value covered the quoted price
+    PaymentUnderpaid  = "underpaid"  // msg.value was below the quoted price
+    PaymentUnverified = "unverified" // No mint transaction could be checked
+)
+
+// LicensePayment is one payment ledger entry for a minted license
+type LicensePayment struct {
+    UserAddress string    `json:"user_address"`
+    ToolID      string    `json:"tool_id"`
+    Nonce       string    `json:"nonce"`
+    TxHash      string    `json:"tx_hash,omitempty"`
+    QuotedWei   string    `json:"quoted_wei"`        // Price quoted by RequestLicense
+    PaidWei     string    `json:"paid_wei"`          // msg.value of the mint transaction
+    ShortfallWei string   `json:"shortfall_wei"`     // QuotedWei - PaidWei when underpaid
+    Status      string    `json:"status"`
+    BlockNumber uint64    `json:"block_number,omitempty"`
+    ExpiresAt   time.Time `json:"expires_at"`        // Expiry of the minted license
+    RecordedAt  time.Time `json:"recorded_at"`
 }
 
 // LicenseMetadata holds on-chain metadata for a license
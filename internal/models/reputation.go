@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// ReputationTotals are running sums over a tool's votes.
+type ReputationTotals struct {
+	Votes       int64 `json:"votes"`
+	TotalScore  int64 `json:"total_score"`  // Weighted
+	RawScore    int64 `json:"raw_score"`    // Unweighted
+	TotalWeight int64 `json:"total_weight"` // Sum of effective weights
+}
+
+// ReputationCheckpoint records what one batch changed in a tool's
+// reputation. Checkpoints are numbered from 1 in batch order; replaying
+// them rebuilds the tool's cumulative stats.
+type ReputationCheckpoint struct {
+	ToolID     string `json:"tool_id"`
+	Sequence   int64  `json:"sequence"`
+	BatchID    string `json:"batch_id"`
+	MerkleRoot string `json:"merkle_root"`
+
+	// Net change of the batch: its votes less the committed votes it
+	// superseded
+	Delta ReputationTotals `json:"delta"`
+	// Cumulative totals after the batch
+	Totals ReputationTotals `json:"totals"`
+
+	// Weights of the batch's votes by score, for scoring
+	UpWeight      int64 `json:"up_weight"`
+	DownWeight    int64 `json:"down_weight"`
+	NeutralWeight int64 `json:"neutral_weight"`
+
+	Supersessions []*VoteSupersession `json:"supersessions,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"` // When the batch was created
+}
+
+// ReputationStats are the cumulative stats of a tool's committed votes.
+type ReputationStats struct {
+	ToolID      string           `json:"tool_id"`
+	Totals      ReputationTotals `json:"totals"`
+	Checkpoints int64            `json:"checkpoints"` // Sequence of the last checkpoint
+	LastBatchID string           `json:"last_batch_id,omitempty"`
+	LastBatchAt time.Time        `json:"last_batch_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// ReputationBucket is what happened to a tool's reputation in one bucket of
+// its history.
+type ReputationBucket struct {
+	Start          time.Time `json:"start"`
+	Votes          int64     `json:"votes"`           // Cast in the bucket
+	Withdrawn      int64     `json:"withdrawn"`       // Superseded or retracted in the bucket
+	ScoreSum       int64     `json:"score_sum"`       // Weighted scores of the votes cast
+	RawScoreSum    int64     `json:"raw_score_sum"`   // Unweighted scores of the votes cast
+	WeightSum      int64     `json:"weight_sum"`      // Weights of the votes cast
+	AverageScore   float64   `json:"average_score"`   // ScoreSum per weight, 0 without votes
+	NetScore       int64     `json:"net_score"`       // ScoreSum less the weighted scores of withdrawn votes
+	Batches        int64     `json:"batches"`         // Batches processed in the bucket
+	CommittedVotes int64     `json:"committed_votes"` // Votes in those batches
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Roles for privileged endpoints. Admins pass every role check.
+const (
+	RoleAdmin     = "admin"      // Everything, including role management
+	RoleOperator  = "operator"   // Runs the service: vote batches, mint records
+	RoleToolOwner = "tool-owner" // Manages its own tools, per StakingNFT toolCreator
+	RoleAuditor   = "auditor"    // Reads payment ledgers, changes nothing
+)
+
+// Role binding subjects are "<kind>:<id>"
+const (
+	SubjectAddress = "address" // Lowercase 0x address, from a SIWE session
+	SubjectAPIKey  = "apikey"  // API key ID
+)
+
+// RoleBinding grants a role to an Ethereum address or an API key.
+type RoleBinding struct {
+	Subject   string    `json:"subject"` // e.g. "address:0xabc…" or "apikey:1f2e…"
+	Role      string    `json:"role"`
+	GrantedBy string    `json:"granted_by,omitempty"` // Subject that granted the role
+	GrantedAt time.Time `json:"granted_at"`
+}
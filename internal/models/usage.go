@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ToolUsage is a user's verified call history for a tool, as recorded by
+// the access paths (/verify and /access/verify).
+type ToolUsage struct {
+	Calls       int64     `json:"calls"`
+	FirstCallAt time.Time `json:"first_call_at"` // Zero if the user never made a call
+}
+
+// ProvenanceReceipt records a provenance hash handed out for a verified
+// call, so the user can later prove the call happened.
+type ProvenanceReceipt struct {
+	Hash        string    `json:"hash"`
+	UserAddress string    `json:"user_address"` // Lowercase
+	ToolID      string    `json:"tool_id"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
@@ -16,6 +16,19 @@ type Vote struct {
     CreatedAt    time.Time `json:"created_at"`   // When the vote was submitted
     Processed    bool      `json:"processed"`    // Whether vote has been included in a batch
     BatchID      string    `json:"batch_id"`     // ID of the batch this vote was included in
+    Weight       uint64    `json:"weight"`       // Weight of the voter at submission; see EffectiveWeight
+    Supersedes   string    `json:"supersedes,omitempty"`    // ID of the voter's earlier vote this one replaces
+    SupersededBy string    `json:"superseded_by,omitempty"` // ID of the vote that replaced this one
+    RetractedAt  *time.Time `json:"retracted_at,omitempty"` // When the voter withdrew this vote
+}
+
+// EffectiveWeight returns the weight the vote counts with. Votes stored
+// before weighting have no weight and count once.
+func (v *Vote) EffectiveWeight() uint64 {
+    if v.Weight == 0 {
+        return 1
+    }
+    return v.Weight
 }
 
 // VoteBatch represents a collection of votes committed to blockchain
@@ -23,31 +36,78 @@ type VoteBatch struct {
     ID           string    `json:"id"`           // Batch ID (Merkle root hash)
     ToolID       string    `json:"tool_id"`      // Tool this batch is for
     VotesCount   int       `json:"votes_count"`  // Number of votes in this batch
-    TotalScore   int64     `json:"total_score"`  // Sum of all vote scores in batch
-    MerkleRoot   string    `json:"merkle_root"`  // Merkle root of votes in this batch
+    TotalScore   int64     `json:"total_score"`  // Sum of all weighted vote scores in batch
+    RawScore     int64     `json:"raw_score"`    // Sum of all vote scores in batch, unweighted
+    MerkleRoot   string    `json:"merkle_root"`  // Merkle root of votes and supersessions in this batch
+    Supersessions []*VoteSupersession `json:"supersessions,omitempty"` // Earlier committed votes replaced or retracted since the last batch
     BlockchainTx string    `json:"blockchain_tx"` // Transaction hash of on-chain commitment
     CommittedAt  time.Time `json:"committed_at"` // When batch was committed to blockchain
     CreatedAt    time.Time `json:"created_at"`   // When batch was created
 }
 
+// VoteSupersession records that a vote committed in an earlier batch was
+// replaced by a newer vote of the same voter, or retracted. It is a leaf of
+// the batch it is recorded in.
+type VoteSupersession struct {
+    VoteID       string    `json:"vote_id"`                 // Superseded vote
+    BatchID      string    `json:"batch_id"`                // Batch the superseded vote was committed in
+    VoterAddress string    `json:"voter_address"`
+    Score        int8      `json:"score"`                   // Score of the superseded vote
+    Weight       uint64    `json:"weight"`                  // Effective weight of the superseded vote
+    VoteLeaf     string    `json:"vote_leaf"`               // Merkle leaf of the superseded vote
+    SupersededBy string    `json:"superseded_by,omitempty"` // Replacing vote; empty for retractions
+    Nonce        uint64    `json:"nonce"`                   // Nonce of the replacing vote or retraction
+    Leaf         string    `json:"leaf,omitempty"`          // Merkle leaf of this record, set when batched
+    CreatedAt    time.Time `json:"created_at"`
+}
+
+// Retracted reports whether the vote was withdrawn rather than replaced.
+func (s *VoteSupersession) Retracted() bool {
+    return s.SupersededBy == ""
+}
+
+// VoteProof is the Merkle proof that a vote is part of a batch. Leaf,
+// Proof and MerkleRoot are hex bytes32 values for MerkleProof.verify.
+type VoteProof struct {
+    VoteID     string   `json:"vote_id"`
+    BatchID    string   `json:"batch_id"`
+    ToolID     string   `json:"tool_id"`
+    Leaf       string   `json:"leaf"`
+    Proof      []string `json:"proof"`
+    MerkleRoot string   `json:"merkle_root"`
+}
+
 // ToolReputation represents the current reputation state of a tool
 type ToolReputation struct {
-    ToolID           string    `json:"tool_id"`
-    TotalScore       int64     `json:"total_score"`       // Sum of all vote scores
-    TotalVotes       int64     `json:"total_votes"`       // Total number of votes
-    AverageScore     float64   `json:"average_score"`     // Average score (total_score / total_votes)
-    RecentScore      float64   `json:"recent_score"`      // Weighted average of recent votes
-    LastCalculatedAt time.Time `json:"last_calculated_at"`
-    LastBatchAt      time.Time `json:"last_batch_at"`     // When last batch was committed
+    ToolID           string             `json:"tool_id"`
+    TotalScore       int64              `json:"total_score"`          // Sum of all weighted vote scores
+    RawScore         int64              `json:"raw_score"`            // Sum of all vote scores, unweighted
+    TotalVotes       int64              `json:"total_votes"`          // Total number of votes
+    TotalWeight      int64              `json:"total_weight"`         // Sum of all vote weights
+    AverageScore     float64            `json:"average_score"`        // All-time score, as computed by Algorithm
+    RecentScore      float64            `json:"recent_score"`         // Score with older votes decayed
+    Algorithm        string             `json:"algorithm"`            // Scorer that computed the scores
+    Parameters       map[string]float64 `json:"parameters,omitempty"` // Parameters of that scorer
+    LastCalculatedAt time.Time          `json:"last_calculated_at"`
+    LastBatchAt      time.Time          `json:"last_batch_at"`        // When last batch was committed
 }
 
 // VoteSubmission is the request structure for submitting a vote
 type VoteSubmission struct {
+    ToolID         string `json:"tool_id" validate:"required"`
+    VoterAddress   string `json:"voter_address" validate:"required,eth_addr"`
+    Score          int8   `json:"score" validate:"required,min=-1,max=1"`
+    Nonce          uint64 `json:"nonce" validate:"required"`
+    Signature      string `json:"signature" validate:"required"` // EIP-712 signature
+    ProvenanceHash string `json:"provenance_hash,omitempty"`     // Receipt of a verified call, if the policy requires one
+}
+
+// VoteRetraction is the request structure for withdrawing a vote
+type VoteRetraction struct {
     ToolID       string `json:"tool_id" validate:"required"`
     VoterAddress string `json:"voter_address" validate:"required,eth_addr"`
-    Score        int8   `json:"score" validate:"required,min=-1,max=1"`
     Nonce        uint64 `json:"nonce" validate:"required"`
-    Signature    string `json:"signature" validate:"required"` // EIP-712 signature
+    Signature    string `json:"signature" validate:"required"` // EIP-712 RetractVote signature
 }
 
 // VoteVerificationResult result of vote signature verification
@@ -55,4 +115,5 @@ type VoteVerificationResult struct {
     Valid     bool   `json:"valid"`
     Reason    string `json:"reason,omitempty"`
     VoteID    string `json:"vote_id,omitempty"`
+    Rule      string `json:"rule,omitempty"` // Eligibility rule the vote failed
 }
\ No newline at end of file
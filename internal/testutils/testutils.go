@@ -8,10 +8,13 @@ import (
 	"testing"
 	"time"
 
+	"moltket/internal/auth"
+
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/stretchr/testify/require"
 )
 
@@ -95,3 +98,30 @@ func SignAuthMessage(t *testing.T, pk *ecdsa.PrivateKey, address string, timesta
 	require.NoError(t, err)
 	return hexutil.Encode(sig)
 }
+
+// SignVerifyRequest signs a /verify request with EIP-712 under domain, the
+// way a wallet answering a verify nonce would.
+func SignVerifyRequest(t *testing.T, pk *ecdsa.PrivateKey, domain apitypes.TypedDataDomain, req *auth.VerifyRequest) string {
+	t.Helper()
+	sig, err := auth.Types.Sign(pk, domain, "VerifyRequest", req.Message())
+	require.NoError(t, err)
+	return hexutil.Encode(sig)
+}
+
+// SignDelegation signs a delegation with the user's key and returns the
+// signature in hex.
+func SignDelegation(t *testing.T, pk *ecdsa.PrivateKey, domain apitypes.TypedDataDomain, d *auth.Delegation) string {
+	t.Helper()
+	sig, err := auth.Types.Sign(pk, domain, "Delegation", d.Message())
+	require.NoError(t, err)
+	return hexutil.Encode(sig)
+}
+
+// SignRevokeDelegation signs a delegation revocation with the user's key and
+// returns the signature in hex.
+func SignRevokeDelegation(t *testing.T, pk *ecdsa.PrivateKey, domain apitypes.TypedDataDomain, r *auth.RevokeDelegation) string {
+	t.Helper()
+	sig, err := auth.Types.Sign(pk, domain, "RevokeDelegation", r.Message())
+	require.NoError(t, err)
+	return hexutil.Encode(sig)
+}